@@ -0,0 +1,129 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/suite"
+)
+
+// loadSummary is the machine readable outcome of a load test. It is saved
+// as load-summary.json and can be used as the baseline of a later load test.
+type loadSummary struct {
+	Requests  int     // Requests is the total number of requests made.
+	Rate      float64 // Rate is the achieved number of requests per second.
+	ErrorRate float64 // ErrorRate is the fraction of non-passing requests.
+
+	// Percentiles of the request duration.
+	Median, Q90, Q95, Q99 time.Duration
+}
+
+// summarize the load test data.
+func summarize(data []suite.TestData) loadSummary {
+	ls := loadSummary{Requests: len(data)}
+	if len(data) == 0 {
+		return ls
+	}
+
+	first, last := data[0].Started, data[0].Started
+	x := make([]time.Duration, len(data))
+	bad := 0
+	for i, d := range data {
+		if d.Started.Before(first) {
+			first = d.Started
+		}
+		if d.Started.After(last) {
+			last = d.Started
+		}
+		if d.Status > ht.Pass {
+			bad++
+		}
+		x[i] = d.ReqDuration
+	}
+	if span := last.Sub(first); span > 0 {
+		ls.Rate = float64(len(data)-1) / span.Seconds()
+	}
+	ls.ErrorRate = float64(bad) / float64(len(data))
+
+	sort.Sort(durationSlice(x))
+	ls.Median, ls.Q90 = quantile(x, 0.5), quantile(x, 0.90)
+	ls.Q95, ls.Q99 = quantile(x, 0.95), quantile(x, 0.99)
+	return ls
+}
+
+// save ls as JSON to filename.
+func (ls loadSummary) save(filename string) error {
+	data, err := json.MarshalIndent(ls, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0666)
+}
+
+// readLoadSummary reads a loadSummary saved to filename.
+func readLoadSummary(filename string) (loadSummary, error) {
+	ls := loadSummary{}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ls, err
+	}
+	err = json.Unmarshal(data, &ls)
+	return ls, err
+}
+
+// compareToBaseline prints the change of each metric of current relative
+// to baseline to out. Metrics which regressed by more than threshold
+// percent are reported as errors.
+func compareToBaseline(out io.Writer, baseline, current loadSummary, threshold float64) error {
+	el := ht.ErrorList{}
+	delta := func(b, c float64) float64 {
+		if b == 0 {
+			return 0
+		}
+		return 100 * (c - b) / b
+	}
+
+	fmt.Fprintf(out, "Comparison to baseline (regression threshold %.1f%%):\n", threshold)
+	for _, m := range []struct {
+		name string
+		b, c time.Duration
+	}{
+		{"Median", baseline.Median, current.Median},
+		{"90%", baseline.Q90, current.Q90},
+		{"95%", baseline.Q95, current.Q95},
+		{"99%", baseline.Q99, current.Q99},
+	} {
+		d := delta(float64(m.b), float64(m.c))
+		bms, cms := float64(m.b/1000)/1000, float64(m.c/1000)/1000
+		fmt.Fprintf(out, "    %-10s %8.1fms -> %8.1fms  %+6.1f%%\n", m.name,
+			bms, cms, d)
+		if d > threshold {
+			el = append(el, fmt.Errorf("%s request duration regressed by %.1f%% (%.1fms -> %.1fms)",
+				m.name, d, bms, cms))
+		}
+	}
+
+	d := delta(baseline.Rate, current.Rate)
+	fmt.Fprintf(out, "    %-10s %10.1f -> %10.1f  %+6.1f%%\n", "Rate",
+		baseline.Rate, current.Rate, d)
+	if -d > threshold {
+		el = append(el, fmt.Errorf("throughput regressed by %.1f%% (%.1f -> %.1f req/s)",
+			-d, baseline.Rate, current.Rate))
+	}
+	fmt.Fprintf(out, "    %-10s %9.1f%% -> %9.1f%%\n", "Errors",
+		100*baseline.ErrorRate, 100*current.ErrorRate)
+
+	if len(el) > 0 {
+		return el
+	}
+	return nil
+}
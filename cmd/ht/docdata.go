@@ -12,33 +12,145 @@ var typeDoc = map[string]string{
 		"    once the first passing check is found. Example (in JSON5 notation) to check\n" +
 		"    status code for '202 OR 404':\n" +
 		"\n" +
-		"    {\n" +
-		"        Check: \"AnyOne\", Of: [\n" +
-		"            {Check: \"StatusCode\", Expect: 202},\n" +
-		"            {Check: \"StatusCode\", Expect: 404},\n" +
-		"        ]\n" +
-		"    }",
+		"        {\n" +
+		"            Check: \"AnyOne\", Of: [\n" +
+		"                {Check: \"StatusCode\", Expect: 202},\n" +
+		"                {Check: \"StatusCode\", Expect: 404},\n" +
+		"            ]\n" +
+		"        }",
 	"body": "type Body Condition\n" +
-		"    Body provides simple condition checks on the response body.",
+		"    Body provides simple condition checks on the response body. The length\n" +
+		"    bounds Min and Max are applied to the number of bytes in the body; use Empty\n" +
+		"    to make sure the body is empty, e.g.\n" +
+		"\n" +
+		"        {Check: \"Body\", Min: 100}    // at least 100 bytes\n" +
+		"        {Check: \"Body\", Empty: true} // no body at all",
+	"bodyabsent": "type BodyAbsent struct {\n" +
+		"\t// Text is the list of forbidden literal texts.\n" +
+		"\tText []string \n" +
+		"\n" +
+		"\t// Regexp is the list of forbidden regular expressions.\n" +
+		"\tRegexp []string \n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    BodyAbsent checks that none of the given texts and regular expressions occur\n" +
+		"    in the response body, e.g. to make sure no stack traces or debug output\n" +
+		"    leak:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"BodyAbsent\"\n" +
+		"            Text: [ \"DEBUG\", \"at java.lang.\" ]\n" +
+		"            Regexp: [ \"(?i)stack ?trace\", \"panic: .*goroutine\" ]\n" +
+		"        }\n" +
+		"\n" +
+		"    Each forbidden text or regular expression found is reported together with\n" +
+		"    the byte offset of its first occurrence in the body.",
 	"bodyextractor": "type BodyExtractor struct {\n" +
 		"\t// Regexp is the regular expression to look for in the body.\n" +
 		"\tRegexp string\n" +
 		"\n" +
 		"\t// SubMatch selects which submatch (capturing group) of Regexp shall\n" +
-		"\t// be returned. A 0 value indicates the whole match.\n" +
+		"\t// be returned. A 0 value indicates the whole match unless Regexp\n" +
+		"\t// contains named groups in which case the first named group is\n" +
+		"\t// returned.\n" +
 		"\tSubmatch int \n" +
+		"\n" +
+		"\t// Group selects the named capturing group of Regexp which shall\n" +
+		"\t// be returned. If set Submatch is ignored.\n" +
+		"\tGroup string \n" +
+		"\n" +
+		"\t// AllowEmpty allows the extracted value to be the empty string.\n" +
+		"\tAllowEmpty bool \n" +
 		"}\n" +
 		"    BodyExtractor extracts a value from the uninterpreted response body via a\n" +
-		"    regular expression.",
+		"    regular expression. The extracted value is either a numbered submatch or the\n" +
+		"    submatch of a named capturing group, e.g. a CSRF token can be extracted with\n" +
+		"\n" +
+		"        {Extractor: \"BodyExtractor\", Regexp: \"csrf=(?P<token>[0-9a-f]+)\", Group: \"token\"}\n" +
+		"\n" +
+		"    For a Regexp with named groups the first named group is extracted if neither\n" +
+		"    Group nor Submatch is given, so Group: \"token\" may be omitted above, even if\n" +
+		"    unnamed groups precede it. It is an error if Regexp does not match or if the\n" +
+		"    extracted value is empty unless AllowEmpty is set.",
+	"caching": "type Caching struct {\n" +
+		"\t// MinMaxAge and MaxMaxAge are the inclusive bounds of the max-age\n" +
+		"\t// directive of the Cache-Control header. If either is non-zero\n" +
+		"\t// the max-age directive is required.\n" +
+		"\tMinMaxAge time.Duration \n" +
+		"\tMaxMaxAge time.Duration \n" +
+		"\n" +
+		"\t// NoStore requires the no-store directive, e.g. for responses with\n" +
+		"\t// sensitive data.\n" +
+		"\tNoStore bool \n" +
+		"\n" +
+		"\t// ETag and LastModified require the respective validator.\n" +
+		"\tETag         bool \n" +
+		"\tLastModified bool \n" +
+		"\n" +
+		"\t// Revalidate makes a conditional GET (or HEAD for HEAD requests)\n" +
+		"\t// request with If-None-Match (or If-Modified-Since if no ETag was\n" +
+		"\t// received) which must be answered with 304 Not Modified.\n" +
+		"\tRevalidate bool \n" +
+		"}\n" +
+		"    Caching checks the Cache-Control, Expires, ETag and Last-Modified headers\n" +
+		"    of a response. Independent of the fields set the headers are checked for\n" +
+		"    coherence: Malformed or contradicting directives (like no-store together\n" +
+		"    with a positive max-age), unparsable dates, Last-Modified dates in the\n" +
+		"    future and malformed ETags are reported. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"Caching\"\n" +
+		"            MinMaxAge: \"5m\"\n" +
+		"            MaxMaxAge: \"1h\"\n" +
+		"            ETag: true\n" +
+		"            Revalidate: true\n" +
+		"        }\n" +
+		"\n" +
+		"    All violations are reported.",
 	"checklist": "type CheckList []Check\n" +
 		"    CheckList is a slice of checks with the sole purpose of attaching JSON\n" +
 		"    (un)marshaling methods.",
+	"clockskew": "type ClockSkew struct {\n" +
+		"\t// MaxSkew is the allowed deviation. Zero means one second.\n" +
+		"\tMaxSkew time.Duration \n" +
+		"}\n" +
+		"    ClockSkew checks that the clock of the server, as reported in the Date\n" +
+		"    header of the response, deviates at most MaxSkew from the local clock.\n" +
+		"    Skewed server clocks break caching and the validation of signatures and\n" +
+		"    tokens. As Date has a resolution of one second and the server may generate\n" +
+		"    it anytime while the request is processed, a Date between the time the\n" +
+		"    request was sent and the time the response was received is considered to be\n" +
+		"    without skew.",
+	"compression": "type Compression struct {\n" +
+		"\t// MinRatio is the minimal compression ratio. Zero disables checking\n" +
+		"\t// the ratio.\n" +
+		"\tMinRatio float64 \n" +
+		"}\n" +
+		"    Compression checks that a response with a compressible content type (text,\n" +
+		"    JSON, JavaScript, XML, SVG) is gzip compressed and, optionally, how well.\n" +
+		"    Responses of other content types may be uncompressed.\n" +
+		"\n" +
+		"    The compression ratio (uncompressed size divided by compressed size) can be\n" +
+		"    checked only if the test explicitly sends an \"Accept-Encoding: gzip\" header;\n" +
+		"    otherwise package net/http decompresses the body transparently. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"Compression\"\n" +
+		"            MinRatio: 3\n" +
+		"        }",
 	"condition": "type Condition struct {\n" +
 		"\t// Equals is the exact value to be expected.\n" +
 		"\t// No other tests are performed if Equals is non-zero as these\n" +
 		"\t// other tests would be redundant.\n" +
 		"\tEquals string \n" +
 		"\n" +
+		"\t// JSONEqual is a JSON document the string must be equal to\n" +
+		"\t// semantically: Both are parsed as JSON and compared ignoring\n" +
+		"\t// the order of keys in objects, whitespace and the formatting\n" +
+		"\t// of numbers (e.g. 1.0, 1 and 1e0 are equal).\n" +
+		"\tJSONEqual string \n" +
+		"\n" +
 		"\t// Prefix is the required prefix\n" +
 		"\tPrefix string \n" +
 		"\n" +
@@ -58,10 +170,14 @@ var typeDoc = map[string]string{
 		"\t//   < 0: No match allowed (invert the condition)\n" +
 		"\tCount int \n" +
 		"\n" +
-		"\t// Min and Max are the minimum and maximum length the string may\n" +
-		"\t// have. Two zero values disables this test.\n" +
+		"\t// Min and Max are the minimum and maximum length (in bytes) the\n" +
+		"\t// string may have. Two zero values disables this test.\n" +
 		"\tMin, Max int \n" +
 		"\n" +
+		"\t// Empty requires the string to be empty. As a zero Max disables\n" +
+		"\t// the length test this is the only way to require an empty string.\n" +
+		"\tEmpty bool \n" +
+		"\n" +
 		"\t// GreaterThan and LessThan are lower and upper bound on the numerical\n" +
 		"\t// value of the string: The string is trimmed from spaces as well as\n" +
 		"\t// from single and double quotes before parsed as a float64. If the\n" +
@@ -69,6 +185,16 @@ var typeDoc = map[string]string{
 		"\t// Nil disables these conditions.\n" +
 		"\tGreaterThan, LessThan *float64 \n" +
 		"\n" +
+		"\t// IgnoreCase makes the Equals, Prefix, Suffix and Contains tests\n" +
+		"\t// case-insensitive. Use the \"(?i)\" flag for a case-insensitive Regexp.\n" +
+		"\tIgnoreCase bool \n" +
+		"\n" +
+		"\t// Not is a condition which must not be fulfilled. It allows to\n" +
+		"\t// negate any condition, e.g. {Not: {Regexp: \"^[0-9]+$\"}} is fulfilled\n" +
+		"\t// by all strings which are not a plain decimal number.\n" +
+		"\t// Not is checked in addition to the other tests of this Condition.\n" +
+		"\tNot *Condition \n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Condition is a conjunction of tests against a string. Note that Contains and\n" +
@@ -108,9 +234,8 @@ var typeDoc = map[string]string{
 		"    at top-level to the current Test being checked.\n" +
 		"\n" +
 		"    The Script's last value indicates success or failure:\n" +
-		"\n" +
-		"    - Success: true, 0, \"\"\n" +
-		"    - Failure: false, any number != 0, any string != \"\"\n" +
+		"      - Success: true, 0, \"\"\n" +
+		"      - Failure: false, any number != 0, any string != \"\"\n" +
 		"\n" +
 		"    CustomJS can be useful to log an excerpt of response (or the request) via\n" +
 		"    console.log.\n" +
@@ -125,6 +250,36 @@ var typeDoc = map[string]string{
 		"    DeleteCookie checks that the HTTP response properly deletes all cookies\n" +
 		"    matching Name, Path and Domain. Path and Domain are optional in which case\n" +
 		"    all cookies with the given Name are checked for deletion.",
+	"diff": "type Diff struct {\n" +
+		"\t// CompareURL is the URL of the second request.\n" +
+		"\tCompareURL string\n" +
+		"\n" +
+		"\t// Ignore are JSON elements which may differ. Selecting an object or\n" +
+		"\t// an array ignores all its elements.\n" +
+		"\tIgnore []string \n" +
+		"\n" +
+		"\t// Sep is the separator in the element selectors. A zero value is\n" +
+		"\t// equivalent to \".\".\n" +
+		"\tSep string \n" +
+		"\n" +
+		"\t// StatusCode requires the status codes of both responses to be equal.\n" +
+		"\tStatusCode bool \n" +
+		"}\n" +
+		"    Diff issues the request of the test a second time against CompareURL and\n" +
+		"    checks that both response bodies are equal. This allows A/B comparisons of\n" +
+		"    e.g. a new backend against the legacy one:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"Diff\"\n" +
+		"            CompareURL: \"https://legacy.example.org/api/items?page=2\"\n" +
+		"            Ignore: [ \"meta.generated\", \"meta.server\" ]\n" +
+		"        }\n" +
+		"\n" +
+		"    The second request uses the same method, header and body as the original\n" +
+		"    request. If both bodies are JSON documents they are compared element\n" +
+		"    by element (elements are selected like in the JSON check) and elements\n" +
+		"    covered by Ignore are not compared. Other bodies are compared line by line.\n" +
+		"    The first difference is reported.",
 	"execution": "type Execution struct {\n" +
 		"\t// Tries is the maximum number of tries made for this test.\n" +
 		"\t// Both 0 and 1 mean: \"Just one try. No redo.\"\n" +
@@ -147,9 +302,52 @@ var typeDoc = map[string]string{
 	"extractormap": "type ExtractorMap map[string]Extractor\n" +
 		"    ExtractorMap is a map of Extractors with the sole purpose of attaching JSON\n" +
 		"    (un)marshaling methods.",
+	"filetype": "type FileType struct {\n" +
+		"\t// Types maps extensions (without the leading dot) to the allowed\n" +
+		"\t// content types. It overrides the default content types of the\n" +
+		"\t// listed extensions.\n" +
+		"\tTypes map[string][]string \n" +
+		"}\n" +
+		"    FileType checks that the Content-Type of the response matches the extension\n" +
+		"    of the (final) request path, e.g. that \"/static/app.js\" is served as\n" +
+		"    application/javascript and not as text/plain. The content types of an\n" +
+		"    extension are taken from package github.com/vdobler/ht/mime and the standard\n" +
+		"    library's mime.TypeByExtension. Paths without an extension are not checked.\n" +
+		"    Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"FileType\"\n" +
+		"            Types: { \"map\": [ \"application/json\" ] }\n" +
+		"        }",
 	"finalurl": "type FinalURL Condition\n" +
 		"    FinalURL checks the last URL after following all redirects. This check is\n" +
-		"    useful only for tests with Request.FollowRedirects=true",
+		"    useful only for tests with Request.FollowRedirects.Follow=true",
+	"followlink": "type FollowLink struct {\n" +
+		"\t// Rel is the link relation to follow. Defaults to \"next\".\n" +
+		"\tRel string \n" +
+		"\n" +
+		"\t// MaxPages limits the number of traversed pages. Defaults to 50.\n" +
+		"\tMaxPages int \n" +
+		"\n" +
+		"\t// Pages is the expected total number of pages (including the first\n" +
+		"\t// one). Zero disables checking the number of pages.\n" +
+		"\tPages int \n" +
+		"\n" +
+		"\t// Checks are applied to all further pages.\n" +
+		"\tChecks CheckList \n" +
+		"}\n" +
+		"    FollowLink walks a paginated resource by following the links with relation\n" +
+		"    Rel (default \"next\") in the Link header of the response and of all\n" +
+		"    subsequent pages until a page has no such link. At most MaxPages pages\n" +
+		"    (including the first one) are traversed; reaching MaxPages while there is\n" +
+		"    still a link to follow is reported as an error. The additional pages are\n" +
+		"    requested with GET and the same header as the original request. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"FollowLink\"\n" +
+		"            Pages: 5\n" +
+		"            Checks: [ {Check: \"StatusCode\", Expect: 200} ]\n" +
+		"        }",
 	"htmlcontains": "type HTMLContains struct {\n" +
 		"\t// Selector is the CSS selector of the HTML elements.\n" +
 		"\tSelector string\n" +
@@ -164,7 +362,8 @@ var typeDoc = map[string]string{
 		"\n" +
 		"\t// Complete makes sure that no excess HTML elements are found:\n" +
 		"\t// If true the len(Text) must be equal to the number of HTML elements\n" +
-		"\t// selected for the check to succeed.\n" +
+		"\t// selected for the check to succeed. A failure reports the extra and\n" +
+		"\t// the missing texts.\n" +
 		"\tComplete bool \n" +
 		"\n" +
 		"\t// InOrder makes the check fail if the selected HTML elements have a\n" +
@@ -178,22 +377,21 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    The text content found in the HTML document is normalized by roughly the\n" +
 		"    following procedure:\n" +
-		"\n" +
-		"    1.  Newlines are inserted around HTML block elements\n" +
-		"        (i.e. any non-inline element)\n" +
-		"    2.  Newlines and tabs are replaced by spaces.\n" +
-		"    3.  Multiple spaces are replaced by one space.\n" +
-		"    4.  Leading and trailing spaces are trimmed of.\n" +
+		"     1. Newlines are inserted around HTML block elements (i.e. any non-inline\n" +
+		"        element)\n" +
+		"     2. Newlines and tabs are replaced by spaces.\n" +
+		"     3. Multiple spaces are replaced by one space.\n" +
+		"     4. Leading and trailing spaces are trimmed of.\n" +
 		"\n" +
 		"    As an example consider the following HTML:\n" +
 		"\n" +
-		"    <html><body>\n" +
-		"      <ul class=\"fancy\"><li>One</li><li>S<strong>econ</strong>d</li><li> Three </li></ul>\n" +
-		"    </body></html>\n" +
+		"        <html><body>\n" +
+		"          <ul class=\"fancy\"><li>One</li><li>S<strong>econ</strong>d</li><li> Three </li></ul>\n" +
+		"        </body></html>\n" +
 		"\n" +
 		"    The normalized text selected by a Selector of \"ul.fancy\" would be\n" +
 		"\n" +
-		"    \"One Second Three\"",
+		"        \"One Second Three\"",
 	"htmlextractor": "type HTMLExtractor struct {\n" +
 		"\t// Selector is the CSS selector of an element, e.g.\n" +
 		"\t//     head meta[name=\"_csrf\"]   or\n" +
@@ -215,8 +413,8 @@ var typeDoc = map[string]string{
 		"    extracting HTML attribute values and HTML text node values. Examples for\n" +
 		"    CSRF token in the HTML:\n" +
 		"\n" +
-		"    <meta name=\"_csrf\" content=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\" />\n" +
-		"    <input type=\"hidden\" name=\"_csrf\" value=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\"/>",
+		"        <meta name=\"_csrf\" content=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\" />\n" +
+		"        <input type=\"hidden\" name=\"_csrf\" value=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\"/>",
 	"htmltag": "type HTMLTag struct {\n" +
 		"\t// Selector is the CSS selector of the HTML elements.\n" +
 		"\tSelector string\n" +
@@ -225,11 +423,39 @@ var typeDoc = map[string]string{
 		"\t//     < 0: no occurrence\n" +
 		"\t//    == 0: one ore more occurrences\n" +
 		"\t//     > 0: exactly that many occurrences\n" +
+		"\t// Count is a shorthand for the most common Matches conditions.\n" +
 		"\tCount int \n" +
 		"\n" +
+		"\t// Matches is a Condition applied to the number of occurrences\n" +
+		"\t// (formatted as a decimal number), e.g. { GreaterThan: 2, LessThan: 11 }\n" +
+		"\t// to allow 3 to 10 occurrences. Matches and Count are mutually\n" +
+		"\t// exclusive.\n" +
+		"\tMatches *Condition \n" +
+		"\n" +
+		"\t// Attributes maps attribute names to the condition their value must\n" +
+		"\t// fulfill in each selected element. Missing attributes fail.\n" +
+		"\tAttributes map[string]Condition \n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
-		"    HTMLTag checks for the existens of HTML elements selected by CSS selectors.",
+		"    HTMLTag checks for the existens of HTML elements selected by CSS selectors\n" +
+		"    and optionally the values of their attributes. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"HTMLTag\"\n" +
+		"            Selector: \"meta[name=robots]\"\n" +
+		"            Count: 1\n" +
+		"            Attributes: { content: { Contains: \"noindex\" } }\n" +
+		"        }\n" +
+		"\n" +
+		"    Pages with a variable number of elements can be checked with a Condition on\n" +
+		"    the number of matches, e.g. for at least 3 product cards:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"HTMLTag\"\n" +
+		"            Selector: \"div.product-card\"\n" +
+		"            Matches: { GreaterThan: 2 }\n" +
+		"        }",
 	"header": "type Header struct {\n" +
 		"\t// Header is the HTTP header to check.\n" +
 		"\tHeader string\n" +
@@ -242,14 +468,58 @@ var typeDoc = map[string]string{
 		"\tAbsent bool \n" +
 		"}\n" +
 		"    Header provides a textual test of single-valued HTTP headers.",
+	"headerunique": "type HeaderUnique struct {\n" +
+		"\t// Headers to check. An empty list checks all headers except\n" +
+		"\t// Set-Cookie which is commonly sent several times.\n" +
+		"\tHeaders []string \n" +
+		"\n" +
+		"\t// Required demands that all Headers are present (exactly once).\n" +
+		"\tRequired bool \n" +
+		"}\n" +
+		"    HeaderUnique checks that headers are not received more than once, e.g.\n" +
+		"    to detect a Content-Security-Policy added twice by a gateway. All duplicated\n" +
+		"    headers are reported. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"HeaderUnique\"\n" +
+		"            Headers: [ \"Content-Security-Policy\", \"Strict-Transport-Security\" ]\n" +
+		"            Required: true\n" +
+		"        }",
 	"identity": "type Identity struct {\n" +
 		"\t// SHA1 is the expected hash as shown by sha1sum of the whole body.\n" +
 		"\t// E.g. 2ef7bde608ce5404e97d5f042f95f89f1c232871 for a \"Hello World!\"\n" +
 		"\t// body (no newline).\n" +
 		"\tSHA1 string\n" +
+		"\n" +
+		"\t// Normalize the body before hashing to make the hash independent\n" +
+		"\t// of insignificant formatting:\n" +
+		"\t//   \"none\" or \"\" : hash the body as received\n" +
+		"\t//   \"json\"       : hash the compact JSON encoding with sorted object keys\n" +
+		"\t//   \"xml\"        : hash the XML without whitespace between elements,\n" +
+		"\t//                  with sorted attributes and <a></a> written as <a/>\n" +
+		"\t// The error message of a failing check reports the hash of the\n" +
+		"\t// normalized body.\n" +
+		"\tNormalize string \n" +
 		"}\n" +
 		"    Identity checks the value of the response body by comparing its SHA1 hash to\n" +
 		"    the expected SHA1 value.",
+	"ifthenelse": "type IfThenElse struct {\n" +
+		"\t// If is the check which selects the branch.\n" +
+		"\tIf Check\n" +
+		"\n" +
+		"\t// Then and Else are the checks executed if If passes or fails.\n" +
+		"\tThen, Else CheckList\n" +
+		"}\n" +
+		"    IfThenElse executes the Then checks if the If check passes and the Else\n" +
+		"    checks otherwise. Example (in JSON5 notation) to check the Location of\n" +
+		"    redirects and the body of all other responses:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"IfThenElse\",\n" +
+		"            If: {Check: \"StatusCode\", Expect: 3},\n" +
+		"            Then: [ {Check: \"Header\", Header: \"Location\", Prefix: \"https://\"} ],\n" +
+		"            Else: [ {Check: \"Body\", Contains: \"Welcome\"} ],\n" +
+		"        }",
 	"image": "type Image struct {\n" +
 		"\t// Format is the format of the image as registered in package image.\n" +
 		"\tFormat string \n" +
@@ -265,8 +535,8 @@ var typeDoc = map[string]string{
 		"\t// from the given BMV or ColorHist fingerprint.\n" +
 		"\tThreshold float64 \n" +
 		"}\n" +
-		"    Image checks image format, size and fingerprint. As usual a zero value of a\n" +
-		"    field skips the check of that property. Image fingerprinting is done via\n" +
+		"    Image checks image format, size and fingerprint. As usual a zero value of\n" +
+		"    a field skips the check of that property. Image fingerprinting is done via\n" +
 		"    github.com/vdobler/ht/fingerprint. Only one of BMV or ColorHist should be\n" +
 		"    used as there is just one threshold.",
 	"jsextractor": "type JSExtractor struct {\n" +
@@ -283,13 +553,12 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    The Script is evaluated and the final expression is the value extracted with\n" +
 		"    the following excpetions:\n" +
-		"\n" +
-		"    - undefined or null is treated as an error\n" +
-		"    - Objects and Arrays are treated as errors. The error message is reported\n" +
-		"      in the field 'errmsg' of the object or the index 0 of the array.\n" +
-		"    - Strings, Numbers and Bools are treated as properly extracted values\n" +
-		"      which are returned.\n" +
-		"    - Other types result in undefined behaviour.\n" +
+		"      - undefined or null is treated as an error\n" +
+		"      - Objects and Arrays are treated as errors. The error message is reported\n" +
+		"        in the field 'errmsg' of the object or the index 0 of the array.\n" +
+		"      - Strings, Numbers and Bools are treated as properly extracted values\n" +
+		"        which are returned.\n" +
+		"      - Other types result in undefined behaviour.\n" +
 		"\n" +
 		"    The JavaScript code is interpreted by otto. See the documentation at\n" +
 		"    https://godoc.org/github.com/robertkrimen/otto for details.",
@@ -324,16 +593,70 @@ var typeDoc = map[string]string{
 		"    Elements of the JSON document are selected by an element selector. In the\n" +
 		"    JSON document\n" +
 		"\n" +
-		"    { \"foo\": 5, \"bar\": [ 1, \"qux\", 3 ], \"waz\": true, \"nil\": null }\n" +
+		"        { \"foo\": 5, \"bar\": [ 1, \"qux\", 3 ], \"waz\": true, \"nil\": null }\n" +
 		"\n" +
 		"    the follwing element selector are present and have the shown values:\n" +
 		"\n" +
-		"    foo       5\n" +
-		"    bar.0     1\n" +
-		"    bar.1     \"qux\"\n" +
-		"    bar.2     3\n" +
-		"    waz       true\n" +
-		"    nil       null",
+		"        foo       5\n" +
+		"        bar.0     1\n" +
+		"        bar.1     \"qux\"\n" +
+		"        bar.2     3\n" +
+		"        waz       true\n" +
+		"        nil       null",
+	"jsonchanged": "type JSONChanged struct {\n" +
+		"\t// Variable is the name of the variable holding the earlier JSON\n" +
+		"\t// document.\n" +
+		"\tVariable string\n" +
+		"\n" +
+		"\t// Changed are the elements which must have changed.\n" +
+		"\tChanged []string \n" +
+		"\n" +
+		"\t// Ignore are elements whose changes are irrelevant, e.g.\n" +
+		"\t// modification timestamps.\n" +
+		"\tIgnore []string \n" +
+		"\n" +
+		"\t// Sep is the separator in the element selectors. A zero value is\n" +
+		"\t// equivalent to \".\".\n" +
+		"\tSep string \n" +
+		"}\n" +
+		"    JSONChanged compares the JSON body with an earlier JSON document stored in a\n" +
+		"    variable and checks that exactly the elements Changed differ. It is useful\n" +
+		"    to test update semantics of PATCH or PUT endpoints: GET the resource and\n" +
+		"    extract the whole body into a variable, e.g. with\n" +
+		"\n" +
+		"        VarEx: { BEFORE: {Extractor: \"BodyExtractor\", Regexp: \"(?s).*\"} }\n" +
+		"\n" +
+		"    then PATCH the resource and GET it again with a check like\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"JSONChanged\"\n" +
+		"            Variable: \"BEFORE\"\n" +
+		"            Changed: [ \"name\", \"address.city\" ]\n" +
+		"            Ignore: [ \"modified\" ]\n" +
+		"        }\n" +
+		"\n" +
+		"    Elements are selected like in the JSON check. Selecting an object or an\n" +
+		"    array includes all its elements, so \"address\" covers \"address.city\". Each\n" +
+		"    element in Changed must differ (or be added or removed) and all elements not\n" +
+		"    covered by Changed or Ignore must be unchanged.",
+	"jsonexists": "type JSONExists struct {\n" +
+		"\t// Paths are the elements which must exist.\n" +
+		"\tPaths []string \n" +
+		"\n" +
+		"\t// Absent are the elements which must not exist.\n" +
+		"\tAbsent []string \n" +
+		"\n" +
+		"\t// Sep is the separator in the paths. A zero value is equivalent to \".\".\n" +
+		"\tSep string \n" +
+		"}\n" +
+		"    JSONExists checks the presence and absence of elements in a JSON document\n" +
+		"    without looking at their values. Elements are selected like in the JSON\n" +
+		"    check but may also be objects or arrays, e.g. in\n" +
+		"\n" +
+		"        { \"foo\": 5, \"bar\": [ 1, { \"waz\": null } ], \"debug\": {} }\n" +
+		"\n" +
+		"    the elements foo, bar, bar.0, bar.1, bar.1.waz and debug exist. All\n" +
+		"    violations are reported.",
 	"jsonexpr": "type JSONExpr struct {\n" +
 		"\t// Expression is a boolean gojee expression which must evaluate\n" +
 		"\t// to true for the check to pass.\n" +
@@ -346,16 +669,16 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    Consider this JSON:\n" +
 		"\n" +
-		"    { \"foo\": 5, \"bar\": [ 1, 2, 3 ] }\n" +
+		"        { \"foo\": 5, \"bar\": [ 1, 2, 3 ] }\n" +
 		"\n" +
 		"    The follwing expression have these truth values:\n" +
 		"\n" +
-		"    .foo == 5                    true\n" +
-		"    $len(.bar) > 2               true as $len(.bar)==3\n" +
-		"    .bar[1] == 2                 true\n" +
-		"    (.foo == 9) || (.bar[0]<7)   true as .bar[0]==1\n" +
-		"    $max(.bar) == 3              true\n" +
-		"    $has(.bar, 7)                false as bar has no 7",
+		"        .foo == 5                    true\n" +
+		"        $len(.bar) > 2               true as $len(.bar)==3\n" +
+		"        .bar[1] == 2                 true\n" +
+		"        (.foo == 9) || (.bar[0]<7)   true as .bar[0]==1\n" +
+		"        $max(.bar) == 3              true\n" +
+		"        $has(.bar, 7)                false as bar has no 7",
 	"jsonextractor": "type JSONExtractor struct {\n" +
 		"\t// Element in the flattened JSON map to extract.\n" +
 		"\tElement string \n" +
@@ -364,14 +687,71 @@ var typeDoc = map[string]string{
 		"\t// A zero value is equivalent to \".\"\n" +
 		"\tSep string \n" +
 		"}\n" +
-		"    JSONExtractor extracts a value from a JSON response body. It uses\n" +
-		"    github.com/nytlabs/gojsonexplode to flatten the JSON file for easier access.\n" +
-		"    Only the lowest level of elements may be accessed: In the JSON {\"c\":[1,2,3]}\n" +
-		"    \"c\" is not available, but c.2 is and equals 3. JSON null values are\n" +
-		"    extracted as the empty string i.e. null and \"\" are indistinguashable.\n" +
+		"    JSONExtractor extracts a value from a JSON response body. It flattens the\n" +
+		"    JSON file like github.com/nytlabs/gojsonexplode for easier access. Only the\n" +
+		"    lowest level of elements may be accessed: In the JSON {\"c\":[1,2,3]} \"c\" is\n" +
+		"    not available, but c.2 is and equals 3. JSON null values are extracted as\n" +
+		"    the empty string i.e. null and \"\" are indistinguashable.\n" +
 		"\n" +
 		"    Note that JSONExtractor behaves differently than the JSON check:\n" +
 		"    JSONExctractor strips quotes from strings if the string is not empty.",
+	"jwt": "type JWT struct {\n" +
+		"\t// Header, Cookie and Element select where the token is taken from:\n" +
+		"\t// The named response header, the named cookie or the element of\n" +
+		"\t// the JSON body (selected like in the JSON check). Exactly one\n" +
+		"\t// must be given.\n" +
+		"\tHeader  string \n" +
+		"\tCookie  string \n" +
+		"\tElement string \n" +
+		"\n" +
+		"\t// Key is used to verify the signature: The shared secret for the\n" +
+		"\t// HS256, HS384 and HS512 algorithms or a PEM encoded public key or\n" +
+		"\t// certificate for the RS*, PS* and ES* algorithms.\n" +
+		"\tKey string \n" +
+		"\n" +
+		"\t// JWKS is the URL of a JSON Web Key Set used to verify the signature.\n" +
+		"\t// The key is selected by the kid in the token header.\n" +
+		"\tJWKS string \n" +
+		"\n" +
+		"\t// Algorithms restricts the acceptable signing algorithms, e.g.\n" +
+		"\t// [\"RS256\"]. The \"none\" algorithm is never acceptable if Key or\n" +
+		"\t// JWKS is given.\n" +
+		"\tAlgorithms []string \n" +
+		"\n" +
+		"\t// Issuer is the expected value of the iss claim.\n" +
+		"\tIssuer string \n" +
+		"\n" +
+		"\t// Audience must be contained in the aud claim.\n" +
+		"\tAudience string \n" +
+		"\n" +
+		"\t// Claims maps claim names to Conditions the claims must fulfill.\n" +
+		"\t// String claims are checked unquoted, all other claims are checked\n" +
+		"\t// in their JSON encoding.\n" +
+		"\tClaims map[string]Condition \n" +
+		"\n" +
+		"\t// Leeway is the allowed clock skew when validating exp and nbf.\n" +
+		"\tLeeway time.Duration \n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    JWT checks a JSON Web Token found in the response. The token is taken from\n" +
+		"    a header (a \"Bearer \" prefix is stripped), a cookie or a string element of a\n" +
+		"    JSON body. If Key or JWKS is given the signature of the token is verified.\n" +
+		"    Time based claims exp and nbf are always validated if present. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"JWT\"\n" +
+		"            Element: \"access_token\"\n" +
+		"            JWKS: \"https://auth.example.org/.well-known/jwks.json\"\n" +
+		"            Issuer: \"https://auth.example.org\"\n" +
+		"            Audience: \"shop\"\n" +
+		"            Claims: {\n" +
+		"                sub:   { Prefix: \"user-\" }\n" +
+		"                scope: { Contains: \"orders:read\" }\n" +
+		"            }\n" +
+		"        }\n" +
+		"\n" +
+		"    All claim mismatches are reported.",
 	"latency": "type Latency struct {\n" +
 		"\t// N is the number if request to measure. It should be much larger\n" +
 		"\t// than Concurrent. Default is 50.\n" +
@@ -408,6 +788,13 @@ var typeDoc = map[string]string{
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Latency provides checks against percentils of the response time latency.",
+	"linkextractor": "type LinkExtractor struct {\n" +
+		"\t// Rel is the link relation to extract, e.g. \"next\".\n" +
+		"\tRel string\n" +
+		"}\n" +
+		"    LinkExtractor extracts the URL of the link with the given relation from\n" +
+		"    the Link header of the response, e.g. the next page of a paginated API.\n" +
+		"    Relative URLs are resolved against the request URL.",
 	"links": "type Links struct {\n" +
 		"\t// Which links to test; a space separated list of tag tag names:\n" +
 		"\t//     'a',   'link',  'img',  'script', 'video', 'audio' or 'source'\n" +
@@ -422,6 +809,8 @@ var typeDoc = map[string]string{
 		"\n" +
 		"\t// Concurrency determines how many of the found links are checked\n" +
 		"\t// concurrently. A zero value indicates sequential checking.\n" +
+		"\t// The total number of concurrent link requests of all Links checks\n" +
+		"\t// is limited by MaxLinkConcurrency.\n" +
 		"\tConcurrency int \n" +
 		"\n" +
 		"\t// Timeout is the client timeout if different from main test.\n" +
@@ -431,6 +820,14 @@ var typeDoc = map[string]string{
 		"\t// all links.\n" +
 		"\tOnlyLinks, IgnoredLinks []Condition \n" +
 		"\n" +
+		"\t// SameHostOnly restricts checking to links to the host of the\n" +
+		"\t// original request.\n" +
+		"\tSameHostOnly bool \n" +
+		"\n" +
+		"\t// RespectRobots skips links disallowed by the robots.txt of the\n" +
+		"\t// linked host. The robots.txt files are cached per host for 24 hours.\n" +
+		"\tRespectRobots bool \n" +
+		"\n" +
 		"\t// FailMixedContent will report a failure for any mixed content, i.e.\n" +
 		"\t// resources retrieved via http for a https HTML page.\n" +
 		"\tFailMixedContent bool\n" +
@@ -443,11 +840,11 @@ var typeDoc = map[string]string{
 		"}\n" +
 		"    Links checks links and references in HTML pages for availability.\n" +
 		"\n" +
-		"    It can reports mixed content as a failure by setting FailMixedContent. (See\n" +
-		"    https://w3c.github.io/webappsec-mixed-content/). Links will upgrade any\n" +
+		"    It can reports mixed content as a failure by setting FailMixedContent.\n" +
+		"    (See https://w3c.github.io/webappsec-mixed-content/). Links will upgrade any\n" +
 		"    non-anchor links if the original reqesponse contains\n" +
 		"\n" +
-		"    Content-Security-Policy: upgrade-insecure-requests\n" +
+		"        Content-Security-Policy: upgrade-insecure-requests\n" +
 		"\n" +
 		"    in the HTTP header.",
 	"logfile": "type Logfile struct {\n" +
@@ -496,11 +893,31 @@ var typeDoc = map[string]string{
 		"    once the first passing check is found. It Example (in JSON5 notation) to\n" +
 		"    check for non-occurrence of 'foo' in body:\n" +
 		"\n" +
-		"    {\n" +
-		"        Check: \"None\", Of: [\n" +
-		"            {Check: \"Body\", Contains: \"foo\"},\n" +
-		"        ]\n" +
-		"    }",
+		"        {\n" +
+		"            Check: \"None\", Of: [\n" +
+		"                {Check: \"Body\", Contains: \"foo\"},\n" +
+		"            ]\n" +
+		"        }",
+	"openapiresponse": "type OpenAPIResponse struct {\n" +
+		"\t// Spec is the filename of the OpenAPI 3 specification in YAML or\n" +
+		"\t// JSON format.\n" +
+		"\tSpec string\n" +
+		"\n" +
+		"\t// Operation is the operationId of the operation to check against.\n" +
+		"\tOperation string\n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    OpenAPIResponse checks the response against the declaration of the operation\n" +
+		"    in an OpenAPI 3 specification: The status code must be one of the declared\n" +
+		"    response codes of the operation (explicitly, as a range like 2XX or via\n" +
+		"    default) and a JSON body must conform to the schema declared for this status\n" +
+		"    code.\n" +
+		"\n" +
+		"    The following subset of JSON schema is validated: type (including nullable),\n" +
+		"    enum, required, properties, additionalProperties, items, allOf, anyOf,\n" +
+		"    oneOf, minimum, maximum, minLength, maxLength, pattern, minItems and\n" +
+		"    maxItems. Only local references ($ref: '#/...') are resolved.",
 	"redirect": "type Redirect struct {\n" +
 		"\t// To is matched against the Location header. It may begin with,\n" +
 		"\t// end with or contain three dots \"...\" which indicate that To should\n" +
@@ -518,7 +935,7 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    Note that this check cannot be used on tests with\n" +
 		"\n" +
-		"    Request.FollowRedirects = true\n" +
+		"        Request.FollowRedirects.Follow = true\n" +
 		"\n" +
 		"    as Redirect checks only the final response which will not be a redirection\n" +
 		"    if redirections are followed automatically.",
@@ -536,7 +953,63 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    Note that this check can be used on tests with\n" +
 		"\n" +
-		"    Request.FollowRedirects = true",
+		"        Request.FollowRedirects.Follow = true",
+	"redirectcount": "type RedirectCount struct {\n" +
+		"\tCondition\n" +
+		"}\n" +
+		"    RedirectCount checks the number of redirects followed automatically.\n" +
+		"    The Condition is applied to the decimal representation of the number of\n" +
+		"    redirects, e.g. to fail if more than two hops are needed:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"RedirectCount\"\n" +
+		"            LessThan: 3\n" +
+		"        }\n" +
+		"\n" +
+		"    The URLs of the redirect chain are reported in failure messages.\n" +
+		"\n" +
+		"    Note that this check is useful on tests with\n" +
+		"\n" +
+		"        Request.FollowRedirects.Follow = true\n" +
+		"\n" +
+		"    only.",
+	"redirectpolicy": "type RedirectPolicy struct {\n" +
+		"\t// Follow determines if automatic following of redirects\n" +
+		"\t// should be done.\n" +
+		"\tFollow bool \n" +
+		"\n" +
+		"\t// Max is the maximum number of redirects to follow. Exceeding it\n" +
+		"\t// is an error. Zero means 10.\n" +
+		"\tMax int \n" +
+		"\n" +
+		"\t// SameHostOnly stops following redirects to a different host:\n" +
+		"\t// The redirect response itself becomes the final response.\n" +
+		"\tSameHostOnly bool \n" +
+		"\n" +
+		"\t// KeepAuthHeaderAcrossHosts sends the Authorization header also\n" +
+		"\t// to a different host. By default the Authorization header is\n" +
+		"\t// dropped when being redirected to a different host to prevent\n" +
+		"\t// leaking credentials.\n" +
+		"\tKeepAuthHeaderAcrossHosts bool \n" +
+		"}\n" +
+		"    RedirectPolicy determines if and how redirects are followed. A plain boolean\n" +
+		"    is accepted for backwards compatibility, i.e.\n" +
+		"\n" +
+		"        FollowRedirects: true\n" +
+		"\n" +
+		"    is the same as\n" +
+		"\n" +
+		"        FollowRedirects: { Follow: true }\n" +
+		"\n" +
+		"    If given as an object Follow defaults to true, so\n" +
+		"\n" +
+		"        FollowRedirects: { Max: 3, SameHostOnly: true }\n" +
+		"\n" +
+		"    follows at most 3 redirects which stay on the same host.\n" +
+		"\n" +
+		"    Note that Request.FollowRedirects used to be a bool: Go code must now use\n" +
+		"    RedirectPolicy{Follow: true} instead of true. Test files are not affected as\n" +
+		"    they may still use a plain boolean.",
 	"renderedhtml": "type RenderedHTML struct {\n" +
 		"\tBrowser\n" +
 		"\n" +
@@ -549,8 +1022,8 @@ var typeDoc = map[string]string{
 		"\tKeepAs string \n" +
 		"}\n" +
 		"    RenderedHTML applies checks to the HTML after processing through the\n" +
-		"    headless browser PhantomJS. This processing will load external resources and\n" +
-		"    evaluate the JavaScript. The checks are run against this 'rendered' HTML\n" +
+		"    headless browser PhantomJS. This processing will load external resources\n" +
+		"    and evaluate the JavaScript. The checks are run against this 'rendered' HTML\n" +
 		"    code.",
 	"renderingtime": "type RenderingTime struct {\n" +
 		"\tBrowser\n" +
@@ -564,7 +1037,9 @@ var typeDoc = map[string]string{
 		"    with absolute accuracy.",
 	"request": "type Request struct {\n" +
 		"\t// Method is the HTTP method to use.\n" +
-		"\t// A empty method is equivalent to \"GET\"\n" +
+		"\t// A empty method is equivalent to \"GET\".\n" +
+		"\t// Checks of the response body (e.g. Body, JSON or HTMLContains)\n" +
+		"\t// are skipped for HEAD and OPTIONS requests.\n" +
 		"\tMethod string \n" +
 		"\n" +
 		"\t// URL ist the URL of the request.\n" +
@@ -577,8 +1052,9 @@ var typeDoc = map[string]string{
 		"\t// files by special formated values.\n" +
 		"\t// The following formats are recognized:\n" +
 		"\t//    @file:/path/to/thefile\n" +
-		"\t//         read in /path/to/thefile and use its content as the\n" +
-		"\t//         parameter value. The path may be relative.\n" +
+		"\t//         stream the content of /path/to/thefile as the parameter\n" +
+		"\t//         value. The path may be relative. The file is not read\n" +
+		"\t//         into memory but sent directly from disk.\n" +
 		"\t//    @vfile:/path/to/thefile\n" +
 		"\t//         read in /path/to/thefile and perform variable substitution\n" +
 		"\t//         in its content to yield the parameter value.\n" +
@@ -610,9 +1086,9 @@ var typeDoc = map[string]string{
 		"\t// empty if Params are sent as multipart or form-urlencoded.\n" +
 		"\tBody string \n" +
 		"\n" +
-		"\t// FollowRedirects determines if automatic following of\n" +
-		"\t// redirects should be done.\n" +
-		"\tFollowRedirects bool \n" +
+		"\t// FollowRedirects determines if and how redirects are followed,\n" +
+		"\t// see RedirectPolicy.\n" +
+		"\tFollowRedirects RedirectPolicy \n" +
 		"\n" +
 		"\t// BasicAuthUser and BasicAuthPass contain optional username and\n" +
 		"\t// password which will be sent in a Basic Authentication header.\n" +
@@ -628,11 +1104,106 @@ var typeDoc = map[string]string{
 		"\t// Timeout of this request. If zero use DefaultClientTimeout.\n" +
 		"\tTimeout time.Duration \n" +
 		"\n" +
+		"\t// Proxy is the URL of the proxy to use for this request, e.g.\n" +
+		"\t// \"http://proxy.example.org:3128\" or \"socks5://localhost:1080\".\n" +
+		"\t// The schemes http, https and socks5 are supported.\n" +
+		"\t// A non-empty Proxy overrides the proxy settings derived from the\n" +
+		"\t// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.\n" +
+		"\tProxy string \n" +
+		"\n" +
+		"\t// HTTPVersion selects the HTTP protocol version to use:\n" +
+		"\t//   \"1.1\"          : use HTTP/1.1 only, even if the server offers HTTP/2\n" +
+		"\t//   \"2\"            : try to negotiate HTTP/2 via TLS ALPN\n" +
+		"\t//   \"auto\" or \"\"   : the default behaviour of Transport\n" +
+		"\t// The protocol actually used is available in Response.Response.Proto.\n" +
+		"\tHTTPVersion string \n" +
+		"\n" +
+		"\t// DisableKeepAlive forces a fresh connection for this request which\n" +
+		"\t// is closed after the response has been read. Whether a connection\n" +
+		"\t// was reused is reported in Response.ConnReused.\n" +
+		"\tDisableKeepAlive bool \n" +
+		"\n" +
+		"\t// CompressBody compresses the request body with \"gzip\" or \"deflate\"\n" +
+		"\t// and sets the Content-Encoding header accordingly. Variables are\n" +
+		"\t// substituted before compression; SentBody is the uncompressed body.\n" +
+		"\t// Multipart bodies cannot be compressed.\n" +
+		"\tCompressBody string \n" +
+		"\n" +
+		"\t// FromForm populates the request from a HTML form in the response\n" +
+		"\t// of the previous test: The form's action, method and the values of\n" +
+		"\t// its controls (e.g. hidden CSRF tokens) are used unless URL, Method\n" +
+		"\t// or the parameter are given explicitly. This allows to submit\n" +
+		"\t// a form with only the visible fields overridden in Params.\n" +
+		"\tFromForm *Form \n" +
+		"\n" +
+		"\t// Sign, if non-nil, signs the request with a HMAC, see Signing.\n" +
+		"\tSign *Signing \n" +
+		"\n" +
+		"\t// AWSSigV4, if non-nil, signs the request with AWS Signature\n" +
+		"\t// Version 4, see AWSSigV4.\n" +
+		"\tAWSSigV4 *AWSSigV4 \n" +
+		"\n" +
+		"\t// NTLM, if non-nil, authenticates the request with NTLM. This is\n" +
+		"\t// experimental, see NTLM.\n" +
+		"\tNTLM *NTLM \n" +
+		"\n" +
+		"\t// SaveBodyTo is the name of a file the response body is streamed to,\n" +
+		"\t// e.g. \"{{TEST_DIR}}/report.pdf\". Missing directories are created.\n" +
+		"\t// To save memory on large downloads the body is kept in memory\n" +
+		"\t// (and shows up in Response.BodyStr and the reports) only if\n" +
+		"\t// one of the checks or variable extractions needs it; checks which\n" +
+		"\t// inspect only the status, headers or cookies do not.\n" +
+		"\tSaveBodyTo string \n" +
+		"\n" +
+		"\t// MaxBodySize limits the number of bytes of the (decompressed)\n" +
+		"\t// response body read into memory to protect against huge responses\n" +
+		"\t// and decompression bombs. Zero means DefaultMaxBodySize, a negative\n" +
+		"\t// value means unlimited. A larger body is truncated: The checks are\n" +
+		"\t// executed on the first MaxBodySize bytes but the test errors.\n" +
+		"\t// Bodies streamed to SaveBodyTo are not limited.\n" +
+		"\tMaxBodySize int64 \n" +
+		"\n" +
 		"\tRequest    *http.Request  // the 'real' request\n" +
 		"\tSentBody   string         // the 'real' body\n" +
 		"\tSentParams url.Values     // the 'real' parameters\n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Request is a HTTP request.",
+	"requestbudget": "type RequestBudget struct {\n" +
+		"\t// Total limits the number of all requests.\n" +
+		"\tTotal int \n" +
+		"\n" +
+		"\t// Redirects limits the number of redirections.\n" +
+		"\tRedirects int \n" +
+		"\n" +
+		"\t// Images, Scripts and Stylesheets limit the number of distinct\n" +
+		"\t// images (img src), external scripts (script src) and stylesheets\n" +
+		"\t// (link rel=stylesheet).\n" +
+		"\tImages      int \n" +
+		"\tScripts     int \n" +
+		"\tStylesheets int \n" +
+		"\n" +
+		"\t// Media limits the number of distinct video, audio and source\n" +
+		"\t// elements, Frames the number of distinct iframes.\n" +
+		"\tMedia  int \n" +
+		"\tFrames int \n" +
+		"}\n" +
+		"    RequestBudget enforces a performance budget on the number of requests a\n" +
+		"    browser needs to load a HTML page: The request itself, all redirections and\n" +
+		"    the distinct sub-resources referenced in the page. The sub-resources are not\n" +
+		"    requested. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"RequestBudget\"\n" +
+		"            Total: 40\n" +
+		"            Scripts: 8\n" +
+		"            Stylesheets: 3\n" +
+		"            Redirects: -1\n" +
+		"        }\n" +
+		"\n" +
+		"    Zero values do not limit the number of requests, negative values forbid any\n" +
+		"    request of that kind.",
 	"resilience": "type Resilience struct {\n" +
 		"\t// Methods is the space separated list of HTTP methods to check,\n" +
 		"\t// e.g. \"GET POST HEAD\". The empty value will test the original\n" +
@@ -679,43 +1250,41 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    Parameters and Header values can undergo several different types of\n" +
 		"    modifications\n" +
-		"\n" +
-		"    * all:       all the individual modifications below (excluding 'space'\n" +
-		"                 for HTTP headers)\n" +
-		"    * drop:      don't send at all\n" +
-		"    * none:      don't modify the individual parameters or header but\n" +
-		"                 don't send any parameters or headers\n" +
-		"    * double:    send same value two times\n" +
-		"    * twice:     send two different values (original and \"extraValue\")\n" +
-		"    * change:    change a single character (first, middle and last one)\n" +
-		"    * delete:    drop single character (first, middle and last one)\n" +
-		"    * nonsense:  the values \"p,f1u;p5c:h*\", \"hubba%12bubba(!\" and \"   \"\n" +
-		"    * space:     the values \" \", \"       \", \"\\t\", \"\\n\", \"\\r\", \"\\v\", \"\\u00A0\",\n" +
-		"                 \"\\u2003\", \"\\u200B\", \"\\x00\\x00\", and \"\\t \\v \\r \\n \"\n" +
-		"    * malicious: the values \"\\uFEFF\\u200B\\u2029\", \"ʇunpᴉpᴉɔuᴉ\",\n" +
-		"                 \"http://a/%%30%30\" and \"' OR 1=1 -- 1\"\n" +
-		"    * user       use user defined values from Values\n" +
-		"    * empty:     \"\"\n" +
-		"    * type:      change the type (if obvious)\n" +
-		"        - \"1234\"     -->  \"wwww\"\n" +
-		"        - \"3.1415\"   -->  \"wwwwww\"\n" +
-		"        - \"i@you.me\" -->  \"iXyouYme\"\n" +
-		"        - \"foobar  \" -->  \"123\"\n" +
-		"    * large:     produce much larger values\n" +
-		"        - \"1234\"     -->  \"9999999\" (just large), \"2147483648\" (MaxInt32 + 1)\n" +
-		"                          \"9223372036854775808\" (MaxInt64 + 1)\n" +
-		"                          \"18446744073709551616\" (MaxUInt64 + 1)\n" +
-		"        - \"56.78\"    -->  \"888888888.9999\", \"123.456e12\",\n" +
-		"                          \"3.5e38\" (larger than MaxFloat32)\n" +
-		"                          \"1.9e308\" (larger than MaxFloat64)\n" +
-		"        - \"foo\"      -->  50 * \"X\", 160 * \"Y\" and 270 * \"Z\"\n" +
-		"    * tiny:      produce 0 or short values\n" +
-		"        - \"1234\"      -->  \"0\" and \"1\"\n" +
-		"        - \"12.3\"      -->  \"0\", \"0.02\", \"0.0003\", \"1e-12\" and \"4.7e-324\"\n" +
-		"        - \"foobar\"    --> \"f\"\n" +
-		"    * negative   produce negative values\n" +
-		"        - \"1234\"      -->  \"-2\"\n" +
-		"        - \"56.78\"     -->  \"-3.3\"\n" +
+		"      - all: all the individual modifications below (excluding 'space' for HTTP\n" +
+		"        headers)\n" +
+		"      - drop: don't send at all\n" +
+		"      - none: don't modify the individual parameters or header but don't send\n" +
+		"        any parameters or headers\n" +
+		"      - double: send same value two times\n" +
+		"      - twice: send two different values (original and \"extraValue\")\n" +
+		"      - change: change a single character (first, middle and last one)\n" +
+		"      - delete: drop single character (first, middle and last one)\n" +
+		"      - nonsense: the values \"p,f1u;p5c:h*\", \"hubba%12bubba(!\" and \" \"\n" +
+		"      - space: the values \" \", \" \", \"\\t\", \"\\n\", \"\\r\", \"\\v\", \"\\u00A0\", \"\\u2003\",\n" +
+		"        \"\\u200B\", \"\\x00\\x00\", and \"\\t \\v \\r \\n \"\n" +
+		"      - malicious: the values \"\\uFEFF\\u200B\\u2029\", \"ʇunpᴉpᴉɔuᴉ\",\n" +
+		"        \"http://a/%%30%30\" and \"' OR 1=1 -- 1\"\n" +
+		"      - user use user defined values from Values\n" +
+		"      - empty: \"\"\n" +
+		"      - type: change the type (if obvious)\n" +
+		"      - \"1234\" --> \"wwww\"\n" +
+		"      - \"3.1415\" --> \"wwwwww\"\n" +
+		"      - \"i@you.me\" --> \"iXyouYme\"\n" +
+		"      - \"foobar \" --> \"123\"\n" +
+		"      - large: produce much larger values\n" +
+		"      - \"1234\" --> \"9999999\" (just large), \"2147483648\" (MaxInt32 + 1)\n" +
+		"        \"9223372036854775808\" (MaxInt64 + 1) \"18446744073709551616\" (MaxUInt64 +\n" +
+		"        1)\n" +
+		"      - \"56.78\" --> \"888888888.9999\", \"123.456e12\", \"3.5e38\" (larger than\n" +
+		"        MaxFloat32) \"1.9e308\" (larger than MaxFloat64)\n" +
+		"      - \"foo\" --> 50 * \"X\", 160 * \"Y\" and 270 * \"Z\"\n" +
+		"      - tiny: produce 0 or short values\n" +
+		"      - \"1234\" --> \"0\" and \"1\"\n" +
+		"      - \"12.3\" --> \"0\", \"0.02\", \"0.0003\", \"1e-12\" and \"4.7e-324\"\n" +
+		"      - \"foobar\" --> \"f\"\n" +
+		"      - negative produce negative values\n" +
+		"      - \"1234\" --> \"-2\"\n" +
+		"      - \"56.78\" --> \"-3.3\"\n" +
 		"\n" +
 		"    This check will make a wast amount of request to the given URL including the\n" +
 		"    modifying and non-idempotent methods POST, PUT, and DELETE. Some care using\n" +
@@ -725,6 +1294,53 @@ var typeDoc = map[string]string{
 		"\tHigher time.Duration \n" +
 		"}\n" +
 		"    ResponseTime checks the response time.",
+	"seo": "type SEO struct {\n" +
+		"\t// TitleMin and TitleMax limit the length of the title in characters.\n" +
+		"\t// A zero value means no limit.\n" +
+		"\tTitleMin int \n" +
+		"\tTitleMax int \n" +
+		"\n" +
+		"\t// Description is applied to the content of the meta description.\n" +
+		"\tDescription Condition \n" +
+		"}\n" +
+		"    SEO checks a HTML document for the basic on-page requirements of search\n" +
+		"    engine optimization:\n" +
+		"      - exactly one non-empty title, optionally of bounded length\n" +
+		"      - a meta description, optionally fulfilling a condition\n" +
+		"      - a canonical link pointing to the document itself\n" +
+		"      - exactly one h1 heading\n" +
+		"\n" +
+		"    All violations are reported. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"SEO\"\n" +
+		"            TitleMax: 60\n" +
+		"            Description: { Min: 50, Max: 160 }\n" +
+		"        }",
+	"sse": "type SSE struct {\n" +
+		"\t// Duration is the time the event stream is read.\n" +
+		"\tDuration time.Duration\n" +
+		"\n" +
+		"\t// MinEvents and MaxEvents are the minimum and maximum number of\n" +
+		"\t// events which must be received during Duration. A zero MaxEvents\n" +
+		"\t// means no upper limit.\n" +
+		"\tMinEvents int \n" +
+		"\tMaxEvents int \n" +
+		"\n" +
+		"\t// Each is applied to the data of each event received.\n" +
+		"\tEach Condition \n" +
+		"}\n" +
+		"    SSE checks a stream of Server-Sent Events (Content-Type text/event-stream).\n" +
+		"    As such a stream never ends the response body is read only for the given\n" +
+		"    Duration; the events received up to then are checked. Duration must be\n" +
+		"    shorter than the timeout of the request. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"SSE\"\n" +
+		"            Duration: \"5s\"\n" +
+		"            MinEvents: 2\n" +
+		"            Each: { Prefix: \"{\" }\n" +
+		"        }",
 	"screenshot": "type Screenshot struct {\n" +
 		"\tBrowser\n" +
 		"\n" +
@@ -754,9 +1370,38 @@ var typeDoc = map[string]string{
 		"\n" +
 		"    Note that PhantomJS will make additional request to fetch all linked\n" +
 		"    resources in the HTML page. If the original request has BasicAuthUser (and\n" +
-		"    BasicAuthPass) set this credentials will be sent to all linked resources of\n" +
-		"    the page. Depending on where these resources are located this might be a\n" +
+		"    BasicAuthPass) set this credentials will be sent to all linked resources\n" +
+		"    of the page. Depending on where these resources are located this might be a\n" +
 		"    security issue.",
+	"securityheaders": "type SecurityHeaders struct {\n" +
+		"\t// StrictTransportSecurity is the requirement on the\n" +
+		"\t// Strict-Transport-Security (HSTS) header.\n" +
+		"\tStrictTransportSecurity *HeaderRequirement \n" +
+		"\n" +
+		"\t// ContentSecurityPolicy is the requirement on the\n" +
+		"\t// Content-Security-Policy header.\n" +
+		"\tContentSecurityPolicy *HeaderRequirement \n" +
+		"\n" +
+		"\t// XContentTypeOptions is the requirement on the\n" +
+		"\t// X-Content-Type-Options header.\n" +
+		"\tXContentTypeOptions *HeaderRequirement \n" +
+		"\n" +
+		"\t// XFrameOptions is the requirement on the X-Frame-Options header.\n" +
+		"\tXFrameOptions *HeaderRequirement \n" +
+		"\n" +
+		"\t// ReferrerPolicy is the requirement on the Referrer-Policy header.\n" +
+		"\tReferrerPolicy *HeaderRequirement \n" +
+		"}\n" +
+		"    SecurityHeaders checks the security related headers of a response. Headers\n" +
+		"    whose requirement is nil are not checked. All missing or misconfigured\n" +
+		"    headers are reported, not just the first one. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"SecurityHeaders\"\n" +
+		"            StrictTransportSecurity: { Required: true, Prefix: \"max-age=\" }\n" +
+		"            XContentTypeOptions:     { Required: true, Equals: \"nosniff\" }\n" +
+		"            XFrameOptions:           { Regexp: \"^(DENY|SAMEORIGIN)$\" }\n" +
+		"        }",
 	"setcookie": "type SetCookie struct {\n" +
 		"\tName   string     // Name is the cookie name.\n" +
 		"\tValue  Condition  // Value is applied to the cookie value\n" +
@@ -793,20 +1438,46 @@ var typeDoc = map[string]string{
 		"\t// Text is the list of text fragments to look for in the\n" +
 		"\t// response body or the normalized text content of the\n" +
 		"\t// HTML page.\n" +
-		"\tText []string\n" +
+		"\tText []string \n" +
 		"\n" +
 		"\t// AllowedMisses is the number of elements of Text which may\n" +
 		"\t// not be present in the response body. The default of 0 means\n" +
 		"\t// all elements of Text must be present.\n" +
 		"\tAllowedMisses int \n" +
-		"}\n" +
-		"    Sorted checks for an ordered occurrence of items. The check Sorted could be\n" +
-		"    replaced by a Regexp based Body test without loss of functionality; Sorted\n" +
-		"    just makes the idea of \"looking for a sorted occurrence\" clearer.\n" +
 		"\n" +
-		"    If the response has a Content-Type header indicating a HTML response the\n" +
-		"    HTML will be parsed and the text content normalized as described in the\n" +
-		"    HTMLContains check.",
+		"\t// Path selects the list of elements in a JSON response body.\n" +
+		"\t// It uses the same dotted element selectors as the JSON check\n" +
+		"\t// with the addition that \"*\" stands for all elements of an array.\n" +
+		"\t// A Path of \".\" selects the whole JSON document which must be an\n" +
+		"\t// array. An empty Path treats the response body as a list of lines.\n" +
+		"\tPath string \n" +
+		"\n" +
+		"\t// Order is either \"asc\" or \"desc\". The zero value means ascending.\n" +
+		"\tOrder string \n" +
+		"\n" +
+		"\t// Numeric compares the elements as numbers instead of strings.\n" +
+		"\tNumeric bool \n" +
+		"\n" +
+		"\t// Unique disallows repeated elements.\n" +
+		"\tUnique bool \n" +
+		"}\n" +
+		"    Sorted checks for an ordered occurrence of items or for a sorted list.\n" +
+		"    It operates in one of two modes, depending on whether Text is given.\n" +
+		"\n" +
+		"    If Text is non-empty Sorted checks for an ordered occurrence of these text\n" +
+		"    fragments. This could be replaced by a Regexp based Body test without\n" +
+		"    loss of functionality; Sorted just makes the idea of \"looking for a sorted\n" +
+		"    occurrence\" clearer. If the response has a Content-Type header indicating\n" +
+		"    a HTML response the HTML will be parsed and the text content normalized as\n" +
+		"    described in the HTMLContains check.\n" +
+		"\n" +
+		"    If Text is empty Sorted checks that a list of elements in the response is\n" +
+		"    properly sorted. The elements are either the lines of the response body (if\n" +
+		"    Path is empty) or the elements of the JSON array selected by Path. Example:\n" +
+		"    The following checks that the names of all items in {\"items\": [{\"name\":\n" +
+		"    \"A\"}, {\"name\": \"B\"}]} are sorted:\n" +
+		"\n" +
+		"        {Check: \"Sorted\", Path: \"items.*.name\", Unique: true}",
 	"statuscode": "type StatusCode struct {\n" +
 		"\t// Expect is the value to expect, e.g. 302.\n" +
 		"\t//\n" +
@@ -815,14 +1486,88 @@ var typeDoc = map[string]string{
 		"\tExpect int\n" +
 		"}\n" +
 		"    StatusCode checks the HTTP statuscode.",
+	"tlscert": "type TLSCert struct {\n" +
+		"\t// MinDaysValid is the number of days the certificate must at least\n" +
+		"\t// stay valid.\n" +
+		"\tMinDaysValid int \n" +
+		"\n" +
+		"\t// Issuer is applied to the distinguished name of the issuer of the\n" +
+		"\t// certificate, e.g. \"CN=Some CA,O=Some Org,C=US\".\n" +
+		"\tIssuer Condition \n" +
+		"\n" +
+		"\t// SubjectCN is applied to the common name of the subject of the\n" +
+		"\t// certificate.\n" +
+		"\tSubjectCN Condition \n" +
+		"\n" +
+		"\t// DNSNames must all be contained in the DNS names (the subject\n" +
+		"\t// alternative names) of the certificate.\n" +
+		"\tDNSNames []string \n" +
+		"\n" +
+		"\t// MinVersion is the minimum TLS version which must have been\n" +
+		"\t// negotiated. One of \"TLS1.0\", \"TLS1.1\", \"TLS1.2\" or \"TLS1.3\".\n" +
+		"\tMinVersion string \n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    TLSCert checks the certificate presented by the server and the negotiated\n" +
+		"    TLS connection. It allows to monitor certificate expiry as part of normal\n" +
+		"    tests. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"TLSCert\"\n" +
+		"            MinDaysValid: 14\n" +
+		"            Issuer: { Contains: \"Let's Encrypt\" }\n" +
+		"            DNSNames: [ \"example.org\", \"www.example.org\" ]\n" +
+		"            MinVersion: \"TLS1.2\"\n" +
+		"        }\n" +
+		"\n" +
+		"    All problems are reported, not just the first one.",
+	"tlsversion": "type TLSVersion struct {\n" +
+		"\t// Min and Max are the inclusive bounds of the negotiated TLS\n" +
+		"\t// version. One of \"TLS1.0\", \"TLS1.1\", \"TLS1.2\" or \"TLS1.3\";\n" +
+		"\t// empty values do not restrict the version.\n" +
+		"\tMin string \n" +
+		"\tMax string \n" +
+		"\n" +
+		"\t// Ciphers is the set of allowed cipher suites. An empty list\n" +
+		"\t// allows all cipher suites.\n" +
+		"\tCiphers []string \n" +
+		"\n" +
+		"\t// NoInsecure forbids cipher suites with known security issues\n" +
+		"\t// (see crypto/tls.InsecureCipherSuites).\n" +
+		"\tNoInsecure bool \n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    TLSVersion checks the negotiated TLS version and cipher suite of the\n" +
+		"    connection, e.g. to ensure compliance with a TLS policy:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"TLSVersion\"\n" +
+		"            Min: \"TLS1.2\"\n" +
+		"            Ciphers: [\n" +
+		"                \"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256\"\n" +
+		"                \"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384\"\n" +
+		"                \"TLS_AES_128_GCM_SHA256\"\n" +
+		"            ]\n" +
+		"        }\n" +
+		"\n" +
+		"    Cipher suites are named like in package crypto/tls.",
 	"test": "type Test struct {\n" +
 		"\tName        string\n" +
 		"\tDescription string \n" +
 		"\n" +
+		"\t// Criticality of the test. Failures of tests less critical than\n" +
+		"\t// the threshold of their suite do not fail the suite.\n" +
+		"\tCriticality Criticality \n" +
+		"\n" +
 		"\t// Request is the HTTP request.\n" +
 		"\tRequest Request\n" +
 		"\n" +
 		"\t// Checks contains all checks to perform on the response to the HTTP request.\n" +
+		"\t// The checks are executed in the given order with the exception of\n" +
+		"\t// expensive checks (see Coster) which are executed last and only if\n" +
+		"\t// all other checks passed.\n" +
 		"\tChecks CheckList\n" +
 		"\n" +
 		"\t// Execution controls the test execution\n" +
@@ -831,10 +1576,40 @@ var typeDoc = map[string]string{
 		"\t// Jar is the cookie jar to use\n" +
 		"\tJar *cookiejar.Jar \n" +
 		"\n" +
+		"\t// Transport, if non-nil, is used to make the request instead of the\n" +
+		"\t// package global Transport. This allows to inject e.g. tracing or\n" +
+		"\t// replaying RoundTrippers without modifying the global Transport.\n" +
+		"\t// Request.Proxy, Request.HTTPVersion, Request.DisableKeepAlive and\n" +
+		"\t// Request.NTLM require Transport to be an *http.Transport (which is\n" +
+		"\t// copied before modification) or a TransportWrapper; the test is\n" +
+		"\t// Bogus for any other RoundTripper.\n" +
+		"\tTransport http.RoundTripper \n" +
+		"\n" +
+		"\t// RateLimiter, if non-nil, limits the rate of the requests made by\n" +
+		"\t// this test, including the requests of checks like Links or Latency.\n" +
+		"\t// Tests sharing a RateLimiter share its rate.\n" +
+		"\tRateLimiter *RateLimiter \n" +
+		"\n" +
+		"\t// LinkSemaphore, if non-nil, bounds the number of link requests made\n" +
+		"\t// concurrently by the Links checks of all tests sharing it to its\n" +
+		"\t// capacity. It takes precedence over MaxLinkConcurrency.\n" +
+		"\tLinkSemaphore chan struct{} \n" +
+		"\n" +
 		"\t// Variables contains name/value-pairs used for variable substitution\n" +
 		"\t// in files read in, e.g. for Request.Body = \"@vfile:/path/to/file\".\n" +
 		"\tVariables map[string]string \n" +
 		"\n" +
+		"\t// RandomSeed, if non-zero, seeds a random source private to this\n" +
+		"\t// test which is used for all {{RANDOM ...}} substitutions. This\n" +
+		"\t// makes the random values of the test reproducible, independent of\n" +
+		"\t// the order in which other tests consume random numbers.\n" +
+		"\tRandomSeed int64 \n" +
+		"\n" +
+		"\t// Deadline, if non-zero, is the point in time by which the test must\n" +
+		"\t// be done: The request timeout is shortened accordingly and no\n" +
+		"\t// further tries are started once the deadline would be missed.\n" +
+		"\tDeadline time.Time \n" +
+		"\n" +
 		"\t// The following results are filled during Run.\n" +
 		"\t// This should be collected into something like struct TestResult{...}.\n" +
 		"\tResponse     Response      \n" +
@@ -858,40 +1633,72 @@ var typeDoc = map[string]string{
 		"\tExValues map[string]Extraction \n" +
 		"\n" +
 		"\t// Log is the logger to use\n" +
-		"\tLog *log.Logger\n" +
+		"\tLog Logger\n" +
 		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Test is a single logical test which does one HTTP request and checks a\n" +
 		"    number of Checks on the received Response.",
-	"utf8encoded": "type UTF8Encoded struct{}\n" +
-		"    UTF8Encoded checks that the response body is valid UTF-8 without BOMs.",
+	"trailer": "type Trailer struct {\n" +
+		"\t// Trailer is the HTTP trailer to check.\n" +
+		"\tTrailer string\n" +
+		"\n" +
+		"\t// Condition is applied to the first trailer value. A zero value checks\n" +
+		"\t// for the existence of the given Trailer only.\n" +
+		"\tCondition \n" +
+		"\n" +
+		"\t// Absent indicates that no trailer Trailer shall be part of the response.\n" +
+		"\tAbsent bool \n" +
+		"}\n" +
+		"    Trailer provides a textual test of single-valued HTTP trailers, i.e.\n" +
+		"    of header fields sent after the body like the grpc-status of gRPC-Web.\n" +
+		"    Trailers are available only after the whole body has been read, so a body\n" +
+		"    truncated due to Request.MaxBodySize makes this check error.",
+	"utf8encoded": "type UTF8Encoded struct {\n" +
+		"\t// AllowBOM accepts a BOM at the start of the body.\n" +
+		"\tAllowBOM bool \n" +
+		"\n" +
+		"\t// Transcode bodies declared as ISO-8859-1 or Windows-1252.\n" +
+		"\tTranscode bool \n" +
+		"}\n" +
+		"    UTF8Encoded checks that the response body is valid UTF-8 without BOMs.\n" +
+		"\n" +
+		"    The body must be byte-valid UTF-8 whatever charset the Content-Type header\n" +
+		"    declares, so e.g. US-ASCII bodies are fine. If Transcode is set bodies\n" +
+		"    declared as ISO-8859-1 (latin1) or Windows-1252 are transcoded to UTF-8 and\n" +
+		"    the body of the response is replaced by the transcoded one, so that checks\n" +
+		"    following UTF8Encoded work on proper UTF-8. Failures report the byte offset\n" +
+		"    of the offending input. Example:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"UTF8Encoded\"\n" +
+		"            AllowBOM: true\n" +
+		"            Transcode: true\n" +
+		"        }",
 	"validhtml": "type ValidHTML struct {\n" +
 		"\t// Ignore is a space separated list of issues to ignore.\n" +
 		"\t// You normaly won't skip detection of these issues as all issues\n" +
 		"\t// are fundamental flaws which are easy to fix.\n" +
 		"\tIgnore string \n" +
 		"}\n" +
-		"    ValidHTML checks for valid HTML 5; well kinda: It make sure that some common\n" +
-		"    but easy to detect fuckups are not present. The following issues are\n" +
+		"    ValidHTML checks for valid HTML 5; well kinda: It make sure that some\n" +
+		"    common but easy to detect fuckups are not present. The following issues are\n" +
 		"    detected:\n" +
-		"\n" +
-		"    * 'doctype':   not exactly one DOCTYPE\n" +
-		"    * 'structure': ill-formed tag nesting / tag closing\n" +
-		"    * 'uniqueids': uniqness of id attribute values\n" +
-		"    * 'lang':      ill-formed lang attributes\n" +
-		"    * 'attr':      dupplicate attributes\n" +
-		"    * 'escaping':  unescaped &, < and > characters or unknown entities\n" +
-		"    * 'label':     reference to nonexisting id in label tags\n" +
-		"    * 'url':       malformed URLs\n" +
+		"      - 'doctype': not exactly one DOCTYPE\n" +
+		"      - 'structure': ill-formed tag nesting / tag closing\n" +
+		"      - 'uniqueids': uniqness of id attribute values\n" +
+		"      - 'lang': ill-formed lang attributes\n" +
+		"      - 'attr': dupplicate attributes\n" +
+		"      - 'escaping': unescaped &, < and > characters or unknown entities\n" +
+		"      - 'label': reference to nonexisting id in label tags\n" +
+		"      - 'url': malformed URLs\n" +
 		"\n" +
 		"    Notes:\n" +
-		"\n" +
-		"    - HTML5 allows unescaped & in several circumstances but ValidHTML\n" +
-		"      reports all stray & as an error.\n" +
-		"    - The lang attributes are parse very lax, e.g. the non-canonical form\n" +
-		"      'de_CH' is considered valid (and equivalent to 'de-CH'). I don't\n" +
-		"      know how browser handle this.",
+		"      - HTML5 allows unescaped & in several circumstances but ValidHTML reports\n" +
+		"        all stray & as an error.\n" +
+		"      - The lang attributes are parse very lax, e.g. the non-canonical form\n" +
+		"        'de_CH' is considered valid (and equivalent to 'de-CH'). I don't know\n" +
+		"        how browser handle this.",
 	"w3cvalidhtml": "type W3CValidHTML struct {\n" +
 		"\t// AllowedErrors is the number of allowed errors (after ignoring errors).\n" +
 		"\tAllowedErrors int \n" +
@@ -901,6 +1708,46 @@ var typeDoc = map[string]string{
 		"}\n" +
 		"    W3CValidHTML checks for valid HTML but checking the response body via the\n" +
 		"    online checker from W3C which is very strict.",
+	"warn": "type Warn struct {\n" +
+		"\t// Of is the list of checks to execute.\n" +
+		"\tOf CheckList\n" +
+		"}\n" +
+		"    Warn executes the checks Of but reports their failures as warnings only:\n" +
+		"    Warn itself passes and does not change the status of the test; the failures\n" +
+		"    of the checks are reported as warnings of the Warn check. Malformed checks\n" +
+		"    are still reported as bogus. Example (in JSON5 notation) to just warn about\n" +
+		"    a changed rendering while a wrong status code still fails the test:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"Warn\", Of: [\n" +
+		"                {Check: \"Screenshot\", Expected: \"home.png\", AllowedDifference: 5},\n" +
+		"            ]\n" +
+		"        }",
+	"when": "type When struct {\n" +
+		"\t// Field of the response the Condition is applied to:\n" +
+		"\t//     StatusCode     the numerical status code, e.g. \"404\" (default)\n" +
+		"\t//     Status         the status line, e.g. \"404 Not Found\"\n" +
+		"\t//     Proto          the protocol, e.g. \"HTTP/1.1\"\n" +
+		"\t//     Header.<Name>  the first value of the header <Name>\n" +
+		"\t//     Body           the response body\n" +
+		"\tField string \n" +
+		"\n" +
+		"\t// Condition the Field must fulfill for Then to be executed.\n" +
+		"\tCondition\n" +
+		"\n" +
+		"\t// Then are the checks executed if the Condition holds.\n" +
+		"\tThen CheckList\n" +
+		"}\n" +
+		"    When executes the Then checks only if the Condition holds for the selected\n" +
+		"    Field of the response. Otherwise the check is reported as Skipped (and\n" +
+		"    counts as not passing if used inside AnyOne or None). Example (in JSON5\n" +
+		"    notation) to check the body schema of successful responses only:\n" +
+		"\n" +
+		"        {\n" +
+		"            Check: \"When\", Field: \"StatusCode\", Equals: \"200\", Then: [\n" +
+		"                {Check: \"JSON\", Element: \"id\"},\n" +
+		"            ]\n" +
+		"        }",
 	"xml": "type XML struct {\n" +
 		"\t// Path is a XPath expression understood by gopkg.in/xmlpath.v2.\n" +
 		"\tPath string\n" +
@@ -915,6 +1762,24 @@ var typeDoc = map[string]string{
 		"\tFile      string\n" +
 		"\tVariables map[string]string\n" +
 		"\n" +
+		"\t// Repeat executes the test once for each value of the listed\n" +
+		"\t// variables: The i'th repetition uses the i'th value of each\n" +
+		"\t// variable. All lists must have the same length. Example to check\n" +
+		"\t// content negotiation:\n" +
+		"\t//     Repeat: {\n" +
+		"\t//         ACCEPT: [ \"application/json\", \"application/xml\" ]\n" +
+		"\t//         TYPE:   [ \"json\", \"xml\" ]\n" +
+		"\t//     }\n" +
+		"\tRepeat map[string][]string \n" +
+		"\n" +
+		"\t// DataFrom is a CSV (*.csv) or JSON-lines file (relative to the\n" +
+		"\t// suite) with test data. The test is executed once per row with\n" +
+		"\t// the columns of the row as additional variables. The first line\n" +
+		"\t// of a CSV file contains the variable names; each line of a\n" +
+		"\t// JSON-lines file is an object mapping variable names to values.\n" +
+		"\t// Repeat and DataFrom are mutually exclusive.\n" +
+		"\tDataFrom string \n" +
+		"\n" +
 		"\tTest map[string]interface{}\n" +
 		"}\n" +
 		"    RawElement represents one test in a RawSuite.",
@@ -933,6 +1798,7 @@ var typeDoc = map[string]string{
 		"\tMaxThreads int               // MaxThreads to use for this scenario. 0 means unlimited.\n" +
 		"\tVariables  map[string]string // Variables used.\n" +
 		"\tOmitChecks bool              // OmitChecks in the tests.\n" +
+		"\tWeights    map[string]int    // Weights of the Main tests, see Scenario.\n" +
 		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
@@ -940,12 +1806,77 @@ var typeDoc = map[string]string{
 	"rawsuite": "type RawSuite struct {\n" +
 		"\t*File\n" +
 		"\tName, Description     string\n" +
+		"\tInclude               []string\n" +
 		"\tSetup, Main, Teardown []RawElement\n" +
 		"\tKeepCookies           bool\n" +
 		"\tOmitChecks            bool\n" +
 		"\tVariables             map[string]string\n" +
+		"\tComputedVariables     map[string]string\n" +
 		"\tVerbosity             int\n" +
 		"\n" +
+		"\t// Metadata like \"Owner\" or \"Ticket\" is shown in the reports, see\n" +
+		"\t// Suite.Metadata. Variables are expanded in the values.\n" +
+		"\tMetadata map[string]string\n" +
+		"\n" +
+		"\t// BaseURL is prepended to the relative request URLs of all tests,\n" +
+		"\t// see Suite.BaseURL.\n" +
+		"\tBaseURL string\n" +
+		"\n" +
+		"\t// Environments maps environment names (e.g. \"dev\" or \"prod\") to\n" +
+		"\t// variables. The variables of the environment selected with\n" +
+		"\t// SelectEnvironment overwrite the suite's Variables.\n" +
+		"\tEnvironments map[string]map[string]string\n" +
+		"\n" +
+		"\t// Threshold is the minimal criticality a failing test must have to\n" +
+		"\t// fail the suite, see Suite.Threshold.\n" +
+		"\tThreshold ht.Criticality\n" +
+		"\n" +
+		"\t// MaxRequestsPerSecond limits the rate of outgoing requests of\n" +
+		"\t// the suite, see Suite.MaxRequestsPerSecond.\n" +
+		"\tMaxRequestsPerSecond float64\n" +
+		"\n" +
+		"\t// MaxLinkConcurrency limits the number of concurrent link requests\n" +
+		"\t// of the suite, see Suite.MaxLinkConcurrency.\n" +
+		"\tMaxLinkConcurrency int\n" +
+		"\n" +
+		"\t// Timeout limits the execution time of the whole suite, see\n" +
+		"\t// Suite.Timeout.\n" +
+		"\tTimeout time.Duration\n" +
+		"\n" +
+		"\t// BeforeEach and AfterEach are hooks called around the execution\n" +
+		"\t// of each test, see the fields of the same name in Suite.\n" +
+		"\tBeforeEach func(test *ht.Test)            \n" +
+		"\tAfterEach  func(test *ht.Test, err error) \n" +
+		"\n" +
+		"\t// Transport is used for all requests of the suite, see\n" +
+		"\t// Suite.Transport.\n" +
+		"\tTransport http.RoundTripper \n" +
+		"\n" +
+		"\t// FailFast skips all remaining Setup and Main tests once a test\n" +
+		"\t// failed, see Suite.FailFast.\n" +
+		"\tFailFast bool\n" +
+		"\n" +
+		"\t// DefaultHeaders are added to the requests of all tests, see\n" +
+		"\t// Suite.DefaultHeaders.\n" +
+		"\tDefaultHeaders http.Header\n" +
+		"\n" +
+		"\t// FinalCookies are the expected cookies in the cookie jar after\n" +
+		"\t// all tests have been executed, see Suite.FinalCookies.\n" +
+		"\tFinalCookies []FinalCookie\n" +
+		"\n" +
+		"\t// Cassette is the file (relative to the suite) in which the responses\n" +
+		"\t// are recorded and from which they are replayed, see Cassette.\n" +
+		"\t// The cassette is not used if Transport is set.\n" +
+		"\tCassette string\n" +
+		"\n" +
+		"\t// CassetteMode determines whether the Cassette is recorded or\n" +
+		"\t// replayed.\n" +
+		"\tCassetteMode CassetteMode \n" +
+		"\n" +
+		"\t// OnFailure is the test executed after each failing test to capture\n" +
+		"\t// diagnostics, see Suite.OnFailure.\n" +
+		"\tOnFailure *RawElement\n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    RawSuite represents a suite as represented on disk as a HJSON file.",
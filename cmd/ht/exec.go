@@ -7,14 +7,17 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
 	"path"
 	"sort"
 	"strings"
@@ -34,6 +37,9 @@ var cmdExec = &Command{
 	Help: `
 Exec loads the given suites and executes them.
 Variables set with the -D flag overwrite variables read from file with -Dfile.
+Both overwrite the variables of the environment selected with -env which in
+turn overwrite the variables of the suite. Suites without Environments ignore
+-env with a warning.
 The current variable assignment at the end of a suite carries over to the next
 suite. All suites (which keep cookies) share a common jar if cookies are
 loaded via -cookie flag; otherwise each suite has its own cookiejar.
@@ -46,6 +52,30 @@ The exit code is 3 if bogus tests or checks are found, 2 if test errors
 are present, 1 if only check failures occurred and 0 if everything passed,
 nothing was executed or everything was skipped. Note that the status of
 Teardown test are ignored while determining the exit code.
+A machine readable summary of the outcome (the counts, the status and output
+folder of each suite and the exit code with its reason) is written to
+exec-summary.json in the output folder.
+
+The text and the HTML report can be replaced by custom templates with the
+-template flag (which may be given twice). The templates are executed with the
+suite as data and have access to the same functions (e.g. ToUpper, Box and
+Underline) and sub-templates (e.g. TEST and CHECK) as the builtin ones.
+A suite exceeding its Timeout has status error; such suites are reported
+with a TIMEOUT line at the end. Interrupting exec (e.g. by Ctrl-C) cancels
+the running request, skips all remaining tests and reports the outcome so far.
+
+Suites with a Cassette record the responses to the cassette file if it does
+not exist and replay them from the file otherwise. The -record flag forces
+recording and -replay forces replaying; with -replay requests without a
+recorded response fail, so the suites run completely offline.
+
+With -failfast the remaining Setup and Main tests of a suite are skipped once
+a test fails or errors; the Teardown tests are still executed.
+
+With -validate no requests are sent at all: All tests are constructed and
+their checks and requests are prepared to detect malformed suites, tests and
+checks. All bogus tests are reported and the exit code is 3 if any are found
+and 0 otherwise.
 
 A suite and the used tests may be given as an archive file like this:
 <entrypoint>@<archivefile>. Here <entrypoint> is the formal suite filename
@@ -55,38 +85,146 @@ objects as described in the main help (run '$ ht help').
 }
 
 var carryVars bool
+var validateOnly bool
+var junitOutput bool
+var templateFiles cmdlTemplates
+var recordFlag, replayFlag bool
+var failFast bool
 
 func init() {
 	addOnlyFlag(cmdExec.Flag)
 	addSkipFlag(cmdExec.Flag)
 
 	addTestFlags(cmdExec.Flag)
+	addEnvFlag(cmdExec.Flag)
 	addOutputFlag(cmdExec.Flag)
 
 	cmdExec.Flag.BoolVar(&carryVars, "carry", false,
 		"carry variables from finished suite to next suite")
-
+	cmdExec.Flag.BoolVar(&validateOnly, "validate", false,
+		"prepare all tests and checks and report bogus ones without sending requests")
+	cmdExec.Flag.BoolVar(&junitOutput, "junit.output", false,
+		"include request URL and response snippet in system-out of each JUnit testcase")
+	cmdExec.Flag.Var(&templateFiles, "template",
+		"use template from `file` for the text report or (*.html) the HTML report")
+	cmdExec.Flag.BoolVar(&recordFlag, "record", false,
+		"make real requests and record the responses to the suites' cassettes")
+	cmdExec.Flag.BoolVar(&replayFlag, "replay", false,
+		"replay all responses from the suites' cassettes")
+	cmdExec.Flag.BoolVar(&failFast, "failfast", false,
+		"skip remaining Setup and Main tests of a suite after the first failure")
 }
 
 func runExecute(cmd *Command, suites []*suite.RawSuite) {
+	if err := loadTemplates(templateFiles); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(9)
+	}
+	if recordFlag && replayFlag {
+		fmt.Fprintln(os.Stderr, "Cannot use -record and -replay together.")
+		os.Exit(9)
+	}
+	for _, rs := range suites {
+		if failFast {
+			rs.FailFast = true
+		}
+		switch {
+		case recordFlag:
+			rs.CassetteMode = suite.CassetteRecord
+		case replayFlag:
+			rs.CassetteMode = suite.CassetteReplay
+		}
+	}
 	prepareHT()
+	if validateOnly {
+		validateSuites(suites, variablesFlag)
+		return
+	}
 	jar := loadCookies()
 
 	outcome := executeSuites(suites, variablesFlag, jar)
 	saveOutcome(outcome)
 }
 
+// loadTemplates reads and parses the custom report templates in files.
+// Files with extension .html or .htm replace the HTML report template,
+// all other the text report template.
+func loadTemplates(files []string) error {
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(path.Ext(file)) {
+		case ".html", ".htm":
+			err = suite.SetHTMLTemplate(string(src))
+		default:
+			err = suite.SetTextTemplate(string(src))
+		}
+		if err != nil {
+			return fmt.Errorf("bad template %s: %s", file, err)
+		}
+	}
+	return nil
+}
+
+// validateSuites prepares all tests in suites without executing them and
+// reports bogus tests. It exits with 3 if bogus tests are found.
+func validateSuites(suites []*suite.RawSuite, variables map[string]string) {
+	total, bogus := reportBogus(os.Stdout, suites, variables)
+	fmt.Printf("Validated %d suites with %d tests: %d bogus\n",
+		len(suites), total, bogus)
+	if bogus > 0 {
+		fmt.Println("BOGUS")
+		os.Exit(3)
+	}
+	fmt.Println("VALID")
+	os.Exit(0)
+}
+
+// reportBogus dry runs suites, writes the bogus tests and suites to w and
+// returns the total number of tests and the number of bogus ones.
+func reportBogus(w io.Writer, suites []*suite.RawSuite, variables map[string]string) (total, bogus int) {
+	for _, rs := range suites {
+		s := rs.DryRun(variables, nil)
+		bogusTests := 0
+		for i, test := range s.Tests {
+			total++
+			if test.Status != ht.Bogus {
+				continue
+			}
+			bogus++
+			bogusTests++
+			fmt.Fprintf(w, "Suite %q, test %d %q is bogus:\n", s.Name, i+1, test.Name)
+			if el, ok := test.Error.(ht.ErrorList); ok {
+				for _, msg := range el.AsStrings() {
+					fmt.Fprintf(w, "    %s\n", msg)
+				}
+			} else if test.Error != nil {
+				fmt.Fprintf(w, "    %s\n", test.Error)
+			}
+		}
+		if s.Status == ht.Bogus && bogusTests == 0 {
+			bogus++
+			fmt.Fprintf(w, "Suite %q is bogus:\n    %s\n", s.Name, s.Error)
+		}
+	}
+	return total, bogus
+}
+
 func saveOutcome(outcome []*suite.Suite) {
 	if outputDir == "" {
 		outputDir = time.Now().Format("2006-01-02_15h04m05s")
 	}
 	os.MkdirAll(outputDir, 0766)
 	total, totalPass, totalError, totalSkiped, totalFailed, totalBogus := 0, 0, 0, 0, 0, 0
+	totalWarnings := 0
 	for _, s := range outcome {
 		s.PrintReport(os.Stdout)
 	}
 
 	overallStatus := ht.NotRun
+	summary := execSummary{OutputDir: outputDir}
 	overallVars := make(map[string]string)
 	overallCookies := make(map[string]cookiejar.Entry)
 
@@ -95,6 +233,7 @@ func saveOutcome(outcome []*suite.Suite) {
 		if s.Status > overallStatus {
 			overallStatus = s.Status
 		}
+		totalWarnings += len(s.Warnings)
 		for _, r := range s.Tests {
 			switch r.Status {
 			case ht.Pass:
@@ -112,6 +251,7 @@ func saveOutcome(outcome []*suite.Suite) {
 		}
 
 		dirname := outputDir + "/" + sanitize.Filename(s.Name)
+		summary.Suites = append(summary.Suites, newSuiteSummary(s, dirname))
 		fmt.Printf("Saving result of suite %q to folder %q.\n", s.Name, dirname)
 		err := os.MkdirAll(dirname, 0766)
 		if err != nil {
@@ -127,7 +267,12 @@ func saveOutcome(outcome []*suite.Suite) {
 			reportURL := "file://" + path.Join(cwd, dirname, "_Report_.html")
 			fmt.Printf("See %s\n", reportURL)
 		}
-		junit, err := s.JUnit4XML()
+		var junit string
+		if junitOutput {
+			junit, err = s.JUnit4XMLWithOutput(0)
+		} else {
+			junit, err = s.JUnit4XML()
+		}
 		if err != nil {
 			log.Panic(err)
 		}
@@ -179,30 +324,117 @@ func saveOutcome(outcome []*suite.Suite) {
 	fmt.Println()
 	fmt.Printf("Total %d,  Passed %d,  Skipped %d,  Errored %d,  Failed %d,  Bogus %d\n",
 		total, totalPass, totalSkiped, totalError, totalFailed, totalBogus)
+	if totalWarnings > 0 {
+		fmt.Printf("Warnings %d (failures of tests below the suite's criticality threshold)\n",
+			totalWarnings)
+	}
+	for _, s := range outcome {
+		if s.TimedOut {
+			fmt.Printf("TIMEOUT: Suite %q exceeded its timeout of %s\n", s.Name, s.Timeout)
+		} else if s.Canceled {
+			fmt.Printf("CANCELED: Suite %q was interrupted\n", s.Name)
+		}
+	}
+
+	summary.Total, summary.Passed, summary.Skipped = total, totalPass, totalSkiped
+	summary.Errored, summary.Failed, summary.Bogus = totalError, totalFailed, totalBogus
+	summary.Warnings = totalWarnings
+	summary.Status = strings.ToUpper(overallStatus.String())
+	summary.ExitCode, summary.Reason = exitCode(overallStatus)
+	if err := summary.save(path.Join(outputDir, "exec-summary.json")); err != nil {
+		log.Panic(err)
+	}
 
+	fmt.Println(summary.Status)
+	os.Exit(summary.ExitCode)
+}
+
+// exitCode of exec for the given overall status and the reason for it.
+func exitCode(overallStatus ht.Status) (int, string) {
 	switch overallStatus {
 	case ht.NotRun:
-		fmt.Println("NOTRUN")
-		os.Exit(0)
+		return 0, "nothing was executed"
 	case ht.Skipped:
-		fmt.Println("SKIPPED")
-		os.Exit(0)
+		return 0, "everything was skipped"
 	case ht.Pass:
-		fmt.Println("PASS")
-		os.Exit(0)
+		return 0, "all tests passed"
 	case ht.Fail:
-		fmt.Println("FAIL")
-		os.Exit(1)
+		return 1, "check failures occurred"
 	case ht.Error:
-		fmt.Println("ERROR")
-		os.Exit(2)
+		return 2, "test errors occurred"
 	case ht.Bogus:
-		fmt.Println("BOGUS")
-		os.Exit(3)
+		return 3, "bogus tests or checks found"
 	}
 	panic(fmt.Sprintf("Ooops: Unknown overall status %d", overallStatus))
 }
 
+// execSummary is the machine readable outcome of exec. It is saved as
+// exec-summary.json in the output directory.
+type execSummary struct {
+	Status   string // Status is the overall status, e.g. "PASS" or "FAIL".
+	ExitCode int    // ExitCode of exec.
+	Reason   string // Reason explains the ExitCode.
+
+	// Number of tests in all suites by their status.
+	Total, Passed, Skipped, Errored, Failed, Bogus int
+
+	// Warnings is the number of failures below the suites' thresholds.
+	Warnings int
+
+	OutputDir string         // OutputDir is the overall output directory.
+	Suites    []suiteSummary // Suites in order of execution.
+}
+
+// suiteSummary is the outcome of a single suite in an execSummary.
+type suiteSummary struct {
+	Name      string
+	Status    string
+	Duration  time.Duration
+	TimedOut  bool `json:",omitempty"`
+	Canceled  bool `json:",omitempty"`
+	OutputDir string
+
+	// Number of tests by their status.
+	Total, Passed, Skipped, Errored, Failed, Bogus int
+}
+
+// newSuiteSummary summarizes s whose results are saved to dirname.
+func newSuiteSummary(s *suite.Suite, dirname string) suiteSummary {
+	ss := suiteSummary{
+		Name:      s.Name,
+		Status:    strings.ToUpper(s.Status.String()),
+		Duration:  s.Duration,
+		TimedOut:  s.TimedOut,
+		Canceled:  s.Canceled,
+		OutputDir: dirname,
+		Total:     len(s.Tests),
+	}
+	for _, test := range s.Tests {
+		switch test.Status {
+		case ht.Pass:
+			ss.Passed++
+		case ht.Error:
+			ss.Errored++
+		case ht.Skipped:
+			ss.Skipped++
+		case ht.Fail:
+			ss.Failed++
+		case ht.Bogus:
+			ss.Bogus++
+		}
+	}
+	return ss
+}
+
+// save es as JSON to filename.
+func (es execSummary) save(filename string) error {
+	data, err := json.MarshalIndent(es, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0666)
+}
+
 func saveVariables(vars map[string]string, filename string) error {
 	b, err := json.MarshalIndent(vars, "    ", "")
 	if err != nil {
@@ -288,6 +520,10 @@ func prepareHT() {
 		fmt.Println("Skipping verification of TLS certificates presented by any server.")
 		ht.Transport.TLSClientConfig.InsecureSkipVerify = true
 	}
+	if maxLinks > 0 {
+		fmt.Printf("Checking at most %d links concurrently.\n", maxLinks)
+		ht.MaxLinkConcurrency = maxLinks
+	}
 	ht.PhantomJSExecutable = phantomjs
 	fmt.Printf("Using %q as PhantomJS executable.\n", phantomjs)
 
@@ -330,12 +566,24 @@ func loadCookies() *cookiejar.Jar {
 func executeSuites(suites []*suite.RawSuite, variables map[string]string, jar *cookiejar.Jar) []*suite.Suite {
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 256)
 	defer bufferedStdout.Flush()
-	logger := log.New(bufferedStdout, "", 0)
+	logger := ht.NewLogger(log.New(bufferedStdout, "", 0))
+
+	// Cancel execution on the first interrupt so that the outcome up to
+	// then is still reported; a second interrupt kills as usual.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		cancel()
+	}()
 
 	outcome := make([]*suite.Suite, len(suites))
 	for i, s := range suites {
-		logger.Println("Starting Suite", i+1, s.Name, s.File.Name)
-		outcome[i] = s.Execute(variables, jar, logger)
+		logger.Event("INFO", "starting suite", "no", i+1, "name", s.Name, "file", s.File.Name)
+		outcome[i] = s.ExecuteContext(ctx, variables, jar, logger)
 		if carryVars {
 			variables = outcome[i].FinalVariables // carry over variables ???
 		}
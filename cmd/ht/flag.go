@@ -58,12 +58,23 @@ func (l *cmdlLimit) Set(s string) error {
 	return nil
 }
 
+// cmdlTemplates collects the template files given via the repeatable
+// -template flag.
+type cmdlTemplates []string
+
+func (t *cmdlTemplates) String() string { return strings.Join(*t, ",") }
+func (t *cmdlTemplates) Set(s string) error {
+	*t = append(*t, s)
+	return nil
+}
+
 // ----------------------------------------------------------------------------
 // Common flags
 
 var (
 	variablesFlag    = make(cmdlVar)   // flag -D
 	variablesFile    string            // -Dfile
+	envFlag          string            // flag -env
 	rtLimits         = make(cmdlLimit) // flag -L
 	onlyFlag         string            // flag -only
 	skipFlag         string            // flag -skip
@@ -76,6 +87,7 @@ var (
 	vardump          string            // flag -vardump
 	cookiedump       string            // flag -cookiedump
 	cookie           string            // flag -cookie
+	maxLinks         int               // flag -maxlinks
 )
 
 func addVarsFlags(fs *flag.FlagSet) {
@@ -91,6 +103,7 @@ func addTestFlags(fs *flag.FlagSet) {
 	addPhantomJSFlag(fs)
 	addDumpFlag(fs)
 	addCookieFlag(fs)
+	addMaxLinksFlag(fs)
 }
 
 func addDfileFlag(fs *flag.FlagSet) {
@@ -98,6 +111,11 @@ func addDfileFlag(fs *flag.FlagSet) {
 		"read variables from `file.json`")
 }
 
+func addEnvFlag(fs *flag.FlagSet) {
+	fs.StringVar(&envFlag, "env", "",
+		"select environment `name` of the suites")
+}
+
 func addOutputFlag(fs *flag.FlagSet) {
 	fs.StringVar(&outputDir, "output", "",
 		"save results to `dirname` instead of timestamp")
@@ -113,6 +131,11 @@ func addSkiptlsverifyFlag(fs *flag.FlagSet) {
 		"do not verify TLS certificate chain of servers")
 }
 
+func addMaxLinksFlag(fs *flag.FlagSet) {
+	fs.IntVar(&maxLinks, "maxlinks", 0,
+		"check at most `n` links concurrently in all Links checks (0 is unlimited)")
+}
+
 func addPhantomJSFlag(fs *flag.FlagSet) {
 	fs.StringVar(&phantomjs, "phantomjs", "phantomjs",
 		"PhantomJS executable")
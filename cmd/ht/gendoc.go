@@ -24,7 +24,7 @@ func allTypes() []string {
 
 	ftypes := []string{
 		"Test", "Request", "Cookie", "Execution",
-		"CheckList", "ExtractorMap", "Condition",
+		"CheckList", "ExtractorMap", "Condition", "RedirectPolicy",
 	}
 	for _, name := range ftypes {
 		t = append(t, "github.com/vdobler/ht/ht."+name)
@@ -121,6 +121,12 @@ func documentation(typ string) ([]string, error) {
 		if bytes.HasPrefix(line, []byte("func ")) {
 			continue
 		}
+		if bytes.HasPrefix(line, []byte("package ")) {
+			continue // newer go doc starts with the package clause
+		}
+		if len(buf) == 0 && len(line) == 0 {
+			continue
+		}
 		buf = append(buf, string(line))
 	}
 	for buf[len(buf)-1] == "" {
@@ -0,0 +1,97 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vdobler/ht/importer"
+)
+
+var cmdImport = &Command{
+	RunArgs:     runImport,
+	Usage:       "import [-output dir] <format> <file>",
+	Description: "import tests from other tools",
+	Flag:        flag.NewFlagSet("import", flag.ContinueOnError),
+	Help: `
+Import converts the requests in <file> into a suite and tests which are
+written to the directory given by -output (defaulting to the current
+directory). The following formats are understood:
+
+    postman   Postman collection (v2.0 or v2.1)
+    har       HAR (HTTP Archive) file, e.g. a network capture of a browser
+    openapi   OpenAPI 3 specification in YAML or JSON format
+
+For Postman collections requests, folders, variables and simple assertions
+in test scripts are converted. For HAR files each entry becomes a test with
+a StatusCode and a ContentType check; request headers common to all entries
+are extracted into a mixin. For OpenAPI specs a smoke test is generated
+for each operation using the example values from the spec and checking
+the declared success status code and content type. Everything which could not be converted is
+listed as a TODO comment at the top of the generated files.
+`,
+}
+
+func init() {
+	addOutputFlag(cmdImport.Flag)
+}
+
+// importers maps the formats understood by import to their importer.
+var importers = map[string]func(io.Reader) (*importer.Result, error){
+	"postman": importer.Postman,
+	"har":     importer.HAR,
+	"openapi": importer.OpenAPI,
+}
+
+func runImport(cmd *Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Missing arguments to import")
+		fmt.Fprintf(os.Stderr, "Usage: %s\n", cmd.Usage)
+		os.Exit(9)
+	}
+	convert, ok := importers[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown import format %q\n", args[0])
+		os.Exit(9)
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %q: %s\n", args[1], err)
+		os.Exit(9)
+	}
+	defer file.Close()
+	result, err := convert(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot import %q: %s\n", args[1], err)
+		os.Exit(8)
+	}
+
+	dir := outputDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0766); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(8)
+	}
+	written, err := result.Write(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot write files: %s\n", err)
+		os.Exit(8)
+	}
+	todos := len(result.Suite.TODO)
+	for _, test := range result.Tests {
+		todos += len(test.TODO)
+	}
+	for _, filename := range written {
+		fmt.Println("Wrote", filename)
+	}
+	fmt.Printf("Imported %d tests, %d TODOs left.\n", len(result.Tests), todos)
+	os.Exit(0)
+}
@@ -30,6 +30,7 @@ var (
 
 func init() {
 	addVarsFlags(cmdList.Flag)
+	addEnvFlag(cmdList.Flag)
 	cmdList.Flag.BoolVar(&fullFlag, "full", false,
 		"print more details")
 }
@@ -33,7 +33,31 @@ var cmdLoad = &Command{
 Execute a throughput test.
 The length of the throuput test can be set with the 'duration' command line
 flag. The desired target rate of requests/seconds (QPS) is set with the
-'rate' command line flag.
+'rate' command line flag. The load is generated in an open model: Requests
+are started at exponentially distributed intervals independent of how
+long earlier requests take. During the initial 'ramp' duration the rate is
+increased linearly to the target rate which is then sustained for the rest
+of the test. The achieved rate during the sustained phase is reported
+together with the target rate.
+
+Each scenario is executed repeatedly by several threads (virtual users).
+The 'pacing' flag makes the threads pause between two repetitions of
+their scenario to model the think time of real users. The pause is
+drawn from a constant, uniform or exponential distribution with the
+given mean, e.g. '-pacing exponential:3s'.
+
+A scenario may give Weights to the Main tests of its suite, e.g.
+    Weights: { "browse.ht": 70, "search.ht": 20, "checkout.ht": 10 }
+Each repetition of such a scenario executes just one Main test drawn
+randomly according to the weights. The achieved mix is reported and
+compared to the target mix.
+
+A summary of the achieved rate and the request duration percentiles is
+saved as load-summary.json in the output folder. Passing the
+load-summary.json of an earlier run with the 'baseline' flag compares the
+current run to this baseline: The change of each metric is printed and the
+load test fails if any latency percentile or the throughput regressed by
+more than 'regression' percent.
 	`,
 }
 
@@ -42,6 +66,9 @@ var testDuration time.Duration
 var rampDuration time.Duration
 var collectFrom string
 var maxErrorRate float64
+var baselineFile string
+var regressionThreshold float64
+var pacingFlag string
 
 func init() {
 	cmdLoad.Flag.Float64Var(&queryPerSecond, "rate", 20,
@@ -54,6 +81,12 @@ func init() {
 		"collect Test with status at least `limit`")
 	cmdLoad.Flag.Float64Var(&maxErrorRate, "errors", 0.9,
 		"abort load test if error rate exceeds `rate`")
+	cmdLoad.Flag.StringVar(&pacingFlag, "pacing", "",
+		"pause `distribution:mean` between scenario repetitions (constant, uniform or exponential)")
+	cmdLoad.Flag.StringVar(&baselineFile, "baseline", "",
+		"compare results to the load-summary.json `file` of an earlier run")
+	cmdLoad.Flag.Float64Var(&regressionThreshold, "regression", 10,
+		"fail if a metric regressed by more than `percent` against the baseline")
 	addOutputFlag(cmdLoad.Flag)
 	addVarsFlags(cmdLoad.Flag)
 }
@@ -79,6 +112,12 @@ func runLoad(cmd *Command, args []string) {
 		os.Exit(9)
 	}
 
+	pacing, err := suite.ParsePacing(pacingFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(9)
+	}
+
 	arg := args[0]
 
 	// Process arguments of the form <name>@<archive>.
@@ -103,7 +142,11 @@ func runLoad(cmd *Command, args []string) {
 	}
 
 	// Prepare scenarios, output folder and the live data log.
-	scenarios := raw.ToScenario(variablesFlag)
+	scenarios, err := raw.ToScenario(variablesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load %q: %s\n", arg, err)
+		os.Exit(9)
+	}
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 512)
 	defer bufferedStdout.Flush()
 	for i, scen := range scenarios {
@@ -132,6 +175,7 @@ func runLoad(cmd *Command, args []string) {
 		Ramp:         rampDuration,
 		CollectFrom:  collectStatus,
 		MaxErrorRate: maxErrorRate,
+		Pacing:       pacing,
 	}
 	data, failures, lterr := suite.Throughput(scenarios, opts, livefile)
 
@@ -145,6 +189,15 @@ func runLoad(cmd *Command, args []string) {
 	}
 	saveLoadtestData(data, failures, scenarios)
 
+	summary := summarize(data)
+	err = summary.save(filepath.Join(outputDir, "load-summary.json"))
+	if err != nil {
+		log.Panic(err)
+	}
+	if baselineFile != "" {
+		lterr = checkBaseline(summary, lterr)
+	}
+
 	interpretLTerrors(lterr)
 }
 
@@ -185,6 +238,10 @@ func printStatistics(out io.Writer, scenarios []suite.Scenario, data []suite.Tes
 		printStat(os.Stdout, h, st)
 	}
 
+	// Achieved vs. target rate
+	printRate(out, data)
+	printRate(os.Stdout, data)
+
 	// All requests
 	st := statsFor(data)
 	printStat(out, "All request:", st)
@@ -219,6 +276,43 @@ func printStatistics(out io.Writer, scenarios []suite.Scenario, data []suite.Tes
 
 }
 
+// printRate prints the target rate and the rate achieved after the ramp.
+func printRate(out io.Writer, data []suite.TestData) {
+	if len(data) == 0 {
+		return
+	}
+	start := data[0].Started
+	for _, d := range data {
+		if d.Started.Before(start) {
+			start = d.Started
+		}
+	}
+	rate, n := achievedRate(data, start.Add(rampDuration))
+	fmt.Fprintf(out, "Rate: target=%.1f req/s, achieved=%.1f req/s (%d requests after ramp of %s)\n",
+		queryPerSecond, rate, n, rampDuration)
+}
+
+// achievedRate returns the number of requests per second and the number
+// of requests in data started at or after from.
+func achievedRate(data []suite.TestData, from time.Time) (float64, int) {
+	n := 0
+	last := from
+	for _, d := range data {
+		if d.Started.Before(from) {
+			continue
+		}
+		n++
+		if d.Started.After(last) {
+			last = d.Started
+		}
+	}
+	span := last.Sub(from)
+	if span <= 0 {
+		return 0, n
+	}
+	return float64(n) / span.Seconds(), n
+}
+
 func printStat(out io.Writer, headline string, st sdata) {
 	fmt.Fprintf(out, "%s Status:   Total=%d  Pass=%d (%.1f%%), Fail=%d (%.1f%%), Error=%d (%.1f%%), Bogus=%d (%.1f%%)\n",
 		headline, st.n,
@@ -323,6 +417,27 @@ func quantile(x []time.Duration, p float64) time.Duration {
 	return time.Duration(float64(xl) + (h-fh)*float64(xr-xl))
 }
 
+// checkBaseline compares summary to the baseline and adds regressions
+// to lterr.
+func checkBaseline(summary loadSummary, lterr error) error {
+	baseline, err := readLoadSummary(baselineFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read baseline %q: %s\n", baselineFile, err)
+		os.Exit(9)
+	}
+	err = compareToBaseline(os.Stdout, baseline, summary, regressionThreshold)
+	if err == nil {
+		return lterr
+	}
+	el := ht.ErrorList{}
+	if list, ok := lterr.(ht.ErrorList); ok {
+		el = append(el, list...)
+	} else if lterr != nil {
+		el = append(el, lterr)
+	}
+	return append(el, err.(ht.ErrorList)...)
+}
+
 func interpretLTerrors(lterr error) {
 	if lterr == nil {
 		fmt.Println("OKAY")
@@ -9,6 +9,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -64,9 +65,10 @@ func init() {
 		cmdRun,
 		cmdExec,
 		// cmdBench,
-		// cmdMonitor,
+		cmdMonitor,
 		cmdFingerprint,
 		cmdReconstruct,
+		cmdImport,
 		cmdLoad,
 	}
 }
@@ -198,7 +200,16 @@ func loadSuites(args []string) []*suite.RawSuite {
 	var suites []*suite.RawSuite
 
 	// Handle -only and -skip flags.
-	only, skip := splitTestIDs(onlyFlag), splitTestIDs(skipFlag)
+	onlySel, err := parseTestIDs(onlyFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad -only flag: %s\n", err)
+		os.Exit(9)
+	}
+	skipSel, err := parseTestIDs(skipFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad -skip flag: %s\n", err)
+		os.Exit(9)
+	}
 
 	// Input and setup suites from command line arguments.
 	exit := false
@@ -227,6 +238,16 @@ func loadSuites(args []string) []*suite.RawSuite {
 		// for varName, varVal := range variablesFlag {
 		// 	suite.Variables[varName] = varVal
 		// }
+		if err := selectEnvironment(os.Stderr, s, envFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit = true
+			continue
+		}
+		if validateOnly {
+			// Bogus tests are reported by validateSuites.
+			suites = append(suites, s)
+			continue
+		}
 		err = s.Validate(variablesFlag)
 		if err != nil {
 			if el, ok := err.(ht.ErrorList); ok {
@@ -246,7 +267,8 @@ func loadSuites(args []string) []*suite.RawSuite {
 	}
 
 	// Merge only into skip.
-	if len(only) > 0 {
+	only, skip := selectTests(onlySel, suites), selectTests(skipSel, suites)
+	if len(onlySel) > 0 {
 		for sNo := range suites {
 			for tNo := range suites[sNo].RawTests() {
 				id := fmt.Sprintf("%d.%d", sNo+1, tNo+1)
@@ -276,46 +298,113 @@ func loadSuites(args []string) []*suite.RawSuite {
 	return suites
 }
 
-func splitTestIDs(f string) map[string]bool {
-	ids := make(map[string]bool)
+// testSelector selects tests of one suite by their one-based number.
+type testSelector struct {
+	suite    int  // number of the suite
+	typ      byte // 'u' for Setup, 'd' for Teardown or 0 for all tests
+	beg, end int  // range of test numbers; end == 0 means open range
+}
+
+// selectEnvironment selects the environment name (from the -env flag) of
+// s. A suite without Environments is left unchanged and a warning is
+// written to w as the environment might have been intended for a
+// different suite.
+func selectEnvironment(w io.Writer, s *suite.RawSuite, name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(s.Environments) == 0 {
+		fmt.Fprintf(w, "Warning: suite %s has no Environments, ignoring -env %s\n",
+			s.File.Name, name)
+		return nil
+	}
+	return s.SelectEnvironment(name)
+}
+
+// parseTestIDs parses the comma separated list of test IDs f as given to
+// the -only and -skip flags. A test ID has the form
+//     [<suite>.][u|d]<range>
+// where <suite> defaults to 1 and range is one of
+//     <n>      the single test number n
+//     <n>-<m>  the tests n to m (inclusive)
+//     <n>-     the tests n to the last one
+//     -<m>     the tests 1 to m
+//     *        all tests
+// Without a type prefix the tests are counted from the first Setup test
+// to the last Teardown test; the prefix u and d restricts counting to
+// the Setup and Teardown tests respectively.
+func parseTestIDs(f string) ([]testSelector, error) {
+	var sels []testSelector
 	if len(f) == 0 {
-		return ids
+		return sels, nil
 	}
-	fp := strings.Split(f, ",")
-	for _, x := range fp {
-		xp := strings.SplitN(x, ".", 2)
-		s, t := "1", xp[0]
-		if len(xp) == 2 {
-			s, t = xp[0], xp[1]
+	for _, x := range strings.Split(f, ",") {
+		sel := testSelector{suite: 1, beg: 1}
+		t := strings.TrimSpace(x)
+		if i := strings.Index(t, "."); i != -1 {
+			n, err := strconv.Atoi(t[:i])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("bad suite number in test ID %q", x)
+			}
+			sel.suite, t = n, t[i+1:]
 		}
-		sNo := mustAtoi(s)
-		beg, end := 1, 99
-		if i := strings.Index(t, "-"); i > -1 {
+		if len(t) > 0 && (t[0] == 'u' || t[0] == 'd') {
+			sel.typ, t = t[0], t[1:]
+		}
+
+		var err error
+		switch i := strings.Index(t, "-"); {
+		case t == "*":
+			// Defaults select all tests.
+		case t == "" || t == "-":
+			err = fmt.Errorf("missing test number")
+		case i == -1:
+			sel.beg, err = strconv.Atoi(t)
+			sel.end = sel.beg
+		default:
 			if i > 0 {
-				beg = mustAtoi(t[:i])
+				sel.beg, err = strconv.Atoi(t[:i])
 			}
-			if i < len(t)-1 {
-				end = mustAtoi(t[i+1:])
+			if err == nil && i < len(t)-1 {
+				sel.end, err = strconv.Atoi(t[i+1:])
 			}
-		} else {
-			beg = mustAtoi(t)
-			end = beg
 		}
-		for tNo := beg; tNo <= end; tNo++ {
-			id := fmt.Sprintf("%d.%d", sNo, tNo)
-			ids[id] = true
+		if err == nil && (sel.beg < 1 || (sel.end != 0 && sel.end < sel.beg)) {
+			err = fmt.Errorf("empty range")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad test ID %q: %s", x, err)
 		}
+		sels = append(sels, sel)
 	}
-	return ids
+	return sels, nil
 }
 
-func mustAtoi(s string) int {
-	n, err := strconv.Atoi(s)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s", err.Error())
-		os.Exit(9)
+// selectTests returns the IDs of the form "<suite>.<test>" (counting all
+// tests of a suite from one) of all tests in suites selected by sels.
+func selectTests(sels []testSelector, suites []*suite.RawSuite) map[string]bool {
+	ids := make(map[string]bool)
+	for _, sel := range sels {
+		if sel.suite > len(suites) {
+			continue
+		}
+		s := suites[sel.suite-1]
+		offset, n := 0, len(s.RawTests())
+		switch sel.typ {
+		case 'u':
+			n = len(s.Setup)
+		case 'd':
+			offset, n = len(s.Setup)+len(s.Main), len(s.Teardown)
+		}
+		end := sel.end
+		if end == 0 || end > n {
+			end = n
+		}
+		for tNo := sel.beg; tNo <= end; tNo++ {
+			ids[fmt.Sprintf("%d.%d", sel.suite, offset+tNo)] = true
+		}
 	}
-	return n
+	return ids
 }
 
 // set (-verbosity) or increase (-v ... -vvvv) test verbosities of s.
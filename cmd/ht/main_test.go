@@ -0,0 +1,225 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/suite"
+)
+
+var parseTestIDsTests = []struct {
+	ids  string
+	want []testSelector
+}{
+	{"", nil},
+	{"3", []testSelector{{1, 0, 3, 3}}},
+	{"2.3-5", []testSelector{{2, 0, 3, 5}}},
+	{"3.2-", []testSelector{{3, 0, 2, 0}}},
+	{"-4", []testSelector{{1, 0, 1, 4}}},
+	{"3.*", []testSelector{{3, 0, 1, 0}}},
+	{"u2,1.d*", []testSelector{{1, 'u', 2, 2}, {1, 'd', 1, 0}}},
+	{"2.u1-2, 2.d3-", []testSelector{{2, 'u', 1, 2}, {2, 'd', 3, 0}}},
+}
+
+func TestParseTestIDs(t *testing.T) {
+	for i, tc := range parseTestIDsTests {
+		got, err := parseTestIDs(tc.ids)
+		if err != nil {
+			t.Errorf("%d. %q: unexpected error %s", i, tc.ids, err)
+			continue
+		}
+		if len(got) == 0 && len(tc.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d. %q: got %v, want %v", i, tc.ids, got, tc.want)
+		}
+	}
+}
+
+func TestParseTestIDsErrors(t *testing.T) {
+	for _, ids := range []string{"x", "1.", "0.3", "a.3", "5-3", "0", "3-x", "-", "u", "1,,2"} {
+		if _, err := parseTestIDs(ids); err == nil {
+			t.Errorf("Missing error for %q", ids)
+		}
+	}
+}
+
+func TestSelectTests(t *testing.T) {
+	s := &suite.RawSuite{
+		Setup:    make([]suite.RawElement, 2),
+		Main:     make([]suite.RawElement, 3),
+		Teardown: make([]suite.RawElement, 2),
+	}
+	s.AddRawTests(make([]*suite.RawTest, 7)...)
+	suites := []*suite.RawSuite{s, s}
+
+	for i, tc := range []struct {
+		ids  string
+		want []string
+	}{
+		{"2", []string{"1.2"}},
+		{"2.5-", []string{"2.5", "2.6", "2.7"}},
+		{"1.*", []string{"1.1", "1.2", "1.3", "1.4", "1.5", "1.6", "1.7"}},
+		{"u2,d1", []string{"1.2", "1.6"}},
+		{"2.d*", []string{"2.6", "2.7"}},
+		{"u1-9", []string{"1.1", "1.2"}},
+		{"3.*", nil},
+	} {
+		sels, err := parseTestIDs(tc.ids)
+		if err != nil {
+			t.Fatalf("%d. Unexpected error %s", i, err)
+		}
+		got := []string{}
+		for id := range selectTests(sels, suites) {
+			got = append(got, id)
+		}
+		sort.Strings(got)
+		if len(got) == 0 && len(tc.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d. %q: got %v, want %v", i, tc.ids, got, tc.want)
+		}
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	baseline := loadSummary{
+		Rate:   100,
+		Median: 10 * time.Millisecond, Q90: 20 * time.Millisecond,
+		Q95: 30 * time.Millisecond, Q99: 40 * time.Millisecond,
+	}
+
+	current := baseline
+	current.Q99 = 43 * time.Millisecond
+	current.Rate = 95
+	if err := compareToBaseline(ioutil.Discard, baseline, current, 10); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	current.Median = 12 * time.Millisecond
+	current.Rate = 80
+	err := compareToBaseline(ioutil.Discard, baseline, current, 10)
+	if el, ok := err.(ht.ErrorList); !ok || len(el) != 2 {
+		t.Errorf("Got %v, want 2 regressions", err)
+	}
+}
+
+func TestAchievedRate(t *testing.T) {
+	start := time.Now()
+	data := []suite.TestData{}
+	for i := 0; i < 40; i++ {
+		// 10 requests/second during the first two seconds,
+		// then 20 requests/second.
+		var at time.Duration
+		if i < 20 {
+			at = time.Duration(i) * 100 * time.Millisecond
+		} else {
+			at = 2*time.Second + time.Duration(i-20)*50*time.Millisecond
+		}
+		data = append(data, suite.TestData{Started: start.Add(at)})
+	}
+
+	rate, n := achievedRate(data, start.Add(2*time.Second))
+	if n != 20 || rate < 19 || rate > 22 {
+		t.Errorf("Got rate %.2f from %d requests, want 20 from 20", rate, n)
+	}
+}
+
+func TestSuiteSummary(t *testing.T) {
+	s := &suite.Suite{
+		Name:   "Demo",
+		Status: ht.Fail,
+		Tests: []*ht.Test{
+			{Status: ht.Pass}, {Status: ht.Fail}, {Status: ht.Pass},
+			{Status: ht.Skipped},
+		},
+	}
+	got := newSuiteSummary(s, "out/Demo")
+	want := suiteSummary{Name: "Demo", Status: "FAIL", OutputDir: "out/Demo",
+		Total: 4, Passed: 2, Skipped: 1, Failed: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v, want %+v", got, want)
+	}
+
+	for status, want := range map[ht.Status]int{
+		ht.NotRun: 0, ht.Pass: 0, ht.Fail: 1, ht.Error: 2, ht.Bogus: 3,
+	} {
+		if code, _ := exitCode(status); code != want {
+			t.Errorf("%s: got exit code %d, want %d", status, code, want)
+		}
+	}
+}
+
+func TestValidateUndefinedVariable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.suite": `{Main: [ {File: "a.ht"}, {File: "b.ht"} ]}`,
+		"a.ht":    `{Name: "A", Request: {URL: "http://{{HOST}}/a"}}`,
+		"b.ht":    `{Name: "B", Request: {URL: "http://example.org/{{MISSING}}"}}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	validateOnly = true
+	defer func() { validateOnly = false }()
+	// loadSuites must not exit early on the undefined variable.
+	suites := loadSuites([]string{filepath.Join(dir, "a.suite")})
+	buf := &bytes.Buffer{}
+	total, bogus := reportBogus(buf, suites, map[string]string{"HOST": "example.org"})
+	if total != 2 || bogus != 1 {
+		t.Errorf("Got %d tests, %d bogus", total, bogus)
+	}
+	if out := buf.String(); !strings.Contains(out, `test 2 "B" is bogus`) ||
+		!strings.Contains(out, "undefined variables MISSING") {
+		t.Errorf("Got output\n%s", out)
+	}
+}
+
+func TestSelectEnvironment(t *testing.T) {
+	plain := &suite.RawSuite{File: &suite.File{Name: "plain.suite"}}
+	envs := &suite.RawSuite{
+		File:         &suite.File{Name: "envs.suite"},
+		Environments: map[string]map[string]string{"qa": {"HOST": "qa.example.org"}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := selectEnvironment(buf, plain, "qa"); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+	if got := buf.String(); got != "Warning: suite plain.suite has no Environments, ignoring -env qa\n" {
+		t.Errorf("Got %q", got)
+	}
+
+	buf.Reset()
+	if err := selectEnvironment(buf, envs, "qa"); err != nil || buf.Len() != 0 ||
+		envs.Variables["HOST"] != "qa.example.org" {
+		t.Errorf("Got %v, %q, %v", err, buf.String(), envs.Variables)
+	}
+	if err := selectEnvironment(buf, envs, "prod"); err == nil {
+		t.Errorf("Missing error for unknown environment")
+	}
+	if err := selectEnvironment(buf, plain, ""); err != nil || buf.Len() != 0 {
+		t.Errorf("Got %v, %q", err, buf.String())
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdMonitor = &Command{
+	RunSuites:   runMonitor,
+	Usage:       "monitor [flags] <suite>...",
+	Description: "continuously execute suites and export metrics",
+	Flag:        flag.NewFlagSet("monitor", flag.ContinueOnError),
+	Help: `
+Monitor executes the given suites repeatedly every -interval and exposes
+the outcome as Prometheus metrics on /metrics of the -listen address, turning
+ht into a blackbox exporter for synthetic monitoring.
+
+The metrics are labeled by suite and test name and comprise the status of
+suites and tests (ht_suite_status and ht_test_status with 0=NotRun, 1=Skipped,
+2=Pass, 3=Fail, 4=Error and 5=Bogus), an up/down gauge per test (ht_test_up),
+a histogram of the request durations (ht_test_duration_seconds) and counters
+of suite executions (ht_suite_runs_total) and check failures
+(ht_check_failures_total).
+
+Each suite is executed independently with a fresh copy of the variables
+given by -D and -Dfile. Monitor runs until interrupted.
+	`,
+}
+
+var (
+	monitorInterval time.Duration
+	monitorListen   string
+)
+
+func init() {
+	addTestFlags(cmdMonitor.Flag)
+	addEnvFlag(cmdMonitor.Flag)
+	cmdMonitor.Flag.DurationVar(&monitorInterval, "interval", time.Minute,
+		"execute each suite every `duration`")
+	cmdMonitor.Flag.StringVar(&monitorListen, "listen", ":9100",
+		"serve metrics on `address`")
+}
+
+func runMonitor(cmd *Command, suites []*suite.RawSuite) {
+	if monitorInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "Interval must be positive")
+		os.Exit(9)
+	}
+	prepareHT()
+
+	monitor := suite.NewMonitor()
+	http.Handle("/metrics", monitor)
+	go func() {
+		log.Fatal(http.ListenAndServe(monitorListen, nil))
+	}()
+	fmt.Printf("Serving metrics on %s/metrics\n", monitorListen)
+
+	logger := ht.NewLogger(log.New(ioutil.Discard, "", 0))
+	if verbosity > 0 {
+		logger = ht.NewLogger(log.New(os.Stdout, "", 0))
+	}
+	report := func(s *suite.Suite) {
+		fmt.Printf("%s %-7s %s (%s)\n", time.Now().Format(time.RFC3339),
+			s.Status, s.Name, s.Duration)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, rs := range suites {
+		wg.Add(1)
+		go func(rs *suite.RawSuite) {
+			defer wg.Done()
+			rs.RunLoop(context.Background(), monitorInterval, variablesFlag,
+				nil, logger, monitor, report)
+		}(rs)
+	}
+	wg.Wait()
+}
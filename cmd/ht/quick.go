@@ -128,7 +128,7 @@ func runQuick(cmd *Command, urls []string) {
 				Method:          "GET",
 				URL:             u,
 				Header:          defaultHeader,
-				FollowRedirects: true,
+				FollowRedirects: ht.RedirectPolicy{Follow: true},
 			},
 			Checks: makeChecks(u),
 		}
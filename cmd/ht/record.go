@@ -31,6 +31,10 @@ var cmdRecord = &Command{
 Record acts as a reverse proxy to <remote-target> capturing requests and
 responses. It allows to filter which request/response pairs get captured.
 Tests can be generated for the captured reqest/response pairs.
+
+With -tls the proxy serves HTTPS. The certificates are issued by a CA
+generated on startup; its certificate is printed and must be trusted by
+the browser used for recording.
 `,
 }
 
@@ -44,18 +48,30 @@ func init() {
 		"disarm recorder for `period` after last capture")
 	cmdRecord.Flag.IntVar(&recorderRewrite, "rewrite", 3,
 		"rewrite RespHeader=1 RespBody=2 ReqHeader=4 ReqBody=8")
+	cmdRecord.Flag.BoolVar(&recorderJSONVars, "json.vars", false,
+		"extract top-level fields of JSON request bodies to variables")
+	cmdRecord.Flag.StringVar(&recorderRedact, "redact", "",
+		"redact headers, cookies and fields with names matching `regexp`")
+	cmdRecord.Flag.BoolVar(&recorderTLS, "tls", false,
+		"serve HTTPS with certificates from a generated CA")
 	addOutputFlag(cmdRecord.Flag)
 }
 
 var (
-	recorderPort    string
-	recorderLocal   string
-	recorderDisarm  time.Duration
-	recorderIgnPath string
-	recorderIgnCT   string
-	recorderRewrite int
+	recorderPort     string
+	recorderLocal    string
+	recorderDisarm   time.Duration
+	recorderIgnPath  string
+	recorderIgnCT    string
+	recorderRewrite  int
+	recorderJSONVars bool
+	recorderRedact   string
+	recorderTLS      bool
 )
 
+// recorderOpts are the options the reverse proxy was started with.
+var recorderOpts recorder.Options
+
 func runRecord(cmd *Command, args []string) {
 	if len(args) != 1 {
 		fmt.Fprintln(os.Stderr, "Missing <remote-target> for record")
@@ -74,15 +90,20 @@ func runRecord(cmd *Command, args []string) {
 
 	rewrite := recorder.NewRewriter(recorderLocal, remote.Host, uint32(recorderRewrite))
 
-	opts := recorder.Options{
-		Disarm:  recorderDisarm,
-		Rewrite: rewrite,
+	recorderOpts = recorder.Options{
+		Disarm:      recorderDisarm,
+		Rewrite:     rewrite,
+		ExtractJSON: recorderJSONVars,
+		TLS:         recorderTLS,
 	}
 	if recorderIgnPath != "" {
-		opts.IgnoredPath = regexp.MustCompile(recorderIgnPath)
+		recorderOpts.IgnoredPath = regexp.MustCompile(recorderIgnPath)
 	}
 	if recorderIgnCT != "" {
-		opts.IgnoredContentType = regexp.MustCompile(recorderIgnCT)
+		recorderOpts.IgnoredContentType = regexp.MustCompile(recorderIgnCT)
+	}
+	if recorderRedact != "" {
+		recorderOpts.Redact = []*regexp.Regexp{regexp.MustCompile(recorderRedact)}
 	}
 
 	if i := strings.Index(recorderLocal, ":"); i != -1 {
@@ -91,7 +112,7 @@ func runRecord(cmd *Command, args []string) {
 		recorderPort = ":80"
 	}
 
-	err = recorder.StartReverseProxy(recorderPort, remote, opts)
+	err = recorder.StartReverseProxy(recorderPort, remote, recorderOpts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Cannot launch reverse proxy: %s", err)
 		os.Exit(1)
@@ -146,7 +167,7 @@ func saveEvents(form url.Values) error {
 	dir = sanitize.Filename(dir)
 	suite = sanitize.Filename(suite)
 
-	err := recorder.DumpEvents(ets, dir, suite)
+	err := recorder.DumpEvents(ets, dir, suite, recorderOpts)
 	if err != nil {
 		return err
 	}
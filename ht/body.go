@@ -7,8 +7,12 @@
 package ht
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -19,6 +23,7 @@ import (
 func init() {
 	RegisterCheck(UTF8Encoded{})
 	RegisterCheck(&Body{})
+	RegisterCheck(&BodyAbsent{})
 	RegisterCheck(&Sorted{})
 }
 
@@ -26,22 +31,68 @@ func init() {
 // UTF8Encoded
 
 // UTF8Encoded checks that the response body is valid UTF-8 without BOMs.
-type UTF8Encoded struct{}
+//
+// The body must be byte-valid UTF-8 whatever charset the Content-Type
+// header declares, so e.g. US-ASCII bodies are fine. If Transcode is set
+// bodies declared as ISO-8859-1 (latin1) or Windows-1252 are transcoded to
+// UTF-8 and the body of the response is replaced by the transcoded one, so
+// that checks following UTF8Encoded work on proper UTF-8. Failures report
+// the byte offset of the offending input. Example:
+//     {
+//         Check: "UTF8Encoded"
+//         AllowBOM: true
+//         Transcode: true
+//     }
+type UTF8Encoded struct {
+	// AllowBOM accepts a BOM at the start of the body.
+	AllowBOM bool `json:",omitempty"`
+
+	// Transcode bodies declared as ISO-8859-1 or Windows-1252.
+	Transcode bool `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector.
+func (UTF8Encoded) InspectsBody() bool { return true }
 
 // Execute implements Check's Execute method.
 func (c UTF8Encoded) Execute(t *Test) error {
+	charset := ""
+	if t.Response.Response != nil {
+		ct := t.Response.Response.Header.Get("Content-Type")
+		if _, params, err := mime.ParseMediaType(ct); err == nil {
+			charset = strings.ToLower(params["charset"])
+		}
+	}
+	switch charset {
+	case "", "utf-8", "utf8":
+		charset = ""
+	case "iso-8859-1", "latin1", "iso_8859-1", "l1", "windows-1252", "cp1252":
+		if c.Transcode {
+			t.Response.BodyStr = transcodeToUTF8(t.Response.BodyStr,
+				strings.Contains(charset, "1252"))
+			charset = ""
+		}
+	}
+
 	p := []byte(t.Response.BodyStr)
-	char := 0
+	offset, char := 0, 0
 	for len(p) > 0 {
 		r, size := utf8.DecodeRune(p)
 		char++
-		if r == utf8.RuneError {
-			return fmt.Errorf("Invalid UTF-8 after character %d.", char)
+		if r == utf8.RuneError && size <= 1 {
+			if charset != "" {
+				return fmt.Errorf("Invalid UTF-8 at byte offset %d (character %d) of body declared as %s.",
+					offset, char, charset)
+			}
+			return fmt.Errorf("Invalid UTF-8 at byte offset %d (character %d).",
+				offset, char)
 		}
-		if r == '\ufeff' { // BOMs suck.
-			return fmt.Errorf("Unicode BOM at character %d.", char)
+		if r == '\ufeff' && !(c.AllowBOM && offset == 0) { // BOMs suck.
+			return fmt.Errorf("Unicode BOM at byte offset %d (character %d).",
+				offset, char)
 		}
 		p = p[size:]
+		offset += size
 	}
 	return nil
 }
@@ -49,12 +100,43 @@ func (c UTF8Encoded) Execute(t *Test) error {
 // Prepare implements Check's Prepare method.
 func (UTF8Encoded) Prepare() error { return nil }
 
+// cp1252 maps the bytes 0x80 to 0x9f of Windows-1252 to Unicode; the
+// undefined ones are mapped to the replacement character.
+var cp1252 = [32]rune{
+	'\u20ac', '\ufffd', '\u201a', '\u0192', '\u201e', '\u2026', '\u2020', '\u2021',
+	'\u02c6', '\u2030', '\u0160', '\u2039', '\u0152', '\ufffd', '\u017d', '\ufffd',
+	'\ufffd', '\u2018', '\u2019', '\u201c', '\u201d', '\u2022', '\u2013', '\u2014',
+	'\u02dc', '\u2122', '\u0161', '\u203a', '\u0153', '\ufffd', '\u017e', '\u0178',
+}
+
+// transcodeToUTF8 converts the ISO-8859-1 (or Windows-1252 if windows is
+// set) encoded s to UTF-8.
+func transcodeToUTF8(s string, windows bool) string {
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if windows && b >= 0x80 && b <= 0x9f {
+			runes[i] = cp1252[b-0x80]
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+	return string(runes)
+}
+
 // ----------------------------------------------------------------------------
 // Body
 
 // Body provides simple condition checks on the response body.
+// The length bounds Min and Max are applied to the number of bytes in
+// the body; use Empty to make sure the body is empty, e.g.
+//     {Check: "Body", Min: 100}    // at least 100 bytes
+//     {Check: "Body", Empty: true} // no body at all
 type Body Condition
 
+// InspectsBody implements BodyInspector.
+func (Body) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (b Body) Execute(t *Test) error {
 	body, err := t.Response.BodyStr, t.Response.BodyErr
@@ -69,31 +151,131 @@ func (b *Body) Prepare() error {
 	return ((*Condition)(b)).Compile()
 }
 
+// ----------------------------------------------------------------------------
+// BodyAbsent
+
+// BodyAbsent checks that none of the given texts and regular expressions
+// occur in the response body, e.g. to make sure no stack traces or debug
+// output leak:
+//     {
+//         Check: "BodyAbsent"
+//         Text: [ "DEBUG", "at java.lang." ]
+//         Regexp: [ "(?i)stack ?trace", "panic: .*goroutine" ]
+//     }
+// Each forbidden text or regular expression found is reported together
+// with the byte offset of its first occurrence in the body.
+type BodyAbsent struct {
+	// Text is the list of forbidden literal texts.
+	Text []string `json:",omitempty"`
+
+	// Regexp is the list of forbidden regular expressions.
+	Regexp []string `json:",omitempty"`
+
+	res []*regexp.Regexp
+}
+
+// InspectsBody implements BodyInspector.
+func (*BodyAbsent) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (c *BodyAbsent) Execute(t *Test) error {
+	body, err := t.Response.BodyStr, t.Response.BodyErr
+	if err != nil {
+		return ErrBadBody
+	}
+
+	errs := ErrorList{}
+	for _, text := range c.Text {
+		if i := strings.Index(body, text); i != -1 {
+			errs = append(errs, fmt.Errorf("found forbidden %q at offset %d", text, i))
+		}
+	}
+	for i, re := range c.res {
+		if loc := re.FindStringIndex(body); loc != nil {
+			errs = append(errs, fmt.Errorf("found %q matching forbidden /%s/ at offset %d",
+				body[loc[0]:loc[1]], c.Regexp[i], loc[0]))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *BodyAbsent) Prepare() error {
+	if len(c.Text) == 0 && len(c.Regexp) == 0 {
+		return MalformedCheck{errors.New("neither Text nor Regexp given")}
+	}
+	c.res = make([]*regexp.Regexp, len(c.Regexp))
+	for i, expr := range c.Regexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return MalformedCheck{err}
+		}
+		c.res[i] = re
+	}
+	return nil
+}
+
 // ----------------------------------------------------------------------------
 // Sorted
 
-// Sorted checks for an ordered occurrence of items.
-// The check Sorted could be replaced by a Regexp based Body test
+// Sorted checks for an ordered occurrence of items or for a sorted list.
+// It operates in one of two modes, depending on whether Text is given.
+//
+// If Text is non-empty Sorted checks for an ordered occurrence of these
+// text fragments. This could be replaced by a Regexp based Body test
 // without loss of functionality; Sorted just makes the idea of
 // "looking for a sorted occurrence" clearer.
-//
 // If the response has a Content-Type header indicating a HTML
 // response the HTML will be parsed and the text content normalized
 // as described in the HTMLContains check.
+//
+// If Text is empty Sorted checks that a list of elements in the
+// response is properly sorted. The elements are either the lines of the
+// response body (if Path is empty) or the elements of the JSON array
+// selected by Path. Example: The following checks that the names of all
+// items in {"items": [{"name": "A"}, {"name": "B"}]} are sorted:
+//     {Check: "Sorted", Path: "items.*.name", Unique: true}
 type Sorted struct {
 	// Text is the list of text fragments to look for in the
 	// response body or the normalized text content of the
 	// HTML page.
-	Text []string
+	Text []string `json:",omitempty"`
 
 	// AllowedMisses is the number of elements of Text which may
 	// not be present in the response body. The default of 0 means
 	// all elements of Text must be present.
 	AllowedMisses int `json:",omitempty"`
+
+	// Path selects the list of elements in a JSON response body.
+	// It uses the same dotted element selectors as the JSON check
+	// with the addition that "*" stands for all elements of an array.
+	// A Path of "." selects the whole JSON document which must be an
+	// array. An empty Path treats the response body as a list of lines.
+	Path string `json:",omitempty"`
+
+	// Order is either "asc" or "desc". The zero value means ascending.
+	Order string `json:",omitempty"`
+
+	// Numeric compares the elements as numbers instead of strings.
+	Numeric bool `json:",omitempty"`
+
+	// Unique disallows repeated elements.
+	Unique bool `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector.
+func (*Sorted) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (s *Sorted) Execute(t *Test) error {
+	if len(s.Text) == 0 {
+		return s.executeList(t)
+	}
+
 	bb := t.Response.BodyStr
 
 	ct := ContentType{Is: "html"}
@@ -125,8 +307,122 @@ func (s *Sorted) Execute(t *Test) error {
 	return nil
 }
 
+// executeList checks the sort order of the list of lines or JSON elements.
+func (s *Sorted) executeList(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+
+	var elems []string
+	if s.Path == "" {
+		elems = strings.Split(t.Response.BodyStr, "\n")
+		if n := len(elems); elems[n-1] == "" {
+			elems = elems[:n-1] // trailing newline
+		}
+		for i, e := range elems {
+			elems[i] = strings.TrimSuffix(e, "\r")
+		}
+	} else {
+		flat, err := explodeJSON([]byte(t.Response.BodyStr), ".")
+		if err != nil {
+			return CantCheck{err}
+		}
+		elems, err = jsonListElements(flat, s.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	desc := s.Order == "desc"
+	var last float64
+	for i, e := range elems {
+		var cmp int
+		if s.Numeric {
+			v, err := strconv.ParseFloat(strings.TrimSpace(e), 64)
+			if err != nil {
+				return fmt.Errorf("element %d %q is not a number", i, e)
+			}
+			if i > 0 {
+				switch {
+				case v < last:
+					cmp = -1
+				case v > last:
+					cmp = 1
+				}
+			}
+			last = v
+		} else if i > 0 {
+			cmp = strings.Compare(e, elems[i-1])
+		}
+		if i == 0 {
+			continue
+		}
+		if cmp == 0 && s.Unique {
+			return fmt.Errorf("element %d %q is a duplicate", i, e)
+		}
+		if (!desc && cmp < 0) || (desc && cmp > 0) {
+			order := "ascending"
+			if desc {
+				order = "descending"
+			}
+			return fmt.Errorf("element %d %q not in %s order after %q",
+				i, e, order, elems[i-1])
+		}
+	}
+	return nil
+}
+
+// jsonListElements returns the string representations of the elements
+// selected by path in the flattened JSON document flat.
+func jsonListElements(flat map[string]string, path string) ([]string, error) {
+	if v, ok := flat[path]; ok {
+		// A leaf: Either an empty array or not a list at all.
+		if v == "[]" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("element %s is not an array", path)
+	}
+
+	// A path without wildcard selects the array itself.
+	pattern := path
+	if path == "." {
+		pattern = "*"
+	} else if !strings.Contains("."+path+".", ".*.") {
+		pattern = path + ".*"
+	}
+	leafs, nested := selectJSON(flat, pattern, ".")
+	if nested {
+		return nil, fmt.Errorf("elements of %s are not all scalars", path)
+	}
+	if len(leafs) == 0 {
+		return nil, fmt.Errorf("element %s not found", path)
+	}
+
+	elems := make([]string, len(leafs))
+	for i, leaf := range leafs {
+		v := flat[leaf]
+		if strings.HasPrefix(v, `"`) {
+			if err := json.Unmarshal([]byte(v), &elems[i]); err == nil {
+				continue
+			}
+		}
+		elems[i] = v
+	}
+	return elems, nil
+}
+
 // Prepare implements Check's Prepare method.
 func (s *Sorted) Prepare() error {
+	if s.Order != "" && s.Order != "asc" && s.Order != "desc" {
+		return MalformedCheck{
+			Err: fmt.Errorf("unknown order %q", s.Order),
+		}
+	}
+
+	if len(s.Text) == 0 {
+		return nil
+	}
+
 	if len(s.Text) < 2 {
 		return MalformedCheck{
 			Err: errors.New("not enough values to check sorted"),
@@ -5,9 +5,11 @@
 package ht
 
 import (
+	"fmt"
 	_ "image/jpeg"
 	_ "image/png"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +29,11 @@ var bodyTests = []TC{
 	{br, &Body{Min: 5, Max: 500}, nil},
 	{br, &Body{Min: 500}, someError},
 	{br, &Body{Max: 10}, someError},
+	{br, &Body{Min: 23, Max: 23}, nil},
+	{br, &Body{Empty: true}, fmt.Errorf("Not empty, was 23 bytes")},
+	{Response{BodyStr: ""}, &Body{Empty: true}, nil},
+	{Response{BodyStr: ""}, &Body{Min: 1}, fmt.Errorf("Too short, was 0")},
+	{Response{BodyStr: "äöü"}, &Body{Max: 5}, fmt.Errorf("Too long, was 6")},
 	{br, &Body{Equals: "foo bar baz foo foo 15\""}, nil},
 	{br, &Body{Equals: "foo bar baZ foo foo 15\""}, someError},
 }
@@ -38,10 +45,101 @@ func TestBody(t *testing.T) {
 	}
 }
 
+var bodyAbsentTests = []TC{
+	{br, &BodyAbsent{Text: []string{"wup", "Foo"}}, nil},
+	{br, &BodyAbsent{Text: []string{"wup", "baz"}}, someError},
+	{br, &BodyAbsent{Regexp: []string{`\d{3}`, "^bar"}}, nil},
+	{br, &BodyAbsent{Regexp: []string{`\d+`}}, someError},
+	{br, &BodyAbsent{Text: []string{"wup"}, Regexp: []string{"z f"}}, someError},
+	{br, &BodyAbsent{}, prepareError},
+	{br, &BodyAbsent{Regexp: []string{"("}}, prepareError},
+	{Response{BodyErr: fmt.Errorf("boom")}, &BodyAbsent{Text: []string{"x"}}, ErrBadBody},
+}
+
+func TestBodyAbsent(t *testing.T) {
+	for i, tc := range bodyAbsentTests {
+		runTest(t, i, tc)
+	}
+
+	ba := &BodyAbsent{Text: []string{"baz"}, Regexp: []string{`\d+`}}
+	ba.Prepare()
+	err := ba.Execute(&Test{Response: br})
+	el, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Got %v, want ErrorList", err)
+	}
+	got := strings.Join(el.AsStrings(), "; ")
+	want := `found forbidden "baz" at offset 8; found "15" matching forbidden /\d+/ at offset 20`
+	if got != want {
+		t.Errorf("Got %s, want %s", got, want)
+	}
+
+	// Only the first of several occurrences is reported.
+	ba = &BodyAbsent{Text: []string{"foo"}}
+	ba.Prepare()
+	err = ba.Execute(&Test{Response: br})
+	if el, ok := err.(ErrorList); !ok || len(el) != 1 ||
+		el[0].Error() != `found forbidden "foo" at offset 0` {
+		t.Errorf("Got %v", err)
+	}
+}
+
 var utf8Tests = []TC{
 	{Response{BodyStr: "All fine!"}, UTF8Encoded{}, nil},
 	{Response{BodyStr: "BOMs \ufeff sucks!"}, UTF8Encoded{}, someError},
 	{Response{BodyStr: "Strange \xbd\xb2\x3d\xbc"}, UTF8Encoded{}, someError},
+	{Response{BodyStr: "\ufeffLeading BOM"}, UTF8Encoded{}, someError},
+	{Response{BodyStr: "\ufeffLeading BOM"}, UTF8Encoded{AllowBOM: true}, nil},
+	{Response{BodyStr: "BOMs \ufeff sucks!"}, UTF8Encoded{AllowBOM: true}, someError},
+	{latin1Response(), UTF8Encoded{}, someError},
+	{latin1Response(), UTF8Encoded{Transcode: true}, nil},
+	{Response{
+		BodyStr:  "Gr\xfc\xdfe",
+		Response: &http.Response{Header: http.Header{"Content-Type": {"text/plain; charset=Shift_JIS"}}},
+	}, UTF8Encoded{Transcode: true}, someError},
+	{Response{
+		BodyStr:  "Plain ASCII",
+		Response: &http.Response{Header: http.Header{"Content-Type": {"text/plain; charset=us-ascii"}}},
+	}, UTF8Encoded{}, nil},
+	{Response{
+		BodyStr:  "Plain ASCII",
+		Response: &http.Response{Header: http.Header{"Content-Type": {"text/plain; charset=ISO-8859-1"}}},
+	}, UTF8Encoded{}, nil},
+	{Response{
+		BodyStr:  "Valid ü",
+		Response: &http.Response{Header: http.Header{"Content-Type": {"text/plain; charset=Shift_JIS"}}},
+	}, UTF8Encoded{}, nil},
+}
+
+func latin1Response() Response {
+	return Response{
+		BodyStr:  "Gr\xfc\xdfe \x80",
+		Response: &http.Response{Header: http.Header{"Content-Type": {"text/html; charset=ISO-8859-1"}}},
+	}
+}
+
+func TestUTF8EncodedTranscode(t *testing.T) {
+	for _, tc := range []struct {
+		charset, want string
+	}{
+		{"ISO-8859-1", "Gr\u00fc\u00dfe \u0080"},
+		{"windows-1252", "Gr\u00fc\u00dfe \u20ac"},
+	} {
+		test := &Test{Response: latin1Response()}
+		test.Response.Response.Header.Set("Content-Type", "text/plain; charset="+tc.charset)
+		if err := (UTF8Encoded{Transcode: true}).Execute(test); err != nil {
+			t.Errorf("%s: unexpected error %s", tc.charset, err)
+		}
+		if test.Response.BodyStr != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.charset, test.Response.BodyStr, tc.want)
+		}
+	}
+
+	test := &Test{Response: Response{BodyStr: "abc\xffdef"}}
+	err := UTF8Encoded{}.Execute(test)
+	if err == nil || !strings.Contains(err.Error(), "byte offset 3") {
+		t.Errorf("Got %v", err)
+	}
 }
 
 func TestUTF8Encoded(t *testing.T) {
@@ -68,6 +166,7 @@ var sortedTests = []TC{
 	{srb, &Sorted{Text: []string{"xxx", "yyy", "2", "??"}, AllowedMisses: 2}, someError},
 	{srb, &Sorted{Text: []string{"xxx", "yyy", "zzz", "??"}, AllowedMisses: 4}, prepareError},
 	{srb, &Sorted{Text: []string{"1"}}, prepareError},
+	{srb, &Sorted{Text: []string{"1", "2"}, Order: "up"}, prepareError},
 	{srbh, &Sorted{Text: []string{"Foo", "Bar", "Waz"}}, nil},
 	{srbh, &Sorted{Text: []string{"Interwordemphasis", "Some important things", "Waz", "Three"}}, nil},
 }
@@ -77,3 +176,45 @@ func TestSorted(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+var srl = Response{BodyStr: "apple\nbanana\nbanana\ncherry\n"}
+var srn = Response{BodyStr: "9\r\n10\r\n100\r\n"}
+var srj = Response{BodyStr: `{"items": [{"id": 3, "name": "C"}, {"id": 2, "name": "B"}, {"id": 2, "name": "A"}], "tags": ["a", "b", "d"]}`}
+var sra = Response{BodyStr: `[2, 10, 30.5]`}
+var srw = Response{BodyStr: `{"rows": [{"cells": [1, 2]}, {"cells": [3]}, {"cells": []},
+    {"cells": [4, 5, 6, 7, 8, 9, 10, 11, 12]}], "empty": [], "deep": [[1], [2]]}`}
+
+var sortedListTests = []TC{
+	{srl, &Sorted{}, nil},
+	{srl, &Sorted{Order: "asc"}, nil},
+	{srl, &Sorted{Unique: true}, fmt.Errorf(`element 2 "banana" is a duplicate`)},
+	{srl, &Sorted{Order: "desc"}, fmt.Errorf(`element 1 "banana" not in descending order after "apple"`)},
+	{srn, &Sorted{}, fmt.Errorf(`element 1 "10" not in ascending order after "9"`)},
+	{srn, &Sorted{Numeric: true, Unique: true}, nil},
+	{srl, &Sorted{Numeric: true}, fmt.Errorf(`element 0 "apple" is not a number`)},
+	{srj, &Sorted{Path: "tags", Unique: true}, nil},
+	{srj, &Sorted{Path: "tags", Order: "desc"}, someError},
+	{srj, &Sorted{Path: "items.*.id", Order: "desc", Numeric: true}, nil},
+	{srj, &Sorted{Path: "items.*.id", Order: "desc", Numeric: true, Unique: true},
+		fmt.Errorf(`element 2 "2" is a duplicate`)},
+	{srj, &Sorted{Path: "items.*.name", Order: "desc"}, nil},
+	{srj, &Sorted{Path: "items.*.name"}, someError},
+	{srj, &Sorted{Path: "items"}, someError},
+	{srj, &Sorted{Path: "foo"}, fmt.Errorf("element foo not found")},
+	{sra, &Sorted{Path: ".", Numeric: true}, nil},
+	{sra, &Sorted{Path: "."}, fmt.Errorf(`element 1 "10" not in ascending order after "2"`)},
+	{Response{BodyStr: "[1,"}, &Sorted{Path: "."}, someError},
+	{srw, &Sorted{Path: "rows.*.cells.*", Numeric: true, Unique: true}, nil},
+	{srw, &Sorted{Path: "rows.3.cells", Numeric: true}, nil},
+	{srw, &Sorted{Path: "rows.3.cells"}, fmt.Errorf(`element 6 "10" not in ascending order after "9"`)},
+	{srw, &Sorted{Path: "empty"}, nil},
+	{srw, &Sorted{Path: "rows.0.cells.1"}, fmt.Errorf("element rows.0.cells.1 is not an array")},
+	{srw, &Sorted{Path: "deep"}, fmt.Errorf("elements of deep are not all scalars")},
+	{srw, &Sorted{Path: "deep.*.*", Numeric: true}, nil},
+}
+
+func TestSortedList(t *testing.T) {
+	for i, tc := range sortedListTests {
+		runTest(t, i, tc)
+	}
+}
@@ -4,11 +4,21 @@
 
 package ht
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/populate"
+)
 
 func init() {
 	RegisterCheck(AnyOne{})
 	RegisterCheck(None{})
+	RegisterCheck(&When{})
+	RegisterCheck(&IfThenElse{})
+	RegisterCheck(&Warn{})
 }
 
 // Boolean combinations of Checks
@@ -44,6 +54,9 @@ func (a AnyOne) Prepare() error {
 	return errs
 }
 
+// InspectsBody implements BodyInspector by forwarding to the underlying checks.
+func (a AnyOne) InspectsBody() bool { return mightInspectBody(a.Of) }
+
 // Execute implements Check's Execute method. It executes the underlying checks
 // until the first passes. If all underlying checks fail the whole list of
 // failures is returned.
@@ -90,6 +103,9 @@ func (n None) Prepare() error {
 	return errs
 }
 
+// InspectsBody implements BodyInspector by forwarding to the underlying checks.
+func (n None) InspectsBody() bool { return mightInspectBody(n.Of) }
+
 // Execute implements Check's Execute method. It executes the underlying checks
 // until the first passes. If all underlying checks fail the whole list of
 // failures is returned.
@@ -102,3 +118,264 @@ func (n None) Execute(t *Test) error {
 	}
 	return nil
 }
+
+// When executes the Then checks only if the Condition holds for the
+// selected Field of the response. Otherwise the check is reported as
+// Skipped (and counts as not passing if used inside AnyOne or None).
+// Example (in JSON5 notation) to check the body schema of successful
+// responses only:
+//     {
+//         Check: "When", Field: "StatusCode", Equals: "200", Then: [
+//             {Check: "JSON", Element: "id"},
+//         ]
+//     }
+type When struct {
+	// Field of the response the Condition is applied to:
+	//     StatusCode     the numerical status code, e.g. "404" (default)
+	//     Status         the status line, e.g. "404 Not Found"
+	//     Proto          the protocol, e.g. "HTTP/1.1"
+	//     Header.<Name>  the first value of the header <Name>
+	//     Body           the response body
+	Field string `json:",omitempty"`
+
+	// Condition the Field must fulfill for Then to be executed.
+	Condition
+
+	// Then are the checks executed if the Condition holds.
+	Then CheckList
+}
+
+// Prepare implements Checks' Prepare method by forwarding to
+// the underlying checks.
+func (w *When) Prepare() error {
+	switch {
+	case w.Field == "", w.Field == "StatusCode", w.Field == "Status",
+		w.Field == "Proto", w.Field == "Body":
+	case strings.HasPrefix(w.Field, "Header.") && len(w.Field) > 7:
+	default:
+		return MalformedCheck{fmt.Errorf("unknown field %q", w.Field)}
+	}
+	if err := w.Condition.Compile(); err != nil {
+		return MalformedCheck{err}
+	}
+	errs := ErrorList{}
+	for _, c := range w.Then {
+		if err := c.Prepare(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// InspectsBody implements BodyInspector: When inspects the body if its
+// Field is the Body or if any of the Then checks does.
+func (w *When) InspectsBody() bool {
+	return w.Field == "Body" || mightInspectBody(w.Then)
+}
+
+// Execute implements Check's Execute method. It returns ErrSkipped if the
+// Condition does not hold and the list of failing checks otherwise.
+func (w *When) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return ErrSkipped
+	}
+	var value string
+	switch {
+	case w.Field == "" || w.Field == "StatusCode":
+		value = strconv.Itoa(t.Response.Response.StatusCode)
+	case w.Field == "Status":
+		value = t.Response.Response.Status
+	case w.Field == "Proto":
+		value = t.Response.Response.Proto
+	case w.Field == "Body":
+		if t.Response.BodyErr != nil {
+			return ErrBadBody
+		}
+		value = t.Response.BodyStr
+	default:
+		value = t.Response.Response.Header.Get(w.Field[len("Header."):])
+	}
+	if w.Condition.Fulfilled(value) != nil {
+		return ErrSkipped
+	}
+	return executeAll(w.Then, t)
+}
+
+// IfThenElse executes the Then checks if the If check passes and the
+// Else checks otherwise.
+// Example (in JSON5 notation) to check the Location of redirects and the
+// body of all other responses:
+//     {
+//         Check: "IfThenElse",
+//         If: {Check: "StatusCode", Expect: 3},
+//         Then: [ {Check: "Header", Header: "Location", Prefix: "https://"} ],
+//         Else: [ {Check: "Body", Contains: "Welcome"} ],
+//     }
+type IfThenElse struct {
+	// If is the check which selects the branch.
+	If Check
+
+	// Then and Else are the checks executed if If passes or fails.
+	Then, Else CheckList
+}
+
+// Prepare implements Checks' Prepare method by forwarding to the
+// If check and the checks of both branches.
+func (c *IfThenElse) Prepare() error {
+	if c.If == nil {
+		return MalformedCheck{fmt.Errorf("missing If")}
+	}
+	errs := ErrorList{}
+	for _, ck := range append(append(CheckList{c.If}, c.Then...), c.Else...) {
+		if err := ck.Prepare(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// InspectsBody implements BodyInspector by forwarding to the If check
+// and the checks of both branches.
+func (c *IfThenElse) InspectsBody() bool {
+	return mightInspectBody(append(append(CheckList{c.If}, c.Then...), c.Else...))
+}
+
+// Execute implements Check's Execute method. It executes If and
+// returns the failures of the checks in the selected branch.
+func (c *IfThenElse) Execute(t *Test) error {
+	if c.If.Execute(t) == nil {
+		return executeAll(c.Then, t)
+	}
+	return executeAll(c.Else, t)
+}
+
+// Populate implements populate.Populator as the If check must be
+// constructed from its name like the checks in a CheckList.
+func (c *IfThenElse) Populate(src interface{}) error {
+	m, ok := src.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("ht: cannot construct IfThenElse from %T", src)
+	}
+	branches := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "If" {
+			branches[k] = v
+		}
+	}
+	x := struct{ Then, Else CheckList }{}
+	if err := populate.Strict(&x, branches); err != nil {
+		return err
+	}
+	c.Then, c.Else = x.Then, x.Else
+	c.If = nil
+	if ifSrc, ok := m["If"]; ok {
+		cond := CheckList{}
+		if err := cond.Populate([]interface{}{ifSrc}); err != nil {
+			return fmt.Errorf("If: %s", err)
+		}
+		c.If = cond[0]
+	}
+	return nil
+}
+
+// MarshalJSON produces the JSON serialization of c including the name
+// of the If check.
+func (c IfThenElse) MarshalJSON() ([]byte, error) {
+	x := struct {
+		If         json.RawMessage
+		Then, Else CheckList `json:",omitempty"`
+	}{If: json.RawMessage("null"), Then: c.Then, Else: c.Else}
+	if c.If != nil {
+		cond, err := CheckList{c.If}.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		x.If = cond[1 : len(cond)-1]
+	}
+	return json.Marshal(x)
+}
+
+// Warn executes the checks Of but reports their failures as warnings only:
+// Warn itself passes and does not change the status of the test; the
+// failures of the checks are reported as warnings of the Warn check.
+// Malformed checks are still reported as bogus.
+// Example (in JSON5 notation) to just warn about a changed rendering
+// while a wrong status code still fails the test:
+//     {
+//         Check: "Warn", Of: [
+//             {Check: "Screenshot", Expected: "home.png", AllowedDifference: 5},
+//         ]
+//     }
+type Warn struct {
+	// Of is the list of checks to execute.
+	Of CheckList
+}
+
+// Cost implements Coster: Warn is as expensive as its most expensive check.
+func (w *Warn) Cost() int {
+	cost := CostCheap
+	for _, c := range w.Of {
+		if cc := CheckCost(c); cc > cost {
+			cost = cc
+		}
+	}
+	return cost
+}
+
+// Prepare implements Checks' Prepare method by forwarding to
+// the underlying checks.
+func (w *Warn) Prepare() error {
+	errs := ErrorList{}
+	for _, c := range w.Of {
+		if err := c.Prepare(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// InspectsBody implements BodyInspector by forwarding to the underlying checks.
+func (w *Warn) InspectsBody() bool { return mightInspectBody(w.Of) }
+
+// Execute implements Check's Execute method. It executes all underlying
+// checks and returns their failures wrapped in a Warning.
+func (w *Warn) Execute(t *Test) error {
+	errs := ErrorList{}
+	for _, c := range w.Of {
+		err := c.Execute(t)
+		if err == nil || err == ErrSkipped {
+			continue
+		}
+		if _, ok := err.(MalformedCheck); ok {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return Warning{errs}
+}
+
+// executeAll executes all checks and returns the list of failures.
+func executeAll(checks CheckList, t *Test) error {
+	errs := ErrorList{}
+	for _, c := range checks {
+		if err := c.Execute(t); err != nil && err != ErrSkipped {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -6,6 +6,8 @@ package ht
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -83,3 +85,194 @@ func TestNone(t *testing.T) {
 		}
 	}
 }
+
+func TestWhen(t *testing.T) {
+	ok := Response{
+		Response: &http.Response{
+			StatusCode: 200,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": {"application/json"}},
+		},
+		BodyStr: `{"id": 7}`,
+	}
+	notFound := Response{
+		Response: &http.Response{StatusCode: 404, Status: "404 Not Found"},
+		BodyStr:  `not found`,
+	}
+	hasID := CheckList{&JSON{Element: "id"}}
+
+	for i, tc := range []TC{
+		{ok, &When{Condition: Condition{Equals: "200"}, Then: hasID}, nil},
+		{notFound, &When{Condition: Condition{Equals: "200"}, Then: hasID}, ErrSkipped},
+		{notFound, &When{Condition: Condition{Prefix: "4"}, Then: hasID}, someError},
+		{ok, &When{Field: "Header.Content-Type", Condition: Condition{Contains: "json"},
+			Then: hasID}, nil},
+		{notFound, &When{Field: "Status", Condition: Condition{Contains: "Not Found"},
+			Then: CheckList{&Body{Equals: "not found"}}}, nil},
+		{ok, &When{Field: "Body", Condition: Condition{Contains: "error"},
+			Then: CheckList{&Body{Equals: "never"}}}, ErrSkipped},
+		{ok, &When{Field: "Cookie"}, prepareError},
+		{ok, &When{Condition: Condition{Regexp: "[a-"}}, prepareError},
+	} {
+		runTest(t, i, tc)
+	}
+}
+
+func TestWhenReportsSkipped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "gone", http.StatusGone)
+		}))
+	defer ts.Close()
+
+	test := &Test{
+		Request: Request{URL: ts.URL},
+		Checks: CheckList{
+			&When{Condition: Condition{Equals: "200"},
+				Then: CheckList{&Body{Contains: "success"}}},
+			&When{Condition: Condition{Equals: "410"},
+				Then: CheckList{&Body{Contains: "gone"}}},
+		},
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Errorf("Got status %s, error %v", test.Status, test.Error)
+	}
+	if len(test.CheckResults) != 2 ||
+		test.CheckResults[0].Status != Skipped ||
+		test.CheckResults[1].Status != Pass {
+		t.Errorf("Got %v", test.CheckResults)
+	}
+}
+
+func TestWhenPopulate(t *testing.T) {
+	src := []interface{}{
+		map[string]interface{}{
+			"Check": "When", "Field": "StatusCode", "Prefix": "2",
+			"Then": []interface{}{
+				map[string]interface{}{"Check": "Body", "Contains": "ok"},
+			},
+		},
+	}
+	cl := CheckList{}
+	if err := cl.Populate(src); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	w, ok := cl[0].(*When)
+	if !ok || w.Prefix != "2" || len(w.Then) != 1 {
+		t.Errorf("Got %#v", cl[0])
+	}
+}
+
+func TestIfThenElse(t *testing.T) {
+	redirect := Response{
+		Response: &http.Response{
+			StatusCode: 302,
+			Header:     http.Header{"Location": {"https://example.org/"}},
+		},
+	}
+	page := Response{
+		Response: &http.Response{StatusCode: 200},
+		BodyStr:  "Welcome",
+	}
+	check := func() *IfThenElse {
+		return &IfThenElse{
+			If:   StatusCode{Expect: 3},
+			Then: CheckList{&Header{Header: "Location", Condition: Condition{Prefix: "https://"}}},
+			Else: CheckList{&Body{Contains: "Welcome"}},
+		}
+	}
+
+	for i, tc := range []TC{
+		{redirect, check(), nil},
+		{page, check(), nil},
+		{Response{Response: &http.Response{StatusCode: 200}, BodyStr: "Bye"},
+			check(), someError},
+		{Response{Response: &http.Response{StatusCode: 301, Header: http.Header{}}},
+			check(), someError},
+		{page, &IfThenElse{If: StatusCode{Expect: 3}}, nil},
+		{page, &IfThenElse{Then: CheckList{&Body{}}}, prepareError},
+		{page, &IfThenElse{If: &Body{Regexp: "[a-"}}, prepareError},
+	} {
+		runTest(t, i, tc)
+	}
+}
+
+func TestIfThenElseSerialization(t *testing.T) {
+	src := []interface{}{
+		map[string]interface{}{
+			"Check": "IfThenElse",
+			"If":    map[string]interface{}{"Check": "StatusCode", "Expect": 3},
+			"Then": []interface{}{
+				map[string]interface{}{"Check": "Header", "Header": "Location"},
+			},
+			"Else": []interface{}{
+				map[string]interface{}{"Check": "Body", "Contains": "Welcome"},
+			},
+		},
+	}
+	cl := CheckList{}
+	if err := cl.Populate(src); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ite, ok := cl[0].(*IfThenElse)
+	if !ok || len(ite.Then) != 1 || len(ite.Else) != 1 {
+		t.Fatalf("Got %#v", cl[0])
+	}
+	if sc, ok := ite.If.(*StatusCode); !ok || sc.Expect != 3 {
+		t.Errorf("Got If %#v", ite.If)
+	}
+
+	data, err := cl.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := `[{"Check":"IfThenElse","If":{"Check":"StatusCode","Expect":3},` +
+		`"Then":[{"Check":"Header","Header":"Location"}],` +
+		`"Else":[{"Check":"Body","Contains":"Welcome"}]}]`
+	if string(data) != want {
+		t.Errorf("Got  %s\nWant %s", data, want)
+	}
+}
+
+func TestWarn(t *testing.T) {
+	page := Response{Response: &http.Response{StatusCode: 200}, BodyStr: "Welcome"}
+	for i, tc := range []TC{
+		{page, &Warn{Of: CheckList{&Body{Contains: "Welcome"}}}, nil},
+		{page, &Warn{Of: CheckList{&Body{Contains: "Bye"}, StatusCode{Expect: 404}}},
+			Warning{ErrorList{ErrNotFound, errorString("got 200, want 404")}}},
+		{page, &Warn{Of: CheckList{&Body{Regexp: "[a-"}}}, prepareError},
+	} {
+		runTest(t, i, tc)
+	}
+}
+
+func TestWarnDoesNotFail(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "Welcome")
+		}))
+	defer ts.Close()
+
+	test := &Test{
+		Request: Request{URL: ts.URL},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			&Warn{Of: CheckList{&Body{Contains: "Bye"}}},
+		},
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Errorf("Got status %s, error %v", test.Status, test.Error)
+	}
+	if cr := test.CheckResults[1]; cr.Status != Pass || len(cr.Warning) != 1 ||
+		cr.Warning[0] != ErrNotFound {
+		t.Errorf("Got %+v", cr)
+	}
+
+	test.Checks[0] = StatusCode{Expect: 404}
+	test.Run()
+	if test.Status != Fail || len(test.CheckResults[1].Warning) != 1 {
+		t.Errorf("Got status %s, warnings %v", test.Status, test.CheckResults[1].Warning)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// caching.go contains a check of the caching related headers.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCheck(&Caching{})
+}
+
+// ----------------------------------------------------------------------------
+// Caching
+
+// Caching checks the Cache-Control, Expires, ETag and Last-Modified headers
+// of a response. Independent of the fields set the headers are checked for
+// coherence: Malformed or contradicting directives (like no-store together
+// with a positive max-age), unparsable dates, Last-Modified dates in the
+// future and malformed ETags are reported. Example:
+//     {
+//         Check: "Caching"
+//         MinMaxAge: "5m"
+//         MaxMaxAge: "1h"
+//         ETag: true
+//         Revalidate: true
+//     }
+// All violations are reported.
+type Caching struct {
+	// MinMaxAge and MaxMaxAge are the inclusive bounds of the max-age
+	// directive of the Cache-Control header. If either is non-zero
+	// the max-age directive is required.
+	MinMaxAge time.Duration `json:",omitempty"`
+	MaxMaxAge time.Duration `json:",omitempty"`
+
+	// NoStore requires the no-store directive, e.g. for responses with
+	// sensitive data.
+	NoStore bool `json:",omitempty"`
+
+	// ETag and LastModified require the respective validator.
+	ETag         bool `json:",omitempty"`
+	LastModified bool `json:",omitempty"`
+
+	// Revalidate makes a conditional GET (or HEAD for HEAD requests)
+	// request with If-None-Match (or If-Modified-Since if no ETag was
+	// received) which must be answered with 304 Not Modified.
+	Revalidate bool `json:",omitempty"`
+}
+
+// Cost implements Coster: Revalidate makes an additional request.
+func (c Caching) Cost() int {
+	if c.Revalidate {
+		return CostExpensive
+	}
+	return CostCheap
+}
+
+// Prepare implements Check's Prepare method.
+func (c *Caching) Prepare() error {
+	if c.MinMaxAge < 0 || c.MaxMaxAge < 0 {
+		return MalformedCheck{errors.New("negative max-age bound")}
+	}
+	if c.MaxMaxAge > 0 && c.MinMaxAge > c.MaxMaxAge {
+		return MalformedCheck{errors.New("MinMaxAge > MaxMaxAge")}
+	}
+	if c.NoStore && c.MinMaxAge > 0 {
+		return MalformedCheck{errors.New("NoStore contradicts MinMaxAge")}
+	}
+	return nil
+}
+
+// Execute implements Check's Execute method.
+func (c *Caching) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	header := t.Response.Response.Header
+	errs := ErrorList{}
+
+	directives, err := parseCacheControl(header["Cache-Control"])
+	if err != nil {
+		errs = append(errs, err)
+	}
+	maxAge, hasMaxAge := -1, false
+	if v, ok := directives["max-age"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("malformed max-age=%s", v))
+		} else {
+			maxAge, hasMaxAge = n, true
+		}
+	}
+	_, noStore := directives["no-store"]
+	if noStore && maxAge > 0 {
+		errs = append(errs, fmt.Errorf("no-store contradicts max-age=%d", maxAge))
+	}
+	if _, public := directives["public"]; public {
+		if _, private := directives["private"]; private {
+			errs = append(errs, errors.New("public contradicts private"))
+		}
+	}
+
+	if c.MinMaxAge > 0 || c.MaxMaxAge > 0 {
+		age := time.Duration(maxAge) * time.Second
+		switch {
+		case !hasMaxAge:
+			errs = append(errs, errors.New("no max-age directive"))
+		case age < c.MinMaxAge:
+			errs = append(errs, fmt.Errorf("max-age=%d shorter than %s", maxAge, c.MinMaxAge))
+		case c.MaxMaxAge > 0 && age > c.MaxMaxAge:
+			errs = append(errs, fmt.Errorf("max-age=%d longer than %s", maxAge, c.MaxMaxAge))
+		}
+	}
+	if c.NoStore && !noStore {
+		errs = append(errs, errors.New("no no-store directive"))
+	}
+
+	if v := header.Get("Expires"); v != "" && v != "0" {
+		if _, err := http.ParseTime(v); err != nil {
+			errs = append(errs, fmt.Errorf("malformed Expires %q", v))
+		}
+	}
+
+	lastModified := header.Get("Last-Modified")
+	if lastModified != "" {
+		lm, err := http.ParseTime(lastModified)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("malformed Last-Modified %q", lastModified))
+		} else if lm.After(time.Now().Add(time.Minute)) {
+			errs = append(errs, fmt.Errorf("Last-Modified %s in the future", lastModified))
+		}
+	} else if c.LastModified {
+		errs = append(errs, errors.New("no Last-Modified header"))
+	}
+
+	etag := header.Get("ETag")
+	if etag != "" {
+		if !validETag(etag) {
+			errs = append(errs, fmt.Errorf("malformed ETag %s", etag))
+		}
+	} else if c.ETag {
+		errs = append(errs, errors.New("no ETag header"))
+	}
+
+	if c.Revalidate {
+		if err := c.revalidate(t, etag, lastModified); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// revalidate makes a conditional request for the resource of t. This is a
+// HEAD request if t was one and a GET request otherwise as conditional
+// requests with other methods have different semantics.
+func (c *Caching) revalidate(t *Test, etag, lastModified string) error {
+	method := "GET"
+	if t.Request.Method == "HEAD" {
+		method = "HEAD"
+	}
+	cond := t.followUp("Revalidation", method, t.Request.Request.URL.String())
+	switch {
+	case etag != "":
+		cond.Request.Header.Set("If-None-Match", etag)
+	case lastModified != "":
+		cond.Request.Header.Set("If-Modified-Since", lastModified)
+	default:
+		return errors.New("cannot revalidate without ETag or Last-Modified")
+	}
+	cond.RunContext(t.context())
+	if cond.Response.Response == nil {
+		return fmt.Errorf("revalidation failed: %s %s", cond.Status, cond.Error)
+	}
+	if sc := cond.Response.Response.StatusCode; sc != http.StatusNotModified {
+		return fmt.Errorf("revalidation got status code %d, want 304", sc)
+	}
+	return nil
+}
+
+// parseCacheControl parses the directives of the Cache-Control headers.
+// Directive names are lowercased, quotes are removed from values.
+func parseCacheControl(values []string) (map[string]string, error) {
+	directives := make(map[string]string)
+	for _, value := range values {
+		for _, d := range strings.Split(value, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, val := d, ""
+			if i := strings.Index(d, "="); i != -1 {
+				name, val = strings.TrimSpace(d[:i]), strings.Trim(strings.TrimSpace(d[i+1:]), `"`)
+			}
+			name = strings.ToLower(name)
+			if old, ok := directives[name]; ok && old != val {
+				return directives, fmt.Errorf("conflicting Cache-Control directives %s", name)
+			}
+			directives[name] = val
+		}
+	}
+	return directives, nil
+}
+
+// validETag reports whether etag is a (possibly weak) quoted entity tag.
+func validETag(etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	return len(etag) >= 2 && etag[0] == '"' && etag[len(etag)-1] == '"' &&
+		!strings.Contains(etag[1:len(etag)-1], `"`)
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func cachingResponse(header ...string) Response {
+	h := http.Header{}
+	for i := 0; i < len(header); i += 2 {
+		h.Add(header[i], header[i+1])
+	}
+	return Response{Response: &http.Response{StatusCode: 200, Header: h}}
+}
+
+var cachingTests = []TC{
+	{cachingResponse("Cache-Control", "public, max-age=600"),
+		&Caching{MinMaxAge: 5 * time.Minute, MaxMaxAge: time.Hour}, nil},
+	{cachingResponse("Cache-Control", "max-age=60"),
+		&Caching{MinMaxAge: 5 * time.Minute},
+		fmt.Errorf("max-age=60 shorter than 5m0s")},
+	{cachingResponse("Cache-Control", "max-age=7200"),
+		&Caching{MaxMaxAge: time.Hour},
+		fmt.Errorf("max-age=7200 longer than 1h0m0s")},
+	{cachingResponse("Cache-Control", "no-cache"),
+		&Caching{MaxMaxAge: time.Hour}, fmt.Errorf("no max-age directive")},
+	{cachingResponse("Cache-Control", "no-store"), &Caching{NoStore: true}, nil},
+	{cachingResponse("Cache-Control", "private"), &Caching{NoStore: true},
+		fmt.Errorf("no no-store directive")},
+	{cachingResponse("Cache-Control", "no-store, max-age=60"), &Caching{},
+		fmt.Errorf("no-store contradicts max-age=60")},
+	{cachingResponse("Cache-Control", "max-age=abc"), &Caching{},
+		fmt.Errorf("malformed max-age=abc")},
+	{cachingResponse("Cache-Control", "max-age=60", "Cache-Control", "max-age=120"),
+		&Caching{}, fmt.Errorf("conflicting Cache-Control directives max-age")},
+	{cachingResponse("Expires", "tomorrow"), &Caching{},
+		fmt.Errorf(`malformed Expires "tomorrow"`)},
+	{cachingResponse("Expires", "0"), &Caching{}, nil},
+	{cachingResponse("ETag", `W/"abc"`, "Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT"),
+		&Caching{ETag: true, LastModified: true}, nil},
+	{cachingResponse("ETag", `abc`), &Caching{}, fmt.Errorf("malformed ETag abc")},
+	{cachingResponse(), &Caching{ETag: true, LastModified: true},
+		ErrorList{fmt.Errorf("no Last-Modified header"), fmt.Errorf("no ETag header")}},
+	{cachingResponse("Last-Modified", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)),
+		&Caching{}, someError},
+	{cachingResponse(), &Caching{MinMaxAge: time.Hour, MaxMaxAge: time.Minute}, prepareError},
+	{cachingResponse(), &Caching{NoStore: true, MinMaxAge: time.Hour}, prepareError},
+}
+
+func TestCaching(t *testing.T) {
+	for i, tc := range cachingTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestCachingRevalidate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			if r.URL.Path == "/etag" {
+				w.Header().Set("ETag", `"v1"`)
+			}
+			if r.Header.Get("If-None-Match") == `"v1"` && r.Method == "GET" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fmt.Fprint(w, "content")
+		}))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		method, path string
+		want         Status
+	}{
+		{"GET", "/etag", Pass},
+		{"GET", "/none", Fail},
+		{"POST", "/etag", Pass}, // revalidated with GET
+	} {
+		test := &Test{
+			Request: Request{Method: tc.method, URL: ts.URL + tc.path},
+			Checks:  CheckList{&Caching{Revalidate: true}},
+		}
+		test.Run()
+		if test.Status != tc.want {
+			t.Errorf("%d. Got %s, want %s: %v", i, test.Status, tc.want, test.Error)
+		}
+	}
+}
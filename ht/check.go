@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/vdobler/ht/populate"
 )
@@ -24,6 +25,69 @@ type Check interface {
 	Execute(*Test) error
 }
 
+// Costs of executing a check as returned by CheckCost.
+const (
+	CostCheap     = 0  // Cheap checks operate on the received response only.
+	CostExpensive = 10 // Expensive checks make requests or run browsers.
+)
+
+// Coster is the interface implemented by checks which declare the cost
+// of their execution. Checks not implementing Coster are cheap.
+type Coster interface {
+	Cost() int
+}
+
+// CheckCost returns the cost of executing check.
+func CheckCost(check Check) int {
+	if c, ok := check.(Coster); ok {
+		return c.Cost()
+	}
+	return CostCheap
+}
+
+// BodyInspector is the interface implemented by checks which declare
+// whether they inspect the response body. Checks inspecting the body are
+// skipped for HEAD and OPTIONS requests and the body is not read if no
+// check needs it. Checks not implementing BodyInspector are never skipped
+// and always get the body.
+type BodyInspector interface {
+	InspectsBody() bool
+}
+
+// inspectsBody reports whether check inspects the response body and
+// whether this is known at all, i.e. whether check is a BodyInspector.
+func inspectsBody(check Check) (inspects bool, known bool) {
+	if bi, ok := check.(BodyInspector); ok {
+		return bi.InspectsBody(), true
+	}
+	return false, false
+}
+
+// mightInspectBody reports whether any of the checks in cl inspects or
+// might inspect the response body.
+func mightInspectBody(cl CheckList) bool {
+	for _, ck := range cl {
+		if inspects, known := inspectsBody(ck); inspects || !known {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrder returns the indices of the checks in cl in the order of
+// their execution: Ordered by cost keeping the given order for checks
+// of equal cost.
+func checkOrder(cl CheckList) []int {
+	order := make([]int, len(cl))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return CheckCost(cl[order[a]]) < CheckCost(cl[order[b]])
+	})
+	return order
+}
+
 // NameOf returns the name of the type of inst.
 func NameOf(inst interface{}) string {
 	typ := reflect.TypeOf(inst)
@@ -71,6 +135,15 @@ var (
 
 	// ErrFailed is returned by a checks failing unspecificly.
 	ErrFailed = errors.New("failed")
+
+	// ErrSkipped is returned by checks which do not apply to the
+	// response, e.g. a When check whose condition does not hold.
+	// Such checks are reported as Skipped instead of failed.
+	ErrSkipped = errors.New("skipped")
+
+	// ErrDeadlineExceeded is the error of a test which could not be
+	// executed (completely) before its Deadline.
+	ErrDeadlineExceeded = errors.New("deadline exceeded")
 )
 
 // CantCheck is the error type returned by checks whose preconditions
@@ -103,6 +176,16 @@ func (m MalformedCheck) Error() string {
 	return fmt.Sprintf("malformed check: %s", m.Err.Error())
 }
 
+// Warning is the error type returned by checks whose failures must not
+// fail the test but are reported as warnings only, see Warn.
+type Warning struct {
+	Err error
+}
+
+func (w Warning) Error() string {
+	return fmt.Sprintf("warning: %s", w.Err.Error())
+}
+
 // ----------------------------------------------------------------------------
 // CheckList
 
@@ -177,7 +260,12 @@ func (cl *CheckList) Populate(src interface{}) error {
 			typ = typ.Elem()
 		}
 		rcheck := reflect.New(typ)
-		err = populate.Strict(rcheck.Interface(), raw[i])
+		if p, ok := rcheck.Interface().(populate.Populator); ok {
+			// Checks may control their own construction.
+			err = p.Populate(raw[i])
+		} else {
+			err = populate.Strict(rcheck.Interface(), raw[i])
+		}
 		if err != nil {
 			return fmt.Errorf("problems constructing check %d %s: %s",
 				i+1, checkName, err)
@@ -0,0 +1,106 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+func init() {
+	RegisterCheck(&Compression{})
+}
+
+// ----------------------------------------------------------------------------
+// Compression
+
+// Compression checks that a response with a compressible content type
+// (text, JSON, JavaScript, XML, SVG) is gzip compressed and, optionally,
+// how well. Responses of other content types may be uncompressed.
+//
+// The compression ratio (uncompressed size divided by compressed size) can
+// be checked only if the test explicitly sends an "Accept-Encoding: gzip"
+// header; otherwise package net/http decompresses the body transparently.
+// Example:
+//     {
+//         Check: "Compression"
+//         MinRatio: 3
+//     }
+type Compression struct {
+	// MinRatio is the minimal compression ratio. Zero disables checking
+	// the ratio.
+	MinRatio float64 `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector.
+func (*Compression) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (c *Compression) Execute(t *Test) error {
+	resp := t.Response.Response
+	if resp == nil {
+		return errors.New("no response to check")
+	}
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+
+	encoding := resp.Header.Get("Content-Encoding")
+	if resp.Uncompressed {
+		encoding = "gzip" // Decompressed by package net/http.
+	}
+	switch encoding {
+	case "", "identity":
+		ct := resp.Header.Get("Content-Type")
+		if isCompressible(ct) {
+			return fmt.Errorf("compressible content type %q sent uncompressed", ct)
+		}
+		return nil
+	case "gzip":
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+
+	if c.MinRatio == 0 {
+		return nil
+	}
+	if resp.Uncompressed || t.Response.WireSize == 0 {
+		return errors.New("compressed size unknown, send Accept-Encoding: gzip explicitly")
+	}
+	ratio := float64(len(t.Response.BodyStr)) / float64(t.Response.WireSize)
+	if ratio < c.MinRatio {
+		return fmt.Errorf("compression ratio %.2f (%d to %d bytes), want at least %.2f",
+			ratio, len(t.Response.BodyStr), t.Response.WireSize, c.MinRatio)
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *Compression) Prepare() error {
+	if c.MinRatio < 0 {
+		return MalformedCheck{errors.New("negative MinRatio")}
+	}
+	return nil
+}
+
+// isCompressible reports whether the content type ct is worth compressing.
+func isCompressible(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mt, "text/") ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+	switch mt {
+	case "application/json", "application/javascript", "application/x-javascript",
+		"application/ecmascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
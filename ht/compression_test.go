@@ -0,0 +1,46 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func compressedResponse(ct, encoding string, size, wire int) Response {
+	header := http.Header{"Content-Type": {ct}}
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	return Response{
+		Response: &http.Response{StatusCode: 200, Header: header},
+		BodyStr:  strings.Repeat("x", size),
+		WireSize: int64(wire),
+	}
+}
+
+var compressionTests = []TC{
+	{compressedResponse("text/html; charset=utf-8", "gzip", 1000, 200), &Compression{}, nil},
+	{compressedResponse("text/html; charset=utf-8", "", 1000, 1000), &Compression{}, someError},
+	{compressedResponse("application/json", "identity", 1000, 1000), &Compression{}, someError},
+	{compressedResponse("application/hal+json", "", 1000, 1000), &Compression{}, someError},
+	{compressedResponse("image/png", "", 1000, 1000), &Compression{}, nil},
+	{compressedResponse("text/css", "br", 1000, 200), &Compression{}, someError},
+	{compressedResponse("text/css", "gzip", 1000, 200), &Compression{MinRatio: 4}, nil},
+	{compressedResponse("text/css", "gzip", 1000, 400), &Compression{MinRatio: 4}, someError},
+	{compressedResponse("text/css", "gzip", 1000, 0), &Compression{MinRatio: 4}, someError},
+	{Response{Response: &http.Response{StatusCode: 200, Header: http.Header{},
+		Uncompressed: true}}, &Compression{}, nil},
+	{Response{Response: &http.Response{StatusCode: 200, Header: http.Header{},
+		Uncompressed: true}}, &Compression{MinRatio: 2}, someError},
+	{compressedResponse("text/css", "gzip", 1000, 200), &Compression{MinRatio: -1}, prepareError},
+}
+
+func TestCompression(t *testing.T) {
+	for i, tc := range compressionTests {
+		runTest(t, i, tc)
+	}
+}
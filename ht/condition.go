@@ -5,8 +5,12 @@
 package ht
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -21,6 +25,12 @@ type Condition struct {
 	// other tests would be redundant.
 	Equals string `json:",omitempty"`
 
+	// JSONEqual is a JSON document the string must be equal to
+	// semantically: Both are parsed as JSON and compared ignoring
+	// the order of keys in objects, whitespace and the formatting
+	// of numbers (e.g. 1.0, 1 and 1e0 are equal).
+	JSONEqual string `json:",omitempty"`
+
 	// Prefix is the required prefix
 	Prefix string `json:",omitempty"`
 
@@ -40,10 +50,14 @@ type Condition struct {
 	//   < 0: No match allowed (invert the condition)
 	Count int `json:",omitempty"`
 
-	// Min and Max are the minimum and maximum length the string may
-	// have. Two zero values disables this test.
+	// Min and Max are the minimum and maximum length (in bytes) the
+	// string may have. Two zero values disables this test.
 	Min, Max int `json:",omitempty"`
 
+	// Empty requires the string to be empty. As a zero Max disables
+	// the length test this is the only way to require an empty string.
+	Empty bool `json:",omitempty"`
+
 	// GreaterThan and LessThan are lower and upper bound on the numerical
 	// value of the string: The string is trimmed from spaces as well as
 	// from single and double quotes before parsed as a float64. If the
@@ -51,6 +65,16 @@ type Condition struct {
 	// Nil disables these conditions.
 	GreaterThan, LessThan *float64 `json:",omitempty"`
 
+	// IgnoreCase makes the Equals, Prefix, Suffix and Contains tests
+	// case-insensitive. Use the "(?i)" flag for a case-insensitive Regexp.
+	IgnoreCase bool `json:",omitempty"`
+
+	// Not is a condition which must not be fulfilled. It allows to
+	// negate any condition, e.g. {Not: {Regexp: "^[0-9]+$"}} is fulfilled
+	// by all strings which are not a plain decimal number.
+	// Not is checked in addition to the other tests of this Condition.
+	Not *Condition `json:",omitempty"`
+
 	re *regexp.Regexp
 }
 
@@ -58,8 +82,26 @@ type Condition struct {
 // A nil return value indicates that s matches the defined conditions.
 // A non-nil return indicates missmatch.
 func (c Condition) Fulfilled(s string) error {
-	if c.Equals != "" {
-		if s == c.Equals {
+	if c.Not != nil && c.Not.Fulfilled(s) == nil {
+		inner, err := json.Marshal(c.Not)
+		if err != nil {
+			return fmt.Errorf("unexpectedly matched Not condition")
+		}
+		return fmt.Errorf("unexpectedly matched %s", inner)
+	}
+
+	// The string fields used in the case-insensitive comparisons.
+	str, equals, prefix, suffix, contains := s, c.Equals, c.Prefix, c.Suffix, c.Contains
+	if c.IgnoreCase {
+		str = strings.ToLower(s)
+		equals = strings.ToLower(equals)
+		prefix = strings.ToLower(prefix)
+		suffix = strings.ToLower(suffix)
+		contains = strings.ToLower(contains)
+	}
+
+	if equals != "" {
+		if str == equals {
 			return nil
 		}
 		ls, le := len(s), len(c.Equals)
@@ -76,7 +118,13 @@ func (c Condition) Fulfilled(s string) error {
 		return fmt.Errorf("Unequal, was %q...", s[:end])
 	}
 
-	if c.Prefix != "" && !strings.HasPrefix(s, c.Prefix) {
+	if c.JSONEqual != "" {
+		if err := jsonEqual(s, c.JSONEqual); err != nil {
+			return err
+		}
+	}
+
+	if prefix != "" && !strings.HasPrefix(str, prefix) {
 		n := len(c.Prefix)
 		if len(s) < n {
 			n = len(s)
@@ -84,7 +132,7 @@ func (c Condition) Fulfilled(s string) error {
 		return fmt.Errorf("Bad prefix, got %q", s[:n])
 	}
 
-	if c.Suffix != "" && !strings.HasSuffix(s, c.Suffix) {
+	if suffix != "" && !strings.HasSuffix(str, suffix) {
 		n := len(c.Suffix)
 		if len(s) < n {
 			n = len(s)
@@ -92,13 +140,13 @@ func (c Condition) Fulfilled(s string) error {
 		return fmt.Errorf("Bad suffix, got %q", s[len(s)-n:])
 	}
 
-	if c.Contains != "" {
-		if c.Count == 0 && strings.Index(s, c.Contains) == -1 {
+	if contains != "" {
+		if c.Count == 0 && strings.Index(str, contains) == -1 {
 			return ErrNotFound
-		} else if c.Count < 0 && strings.Index(s, c.Contains) != -1 {
+		} else if c.Count < 0 && strings.Index(str, contains) != -1 {
 			return ErrFoundForbidden
 		} else if c.Count > 0 {
-			if cnt := strings.Count(s, c.Contains); cnt != c.Count {
+			if cnt := strings.Count(str, contains); cnt != c.Count {
 				return WrongCount{Got: cnt, Want: c.Count}
 			}
 		}
@@ -120,6 +168,10 @@ func (c Condition) Fulfilled(s string) error {
 		}
 	}
 
+	if c.Empty && s != "" {
+		return fmt.Errorf("Not empty, was %d bytes", len(s))
+	}
+
 	if c.Min > 0 {
 		if len(s) < c.Min {
 			return fmt.Errorf("Too short, was %d", len(s))
@@ -161,8 +213,19 @@ func (c Condition) FulfilledBytes(b []byte) error {
 	return c.Fulfilled(string(b))
 }
 
-// Compile pre-compiles the regular expression if part of c.
+// Compile pre-compiles the regular expression if part of c (or its Not
+// condition). It also makes sure JSONEqual is wellformed JSON.
 func (c *Condition) Compile() (err error) {
+	if c.JSONEqual != "" {
+		if _, err := decodeJSONNumbers(c.JSONEqual); err != nil {
+			return MalformedCheck{Err: fmt.Errorf("JSONEqual: %s", err)}
+		}
+	}
+	if c.Not != nil {
+		if err := c.Not.Compile(); err != nil {
+			return err
+		}
+	}
 	if c.Regexp != "" {
 		c.re, err = regexp.Compile(c.Regexp)
 		if err != nil {
@@ -172,3 +235,132 @@ func (c *Condition) Compile() (err error) {
 	}
 	return nil
 }
+
+// ----------------------------------------------------------------------------
+// Semantic comparison of JSON documents
+
+// decodeJSONNumbers decodes s keeping numbers as json.Number.
+func decodeJSONNumbers(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return v, nil
+}
+
+// jsonEqual reports whether the JSON documents got and want are semantically
+// equal. A non-nil error describes the first difference found.
+func jsonEqual(got, want string) error {
+	w, err := decodeJSONNumbers(want)
+	if err != nil {
+		return MalformedCheck{Err: fmt.Errorf("JSONEqual: %s", err)}
+	}
+	g, err := decodeJSONNumbers(got)
+	if err != nil {
+		return fmt.Errorf("not valid JSON: %s", err)
+	}
+	return jsonDiff("", g, w)
+}
+
+// jsonDiff compares got and want recursively. The path of the current
+// element is given in the same dotted notation as used in the JSON check.
+func jsonDiff(path string, got, want interface{}) error {
+	where := path
+	if where == "" {
+		where = "top level"
+	}
+	mismatch := func() error {
+		return fmt.Errorf("JSON differs at %s: got %s, want %s",
+			where, shortJSON(got), shortJSON(want))
+	}
+	join := func(elem string) string {
+		if path == "" {
+			return elem
+		}
+		return path + "." + elem
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			gv, ok := g[k]
+			if !ok {
+				return fmt.Errorf("JSON differs at %s: missing %s",
+					where, join(k))
+			}
+			if err := jsonDiff(join(k), gv, w[k]); err != nil {
+				return err
+			}
+		}
+		extra := []string{}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				extra = append(extra, k)
+			}
+		}
+		if len(extra) > 0 {
+			sort.Strings(extra)
+			return fmt.Errorf("JSON differs at %s: unexpected %s",
+				where, join(extra[0]))
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for i := 0; i < len(w) && i < len(g); i++ {
+			if err := jsonDiff(join(strconv.Itoa(i)), g[i], w[i]); err != nil {
+				return err
+			}
+		}
+		if len(g) != len(w) {
+			return fmt.Errorf("JSON differs at %s: got %d elements, want %d",
+				where, len(g), len(w))
+		}
+	case json.Number:
+		g, ok := got.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		wr, wok := new(big.Rat).SetString(string(w))
+		gr, gok := new(big.Rat).SetString(string(g))
+		if !wok || !gok || wr.Cmp(gr) != 0 {
+			return mismatch()
+		}
+	default:
+		// Strings, booleans and null.
+		if got != want {
+			return mismatch()
+		}
+	}
+	return nil
+}
+
+// shortJSON renders v as compact JSON, abbreviated if too long.
+func shortJSON(v interface{}) string {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	s := strings.TrimSpace(buf.String())
+	if len(s) > 40 {
+		s = s[:37] + "..."
+	}
+	return s
+}
@@ -5,7 +5,6 @@
 package ht
 
 import (
-	"regexp"
 	"testing"
 )
 
@@ -30,6 +29,18 @@ var conditionTests = []struct {
 	{"B", Condition{Equals: "A"}, `Unequal, was "B"`},
 	{"BB", Condition{Equals: "A"}, `Unequal, was "BB"`},
 
+	// JSONEqual
+	{`{"a": 1, "b": [1, 2]}`, Condition{JSONEqual: `{"b":[1,2],"a":1}`}, ``},
+	{`{"a": 1.0, "b": "x"}`, Condition{JSONEqual: `{"a":1,"b":"x"}`}, ``},
+	{`[1e2, true, null]`, Condition{JSONEqual: `[100, true, null]`}, ``},
+	{`{"a": {"b": [1, 3]}}`, Condition{JSONEqual: `{"a":{"b":[1,2]}}`}, `JSON differs at a.b.1: got 3, want 2`},
+	{`{"a": 1}`, Condition{JSONEqual: `{"a":1,"b":2}`}, `JSON differs at top level: missing b`},
+	{`{"a": 1, "c": 3}`, Condition{JSONEqual: `{"a":1}`}, `JSON differs at top level: unexpected c`},
+	{`{"a": [1, 2, 3]}`, Condition{JSONEqual: `{"a":[1,2]}`}, `JSON differs at a: got 3 elements, want 2`},
+	{`{"a": "1"}`, Condition{JSONEqual: `{"a":1}`}, `JSON differs at a: got "1", want 1`},
+	{`"foo"`, Condition{JSONEqual: `{"a":1}`}, `JSON differs at top level: got "foo", want {"a":1}`},
+	{`{"a": `, Condition{JSONEqual: `{"a":1}`}, `not valid JSON: unexpected EOF`},
+
 	// Prefix and Suffix
 	{"foobar", Condition{Prefix: "foo"}, ``},
 	{"foobar", Condition{Prefix: "waz"}, `Bad prefix, got "foo"`},
@@ -59,11 +70,36 @@ var conditionTests = []struct {
 	{"foobarwu", Condition{Regexp: "[aeiou].", Count: 3}, `found 2, want 3`},
 	{"foobarwu", Condition{Regexp: "[aeiou].", Count: -1}, `found forbidden`},
 	{"frtgbwu", Condition{Regexp: "[aeiou]."}, `not found`},
+	// IgnoreCase
+	{"FooBar", Condition{Equals: "foobar", IgnoreCase: true}, ``},
+	{"FooBar", Condition{Equals: "foobaz", IgnoreCase: true}, `Unequal, was "FooBar"`},
+	{"FooBar", Condition{Equals: "foobar"}, `Unequal, was "FooBar"`},
+	{"FooBar", Condition{Prefix: "FOO", IgnoreCase: true}, ``},
+	{"FooBar", Condition{Prefix: "BAR", IgnoreCase: true}, `Bad prefix, got "Foo"`},
+	{"FooBar", Condition{Suffix: "bar", IgnoreCase: true}, ``},
+	{"FooBar", Condition{Suffix: "bar"}, `Bad suffix, got "Bar"`},
+	{"FooBarFOO", Condition{Contains: "foo", Count: 2, IgnoreCase: true}, ``},
+	{"FooBarFOO", Condition{Contains: "foo", Count: 1}, `found 0, want 1`},
+	{"FooBarFOO", Condition{Contains: "BAR", Count: -1, IgnoreCase: true}, `found forbidden`},
+	{"FooBar", Condition{Regexp: "foo", IgnoreCase: true}, `not found`},
+	{"FooBar", Condition{Regexp: "(?i)foo"}, ``},
+
+	// Not
+	{"12345", Condition{Not: &Condition{Regexp: "^[0-9]+$"}}, `unexpectedly matched {"Regexp":"^[0-9]+$"}`},
+	{"123a5", Condition{Not: &Condition{Regexp: "^[0-9]+$"}}, ``},
+	{"foobar", Condition{Not: &Condition{Equals: "foobar"}}, `unexpectedly matched {"Equals":"foobar"}`},
+	{"foobar", Condition{Prefix: "foo", Not: &Condition{Suffix: "baz"}}, ``},
+	{"foobar", Condition{Prefix: "waz", Not: &Condition{Suffix: "baz"}}, `Bad prefix, got "foo"`},
+	{"foobar", Condition{Not: &Condition{Not: &Condition{Contains: "oba"}}}, ``},
+	{"3", Condition{Not: &Condition{LessThan: &float12_3}}, `unexpectedly matched {"LessThan":12.3}`},
+
 	// Min and Max
 	{"foobar", Condition{Min: 2}, ``},
 	{"foobar", Condition{Min: 20}, `Too short, was 6`},
 	{"foobar", Condition{Max: 30}, ``},
 	{"foobar", Condition{Max: 3}, `Too long, was 6`},
+	{"", Condition{Empty: true}, ``},
+	{"foobar", Condition{Empty: true}, `Not empty, was 6 bytes`},
 	// GreaterThan and LessThan
 	{"3", Condition{LessThan: &float12_3}, ``},
 	{"3", Condition{GreaterThan: &float12_3}, `not greater than 12.3, was 3`},
@@ -77,8 +113,9 @@ var conditionTests = []struct {
 
 func TestCondition(t *testing.T) {
 	for i, tc := range conditionTests {
-		if tc.c.Regexp != "" {
-			tc.c.re = regexp.MustCompile(tc.c.Regexp)
+		if err := tc.c.Compile(); err != nil {
+			t.Errorf("%d. %s, unexpected error %s during compile", i, tc.s, err)
+			continue
 		}
 		err := tc.c.Fulfilled(tc.s)
 		switch {
@@ -58,6 +58,9 @@ type SetCookie struct {
 	Type string `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: SetCookie ignores the body.
+func (SetCookie) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (c SetCookie) Execute(t *Test) error {
 	var cookie *http.Cookie
@@ -182,6 +185,9 @@ type DeleteCookie struct {
 	Domain string `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: DeleteCookie ignores the body.
+func (DeleteCookie) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (c DeleteCookie) Execute(t *Test) error {
 	errors := []string{}
@@ -0,0 +1,146 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// diff.go contains a check comparing the response with the response
+// of a second request, e.g. from a canary or a legacy backend.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterCheck(&Diff{})
+}
+
+// ----------------------------------------------------------------------------
+// Diff
+
+// Diff issues the request of the test a second time against CompareURL and
+// checks that both response bodies are equal. This allows A/B comparisons
+// of e.g. a new backend against the legacy one:
+//     {
+//         Check: "Diff"
+//         CompareURL: "https://legacy.example.org/api/items?page=2"
+//         Ignore: [ "meta.generated", "meta.server" ]
+//     }
+// The second request uses the same method, header and body as the original
+// request. If both bodies are JSON documents they are compared element
+// by element (elements are selected like in the JSON check) and elements
+// covered by Ignore are not compared. Other bodies are compared line by
+// line. The first difference is reported.
+type Diff struct {
+	// CompareURL is the URL of the second request.
+	CompareURL string
+
+	// Ignore are JSON elements which may differ. Selecting an object or
+	// an array ignores all its elements.
+	Ignore []string `json:",omitempty"`
+
+	// Sep is the separator in the element selectors. A zero value is
+	// equivalent to ".".
+	Sep string `json:",omitempty"`
+
+	// StatusCode requires the status codes of both responses to be equal.
+	StatusCode bool `json:",omitempty"`
+}
+
+// Cost implements Coster: Diff makes an additional request.
+func (Diff) Cost() int { return CostExpensive }
+
+// Prepare implements Check's Prepare method.
+func (d *Diff) Prepare() error {
+	if d.CompareURL == "" {
+		return MalformedCheck{errors.New("missing CompareURL")}
+	}
+	return nil
+}
+
+// InspectsBody implements BodyInspector.
+func (*Diff) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (d *Diff) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+
+	other := t.followUp("Comparison", t.Request.Method, d.CompareURL)
+	other.Request.Body = t.Request.SentBody
+	other.RunContext(t.context())
+	if other.Status != Pass {
+		return fmt.Errorf("request to %s: %s %s", d.CompareURL, other.Status, other.Error)
+	}
+	if d.StatusCode {
+		a, b := t.Response.Response.StatusCode, other.Response.Response.StatusCode
+		if a != b {
+			return fmt.Errorf("status code %d differs from %d of %s", a, b, d.CompareURL)
+		}
+	}
+
+	sep := "."
+	if d.Sep != "" {
+		sep = d.Sep
+	}
+	fa, errA := explodeJSON([]byte(t.Response.BodyStr), sep)
+	fb, errB := explodeJSON([]byte(other.Response.BodyStr), sep)
+	if errA == nil && errB == nil {
+		return diffJSON(fa, fb, d.Ignore, sep)
+	}
+	return diffLines(t.Response.BodyStr, other.Response.BodyStr)
+}
+
+// diffJSON reports the first element (in sorted order) not covered by
+// ignore which differs in the flattened JSON documents fa and fb.
+func diffJSON(fa, fb map[string]string, ignore []string, sep string) error {
+	elements := make([]string, 0, len(fa)+len(fb))
+	for elem := range fa {
+		elements = append(elements, elem)
+	}
+	for elem := range fb {
+		if _, ok := fa[elem]; !ok {
+			elements = append(elements, elem)
+		}
+	}
+	sort.Strings(elements)
+
+	format := func(v string, ok bool) string {
+		if !ok {
+			return "<missing>"
+		}
+		return v
+	}
+	for _, elem := range elements {
+		va, oka := fa[elem]
+		vb, okb := fb[elem]
+		if (oka == okb && va == vb) || jsonPathCovered(elem, ignore, sep) {
+			continue
+		}
+		return fmt.Errorf("element %s differs: %s != %s", elem, format(va, oka), format(vb, okb))
+	}
+	return nil
+}
+
+// diffLines reports the first line in which a and b differ.
+func diffLines(a, b string) error {
+	la, lb := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; i < len(la) || i < len(lb); i++ {
+		switch {
+		case i >= len(la):
+			return fmt.Errorf("line %d missing, other has %q", i+1, lb[i])
+		case i >= len(lb):
+			return fmt.Errorf("line %d %q missing in other", i+1, la[i])
+		case la[i] != lb[i]:
+			return fmt.Errorf("line %d differs: %q != %q", i+1, la[i], lb[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/new", "/legacy":
+		fmt.Fprintf(w, `{"items": [1, 2], "meta": {"server": %q}}`, r.URL.Path)
+	case "/changed":
+		fmt.Fprint(w, `{"items": [1, 3], "meta": {"server": "changed"}}`)
+	case "/text":
+		fmt.Fprint(w, "line 1\nline 2\n")
+	case "/text2":
+		fmt.Fprint(w, "line 1\nline two\n")
+	case "/missing":
+		http.Error(w, "nothing here", http.StatusNotFound)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(diffHandler))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		path  string
+		check *Diff
+		want  string
+	}{
+		{"/new", &Diff{CompareURL: ts.URL + "/legacy", Ignore: []string{"meta"}}, ""},
+		{"/new", &Diff{CompareURL: ts.URL + "/legacy"},
+			`element meta.server differs: "/new" != "/legacy"`},
+		{"/new", &Diff{CompareURL: ts.URL + "/changed", Ignore: []string{"meta.server"}},
+			"element items.1 differs: 2 != 3"},
+		{"/text", &Diff{CompareURL: ts.URL + "/text"}, ""},
+		{"/text", &Diff{CompareURL: ts.URL + "/text2"},
+			`line 2 differs: "line 2" != "line two"`},
+		{"/text", &Diff{CompareURL: ts.URL + "/missing", StatusCode: true},
+			"status code 200 differs from 404 of " + ts.URL + "/missing"},
+	} {
+		test := &Test{
+			Request: Request{URL: ts.URL + tc.path},
+			Checks:  CheckList{tc.check},
+		}
+		test.Run()
+		got := ""
+		if test.Status != Pass {
+			if len(test.CheckResults) != 1 || test.CheckResults[0].Error == nil {
+				t.Errorf("%d. Unexpected %s: %v", i, test.Status, test.Error)
+				continue
+			}
+			got = test.CheckResults[0].Error.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+
+	if err := (&Diff{}).Prepare(); err == nil {
+		t.Errorf("Missing error for missing CompareURL")
+	}
+}
@@ -29,6 +29,7 @@
 //
 // The following checks are provided
 //     * Body            checks text in the response body
+//     * ClockSkew       checks the server clock via the Date header
 //     * ContentType     checks Content-Type header
 //     * DeleteCookie    checks for proper deletion of cookies
 //     * FinalURL        checks final URL after a redirect chain
@@ -38,6 +39,7 @@
 //     * Identity        checks the SHA1 hash of the HTTP body
 //     * Image           checks image format, size and content
 //     * JSON            checks structure and content of a JSON body
+//     * JWT             checks signature and claims of a JSON Web Token
 //     * Links           checks accesability of hrefs and srcs in HTML
 //     * Logfile         checks data written to a logfile
 //     * Redirect        checks for redirections
@@ -57,6 +59,14 @@
 // All the ugly stuff like parameter encoding, generation of multipart
 // bodies, etc. are hidden from the user.
 //
+// Requests to APIs which require signed requests can be signed with a
+// HMAC computed over a canonical form of the request, see Signing. The
+// secret is taken from a variable and thus kept out of the test files.
+// Requests to AWS endpoints behind IAM authentication can be signed with
+// AWS Signature Version 4, see AWSSigV4.
+// Endpoints requiring NTLM authentication (e.g. on IIS) can be tested with
+// the experimental NTLM support, see NTLM.
+//
 // Parametrisations
 //
 // Hardcoding e.g. the hostname in a test has obvious drawbacks. To overcome
@@ -74,9 +84,15 @@
 //     {{NOW + 15s}}                 -->  Wed, 01 Oct 2014 12:22:51 CEST
 //     {{NOW + 25m | "15:04"}}       -->  12:47
 //     {{NOW + 3d | "2006-Jan-02"}}  -->  2014-Oct-04
+//     {{NOW - 1w | "2006-Jan-02"}}  -->  2014-Sep-24
+//     {{NOW + 1d12h | "15:04"}}     -->  00:22
+//     {{NOW | "UTC" "15:04 MST"}}   -->  10:22 UTC
 // Formating the time is done with the usual reference time of package time
 // and defaults to RFC1123. Offset can be negative, the known units are "s" for
-// seconds, "m" for minutes, "h" for hours and "d" for days.
+// seconds, "m" for minutes, "h" for hours, "d" for days and "w" for weeks.
+// Several offsets may be combined like in "1d12h". The time is formated in
+// local time unless a timezone like "UTC" or "Europe/Berlin" is given in
+// front of the format.
 //
 // Some random values can be include by the following syntax:
 //     {{RANDOM NUMBER 99}}          -->  22
@@ -87,6 +103,22 @@
 //     {{RANDOM TEXT de 5}}          -->  Denn die fromme Seele
 //     {{RANDOM EMAIL}}              -->  Leon.Schneider@gmail.com
 //     {{RANDOM EMAIL web.de}}       -->  Meier.Anna@web.de
+//     {{RANDOM EMAIL f.last corp.com}} -->  L.Schneider@corp.com
+//     {{RANDOM UUID}}               -->  e2aadcd8-68ce-4284-b7f8-6e430140149b
+// The known styles of the local part of an email address are first.last,
+// last.first, first_last, f.last and first.
+// The random values are drawn from the global Random unless the Test has
+// a non-zero RandomSeed in which case a private random source seeded with
+// RandomSeed is used, making the values reproducible.
+//
+// Simple arithmetic on numeric variables is possible with the {{= ...}}
+// construct which understands integers, floats, + - * / and parentheses:
+//     {{= PAGE + 1}}                -->  3     (with PAGE=2)
+//     {{= (PAGE - 1) * SIZE}}       -->  20    (with PAGE=2 and SIZE=20)
+//     {{= PRICE * 1.5}}             -->  14.25 (with PRICE=9.5)
+//     {{= 7 / 2}}                   -->  3.5
+// The result is an integer if all operands are integers and all divisions
+// are exact.
 //
 // Tests
 //
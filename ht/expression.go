@@ -0,0 +1,231 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// expression.go contains the special {{= ...}} arithmetic expressions.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// exprRe matches the {{= ...}} constructs.
+var exprRe = regexp.MustCompile(`\{\{(=[^{}]*)\}\}`)
+
+// SetExpressionVariables evaluates all {{= ...}} constructs found in texts
+// and stores the results in vars. An expression like
+//     {{= (PAGE - 1) * SIZE + 1}}
+// consists of integer or floating point numbers, names of variables with
+// numeric values from values, the operators + - * / and parentheses.
+// The result is an integer if all operands are integers and all divisions
+// are exact and a float otherwise.
+// Expressions which reference a variable whose value is still unknown (i.e.
+// a variable whose value is a reference to itself like "{{PAGE}}" during a
+// dry run) are left unevaluated.
+func SetExpressionVariables(vars map[string]string, values map[string]string, texts ...string) error {
+	for _, text := range texts {
+		for _, m := range exprRe.FindAllStringSubmatch(text, -1) {
+			if _, ok := vars[m[1]]; ok {
+				continue
+			}
+			value, err := evalExpression(m[1][1:], values)
+			if err == errUnknownValue {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("ht: bad expression %q: %s", m[1], err)
+			}
+			vars[m[1]] = value
+		}
+	}
+	return nil
+}
+
+// errUnknownValue indicates that a variable in an expression has no
+// value yet.
+var errUnknownValue = errors.New("unknown value")
+
+// exprNumber is an integer (if isInt) or a float.
+type exprNumber struct {
+	i     int64
+	f     float64
+	isInt bool
+}
+
+func (n exprNumber) float() float64 {
+	if n.isInt {
+		return float64(n.i)
+	}
+	return n.f
+}
+
+func (n exprNumber) String() string {
+	if n.isInt {
+		return strconv.FormatInt(n.i, 10)
+	}
+	return strconv.FormatFloat(n.f, 'f', -1, 64)
+}
+
+// parseNumber parses s as an integer or a float.
+func parseNumber(s string) (exprNumber, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return exprNumber{i: i, isInt: true}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return exprNumber{}, fmt.Errorf("%q is not a number", s)
+	}
+	return exprNumber{f: f}, nil
+}
+
+// exprTokenRe splits an expression into numbers, variable names, operators
+// and parentheses.
+var exprTokenRe = regexp.MustCompile(`\s*([0-9]+(?:\.[0-9]+)?|[A-Za-z_][A-Za-z0-9_.-]*|[-+*/()]|\S)`)
+
+// exprNameRe matches variable names in expressions.
+var exprNameRe = regexp.MustCompile(`^[A-Za-z_]`)
+
+// exprParser is a simple recursive descent parser and evaluator of
+//     expr   = term { ("+" | "-") term }
+//     term   = factor { ("*" | "/") factor }
+//     factor = [ "-" ] ( number | variable | "(" expr ")" )
+type exprParser struct {
+	tokens []string
+	values map[string]string
+}
+
+// evalExpression evaluates expr with the variables from values.
+func evalExpression(expr string, values map[string]string) (string, error) {
+	p := &exprParser{values: values}
+	for _, m := range exprTokenRe.FindAllStringSubmatch(expr, -1) {
+		p.tokens = append(p.tokens, m[1])
+	}
+	if len(p.tokens) == 0 {
+		return "", fmt.Errorf("empty expression")
+	}
+	n, err := p.expr()
+	if err != nil {
+		return "", err
+	}
+	if len(p.tokens) > 0 {
+		return "", fmt.Errorf("unexpected %q", p.tokens[0])
+	}
+	return n.String(), nil
+}
+
+// next returns the next token or "" at the end of the expression.
+func (p *exprParser) next() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	t := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return t
+}
+
+func (p *exprParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *exprParser) expr() (exprNumber, error) {
+	a, err := p.term()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		op := p.next()
+		var b exprNumber
+		if b, err = p.term(); err == nil {
+			a, err = arith(op, a, b)
+		}
+	}
+	return a, err
+}
+
+func (p *exprParser) term() (exprNumber, error) {
+	a, err := p.factor()
+	for err == nil && (p.peek() == "*" || p.peek() == "/") {
+		op := p.next()
+		var b exprNumber
+		if b, err = p.factor(); err == nil {
+			a, err = arith(op, a, b)
+		}
+	}
+	return a, err
+}
+
+func (p *exprParser) factor() (exprNumber, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return exprNumber{}, fmt.Errorf("unexpected end")
+	case t == "-":
+		n, err := p.factor()
+		n.i, n.f = -n.i, -n.f
+		return n, err
+	case t == "(":
+		n, err := p.expr()
+		if err != nil {
+			return n, err
+		}
+		if p.next() != ")" {
+			return n, fmt.Errorf("missing )")
+		}
+		return n, nil
+	case t[0] >= '0' && t[0] <= '9':
+		return parseNumber(t)
+	case exprNameRe.MatchString(t):
+		v, ok := p.values[t]
+		if !ok {
+			return exprNumber{}, fmt.Errorf("undefined variable %s", t)
+		}
+		if v == "{{"+t+"}}" {
+			return exprNumber{}, errUnknownValue
+		}
+		n, err := parseNumber(strings.TrimSpace(v))
+		if err != nil {
+			return n, fmt.Errorf("variable %s: %s", t, err)
+		}
+		return n, nil
+	}
+	return exprNumber{}, fmt.Errorf("unexpected %q", t)
+}
+
+// arith applies the operator op to a and b.
+func arith(op string, a, b exprNumber) (exprNumber, error) {
+	if a.isInt && b.isInt {
+		switch op {
+		case "+":
+			return exprNumber{i: a.i + b.i, isInt: true}, nil
+		case "-":
+			return exprNumber{i: a.i - b.i, isInt: true}, nil
+		case "*":
+			return exprNumber{i: a.i * b.i, isInt: true}, nil
+		case "/":
+			if b.i == 0 {
+				return exprNumber{}, fmt.Errorf("division by zero")
+			}
+			if a.i%b.i == 0 {
+				return exprNumber{i: a.i / b.i, isInt: true}, nil
+			}
+		}
+	}
+	x, y := a.float(), b.float()
+	switch op {
+	case "+":
+		return exprNumber{f: x + y}, nil
+	case "-":
+		return exprNumber{f: x - y}, nil
+	case "*":
+		return exprNumber{f: x * y}, nil
+	}
+	if y == 0 {
+		return exprNumber{}, fmt.Errorf("division by zero")
+	}
+	return exprNumber{f: x / y}, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpressionVariables(t *testing.T) {
+	values := map[string]string{
+		"PAGE":  "2",
+		"SIZE":  " 20 ",
+		"PRICE": "9.5",
+		"NAME":  "foo",
+		"LATER": "{{LATER}}",
+	}
+	for i, tc := range []struct {
+		text, key, want, err string
+	}{
+		{text: "{{= 1 + 2}}", key: "= 1 + 2", want: "3"},
+		{text: "{{=PAGE+1}}", key: "=PAGE+1", want: "3"},
+		{text: "{{= (PAGE - 1) * SIZE + 1}}", key: "= (PAGE - 1) * SIZE + 1", want: "21"},
+		{text: "{{= PAGE - 1 * SIZE}}", key: "= PAGE - 1 * SIZE", want: "-18"},
+		{text: "{{= -PAGE * -3}}", key: "= -PAGE * -3", want: "6"},
+		{text: "{{= PRICE * 2}}", key: "= PRICE * 2", want: "19"},
+		{text: "{{= PRICE * 1.5}}", key: "= PRICE * 1.5", want: "14.25"},
+		{text: "{{= 8 / 2}}", key: "= 8 / 2", want: "4"},
+		{text: "{{= 7 / 2}}", key: "= 7 / 2", want: "3.5"},
+		{text: "{{= LATER + 1}}", key: "= LATER + 1"},
+		{text: "{{= PAGE / 0}}", err: "division by zero"},
+		{text: "{{= PAGE / 0.0}}", err: "division by zero"},
+		{text: "{{= MISSING + 1}}", err: "undefined variable MISSING"},
+		{text: "{{= NAME + 1}}", err: "variable NAME"},
+		{text: "{{= (1 + 2}}", err: "missing )"},
+		{text: "{{= 1 +}}", err: "unexpected end"},
+		{text: "{{= 1 2}}", err: `unexpected "2"`},
+		{text: "{{= 2 ^ 3}}", err: `unexpected "^"`},
+		{text: "{{=}}", err: "empty expression"},
+	} {
+		vars := map[string]string{}
+		err := SetExpressionVariables(vars, values, tc.text)
+		if tc.err != "" {
+			if err == nil {
+				t.Errorf("%d: %q missing error, want %s", i, tc.text, tc.err)
+			} else if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%d: %q wrong error, got %s, want %s", i, tc.text, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %q unexpected error %s", i, tc.text, err)
+			continue
+		}
+		if got, ok := vars[tc.key]; tc.want == "" && ok {
+			t.Errorf("%d: %q unexpected value %q", i, tc.text, got)
+		} else if got != tc.want {
+			t.Errorf("%d: %q got %q, want %q", i, tc.text, got, tc.want)
+		}
+	}
+}
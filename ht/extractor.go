@@ -14,7 +14,6 @@ import (
 	"strings"
 
 	"github.com/andybalholm/cascadia"
-	"github.com/nytlabs/gojsonexplode"
 	"github.com/robertkrimen/otto"
 	"github.com/vdobler/ht/populate"
 	"golang.org/x/net/html"
@@ -220,14 +219,31 @@ func (e HTMLExtractor) Extract(t *Test) (string, error) {
 // BodyExtractor
 
 // BodyExtractor extracts a value from the uninterpreted response body
-// via a regular expression.
+// via a regular expression. The extracted value is either a numbered
+// submatch or the submatch of a named capturing group, e.g. a CSRF token
+// can be extracted with
+//     {Extractor: "BodyExtractor", Regexp: "csrf=(?P<token>[0-9a-f]+)", Group: "token"}
+// For a Regexp with named groups the first named group is extracted if
+// neither Group nor Submatch is given, so Group: "token" may be omitted
+// above, even if unnamed groups precede it.
+// It is an error if Regexp does not match or if the extracted value is
+// empty unless AllowEmpty is set.
 type BodyExtractor struct {
 	// Regexp is the regular expression to look for in the body.
 	Regexp string
 
 	// SubMatch selects which submatch (capturing group) of Regexp shall
-	// be returned. A 0 value indicates the whole match.
+	// be returned. A 0 value indicates the whole match unless Regexp
+	// contains named groups in which case the first named group is
+	// returned.
 	Submatch int `json:",omitempty"`
+
+	// Group selects the named capturing group of Regexp which shall
+	// be returned. If set Submatch is ignored.
+	Group string `json:",omitempty"`
+
+	// AllowEmpty allows the extracted value to be the empty string.
+	AllowEmpty bool `json:",omitempty"`
 }
 
 // Extract implements Extractor's Extract method.
@@ -245,21 +261,53 @@ func (e BodyExtractor) Extract(t *Test) (string, error) {
 		return "", errors.New("BodyExtractor.Submatch < 0")
 	}
 
-	submatches := re.FindStringSubmatch(t.Response.BodyStr)
-	if len(submatches) > e.Submatch {
-		return string(submatches[e.Submatch]), nil
+	n := e.Submatch
+	if n == 0 && e.Group == "" {
+		n = firstNamedGroup(re)
 	}
+	if e.Group != "" {
+		n = -1
+		for i, name := range re.SubexpNames() {
+			if name == e.Group {
+				n = i
+				break
+			}
+		}
+		if n == -1 {
+			return "", fmt.Errorf("no group %q in regexp %q", e.Group, e.Regexp)
+		}
+	}
+
+	submatches := re.FindStringSubmatch(t.Response.BodyStr)
 	if len(submatches) == 0 {
 		return "", fmt.Errorf("no match found in %q", t.Response.BodyStr)
 	}
-	return "", fmt.Errorf("got only %d submatches in %q", len(submatches)-1, submatches[0])
+	if len(submatches) <= n {
+		return "", fmt.Errorf("got only %d submatches in %q", len(submatches)-1, submatches[0])
+	}
+	value := submatches[n]
+	if value == "" && !e.AllowEmpty {
+		return "", fmt.Errorf("extracted empty value from %q", submatches[0])
+	}
+	return value, nil
+}
+
+// firstNamedGroup returns the submatch index of the first named capturing
+// group of re or 0 if re has no named groups.
+func firstNamedGroup(re *regexp.Regexp) int {
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			return i
+		}
+	}
+	return 0
 }
 
 // ----------------------------------------------------------------------------
 // JSONExtractor
 
 // JSONExtractor extracts a value from a JSON response body.
-// It uses github.com/nytlabs/gojsonexplode to flatten the JSON file
+// It flattens the JSON file like github.com/nytlabs/gojsonexplode
 // for easier access.
 // Only the lowest level of elements may be accessed: In the JSON {"c":[1,2,3]}
 // "c" is not available, but c.2 is and equals 3. JSON null values are
@@ -287,32 +335,24 @@ func (e JSONExtractor) Extract(t *Test) (string, error) {
 		sep = e.Sep
 	}
 
-	out, err := gojsonexplode.Explodejson([]byte(t.Response.BodyStr), sep)
+	flat, err := explodeJSON([]byte(t.Response.BodyStr), sep)
 	if err != nil {
 		return "", fmt.Errorf("unable to explode JSON: %s", err.Error())
 	}
 
-	var flat map[string]*json.RawMessage
-	err = json.Unmarshal(out, &flat)
-	if err != nil {
-		return "", fmt.Errorf("unable to parse exploded JSON: %s", err.Error())
-	}
-
-	val, ok := flat[e.Element]
+	s, ok := flat[e.Element]
 	if !ok {
 		return "", ErrNotFound
 	}
 
-	// The element might be present but null like in {"a": null} in which
-	// case val==nil.
-	if val == nil {
+	// The element might be present but null like in {"a": null}.
+	if s == "null" {
 		// TODO: is this the most sensible outcome?  Or would
 		// "", ErrNotFound be better?
 		return "", nil
 	}
 
 	// Strip quotes from strings.
-	s := string(*val)
 	if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) && len(s) >= 2 {
 		s = s[1 : len(s)-1]
 	}
@@ -97,6 +97,56 @@ func TestBodyExtractor(t *testing.T) {
 	}
 }
 
+func TestBodyExtractorGroup(t *testing.T) {
+	test := &Test{
+		Response: Response{
+			BodyStr: `<input type="hidden" name="csrf" value="a1b2c3"/>` +
+				`<input type="hidden" name="other" value=""/>`,
+		},
+	}
+
+	for i, tc := range []struct {
+		ex   BodyExtractor
+		want string
+		err  string
+	}{
+		{BodyExtractor{Regexp: `name="csrf" value="(?P<token>[^"]*)"`, Group: "token"},
+			"a1b2c3", ""},
+		{BodyExtractor{Regexp: `name="(?P<name>[a-z]+)" value="(?P<value>[^"]*)"`, Group: "value"},
+			"a1b2c3", ""},
+		{BodyExtractor{Regexp: `name="csrf" value="(?P<token>[^"]*)"`},
+			"a1b2c3", ""},
+		{BodyExtractor{Regexp: `name="(?P<name>[a-z]+)" value="(?P<value>[^"]*)"`},
+			"csrf", ""},
+		{BodyExtractor{Regexp: `name="(?P<name>[a-z]+)" value="(?P<value>[^"]*)"`, Submatch: 2},
+			"a1b2c3", ""},
+		{BodyExtractor{Regexp: `name="(csrf|other)" value="(?P<token>[^"]*)"`},
+			"a1b2c3", ""},
+		{BodyExtractor{Regexp: `type="(hidden)"`}, `type="hidden"`, ""},
+		{BodyExtractor{Regexp: `name="csrf" value="(?P<token>[^"]*)"`, Group: "foo"},
+			"", `no group "foo"`},
+		{BodyExtractor{Regexp: `name="other" value="(?P<token>[^"]*)"`, Group: "token"},
+			"", "extracted empty value"},
+		{BodyExtractor{Regexp: `name="other" value="(?P<token>[^"]*)"`, Group: "token", AllowEmpty: true},
+			"", ""},
+		{BodyExtractor{Regexp: `name="missing" value="(?P<token>[^"]*)"`, Group: "token", AllowEmpty: true},
+			"", "no match found"},
+	} {
+		got, err := tc.ex.Extract(test)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%d: got error %v, want %s", i, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+		} else if got != tc.want {
+			t.Errorf("%d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
+
 var jsonExtractorTests = []struct {
 	body string
 	path string
@@ -0,0 +1,168 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// form.go contains helpers to populate a request from a HTML form.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// Form describes a HTML form in the response of a previous test which
+// is used to populate a request.
+type Form struct {
+	// Selector is the CSS selector of the <form> element, e.g.
+	//     form#login
+	Selector string
+}
+
+// PopulateFromForm fills the request of t from the form selected by
+// t.Request.FromForm in the response of prev. The form's action URL
+// and method are used if t's URL and Method are empty. All successful
+// form controls (including hidden inputs like CSRF tokens) are added to
+// the parameters unless t already specifies a value for the parameter.
+// Nothing is done if t.Request.FromForm is nil.
+func (t *Test) PopulateFromForm(prev *Test) error {
+	ff := t.Request.FromForm
+	if ff == nil {
+		return nil
+	}
+	if prev == nil || prev.Response.Response == nil {
+		return errors.New("no previous response to read form from")
+	}
+
+	sel, err := cascadia.Compile(ff.Selector)
+	if err != nil {
+		return err
+	}
+	doc, err := html.Parse(prev.Response.Body())
+	if err != nil {
+		return err
+	}
+	form := sel.MatchFirst(doc)
+	if form == nil {
+		return fmt.Errorf("could not find form '%s'", ff.Selector)
+	}
+	if form.Data != "form" {
+		return fmt.Errorf("selector '%s' matches a <%s>, not a <form>",
+			ff.Selector, form.Data)
+	}
+
+	// Action and method of form.
+	base := prev.Response.Response.Request.URL
+	action, err := base.Parse(attrValue(form, "action"))
+	if err != nil {
+		return err
+	}
+	action.Fragment = ""
+	method := strings.ToUpper(attrValue(form, "method"))
+	if method == "" {
+		method = "GET"
+	}
+	if t.Request.URL == "" {
+		t.Request.URL = action.String()
+	}
+	if t.Request.Method == "" {
+		t.Request.Method = method
+	}
+	if t.Request.ParamsAs == "" && t.Request.Method != "GET" {
+		if attrValue(form, "enctype") == "multipart/form-data" {
+			t.Request.ParamsAs = "multipart"
+		} else {
+			t.Request.ParamsAs = "body"
+		}
+	}
+
+	// Explicit parameters dominate the form values.
+	if t.Request.Params == nil {
+		t.Request.Params = make(url.Values)
+	}
+	for name, values := range formValues(form) {
+		if _, ok := t.Request.Params[name]; ok {
+			continue
+		}
+		t.Request.Params[name] = values
+	}
+
+	return nil
+}
+
+var formControlSel = cascadia.MustCompile("input, select, textarea")
+var selectedOptionSel = cascadia.MustCompile("option[selected]")
+var optionSel = cascadia.MustCompile("option")
+
+// formValues returns the values of the successful controls of form.
+// Buttons and file inputs are ignored.
+func formValues(form *html.Node) url.Values {
+	values := make(url.Values)
+	for _, control := range formControlSel.MatchAll(form) {
+		name := attrValue(control, "name")
+		if name == "" || hasAttr(control, "disabled") {
+			continue
+		}
+		switch control.Data {
+		case "input":
+			typ := strings.ToLower(attrValue(control, "type"))
+			switch typ {
+			case "submit", "button", "image", "reset", "file":
+				continue
+			case "checkbox", "radio":
+				if !hasAttr(control, "checked") {
+					continue
+				}
+				value := "on"
+				if hasAttr(control, "value") {
+					value = attrValue(control, "value")
+				}
+				values.Add(name, value)
+			default:
+				values.Add(name, attrValue(control, "value"))
+			}
+		case "select":
+			options := selectedOptionSel.MatchAll(control)
+			if len(options) == 0 {
+				if first := optionSel.MatchFirst(control); first != nil {
+					options = append(options, first)
+				}
+			}
+			for _, option := range options {
+				value := TextContent(option, false)
+				if hasAttr(option, "value") {
+					value = attrValue(option, "value")
+				}
+				values.Add(name, value)
+			}
+		case "textarea":
+			values.Add(name, TextContent(control, true))
+		}
+	}
+	return values
+}
+
+// attrValue returns the value of the attribute key of n.
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether n has the attribute key.
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var loginPage = `<!DOCTYPE html>
+<html><head><title>Login</title></head>
+<body>
+  <form id="search" action="/search"><input name="q" value=""></form>
+  <form id="login" action="/login?next=home" method="post">
+    <input type="hidden" name="csrf" value="a1b2c3">
+    <input type="text" name="user" value="">
+    <input type="password" name="pass">
+    <input type="checkbox" name="remember" checked>
+    <input type="checkbox" name="spam" value="yes">
+    <input type="text" name="off" value="x" disabled>
+    <select name="lang">
+      <option value="en">English</option>
+      <option value="de" selected>Deutsch</option>
+    </select>
+    <textarea name="note">Hi</textarea>
+    <input type="submit" name="go" value="Login">
+  </form>
+</body></html>`
+
+func formPrevTest(body string) *Test {
+	u, _ := url.Parse("http://www.example.org/account/page.html")
+	return &Test{
+		Response: Response{
+			Response: &http.Response{Request: &http.Request{URL: u}},
+			BodyStr:  body,
+		},
+	}
+}
+
+func TestPopulateFromForm(t *testing.T) {
+	test := &Test{
+		Request: Request{
+			FromForm: &Form{Selector: "form#login"},
+			Params:   url.Values{"user": {"joe"}, "pass": {"secret"}},
+		},
+	}
+	err := test.PopulateFromForm(formPrevTest(loginPage))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if got := test.Request.URL; got != "http://www.example.org/login?next=home" {
+		t.Errorf("Got URL %q", got)
+	}
+	if test.Request.Method != "POST" || test.Request.ParamsAs != "body" {
+		t.Errorf("Got Method=%q ParamsAs=%q", test.Request.Method, test.Request.ParamsAs)
+	}
+	want := url.Values{
+		"csrf":     {"a1b2c3"},
+		"user":     {"joe"},
+		"pass":     {"secret"},
+		"remember": {"on"},
+		"lang":     {"de"},
+		"note":     {"Hi"},
+	}
+	if !reflect.DeepEqual(test.Request.Params, want) {
+		t.Errorf("Got Params %v, want %v", test.Request.Params, want)
+	}
+}
+
+func TestPopulateFromFormErrors(t *testing.T) {
+	for i, tc := range []struct {
+		selector string
+		prev     *Test
+		err      string
+	}{
+		{"form#login", nil, "no previous response"},
+		{"form#logout", formPrevTest(loginPage), "could not find form"},
+		{"select", formPrevTest(loginPage), "not a <form>"},
+		{"form[", formPrevTest(loginPage), "expected"},
+	} {
+		test := &Test{Request: Request{FromForm: &Form{Selector: tc.selector}}}
+		err := test.PopulateFromForm(tc.prev)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: got error %v, want %s", i, err, tc.err)
+		}
+	}
+}
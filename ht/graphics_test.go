@@ -54,7 +54,6 @@ func TestResponseTimeHistogram(t *testing.T) {
 				"fail": {"5"},
 				"bad":  {"10"},
 			},
-			FollowRedirects: false,
 		},
 		Checks: []Check{
 			StatusCode{200},
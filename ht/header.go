@@ -10,16 +10,28 @@ package ht
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+
+	mimelist "github.com/vdobler/ht/mime"
 )
 
 func init() {
 	RegisterCheck(&Header{})
+	RegisterCheck(&Trailer{})
 	RegisterCheck(&ContentType{})
+	RegisterCheck(&FileType{})
 	RegisterCheck(&FinalURL{})
 	RegisterCheck(&Redirect{})
 	RegisterCheck(&RedirectChain{})
+	RegisterCheck(&RedirectCount{})
+	RegisterCheck(&SecurityHeaders{})
+	RegisterCheck(&HeaderUnique{})
 }
 
 // Header provides a textual test of single-valued HTTP headers.
@@ -35,6 +47,9 @@ type Header struct {
 	Absent bool `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: Header ignores the body.
+func (Header) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (h Header) Execute(t *Test) error {
 	key := http.CanonicalHeaderKey(h.Header)
@@ -54,6 +69,50 @@ func (h *Header) Prepare() error {
 	return h.Condition.Compile()
 }
 
+// ----------------------------------------------------------------------------
+// Trailer
+
+// Trailer provides a textual test of single-valued HTTP trailers, i.e.
+// of header fields sent after the body like the grpc-status of gRPC-Web.
+// Trailers are available only after the whole body has been read, so
+// a body truncated due to Request.MaxBodySize makes this check error.
+type Trailer struct {
+	// Trailer is the HTTP trailer to check.
+	Trailer string
+
+	// Condition is applied to the first trailer value. A zero value checks
+	// for the existence of the given Trailer only.
+	Condition `json:",omitempty"`
+
+	// Absent indicates that no trailer Trailer shall be part of the response.
+	Absent bool `json:",omitempty"`
+}
+
+// Execute implements Check's Execute method.
+func (tr Trailer) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	if t.Response.Truncated || t.Response.BodyErr != nil {
+		return errors.New("body not read completely, trailers unavailable")
+	}
+	key := http.CanonicalHeaderKey(tr.Trailer)
+	values := t.Response.Response.Trailer[key]
+	if len(values) == 0 && tr.Absent {
+		return nil
+	} else if len(values) == 0 && !tr.Absent {
+		return fmt.Errorf("trailer %s not received", tr.Trailer)
+	} else if len(values) > 0 && tr.Absent {
+		return fmt.Errorf("forbidden trailer %s received", tr.Trailer)
+	}
+	return tr.Fulfilled(values[0])
+}
+
+// Prepare implements Check's Prepare method.
+func (tr *Trailer) Prepare() error {
+	return tr.Condition.Compile()
+}
+
 // ----------------------------------------------------------------------------
 // ContentType
 
@@ -67,6 +126,9 @@ type ContentType struct {
 	Charset string `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: ContentType ignores the body.
+func (ContentType) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (c ContentType) Execute(t *Test) error {
 	if t.Response.Response == nil || t.Response.Response.Header == nil {
@@ -108,13 +170,125 @@ func (c ContentType) Execute(t *Test) error {
 // Prepare implements Check's Prepare method.
 func (ContentType) Prepare() error { return nil }
 
+// ----------------------------------------------------------------------------
+// FileType
+
+// fileTypeAliases are common content types not derivable from the
+// mime package.
+var fileTypeAliases = map[string][]string{
+	"js":  {"text/javascript"},
+	"mjs": {"text/javascript"},
+	"xml": {"text/xml"},
+}
+
+// FileType checks that the Content-Type of the response matches the
+// extension of the (final) request path, e.g. that "/static/app.js" is
+// served as application/javascript and not as text/plain. The content
+// types of an extension are taken from package github.com/vdobler/ht/mime
+// and the standard library's mime.TypeByExtension.
+// Paths without an extension are not checked. Example:
+//     {
+//         Check: "FileType"
+//         Types: { "map": [ "application/json" ] }
+//     }
+type FileType struct {
+	// Types maps extensions (without the leading dot) to the allowed
+	// content types. It overrides the default content types of the
+	// listed extensions.
+	Types map[string][]string `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector: FileType ignores the body.
+func (FileType) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (c FileType) Execute(t *Test) error {
+	if t.Response.Response == nil || t.Response.Response.Header == nil {
+		return fmt.Errorf("no proper response available")
+	}
+	var u *url.URL
+	if t.Response.Response.Request != nil {
+		u = t.Response.Response.Request.URL
+	} else if t.Request.Request != nil {
+		u = t.Request.Request.URL
+	}
+	if u == nil {
+		return errors.New("no request URL available")
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if ext == "" {
+		return nil
+	}
+	allowed := c.contentTypes(ext)
+	if len(allowed) == 0 {
+		return CantCheck{fmt.Errorf("unknown extension .%s", ext)}
+	}
+
+	ct := t.Response.Response.Header.Get("Content-Type")
+	if ct == "" {
+		return fmt.Errorf("no Content-Type header received")
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("malformed Content-Type %s: %s", ct, err)
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(mt, a) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Content-Type %s for .%s, want %s", mt, ext,
+		strings.Join(allowed, " or "))
+}
+
+// contentTypes returns the sorted content types allowed for ext.
+func (c FileType) contentTypes(ext string) []string {
+	for e, types := range c.Types {
+		if strings.EqualFold(strings.TrimPrefix(e, "."), ext) {
+			return types
+		}
+	}
+	types := append([]string(nil), fileTypeAliases[ext]...)
+	for mt, e := range mimelist.MimeTypeExtension {
+		if e == ext {
+			types = append(types, mt)
+		}
+	}
+	// MimeTypeExtension keeps only the first extension of a content type
+	// (e.g. jpeg but not jpg) so consult the standard library too.
+	if mt, _, err := mime.ParseMediaType(mime.TypeByExtension("." + ext)); err == nil {
+		known := false
+		for _, t := range types {
+			known = known || strings.EqualFold(t, mt)
+		}
+		if !known {
+			types = append(types, mt)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Prepare implements Check's Prepare method.
+func (c FileType) Prepare() error {
+	for ext, types := range c.Types {
+		if len(types) == 0 {
+			return MalformedCheck{fmt.Errorf("no content types for extension %s", ext)}
+		}
+	}
+	return nil
+}
+
 // ----------------------------------------------------------------------------
 // FinalURL
 
 // FinalURL checks the last URL after following all redirects.
-// This check is useful only for tests with Request.FollowRedirects=true
+// This check is useful only for tests with Request.FollowRedirects.Follow=true
 type FinalURL Condition
 
+// InspectsBody implements BodyInspector: FinalURL ignores the body.
+func (FinalURL) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (f FinalURL) Execute(t *Test) error {
 	if t.Response.Response == nil || t.Response.Response.Request == nil ||
@@ -135,7 +309,7 @@ func (f *FinalURL) Prepare() error {
 // Redirect checks for a singe HTTP redirection.
 //
 // Note that this check cannot be used on tests with
-//     Request.FollowRedirects = true
+//     Request.FollowRedirects.Follow = true
 // as Redirect checks only the final response which will not be a
 // redirection if redirections are followed automatically.
 type Redirect struct {
@@ -152,6 +326,9 @@ type Redirect struct {
 	StatusCode int `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: Redirect ignores the body.
+func (Redirect) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (r Redirect) Execute(t *Test) error {
 	err := ErrorList{}
@@ -223,7 +400,7 @@ func (r Redirect) Prepare() error {
 // actual redirect chain may hit additional stations.
 //
 // Note that this check can be used on tests with
-//     Request.FollowRedirects = true
+//     Request.FollowRedirects.Follow = true
 type RedirectChain struct {
 	// Via contains the necessary URLs accessed during a redirect chain.
 	//
@@ -233,6 +410,9 @@ type RedirectChain struct {
 	Via []string
 }
 
+// InspectsBody implements BodyInspector: RedirectChain ignores the body.
+func (RedirectChain) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (r RedirectChain) Execute(t *Test) error {
 	reds := t.Response.Redirections
@@ -259,3 +439,219 @@ func (r RedirectChain) Prepare() error {
 	}
 	return nil
 }
+
+// ----------------------------------------------------------------------------
+// RedirectCount
+
+// RedirectCount checks the number of redirects followed automatically.
+// The Condition is applied to the decimal representation of the number
+// of redirects, e.g. to fail if more than two hops are needed:
+//     {
+//         Check: "RedirectCount"
+//         LessThan: 3
+//     }
+// The URLs of the redirect chain are reported in failure messages.
+//
+// Note that this check is useful on tests with
+//     Request.FollowRedirects.Follow = true
+// only.
+type RedirectCount struct {
+	Condition
+}
+
+// InspectsBody implements BodyInspector: RedirectCount ignores the body.
+func (RedirectCount) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (r RedirectCount) Execute(t *Test) error {
+	n := t.Response.RedirectCount()
+	if err := r.Fulfilled(strconv.Itoa(n)); err != nil {
+		if n == 0 {
+			return fmt.Errorf("no redirections: %s", err)
+		}
+		return fmt.Errorf("%d redirections via %s: %s", n,
+			strings.Join(t.Response.Redirections, " -> "), err)
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (r *RedirectCount) Prepare() error {
+	return r.Condition.Compile()
+}
+
+// ----------------------------------------------------------------------------
+// SecurityHeaders
+
+// HeaderRequirement describes the requirements on one security header.
+type HeaderRequirement struct {
+	// Required demands the presence of the header.
+	Required bool `json:",omitempty"`
+
+	// Condition is applied to the first header value if the header
+	// was received.
+	Condition `json:",omitempty"`
+}
+
+// SecurityHeaders checks the security related headers of a response.
+// Headers whose requirement is nil are not checked. All missing or
+// misconfigured headers are reported, not just the first one. Example:
+//     {
+//         Check: "SecurityHeaders"
+//         StrictTransportSecurity: { Required: true, Prefix: "max-age=" }
+//         XContentTypeOptions:     { Required: true, Equals: "nosniff" }
+//         XFrameOptions:           { Regexp: "^(DENY|SAMEORIGIN)$" }
+//     }
+type SecurityHeaders struct {
+	// StrictTransportSecurity is the requirement on the
+	// Strict-Transport-Security (HSTS) header.
+	StrictTransportSecurity *HeaderRequirement `json:",omitempty"`
+
+	// ContentSecurityPolicy is the requirement on the
+	// Content-Security-Policy header.
+	ContentSecurityPolicy *HeaderRequirement `json:",omitempty"`
+
+	// XContentTypeOptions is the requirement on the
+	// X-Content-Type-Options header.
+	XContentTypeOptions *HeaderRequirement `json:",omitempty"`
+
+	// XFrameOptions is the requirement on the X-Frame-Options header.
+	XFrameOptions *HeaderRequirement `json:",omitempty"`
+
+	// ReferrerPolicy is the requirement on the Referrer-Policy header.
+	ReferrerPolicy *HeaderRequirement `json:",omitempty"`
+}
+
+// requirements returns the headers and their requirements.
+func (s *SecurityHeaders) requirements() []struct {
+	header string
+	req    *HeaderRequirement
+} {
+	return []struct {
+		header string
+		req    *HeaderRequirement
+	}{
+		{"Strict-Transport-Security", s.StrictTransportSecurity},
+		{"Content-Security-Policy", s.ContentSecurityPolicy},
+		{"X-Content-Type-Options", s.XContentTypeOptions},
+		{"X-Frame-Options", s.XFrameOptions},
+		{"Referrer-Policy", s.ReferrerPolicy},
+	}
+}
+
+// InspectsBody implements BodyInspector: SecurityHeaders ignores the body.
+func (*SecurityHeaders) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (s *SecurityHeaders) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+
+	errs := ErrorList{}
+	for _, r := range s.requirements() {
+		if r.req == nil {
+			continue
+		}
+		values := t.Response.Response.Header[r.header]
+		if len(values) == 0 {
+			if r.req.Required {
+				errs = append(errs, fmt.Errorf("header %s not received", r.header))
+			}
+			continue
+		}
+		if err := r.req.Fulfilled(values[0]); err != nil {
+			errs = append(errs, fmt.Errorf("header %s: %s", r.header, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (s *SecurityHeaders) Prepare() error {
+	n := 0
+	for _, r := range s.requirements() {
+		if r.req == nil {
+			continue
+		}
+		n++
+		if err := r.req.Compile(); err != nil {
+			return err
+		}
+	}
+	if n == 0 {
+		return MalformedCheck{errors.New("no header to check")}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// HeaderUnique
+
+// HeaderUnique checks that headers are not received more than once, e.g.
+// to detect a Content-Security-Policy added twice by a gateway. All
+// duplicated headers are reported. Example:
+//     {
+//         Check: "HeaderUnique"
+//         Headers: [ "Content-Security-Policy", "Strict-Transport-Security" ]
+//         Required: true
+//     }
+type HeaderUnique struct {
+	// Headers to check. An empty list checks all headers except
+	// Set-Cookie which is commonly sent several times.
+	Headers []string `json:",omitempty"`
+
+	// Required demands that all Headers are present (exactly once).
+	Required bool `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector: HeaderUnique ignores the body.
+func (*HeaderUnique) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (h *HeaderUnique) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	header := t.Response.Response.Header
+
+	names := []string{}
+	if len(h.Headers) == 0 {
+		for name := range header {
+			if name != "Set-Cookie" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	} else {
+		for _, name := range h.Headers {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	errs := ErrorList{}
+	for _, name := range names {
+		switch n := len(header[name]); {
+		case n > 1:
+			errs = append(errs, fmt.Errorf("header %s received %d times", name, n))
+		case n == 0 && h.Required:
+			errs = append(errs, fmt.Errorf("header %s not received", name))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (h *HeaderUnique) Prepare() error {
+	if h.Required && len(h.Headers) == 0 {
+		return MalformedCheck{errors.New("Required needs Headers")}
+	}
+	return nil
+}
@@ -5,7 +5,10 @@
 package ht
 
 import (
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -50,6 +53,114 @@ func TestContentType(t *testing.T) {
 	}
 }
 
+func fileTypeResponse(path, contentType string) Response {
+	return Response{Response: &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Request:    &http.Request{URL: &url.URL{Scheme: "http", Host: "example.org", Path: path}},
+	}}
+}
+
+var fileTypeTests = []TC{
+	{fileTypeResponse("/static/app.js", "application/javascript; charset=utf-8"), FileType{}, nil},
+	{fileTypeResponse("/static/app.js", "text/javascript"), FileType{}, nil},
+	{fileTypeResponse("/static/app.js", "text/plain"), FileType{},
+		fmt.Errorf("Content-Type text/plain for .js, want application/javascript or text/javascript")},
+	{fileTypeResponse("/static/STYLE.CSS", "text/css"), FileType{}, nil},
+	{fileTypeResponse("/index.html", "text/html; charset=UTF-8"), FileType{}, nil},
+	{fileTypeResponse("/index.htm", "text/html"), FileType{}, nil},
+	{fileTypeResponse("/photo.jpg", "image/jpeg"), FileType{}, nil},
+	{fileTypeResponse("/photo.JPEG", "image/jpeg"), FileType{}, nil},
+	{fileTypeResponse("/photo.jpg", "image/png"), FileType{}, someError},
+	{fileTypeResponse("/api/items", "application/json"), FileType{}, nil},
+	{fileTypeResponse("/app.js.map", "application/json"),
+		FileType{Types: map[string][]string{".map": {"application/json"}}}, nil},
+	{fileTypeResponse("/app.js", "text/javascript"),
+		FileType{Types: map[string][]string{"js": {"application/javascript"}}}, someError},
+	{fileTypeResponse("/data.xyzzy", "text/plain"), FileType{}, someError},
+	{fileTypeResponse("/logo.png", ""), FileType{}, fmt.Errorf("no Content-Type header received")},
+	{fileTypeResponse("/logo.png", ""), FileType{Types: map[string][]string{"png": nil}}, prepareError},
+}
+
+func TestFileType(t *testing.T) {
+	for i, tc := range fileTypeTests {
+		runTest(t, i, tc)
+	}
+}
+
+var trailerResp = Response{Response: &http.Response{
+	StatusCode: 200,
+	Header:     http.Header{"Content-Type": []string{"application/grpc-web"}},
+	Trailer:    http.Header{"Grpc-Status": []string{"0"}},
+}}
+
+var trailerTests = []TC{
+	{trailerResp, &Trailer{Trailer: "grpc-status"}, nil},
+	{trailerResp, &Trailer{Trailer: "Grpc-Status", Condition: Condition{Equals: "0"}}, nil},
+	{trailerResp, &Trailer{Trailer: "Grpc-Status", Condition: Condition{Equals: "5"}}, someError},
+	{trailerResp, &Trailer{Trailer: "Grpc-Message", Absent: true}, nil},
+	{trailerResp, &Trailer{Trailer: "Grpc-Status", Absent: true}, someError},
+	{trailerResp, &Trailer{Trailer: "Content-Type"}, someError},
+	{Response{}, &Trailer{Trailer: "Grpc-Status"}, someError},
+}
+
+func TestTrailer(t *testing.T) {
+	for i, tc := range trailerTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestTrailerLive(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte("payload"))
+			w.Header().Set("Grpc-Status", "7")
+		}))
+	defer ts.Close()
+
+	test := &Test{
+		Request: Request{URL: ts.URL},
+		Checks: CheckList{
+			&Trailer{Trailer: "Grpc-Status", Condition: Condition{Equals: "7"}},
+		},
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Errorf("Got %s: %v", test.Status, test.Error)
+	}
+}
+
+var dupHeaderResp = Response{Response: &http.Response{
+	StatusCode: 200,
+	Header: http.Header{
+		"Content-Security-Policy": []string{"default-src 'self'", "default-src 'self'"},
+		"Content-Type":            []string{"text/html"},
+		"Set-Cookie":              []string{"a=1", "b=2"},
+		"Vary":                    []string{"Accept", "Cookie"},
+	},
+}}
+
+var headerUniqueTests = []TC{
+	{dupHeaderResp, &HeaderUnique{Headers: []string{"content-type", "Set-Cookie"}}, someError},
+	{dupHeaderResp, &HeaderUnique{Headers: []string{"Content-Type", "X-Frame-Options"}}, nil},
+	{dupHeaderResp, &HeaderUnique{Headers: []string{"Content-Type", "X-Frame-Options"}, Required: true},
+		fmt.Errorf("header X-Frame-Options not received")},
+	{dupHeaderResp, &HeaderUnique{Headers: []string{"content-security-policy"}},
+		fmt.Errorf("header Content-Security-Policy received 2 times")},
+	{dupHeaderResp, &HeaderUnique{},
+		ErrorList{fmt.Errorf("header Content-Security-Policy received 2 times"),
+			fmt.Errorf("header Vary received 2 times")}},
+	{htmlct, &HeaderUnique{}, nil},
+	{htmlct, &HeaderUnique{Required: true}, prepareError},
+}
+
+func TestHeaderUnique(t *testing.T) {
+	for i, tc := range headerUniqueTests {
+		runTest(t, i, tc)
+	}
+}
+
 var redirectTests = []TC{
 	{jsonct, &Redirect{To: "http://example.org/foo/bar"}, nil},
 	{jsonct, &Redirect{To: "http://example.org/..."}, nil},
@@ -73,6 +184,68 @@ func TestRedirect(t *testing.T) {
 	}
 }
 
+var secureResponse = Response{Response: &http.Response{
+	StatusCode: 200,
+	Header: http.Header{
+		"Strict-Transport-Security": []string{"max-age=31536000; includeSubDomains"},
+		"Content-Security-Policy":   []string{"default-src 'self'"},
+		"X-Content-Type-Options":    []string{"nosniff"},
+		"X-Frame-Options":           []string{"DENY"},
+	},
+}}
+
+var securityHeadersTests = []TC{
+	{secureResponse, &SecurityHeaders{
+		StrictTransportSecurity: &HeaderRequirement{Required: true},
+		XContentTypeOptions:     &HeaderRequirement{Condition: Condition{Equals: "nosniff"}},
+	}, nil},
+	{secureResponse, &SecurityHeaders{
+		StrictTransportSecurity: &HeaderRequirement{Condition: Condition{Prefix: "max-age="}},
+		ContentSecurityPolicy:   &HeaderRequirement{Required: true, Condition: Condition{Contains: "'self'"}},
+		XFrameOptions:           &HeaderRequirement{Condition: Condition{Regexp: "^(DENY|SAMEORIGIN)$"}},
+		ReferrerPolicy:          &HeaderRequirement{Condition: Condition{Equals: "no-referrer"}},
+	}, nil},
+	{secureResponse, &SecurityHeaders{
+		ReferrerPolicy: &HeaderRequirement{Required: true},
+	}, someError},
+	{secureResponse, &SecurityHeaders{
+		XFrameOptions: &HeaderRequirement{Condition: Condition{Equals: "SAMEORIGIN"}},
+	}, someError},
+	{jsonct, &SecurityHeaders{
+		XFrameOptions: &HeaderRequirement{Required: true},
+	}, someError},
+	{secureResponse, &SecurityHeaders{}, prepareError},
+	{secureResponse, &SecurityHeaders{
+		XFrameOptions: &HeaderRequirement{Condition: Condition{Regexp: "("}},
+	}, prepareError},
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	for i, tc := range securityHeadersTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestSecurityHeadersReportsAll(t *testing.T) {
+	check := &SecurityHeaders{
+		StrictTransportSecurity: &HeaderRequirement{Required: true},
+		ContentSecurityPolicy:   &HeaderRequirement{Required: true},
+		XContentTypeOptions:     &HeaderRequirement{Required: true},
+		XFrameOptions:           &HeaderRequirement{Condition: Condition{Equals: "DENY"}},
+	}
+	if err := check.Prepare(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	test := &Test{Response: Response{Response: &http.Response{
+		Header: http.Header{"X-Frame-Options": []string{"ALLOW-FROM x"}},
+	}}}
+	err := check.Execute(test)
+	el, ok := err.(ErrorList)
+	if !ok || len(el) != 4 {
+		t.Errorf("Got %v, want 4 errors", err)
+	}
+}
+
 func TestDotMatch(t *testing.T) {
 	for i, tc := range []struct {
 		g, w  string
@@ -123,3 +296,24 @@ func TestRedirectChain(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+func TestRedirectCount(t *testing.T) {
+	resp := Response{Redirections: []string{
+		"http://www.example.org/a",
+		"http://www.example.org/b",
+	}}
+	two, three := 2.0, 3.0
+
+	rdCountTests := []TC{
+		{resp, &RedirectCount{Condition{Equals: "2"}}, nil},
+		{resp, &RedirectCount{Condition{LessThan: &three}}, nil},
+		{resp, &RedirectCount{Condition{LessThan: &two}}, someError},
+		{Response{}, &RedirectCount{Condition{Equals: "0"}}, nil},
+		{Response{}, &RedirectCount{Condition{GreaterThan: &two}}, someError},
+		{resp, &RedirectCount{Condition{Regexp: "("}}, prepareError},
+	}
+
+	for i, tc := range rdCountTests {
+		runTest(t, i, tc)
+	}
+}
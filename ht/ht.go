@@ -7,24 +7,29 @@ package ht
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"math"
 	"mime"
 	"mime/multipart"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/textproto"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -42,10 +47,17 @@ var (
 
 	// DefaultClientTimeout is the timeout used by the http clients.
 	DefaultClientTimeout = 10 * time.Second
+
+	// DefaultMaxBodySize is the maximal number of bytes of a response
+	// body read into memory if the test does not set MaxBodySize.
+	DefaultMaxBodySize int64 = 64 << 20
 )
 
 // Transport is the http Transport used while making requests.
 // It is exposed to allow different Timeouts or laxer TLS settings.
+// It must not be modified once tests have been run: Tests using
+// Request.Proxy, HTTPVersion or DisableKeepAlive use a copy of it made
+// on first use which does not see later changes.
 var Transport = &http.Transport{
 	Proxy: http.ProxyFromEnvironment,
 	Dial: (&net.Dialer{
@@ -69,7 +81,9 @@ func float64ToString(f float64) string {
 // Request is a HTTP request.
 type Request struct {
 	// Method is the HTTP method to use.
-	// A empty method is equivalent to "GET"
+	// A empty method is equivalent to "GET".
+	// Checks of the response body (e.g. Body, JSON or HTMLContains)
+	// are skipped for HEAD and OPTIONS requests.
 	Method string `json:",omitempty"`
 
 	// URL ist the URL of the request.
@@ -82,8 +96,9 @@ type Request struct {
 	// files by special formated values.
 	// The following formats are recognized:
 	//    @file:/path/to/thefile
-	//         read in /path/to/thefile and use its content as the
-	//         parameter value. The path may be relative.
+	//         stream the content of /path/to/thefile as the parameter
+	//         value. The path may be relative. The file is not read
+	//         into memory but sent directly from disk.
 	//    @vfile:/path/to/thefile
 	//         read in /path/to/thefile and perform variable substitution
 	//         in its content to yield the parameter value.
@@ -115,9 +130,9 @@ type Request struct {
 	// empty if Params are sent as multipart or form-urlencoded.
 	Body string `json:",omitempty"`
 
-	// FollowRedirects determines if automatic following of
-	// redirects should be done.
-	FollowRedirects bool `json:",omitempty"`
+	// FollowRedirects determines if and how redirects are followed,
+	// see RedirectPolicy.
+	FollowRedirects RedirectPolicy `json:",omitempty"`
 
 	// BasicAuthUser and BasicAuthPass contain optional username and
 	// password which will be sent in a Basic Authentication header.
@@ -133,9 +148,74 @@ type Request struct {
 	// Timeout of this request. If zero use DefaultClientTimeout.
 	Timeout time.Duration `json:",omitempty"`
 
+	// Proxy is the URL of the proxy to use for this request, e.g.
+	// "http://proxy.example.org:3128" or "socks5://localhost:1080".
+	// The schemes http, https and socks5 are supported.
+	// A non-empty Proxy overrides the proxy settings derived from the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `json:",omitempty"`
+
+	// HTTPVersion selects the HTTP protocol version to use:
+	//   "1.1"          : use HTTP/1.1 only, even if the server offers HTTP/2
+	//   "2"            : try to negotiate HTTP/2 via TLS ALPN
+	//   "auto" or ""   : the default behaviour of Transport
+	// The protocol actually used is available in Response.Response.Proto.
+	HTTPVersion string `json:",omitempty"`
+
+	// DisableKeepAlive forces a fresh connection for this request which
+	// is closed after the response has been read. Whether a connection
+	// was reused is reported in Response.ConnReused.
+	DisableKeepAlive bool `json:",omitempty"`
+
+	// CompressBody compresses the request body with "gzip" or "deflate"
+	// and sets the Content-Encoding header accordingly. Variables are
+	// substituted before compression; SentBody is the uncompressed body.
+	// Multipart bodies cannot be compressed.
+	CompressBody string `json:",omitempty"`
+
+	// FromForm populates the request from a HTML form in the response
+	// of the previous test: The form's action, method and the values of
+	// its controls (e.g. hidden CSRF tokens) are used unless URL, Method
+	// or the parameter are given explicitly. This allows to submit
+	// a form with only the visible fields overridden in Params.
+	FromForm *Form `json:",omitempty"`
+
+	// Sign, if non-nil, signs the request with a HMAC, see Signing.
+	Sign *Signing `json:",omitempty"`
+
+	// AWSSigV4, if non-nil, signs the request with AWS Signature
+	// Version 4, see AWSSigV4.
+	AWSSigV4 *AWSSigV4 `json:",omitempty"`
+
+	// NTLM, if non-nil, authenticates the request with NTLM. This is
+	// experimental, see NTLM.
+	NTLM *NTLM `json:",omitempty"`
+
+	// SaveBodyTo is the name of a file the response body is streamed to,
+	// e.g. "{{TEST_DIR}}/report.pdf". Missing directories are created.
+	// To save memory on large downloads the body is kept in memory
+	// (and shows up in Response.BodyStr and the reports) only if
+	// one of the checks or variable extractions needs it; checks which
+	// inspect only the status, headers or cookies do not.
+	SaveBodyTo string `json:",omitempty"`
+
+	// MaxBodySize limits the number of bytes of the (decompressed)
+	// response body read into memory to protect against huge responses
+	// and decompression bombs. Zero means DefaultMaxBodySize, a negative
+	// value means unlimited. A larger body is truncated: The checks are
+	// executed on the first MaxBodySize bytes but the test errors.
+	// Bodies streamed to SaveBodyTo are not limited.
+	MaxBodySize int64 `json:",omitempty"`
+
 	Request    *http.Request `json:"-"` // the 'real' request
 	SentBody   string        `json:"-"` // the 'real' body
 	SentParams url.Values    `json:"-"` // the 'real' parameters
+
+	// openBody, if non-nil, opens the request body which is streamed
+	// from disk instead of being taken from SentBody. The body is
+	// bodySize bytes long.
+	openBody func() (io.ReadCloser, error)
+	bodySize int64
 }
 
 // Response captures information about a http response.
@@ -144,6 +224,9 @@ type Response struct {
 	// closed already.
 	Response *http.Response `json:",omitempty"`
 
+	// Sent is the (client) time the request was sent.
+	Sent time.Time
+
 	// Duration to receive response and read the whole body.
 	Duration time.Duration `json:",omitempty"`
 
@@ -151,8 +234,100 @@ type Response struct {
 	BodyStr string `json:",omitempty"`
 	BodyErr error  `json:",omitempty"`
 
-	// Redirections records the URLs of automatic GET requests due to redirects.
+	// WireSize is the size of the body as transmitted, i.e. before
+	// decompressing a gzip encoded body.
+	WireSize int64 `json:",omitempty"`
+
+	// Truncated is set if the body exceeded Request.MaxBodySize and
+	// BodyStr contains only the first MaxBodySize bytes.
+	Truncated bool `json:",omitempty"`
+
+	// Redirections records the URLs of automatic GET requests due to
+	// redirects, i.e. the full redirect chain.
 	Redirections []string `json:",omitempty"`
+
+	// ConnReused is set if the response was received on a connection
+	// which was kept alive from a previous request.
+	ConnReused bool `json:",omitempty"`
+
+	// Timing is the breakdown of Duration into the phases of the request.
+	Timing Timing
+}
+
+// Timing is the breakdown of the duration of a request into its phases.
+// Phases which did not happen (e.g. DNS lookup and connecting on a reused
+// connection) are zero. If redirects are followed DNS, Connect and TLS are
+// summed up over the whole redirect chain while TTFB lasts from the start
+// of the first request till the first byte of the final response and Body
+// covers reading the final body only.
+type Timing struct {
+	DNS     time.Duration `json:",omitempty"` // DNS lookup
+	Connect time.Duration `json:",omitempty"` // establishing the TCP connection
+	TLS     time.Duration `json:",omitempty"` // TLS handshake
+	TTFB    time.Duration `json:",omitempty"` // start of request till first response byte
+	Body    time.Duration `json:",omitempty"` // first response byte till body fully read
+}
+
+// timingRecorder records the Timing of a request via a httptrace.ClientTrace.
+type timingRecorder struct {
+	mu                            sync.Mutex
+	start                         time.Time
+	dnsStart, connStart, tlsStart time.Time
+	firstByte                     time.Time
+	timing                        Timing
+	reused                        bool
+}
+
+func newTimingRecorder(start time.Time) *timingRecorder {
+	return &timingRecorder{start: start}
+}
+
+func (r *timingRecorder) trace() *httptrace.ClientTrace {
+	now := func(t *time.Time) {
+		r.mu.Lock()
+		*t = time.Now()
+		r.mu.Unlock()
+	}
+	since := func(d *time.Duration, t *time.Time) {
+		r.mu.Lock()
+		if !t.IsZero() {
+			*d += time.Since(*t)
+		}
+		r.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { now(&r.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { since(&r.timing.DNS, &r.dnsStart) },
+		ConnectStart:      func(_, _ string) { now(&r.connStart) },
+		ConnectDone:       func(_, _ string, _ error) { since(&r.timing.Connect, &r.connStart) },
+		TLSHandshakeStart: func() { now(&r.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			since(&r.timing.TLS, &r.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.reused = info.Reused
+			r.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { now(&r.firstByte) },
+	}
+}
+
+// done returns the recorded Timing and whether the connection was reused
+// once the response body has been read completely at end.
+func (r *timingRecorder) done(end time.Time) (Timing, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.firstByte.IsZero() {
+		r.timing.TTFB = r.firstByte.Sub(r.start)
+		r.timing.Body = end.Sub(r.firstByte)
+	}
+	return r.timing, r.reused
+}
+
+// RedirectCount returns the number of redirects followed automatically.
+func (resp *Response) RedirectCount() int {
+	return len(resp.Redirections)
 }
 
 // Body returns a reader of the response body.
@@ -195,10 +370,17 @@ type Test struct {
 	Name        string
 	Description string `json:",omitempty"`
 
+	// Criticality of the test. Failures of tests less critical than
+	// the threshold of their suite do not fail the suite.
+	Criticality Criticality `json:",omitempty"`
+
 	// Request is the HTTP request.
 	Request Request
 
 	// Checks contains all checks to perform on the response to the HTTP request.
+	// The checks are executed in the given order with the exception of
+	// expensive checks (see Coster) which are executed last and only if
+	// all other checks passed.
 	Checks CheckList
 
 	// Execution controls the test execution
@@ -207,10 +389,40 @@ type Test struct {
 	// Jar is the cookie jar to use
 	Jar *cookiejar.Jar `json:"-"`
 
+	// Transport, if non-nil, is used to make the request instead of the
+	// package global Transport. This allows to inject e.g. tracing or
+	// replaying RoundTrippers without modifying the global Transport.
+	// Request.Proxy, Request.HTTPVersion, Request.DisableKeepAlive and
+	// Request.NTLM require Transport to be an *http.Transport (which is
+	// copied before modification) or a TransportWrapper; the test is
+	// Bogus for any other RoundTripper.
+	Transport http.RoundTripper `json:"-"`
+
+	// RateLimiter, if non-nil, limits the rate of the requests made by
+	// this test, including the requests of checks like Links or Latency.
+	// Tests sharing a RateLimiter share its rate.
+	RateLimiter *RateLimiter `json:"-"`
+
+	// LinkSemaphore, if non-nil, bounds the number of link requests made
+	// concurrently by the Links checks of all tests sharing it to its
+	// capacity. It takes precedence over MaxLinkConcurrency.
+	LinkSemaphore chan struct{} `json:"-"`
+
 	// Variables contains name/value-pairs used for variable substitution
 	// in files read in, e.g. for Request.Body = "@vfile:/path/to/file".
 	Variables map[string]string `json:",omitempty"`
 
+	// RandomSeed, if non-zero, seeds a random source private to this
+	// test which is used for all {{RANDOM ...}} substitutions. This
+	// makes the random values of the test reproducible, independent of
+	// the order in which other tests consume random numbers.
+	RandomSeed int64 `json:",omitempty"`
+
+	// Deadline, if non-zero, is the point in time by which the test must
+	// be done: The request timeout is shortened accordingly and no
+	// further tries are started once the deadline would be missed.
+	Deadline time.Time `json:"-"`
+
 	// The following results are filled during Run.
 	// This should be collected into something like struct TestResult{...}.
 	Response     Response      `json:",omitempty"`
@@ -234,9 +446,11 @@ type Test struct {
 	ExValues map[string]Extraction `json:",omitempty"`
 
 	// Log is the logger to use
-	Log *log.Logger
+	Log Logger
 
 	client *http.Client
+	ctx    context.Context // of the current RunContext
+	ntlm   *http.Transport // private to the current run, see releaseTransport
 }
 
 // Disable disables t by setting the maximum number of tries to -1.
@@ -251,6 +465,7 @@ type CheckResult struct {
 	Status   Status        // Outcome of check. All status but Error
 	Duration time.Duration // How long the check took.
 	Error    ErrorList     // For a Status of Bogus or Fail.
+	Warning  ErrorList     // Failures reported as warnings only, see Warn.
 }
 
 // Extraction captures the result of a variable extraction.
@@ -317,6 +532,10 @@ outer:
 
 	m.FollowRedirects = r.FollowRedirects
 	m.Chunked = r.Chunked
+	m.DisableKeepAlive = r.DisableKeepAlive
+	if err := allNonemptyMustBeSame(&(m.CompressBody), r.CompressBody); err != nil {
+		return err
+	}
 
 	if err := onlyOneMayBeNonempty(&(m.BasicAuthUser), r.BasicAuthUser); err != nil {
 		return err
@@ -325,6 +544,48 @@ outer:
 		return err
 	}
 
+	if err := allNonemptyMustBeSame(&(m.Proxy), r.Proxy); err != nil {
+		return err
+	}
+	if err := allNonemptyMustBeSame(&(m.HTTPVersion), r.HTTPVersion); err != nil {
+		return err
+	}
+	if err := allNonemptyMustBeSame(&(m.SaveBodyTo), r.SaveBodyTo); err != nil {
+		return err
+	}
+
+	if r.FromForm != nil {
+		if m.FromForm != nil {
+			return errors.New("only one FromForm may be given")
+		}
+		m.FromForm = r.FromForm
+	}
+	if r.Sign != nil {
+		if m.Sign != nil {
+			return errors.New("only one Sign may be given")
+		}
+		m.Sign = r.Sign
+	}
+	if r.AWSSigV4 != nil {
+		if m.AWSSigV4 != nil {
+			return errors.New("only one AWSSigV4 may be given")
+		}
+		m.AWSSigV4 = r.AWSSigV4
+	}
+	if r.NTLM != nil {
+		if m.NTLM != nil {
+			return errors.New("only one NTLM may be given")
+		}
+		m.NTLM = r.NTLM
+	}
+	if r.MaxBodySize != 0 {
+		if m.MaxBodySize != 0 && m.MaxBodySize != r.MaxBodySize {
+			return fmt.Errorf("Cannot merge MaxBodySize %d into %d",
+				r.MaxBodySize, m.MaxBodySize)
+		}
+		m.MaxBodySize = r.MaxBodySize
+	}
+
 	return nil
 }
 
@@ -342,9 +603,21 @@ outer:
 //       Body       Only one may be nonempty
 //       FollowRdr  Last wins
 //       Chunked    Last wins
+//       Proxy      All nonempty must be the same
+//       HTTPVers   All nonempty must be the same
+//       NoKeepAliv Last wins
+//       SaveBodyTo All nonempty must be the same
+//       FromForm   Only one may be given
+//       Compress   All nonempty must be the same
+//       Sign       Only one may be given
+//       AWSSigV4   Only one may be given
+//       NTLM       Only one may be given
+//       MaxBody    All nonzero must be the same
 //     Checks       Append all checks
 //     VarEx        Merge, same keys must have same value
 //     TestVars     Use values from first only.
+//     RandomSeed   All nonzero must be the same
+//     Criticality  Use largest
 //     Poll
 //       Max        Use largest
 //       Sleep      Use largest
@@ -393,6 +666,16 @@ func Merge(tests ...*Test) (*Test, error) {
 		if t.Execution.Verbosity > m.Execution.Verbosity {
 			m.Execution.Verbosity = t.Execution.Verbosity
 		}
+		if t.Criticality > m.Criticality {
+			m.Criticality = t.Criticality
+		}
+		if t.RandomSeed != 0 {
+			if m.RandomSeed != 0 && m.RandomSeed != t.RandomSeed {
+				return &m, fmt.Errorf("cannot merge random seeds %d and %d",
+					m.RandomSeed, t.RandomSeed)
+			}
+			m.RandomSeed = t.RandomSeed
+		}
 		m.Execution.PreSleep += t.Execution.PreSleep
 		m.Execution.InterSleep += t.Execution.InterSleep
 		m.Execution.PostSleep += t.Execution.PostSleep
@@ -407,6 +690,47 @@ func Merge(tests ...*Test) (*Test, error) {
 	return &m, nil
 }
 
+// RepeatWithHeaders returns one copy of t for each entry of negotiation
+// which maps the Accept header to send to the expected content type of
+// the response (as in ContentType.Is). Content negotiation can be tested
+// like this:
+//     tests, err := RepeatWithHeaders(test, map[string]string{
+//         "application/json": "json",
+//         "application/xml":  "xml",
+//     })
+// ContentType checks of t are replaced in the copies which are ordered
+// by their Accept header.
+func RepeatWithHeaders(t *Test, negotiation map[string]string) ([]*Test, error) {
+	accepts := make([]string, 0, len(negotiation))
+	for accept := range negotiation {
+		accepts = append(accepts, accept)
+	}
+	sort.Strings(accepts)
+
+	tests := make([]*Test, 0, len(accepts))
+	for _, accept := range accepts {
+		rt, err := Merge(t)
+		if err != nil {
+			return nil, err
+		}
+		rt.Name = fmt.Sprintf("%s (Accept: %s)", t.Name, accept)
+		rt.Description = t.Description
+		rt.Jar, rt.Log = t.Jar, t.Log
+		rt.Request.Header.Set("Accept", accept)
+		checks := CheckList{}
+		for _, ck := range rt.Checks {
+			switch ck.(type) {
+			case ContentType, *ContentType:
+				continue
+			}
+			checks = append(checks, ck)
+		}
+		rt.Checks = append(checks, ContentType{Is: negotiation[accept]})
+		tests = append(tests, rt)
+	}
+	return tests, nil
+}
+
 // PopulateCookies populates t.Request.Cookies with the those
 // cookies from jar which would be sent to u.
 func (t *Test) PopulateCookies(jar *cookiejar.Jar, u *url.URL) {
@@ -459,7 +783,15 @@ func (t *Test) AsJSON() ([]byte, error) {
 // request, problems reading the body or any failing checks do not trigger a
 // non-nil return value.
 func (t *Test) Run() error {
+	return t.RunContext(context.Background())
+}
+
+// RunContext is like Run but stops the execution of t once ctx is done:
+// A request in flight is canceled, no further tries are made and sleeps
+// are cut short. A canceled test has status Error.
+func (t *Test) RunContext(ctx context.Context) error {
 	t.Started = time.Now()
+	t.ctx = ctx
 	defer func() { t.FullDuration = time.Since(t.Started) }()
 
 	t.infof("Running")
@@ -486,30 +818,58 @@ func (t *Test) Run() error {
 
 	if t.Execution.PreSleep > 0 {
 		t.debugf("PreSleep %s", t.Execution.PreSleep)
-		time.Sleep(t.Execution.PreSleep)
+		sleep(ctx, t.Execution.PreSleep)
 	}
 
 	// Try until first success.
 	start := time.Now()
 	try := 1
 	for ; try <= t.Execution.Tries; try++ {
+		if try > 1 && !t.Deadline.IsZero() &&
+			time.Now().Add(t.Execution.Wait).After(t.Deadline) {
+			t.infof("Deadline reached, no more tries")
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			t.infof("Canceled: %s", err)
+			t.Status, t.Error = Error, err
+			break
+		}
 		t.Tries = try
 		if try > 1 {
 			t.infof("Retry %d", try)
 			if t.Execution.Wait > 0 {
 				t.debugf("Waiting %s", t.Execution.Wait)
-				time.Sleep(t.Execution.Wait)
+				if !sleep(ctx, t.Execution.Wait) {
+					t.Status, t.Error = Error, ctx.Err()
+					break
+				}
 			}
 		}
-		t.resetRequest()
 		// Clear status and error; is updated in executeChecks.
 		t.Status, t.Error = NotRun, nil
 		t.Response = Response{}
+		if err := t.resetRequest(); err != nil {
+			t.Status, t.Error = Error, err
+			continue
+		}
+		if !t.Deadline.IsZero() && t.client != nil {
+			left := time.Until(t.Deadline)
+			if left <= 0 {
+				t.Status, t.Error = Error, ErrDeadlineExceeded
+				break
+			}
+			if t.client.Timeout == 0 || left < t.client.Timeout {
+				t.client.Timeout = left
+			}
+		}
+		t.Request.Request = t.Request.Request.WithContext(ctx)
 		t.execute()
 		if t.Status == Pass {
 			break
 		}
 	}
+	t.releaseTransport()
 	t.Duration = time.Since(start)
 	if t.Execution.Tries > 1 {
 		if t.Status == Pass {
@@ -521,15 +881,56 @@ func (t *Test) Run() error {
 
 	t.infof("Result: %s (%s %s) %d tries", t.Status,
 		t.Duration, t.Response.Duration, t.Tries)
+	if t.Status > Pass {
+		t.debugf("Reproduce with:\n%s", t.CurlCall())
+	}
 
 	if t.Execution.PostSleep > 0 {
 		t.debugf("PostSleep %s", t.Execution.PostSleep)
-		time.Sleep(t.Execution.PostSleep)
+		sleep(ctx, t.Execution.PostSleep)
 	}
 
 	return nil
 }
 
+// context returns the context t is run with. Checks making additional
+// requests use it to stop them once t is canceled.
+func (t *Test) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// sleep for d or until ctx is done and report whether d elapsed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Prepare compiles and prepares all checks of t and crafts the underlying
+// http request without sending it. If anything is malformed t's Status is
+// set to Bogus and the error is returned. Prepare is useful to validate
+// tests without sending any requests. The request of a test which will be
+// populated from a form is not prepared as it is known only after the
+// previous test has been executed.
+func (t *Test) Prepare() error {
+	err := t.prepareChecks()
+	if err == nil && t.Request.FromForm == nil {
+		err = t.prepareRequest()
+	}
+	if err != nil {
+		t.Status, t.Error = Bogus, err
+	}
+	return err
+}
+
 // execute does a single request and check the response.
 func (t *Test) execute() {
 	var err error
@@ -548,6 +949,11 @@ func (t *Test) execute() {
 		} else {
 			t.Status = Pass
 		}
+		if t.Response.Truncated {
+			t.Status = Error
+			t.Error = fmt.Errorf("response body exceeds MaxBodySize of %d bytes",
+				t.maxBodySize())
+		}
 	} else {
 		t.Status = Error
 		t.Error = err
@@ -584,12 +990,21 @@ func (t *Test) prepareChecks() error {
 }
 
 // resetRequest rewinds the request body reader.
-func (t *Test) resetRequest() {
+func (t *Test) resetRequest() error {
+	if t.Request.openBody != nil {
+		body, err := t.Request.openBody()
+		if err != nil {
+			return err
+		}
+		t.Request.Request.Body = body
+		return nil
+	}
 	if t.Request.SentBody == "" {
-		return
+		return nil
 	}
 	body := ioutil.NopCloser(strings.NewReader(t.Request.SentBody))
 	t.Request.Request.Body = body
+	return nil
 }
 
 // prepare the test for execution by crafting the underlying http request
@@ -633,33 +1048,17 @@ func (t *Test) prepareRequest() error {
 		to = t.Request.Timeout
 	}
 
-	if t.Request.FollowRedirects {
-		cr := func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return errors.New("stopped after 10 redirects")
-			}
-			if req.URL.Host == t.Request.Request.URL.Host &&
-				t.Request.BasicAuthUser != "" {
-				if user, pass, ok := t.Request.Request.BasicAuth(); ok {
-					req.SetBasicAuth(user, pass)
-				}
-			}
-			t.Response.Redirections = append(t.Response.Redirections, req.URL.String())
-			return nil
-		}
-		t.client = &http.Client{
-			Transport:     Transport,
-			CheckRedirect: cr,
-			Jar:           nil,
-			Timeout:       to,
-		}
-	} else {
-		t.client = &http.Client{
-			Transport:     Transport,
-			CheckRedirect: dontFollowRedirects,
-			Jar:           nil,
-			Timeout:       to,
-		}
+	transport, err := t.requestTransport()
+	if err != nil {
+		t.errorf("%s", err.Error())
+		return err
+	}
+
+	t.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: t.checkRedirect(),
+		Jar:           nil,
+		Timeout:       to,
 	}
 	if t.Jar != nil {
 		t.client.Jar = t.Jar
@@ -668,6 +1067,216 @@ func (t *Test) prepareRequest() error {
 	return nil
 }
 
+// inheritTransport makes the sub-request sub of t use the same transport
+// as t: The Transport itself as well as proxy, HTTP version and keep-alive
+// settings, the rate limiter and the link semaphore.
+func (t *Test) inheritTransport(sub *Test) {
+	sub.Transport = t.Transport
+	sub.RateLimiter = t.RateLimiter
+	sub.LinkSemaphore = t.LinkSemaphore
+	sub.Request.Proxy = t.Request.Proxy
+	sub.Request.HTTPVersion = t.Request.HTTPVersion
+	sub.Request.DisableKeepAlive = t.Request.DisableKeepAlive
+}
+
+// auxClient returns a client for auxiliary requests made by checks, e.g.
+// to fetch a JSON Web Key Set: It uses the transport of t (without NTLM
+// authentication) and the given timeout or DefaultClientTimeout if zero.
+func (t *Test) auxClient(timeout time.Duration) (*http.Client, error) {
+	transport, err := t.transport()
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultClientTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// followUp returns a new test which requests u with the given method and
+// the header, authentication, cookies and transport of t. It is used by
+// checks which make additional requests which should be run with the
+// context of t.
+func (t *Test) followUp(name, method, u string) *Test {
+	f := &Test{
+		Name: name + " of " + t.Name,
+		Request: Request{
+			Method:          method,
+			URL:             u,
+			Header:          make(http.Header),
+			FollowRedirects: t.Request.FollowRedirects,
+			BasicAuthUser:   t.Request.BasicAuthUser,
+			BasicAuthPass:   t.Request.BasicAuthPass,
+			Timeout:         t.Request.Timeout,
+			MaxBodySize:     t.Request.MaxBodySize,
+			CompressBody:    t.Request.CompressBody,
+			Sign:            t.Request.Sign,
+			AWSSigV4:        t.Request.AWSSigV4,
+			NTLM:            t.Request.NTLM,
+		},
+		Execution: Execution{
+			Verbosity: t.Execution.Verbosity - 1,
+		},
+		Jar: t.Jar,
+		Log: t.Log,
+	}
+	t.inheritTransport(f)
+	for h, v := range t.Request.Header {
+		f.Request.Header[h] = append([]string(nil), v...)
+	}
+	return f
+}
+
+// A TransportWrapper is a http.RoundTripper which wraps the real transport,
+// e.g. to record or replay responses. If the Transport of a Test is a
+// TransportWrapper it wraps the transport derived from the global Transport
+// for the test's Request.Proxy, HTTPVersion and DisableKeepAlive and, for
+// the test's own request, the NTLM handshake.
+type TransportWrapper interface {
+	http.RoundTripper
+	Wrap(transport http.RoundTripper) http.RoundTripper
+}
+
+// transport returns the http.RoundTripper to use for t. This is t.Transport
+// if set and the global Transport otherwise unless t.Request needs special
+// settings like an explicit proxy or a fixed HTTP version in which case a
+// modified copy is returned.
+func (t *Test) transport() (http.RoundTripper, error) {
+	transport, err := t.unwrappedTransport()
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := t.Transport.(TransportWrapper); ok {
+		return w.Wrap(transport), nil
+	}
+	return transport, nil
+}
+
+// requestTransport returns the http.RoundTripper for the request of t.
+// It differs from transport only for NTLM authenticated requests: The NTLM
+// handshake is done over a private copy of the unwrapped transport which
+// then gets wrapped by a TransportWrapper.
+func (t *Test) requestTransport() (http.RoundTripper, error) {
+	if t.Request.NTLM == nil {
+		return t.transport()
+	}
+	transport, err := t.unwrappedTransport()
+	if err == nil {
+		transport, err = t.ntlmTransport(transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := t.Transport.(TransportWrapper); ok {
+		return w.Wrap(transport), nil
+	}
+	return transport, nil
+}
+
+// unwrappedTransport is like transport but does not wrap the transport
+// in t.Transport if this is a TransportWrapper.
+func (t *Test) unwrappedTransport() (http.RoundTripper, error) {
+	if _, ok := t.Transport.(TransportWrapper); ok {
+		return t.deriveTransport(Transport)
+	}
+
+	base := Transport
+	if t.Transport != nil {
+		tr, ok := t.Transport.(*http.Transport)
+		if !ok {
+			if t.Request.needsDerivedTransport() {
+				return nil, fmt.Errorf("Proxy, HTTPVersion and DisableKeepAlive need an *http.Transport, have %T",
+					t.Transport)
+			}
+			return t.Transport, nil
+		}
+		base = tr
+	}
+	return t.deriveTransport(base)
+}
+
+// needsDerivedTransport reports whether r needs a modified copy of the
+// transport. An HTTPVersion of "auto" keeps the transport as is.
+func (r Request) needsDerivedTransport() bool {
+	version := r.HTTPVersion
+	return r.Proxy != "" || (version != "" && version != "auto") || r.DisableKeepAlive
+}
+
+// derivedTransport identifies a copy of base modified for special request
+// settings.
+type derivedTransport struct {
+	base             *http.Transport
+	proxy, version   string
+	disableKeepAlive bool
+}
+
+// derivedTransports caches the modified copies of transports so that
+// repeated runs of tests with the same settings share one connection pool.
+// Changes made to a base transport after it was copied do not propagate,
+// so base transports like Transport must not be changed after first use.
+var derivedTransports = struct {
+	sync.Mutex
+	m map[derivedTransport]*http.Transport
+}{m: make(map[derivedTransport]*http.Transport)}
+
+// deriveTransport returns base or a copy of base modified for the proxy,
+// HTTP version and keep-alive settings of t.Request. Copies are cached and
+// reused.
+func (t *Test) deriveTransport(base *http.Transport) (http.RoundTripper, error) {
+	if !t.Request.needsDerivedTransport() {
+		return base, nil
+	}
+	version := t.Request.HTTPVersion
+
+	key := derivedTransport{
+		base:             base,
+		proxy:            t.Request.Proxy,
+		version:          version,
+		disableKeepAlive: t.Request.DisableKeepAlive,
+	}
+	derivedTransports.Lock()
+	defer derivedTransports.Unlock()
+	if transport, ok := derivedTransports.m[key]; ok {
+		return transport, nil
+	}
+
+	transport := base.Clone()
+	transport.DisableKeepAlives = t.Request.DisableKeepAlive
+
+	if t.Request.Proxy != "" {
+		proxyURL, err := url.Parse(t.Request.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("malformed proxy %q: %s", t.Request.Proxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported scheme %q of proxy %q",
+				proxyURL.Scheme, t.Request.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	switch version {
+	case "", "auto":
+		// Keep whatever Transport does.
+	case "1.1":
+		// A non-nil, empty TLSNextProto disables HTTP/2.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		}
+	case "2":
+		transport.ForceAttemptHTTP2 = true
+	default:
+		return nil, fmt.Errorf("unknown HTTP version %q", version)
+	}
+
+	derivedTransports.m[key] = transport
+	return transport, nil
+}
+
 // newRequest sets up the request field of t.
 // If a sepcial Content-Type header is needed (e.g. because of a multipart
 // body) it is returned.
@@ -680,6 +1289,8 @@ func (t *Test) newRequest() (contentType string, err error) {
 		t.Request.ParamsAs = "URL"
 	}
 
+	t.Request.openBody, t.Request.bodySize = nil, 0
+
 	rurl := t.Request.URL
 	prurl, err := url.Parse(rurl)
 	if err != nil {
@@ -721,15 +1332,11 @@ func (t *Test) newRequest() (contentType string, err error) {
 			encoded := url.Values(urlValues).Encode()
 			t.Request.SentBody = encoded
 		case "multipart":
-			b, boundary, err := multipartBody(t.Request.Params, t.Variables)
-			if err != nil {
-				return "", err
-			}
-			bb, err := ioutil.ReadAll(b)
+			chunks, boundary, err := multipartBody(t.Request.Params, t.Variables)
 			if err != nil {
 				return "", err
 			}
-			t.Request.SentBody = string(bb)
+			t.Request.SentBody, t.Request.openBody, t.Request.bodySize = streamChunks(chunks)
 			contentType = "multipart/form-data; boundary=" + boundary
 		default:
 			err := fmt.Errorf("unknown parameter method %q", t.Request.ParamsAs)
@@ -746,20 +1353,84 @@ func (t *Test) newRequest() (contentType string, err error) {
 		t.Request.SentBody = bodydata
 	}
 
+	if t.Request.CompressBody != "" && t.Request.SentBody != "" {
+		if err := t.Request.compressBody(); err != nil {
+			return "", err
+		}
+	}
+	if t.Request.Sign != nil {
+		if err := t.Request.Sign.validate(); err != nil {
+			return "", fmt.Errorf("bad Sign: %s", err)
+		}
+		if _, ok := t.Variables[t.Request.Sign.SecretVar]; !ok {
+			return "", fmt.Errorf("bad Sign: no variable %s", t.Request.Sign.SecretVar)
+		}
+	}
+	if t.Request.AWSSigV4 != nil {
+		if err := t.Request.AWSSigV4.validate(t.Variables); err != nil {
+			return "", fmt.Errorf("bad AWSSigV4: %s", err)
+		}
+	}
+	if t.Request.NTLM != nil {
+		if err := t.Request.NTLM.validate(t.Variables); err != nil {
+			return "", fmt.Errorf("bad NTLM: %s", err)
+		}
+		if t.Request.BasicAuthUser != "" || t.Request.DisableKeepAlive {
+			return "", errors.New("bad NTLM: cannot be combined with BasicAuthUser or DisableKeepAlive")
+		}
+	}
+
 	// body := ioutil.NopCloser(strings.NewReader(t.Request.SentBody))
 	t.Request.Request, err = http.NewRequest(t.Request.Method, rurl, nil /*body*/)
 	if err != nil {
 		return "", err
 	}
+	if t.Request.CompressBody != "" && t.Request.SentBody != "" {
+		t.Request.Request.Header.Set("Content-Encoding", t.Request.CompressBody)
+	}
 
 	// Content-Length
-	if cl := len(t.Request.SentBody); cl > 0 && !t.Request.Chunked {
-		t.Request.Request.ContentLength = int64(cl)
+	cl := int64(len(t.Request.SentBody))
+	if t.Request.openBody != nil {
+		cl = t.Request.bodySize
+	}
+	if cl > 0 && !t.Request.Chunked {
+		t.Request.Request.ContentLength = cl
 	}
 
 	return contentType, nil
 }
 
+// compressBody compresses the SentBody of r according to CompressBody.
+// The compressed body is sent via openBody.
+func (r *Request) compressBody() error {
+	if r.openBody != nil {
+		return fmt.Errorf("cannot compress streamed multipart body")
+	}
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch r.CompressBody {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	default:
+		return fmt.Errorf("unknown body compression %q", r.CompressBody)
+	}
+	if _, err := io.WriteString(w, r.SentBody); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	compressed := buf.Bytes()
+	r.openBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	r.bodySize = int64(len(compressed))
+	return nil
+}
+
 // fileData allows to reading file data to be used as the value for s.
 // Handled cases if s is of the form:
 //    @file:/path/to/thefile
@@ -812,25 +1483,48 @@ var (
 // executeRequest performs the HTTP request defined in t which must have been
 // prepared by Prepare. Executing an unprepared Test results will panic.
 func (t *Test) executeRequest() error {
-	t.infof("%s %q", t.Request.Request.Method, t.Request.Request.URL.String())
+	t.event(1, "INFO", "request",
+		"method", t.Request.Request.Method, "url", t.Request.Request.URL.String())
 
 	var err error
 	abortedRedirection := false
 	t.Response.Redirections = nil
 
+	if err := t.RateLimiter.Wait(t.context()); err != nil {
+		return err
+	}
+	if t.Request.Sign != nil {
+		if err := t.Request.Sign.sign(t); err != nil {
+			return err
+		}
+	}
+	if t.Request.AWSSigV4 != nil {
+		if err := t.Request.AWSSigV4.sign(t); err != nil {
+			return err
+		}
+	}
 	start := time.Now()
+	t.Response.Sent = start
 
 	if t.Execution.Verbosity >= 4 {
 		buf := &bytes.Buffer{}
 		t.Request.Request.Write(buf)
 		t.tracef(" Full Request\n%s\n", buf.String())
 		// "Rewind body"
-		t.Request.Request.Body = ioutil.NopCloser(strings.NewReader(t.Request.SentBody))
+		if err := t.resetRequest(); err != nil {
+			return err
+		}
+	}
+
+	timing := newTimingRecorder(start)
+	t.Request.Request = t.Request.Request.WithContext(
+		httptrace.WithClientTrace(t.Request.Request.Context(), timing.trace()))
+	if t.Request.DisableKeepAlive {
+		t.Request.Request.Close = true
 	}
 
 	resp, err := t.client.Do(t.Request.Request)
-	if ue, ok := err.(*url.Error); ok && ue.Err == redirectNofollow &&
-		!t.Request.FollowRedirects {
+	if ue, ok := err.(*url.Error); ok && ue.Err == redirectNofollow {
 		// Clear err if it is just our redirect non-following policy.
 		err = nil
 		abortedRedirection = true
@@ -843,22 +1537,37 @@ func (t *Test) executeRequest() error {
 		if t.Request.Request.Method == "HEAD" || abortedRedirection {
 			goto done
 		}
+		wire := &countingReadCloser{ReadCloser: resp.Body}
 		var reader io.ReadCloser
 		switch resp.Header.Get("Content-Encoding") {
 		case "gzip":
-			reader, err = gzip.NewReader(resp.Body)
+			reader, err = gzip.NewReader(wire)
 			if err != nil {
 				t.Response.BodyErr = err
 				goto done
 			}
 			t.debugf("Unzipping gzip body")
 		default:
-			reader = resp.Body
+			reader = wire
+		}
+		// Endless streams (e.g. Server-Sent Events) are cut off by
+		// closing the body after the requested duration.
+		var cutoff *time.Timer
+		if d := t.streamDuration(); d > 0 {
+			cutoff = time.AfterFunc(d, func() { resp.Body.Close() })
+		}
+		if t.Request.SaveBodyTo != "" {
+			t.Response.BodyErr = t.saveBody(reader)
+		} else {
+			t.Response.BodyStr, t.Response.Truncated, t.Response.BodyErr =
+				readLimited(reader, t.maxBodySize())
+		}
+		if cutoff != nil && !cutoff.Stop() {
+			t.debugf("Stopped reading body after %s", t.streamDuration())
+			t.Response.BodyErr = nil
 		}
-		bb, be := ioutil.ReadAll(reader)
-		t.Response.BodyStr = string(bb)
-		t.Response.BodyErr = be
 		reader.Close()
+		t.Response.WireSize = wire.n
 		if t.Execution.Verbosity >= 4 {
 			buf := &bytes.Buffer{}
 			t.Response.Response.Header.Write(buf)
@@ -873,7 +1582,9 @@ func (t *Test) executeRequest() error {
 	}
 
 done:
-	t.Response.Duration = time.Since(start)
+	end := time.Now()
+	t.Response.Duration = end.Sub(start)
+	t.Response.Timing, t.Response.ConnReused = timing.done(end)
 
 	for i, via := range t.Response.Redirections {
 		t.infof("Redirection %d: %s", i+1, via)
@@ -884,8 +1595,81 @@ done:
 	return err
 }
 
+// maxBodySize returns the limit of the body size of t, a negative value
+// means unlimited.
+func (t *Test) maxBodySize() int64 {
+	if t.Request.MaxBodySize != 0 {
+		return t.Request.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// readLimited reads at most max bytes from r and reports whether r
+// contained more data. A negative max reads everything.
+func readLimited(r io.Reader, max int64) (string, bool, error) {
+	if max < 0 {
+		bb, err := ioutil.ReadAll(r)
+		return string(bb), false, err
+	}
+	bb, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if int64(len(bb)) > max {
+		return string(bb[:max]), true, err
+	}
+	return string(bb), false, err
+}
+
+// countingReadCloser counts the bytes read.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// saveBody streams body to the file Request.SaveBodyTo. The body is
+// kept in Response.BodyStr only if t needs it.
+func (t *Test) saveBody(body io.Reader) error {
+	name := t.Request.SaveBodyTo
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = file
+	var buf *bytes.Buffer
+	if t.needsBody() {
+		buf = &bytes.Buffer{}
+		w = io.MultiWriter(file, buf)
+	}
+	n, err := io.Copy(w, body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if buf != nil {
+		t.Response.BodyStr = buf.String()
+	}
+	t.debugf("Saved %d bytes of body to %s", n, name)
+	return err
+}
+
+// needsBody reports whether any check or variable extraction of t
+// might inspect the response body.
+func (t *Test) needsBody() bool {
+	if len(t.VarEx) > 0 {
+		return true
+	}
+	return mightInspectBody(t.Checks)
+}
+
 func (t *Test) executeFile() error {
-	t.infof("%s %q", t.Request.Request.Method, t.Request.Request.URL.String())
+	t.event(1, "INFO", "request",
+		"method", t.Request.Request.Method, "url", t.Request.Request.URL.String())
 
 	start := time.Now()
 	defer func() {
@@ -948,15 +1732,57 @@ func (t *Test) executeFile() error {
 // executeRequest. A non-nil error is returned for bogus checks and checks
 // which have errors: Just failing checks do not lead to non-nil-error
 //
-// Normally all checks in t.Checks are executed. If the first check in
-// t.Checks is a StatusCode check against 200 and it fails, then the rest of
-// the tests are skipped.
+// Normally all checks in t.Checks are executed in the order given but
+// expensive checks (those with a Cost of at least CostExpensive, e.g.
+// Screenshot or Links) are executed after all cheap checks. If a cheap
+// check fails the expensive checks are skipped. If the first check in
+// t.Checks is a StatusCode check against 200 and it fails, then the rest
+// of the tests are skipped.
 func (t *Test) executeChecks() {
 	done := false
-	for i, ck := range t.Checks {
+	bodyless := t.Request.Method == "HEAD" || t.Request.Method == "OPTIONS"
+	skip := func(i int) {
+		t.CheckResults[i].Status = Skipped
+		t.CheckResults[i].Error = nil
+		if Skipped > t.Status {
+			t.Status = Skipped
+		}
+	}
+	cheapFailed := false
+	for n, i := range checkOrder(t.Checks) {
+		ck := t.Checks[i]
+		if inspects, _ := inspectsBody(ck); bodyless && inspects {
+			t.event(1, "INFO", "skipping check", "check", i+1, "type", NameOf(ck),
+				"reason", "no response body to "+t.Request.Method+" request")
+			skip(i)
+			continue
+		}
+		expensive := CheckCost(ck) >= CostExpensive
+		if expensive && cheapFailed {
+			t.event(2, "DEBUG", "skipping check", "check", i+1, "type", NameOf(ck),
+				"reason", "expensive check after failed cheap checks")
+			skip(i)
+			continue
+		}
 		start := time.Now()
 		err := ck.Execute(t)
 		t.CheckResults[i].Duration = time.Since(start)
+		if err == ErrSkipped {
+			t.debugf("Check %d %s: Skipped", i+1, NameOf(ck))
+			skip(i)
+			continue
+		}
+		t.CheckResults[i].Warning = nil
+		if w, ok := err.(Warning); ok {
+			t.event(1, "INFO", "check warning", "check", i+1, "type", NameOf(ck),
+				"warning", w.Err.Error())
+			if el, ok := w.Err.(ErrorList); ok {
+				t.CheckResults[i].Warning = el
+			} else {
+				t.CheckResults[i].Warning = ErrorList{w.Err}
+			}
+			err = nil
+		}
 		if el, ok := err.(ErrorList); ok {
 			t.CheckResults[i].Error = el
 		} else {
@@ -972,8 +1798,11 @@ func (t *Test) executeChecks() {
 			if t.Error == nil {
 				t.Error = err
 			}
+			if !expensive {
+				cheapFailed = true
+			}
 			// Abort needles checking if all went wrong.
-			if i == 0 { // only first check is checked against StatusCode/200.
+			if n == 0 && i == 0 { // only first check is checked against StatusCode/200.
 				sc, ok := ck.(StatusCode)
 				if !ok {
 					if psc, pok := ck.(*StatusCode); pok {
@@ -982,7 +1811,8 @@ func (t *Test) executeChecks() {
 					}
 				}
 				if ok && sc.Expect == 200 {
-					t.debugf("skipping remaining tests as bad StatusCode %s", t.Response.Response.Status)
+					t.event(2, "DEBUG", "skipping remaining checks",
+						"reason", "bad status code", "status", t.Response.Response.Status)
 					// Clear Status and Error field as these might be
 					// populated from a prior try run of the test.
 					for j := 1; j < len(t.CheckResults); j++ {
@@ -1009,38 +1839,6 @@ func (t *Test) prepared() bool {
 	return t.Request.Request != nil
 }
 
-func (t *Test) errorf(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 0 && t.Log != nil {
-		format = "ERROR " + format + " [%q]"
-		v = append(v, t.Name)
-		t.Log.Printf(format, v...)
-	}
-}
-
-func (t *Test) infof(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 1 && t.Log != nil {
-		format = "INFO  " + format + " [%q]"
-		v = append(v, t.Name)
-		t.Log.Printf(format, v...)
-	}
-}
-
-func (t *Test) debugf(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 2 && t.Log != nil {
-		format = "DEBUG " + format + " [%q]"
-		v = append(v, t.Name)
-		t.Log.Printf(format, v...)
-	}
-}
-
-func (t *Test) tracef(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 3 && t.Log != nil {
-		format = "TRACE Begin [%q]" + format + "TRACE End"
-		v = append([]interface{}{t.Name}, v...)
-		t.Log.Printf(format, v...)
-	}
-}
-
 // ----------------------------------------------------------------------------
 //  Multipart bodies
 
@@ -1053,8 +1851,9 @@ func escapeQuotes(s string) string {
 // multipartBody formats the given param as a proper multipart/form-data
 // body and returns a reader ready to use as the body as well as the
 // multipart boundary to be include in the content type.
-func multipartBody(param map[string][]string, variables map[string]string) (io.ReadCloser, string, error) {
+func multipartBody(param map[string][]string, variables map[string]string) ([]bodyChunk, string, error) {
 	var body = &bytes.Buffer{}
+	var chunks []bodyChunk
 
 	isFile := func(v string) bool {
 		return strings.HasPrefix(v, "@file:") || strings.HasPrefix(v, "@vfile:")
@@ -1086,22 +1885,45 @@ func multipartBody(param map[string][]string, variables map[string]string) (io.R
 			if !isFile(vv) {
 				continue // already written
 			}
-			err := addFilePart(mpwriter, n, vv, variables)
+			file, err := addFilePart(mpwriter, n, vv, variables)
 			if err != nil {
 				return nil, "", err
 			}
+			if file != nil {
+				// Content of file is streamed: Cut body here.
+				chunks = append(chunks, bodyChunk{data: body.String()}, *file)
+				body.Reset()
+			}
 		}
 	}
 	mpwriter.Close()
+	chunks = append(chunks, bodyChunk{data: body.String()})
 
-	return ioutil.NopCloser(body), mpwriter.Boundary(), nil
+	return chunks, mpwriter.Boundary(), nil
 }
 
 // addFilePart to mpwriter where the parameter n has a @file:-value vv.
-func addFilePart(mpwriter *multipart.Writer, n, vv string, variables map[string]string) error {
-	data, basename, err := fileData(vv, variables)
-	if err != nil {
-		return err
+// Files read from disk without variable substitution are not written to
+// mpwriter but returned as a bodyChunk to be streamed later.
+func addFilePart(mpwriter *multipart.Writer, n, vv string, variables map[string]string) (*bodyChunk, error) {
+	var data, basename string
+	var chunk *bodyChunk
+	if file := strings.TrimPrefix(vv, "@file:"); file != vv && !strings.HasPrefix(file, "@") {
+		if file == "" {
+			return nil, fmt.Errorf("missing filename in @[v]file: parameter")
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		basename = path.Base(file)
+		chunk = &bodyChunk{file: file, size: info.Size()}
+	} else {
+		var err error
+		data, basename, err = fileData(vv, variables)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Doing fw, err := mpwriter.CreateFormFile(n, basename) would
@@ -1121,15 +1943,86 @@ func addFilePart(mpwriter *multipart.Writer, n, vv string, variables map[string]
 	h.Set("Content-Type", ct)
 	fw, err := mpwriter.CreatePart(h)
 	if err != nil {
-		return fmt.Errorf("Unable to create part for parameter %q: %s",
+		return nil, fmt.Errorf("Unable to create part for parameter %q: %s",
 			n, err.Error())
 	}
+	if chunk != nil {
+		return chunk, nil
+	}
 
 	_, err = io.WriteString(fw, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return nil, nil
+}
+
+// bodyChunk is part of a request body: Either literal data or the
+// content of a file of the given size.
+type bodyChunk struct {
+	data string
+	file string
+	size int64
+}
+
+// streamChunks returns the literal body if chunks contain no file. Otherwise
+// a textual representation of the body (with file contents elided) is
+// returned together with a function to open the body and its size.
+func streamChunks(chunks []bodyChunk) (string, func() (io.ReadCloser, error), int64) {
+	buf := &bytes.Buffer{}
+	stream := false
+	size := int64(0)
+	for _, c := range chunks {
+		if c.file == "" {
+			buf.WriteString(c.data)
+			size += int64(len(c.data))
+			continue
+		}
+		stream = true
+		fmt.Fprintf(buf, "[content of %s: %d bytes]", c.file, c.size)
+		size += c.size
+	}
+	if !stream {
+		return buf.String(), nil, 0
+	}
+
+	open := func() (io.ReadCloser, error) {
+		rc := &multiReadCloser{}
+		readers := []io.Reader{}
+		for _, c := range chunks {
+			if c.file == "" {
+				readers = append(readers, strings.NewReader(c.data))
+				continue
+			}
+			f, err := os.Open(c.file)
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			rc.closers = append(rc.closers, f)
+			readers = append(readers, f)
+		}
+		rc.Reader = io.MultiReader(readers...)
+		return rc, nil
+	}
+	return buf.String(), open, size
+}
+
+// multiReadCloser reads from Reader and closes all closers on Close.
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close implements io.Closer.
+func (m *multiReadCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 // -------------------------------------------------------------------------
@@ -1196,10 +2089,57 @@ func stripAtFile(s string) string {
 	return s
 }
 
+// RedactedHeaders lists the HTTP headers whose values are not shown in
+// curl calls generated by Request.ToCurl and Test.CurlCall.
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// redacted is the replacement of sensitive values in curl calls.
+const redacted = "REDACTED"
+
+// ToCurl returns a curl command line which sends r. The values of headers
+// listed in RedactedHeaders and the password of basic auth are redacted.
+func (r *Request) ToCurl() string {
+	return r.curlCall(nil)
+}
+
+// CurlCall returns the curl command line to reproduce the request of t
+// including the cookies in t's cookie jar. See Request.ToCurl.
 func (t *Test) CurlCall() string {
+	return t.Request.curlCall(t.Jar.Cookies(t.Request.parsedURL()))
+}
+
+// parsedURL returns the URL of r which is taken from the crafted
+// request if available.
+func (r *Request) parsedURL() *url.URL {
+	if r.Request != nil {
+		return r.Request.URL
+	}
+	reqURL, err := url.Parse(r.URL)
+	if err != nil {
+		// Fake one.
+		reqURL = &url.URL{
+			Scheme: "http",
+			Host:   "this.should",
+			Path:   "not/happen",
+		}
+	}
+	return reqURL
+}
+
+// isRedacted reports whether the values of header h must be redacted.
+func isRedacted(h string) bool {
+	for _, rh := range RedactedHeaders {
+		if http.CanonicalHeaderKey(rh) == h {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Request) curlCall(jarCookies []*http.Cookie) string {
 	call := "curl"
 
-	nontrivial := nontrivialData(t.Request.Body)
+	nontrivial := nontrivialData(r.Body)
 	if nontrivial {
 		// We have a request body which will be hard or impossible
 		// to escape to be copy/pasted to a bash command line
@@ -1210,7 +2150,7 @@ func (t *Test) CurlCall() string {
 		call = "tmp=$(mktemp)\n"
 		buf := &bytes.Buffer{}
 		p := make([]byte, 4)
-		for _, r := range t.Request.SentBody {
+		for _, r := range r.SentBody {
 			if r >= ' ' && r <= '~' &&
 				!(r == '"' || r == '\'' || r == '\\') {
 				buf.WriteRune(r)
@@ -1227,29 +2167,15 @@ func (t *Test) CurlCall() string {
 	}
 
 	// We need the parsed URL which may be unavailable.
-	var reqURL *url.URL
-	if t.Request.Request != nil {
-		reqURL = t.Request.Request.URL
-	} else {
-		var err error
-		reqURL, err = url.Parse(t.Request.URL)
-		if err != nil {
-			// Fake one.
-			reqURL = &url.URL{
-				Scheme: "http",
-				Host:   "this.should",
-				Path:   "not/happen",
-			}
-		}
-	}
+	reqURL := r.parsedURL()
 
 	// Method
-	if t.Request.Method != "" {
-		call += fmt.Sprintf(" -X %s", t.Request.Method)
+	if r.Method != "" {
+		call += fmt.Sprintf(" -X %s", r.Method)
 	}
 
 	// HTTP header
-	for header, vals := range t.Request.Header {
+	for header, vals := range r.Header {
 		ch := http.CanonicalHeaderKey(header)
 		if ch == "Cookie" {
 			continue // Cookies are handled below.
@@ -1257,6 +2183,9 @@ func (t *Test) CurlCall() string {
 		for _, v := range vals {
 			if v == "" {
 				call += fmt.Sprintf(" -H %s;", ch)
+			} else if isRedacted(ch) {
+				line := fmt.Sprintf("%s: %s", ch, redacted)
+				call += fmt.Sprintf(" -H %s", escapeForBash(line))
 			} else {
 				line := fmt.Sprintf("%s: %s", ch, v)
 				call += fmt.Sprintf(" -H %s", escapeForBash(line))
@@ -1265,18 +2194,31 @@ func (t *Test) CurlCall() string {
 	}
 
 	// BasicAuth
-	if t.Request.BasicAuthUser != "" {
-		arg := fmt.Sprintf("%s:%s", t.Request.BasicAuthUser, t.Request.BasicAuthPass)
+	if r.BasicAuthUser != "" {
+		arg := fmt.Sprintf("%s:%s", r.BasicAuthUser, redacted)
 		call += fmt.Sprintf(" -u %s", escapeForBash(arg))
 
 	}
 
+	// Proxy
+	if r.Proxy != "" {
+		call += fmt.Sprintf(" -x %s", escapeForBash(r.Proxy))
+	}
+
+	// HTTP version
+	switch r.HTTPVersion {
+	case "1.1":
+		call += " --http1.1"
+	case "2":
+		call += " --http2"
+	}
+
 	// Cookies
 	nvp := []string{}
-	for _, cookie := range t.Request.Cookies {
+	for _, cookie := range r.Cookies {
 		nvp = append(nvp, fmt.Sprintf("%s=%s", cookie.Name, cookie.Value))
 	}
-	for _, cookie := range t.Jar.Cookies(reqURL) {
+	for _, cookie := range jarCookies {
 		nvp = append(nvp, fmt.Sprintf("%s=%s", cookie.Name, cookie.Value))
 	}
 	if len(nvp) > 0 {
@@ -1285,14 +2227,14 @@ func (t *Test) CurlCall() string {
 	}
 
 	// Parameters and URL
-	theURL := t.Request.URL
+	theURL := r.URL
 	pType := "-d"
-	switch t.Request.ParamsAs {
+	switch r.ParamsAs {
 	case "multipart":
 		pType = "-F"
 		fallthrough
 	case "body":
-		for name, params := range t.Request.Params {
+		for name, params := range r.Params {
 			for _, p := range params {
 				// BUG: @vfile will link to the unreplace file.
 				arg := fmt.Sprintf("%s=%s", name, stripAtFile(p))
@@ -1306,12 +2248,12 @@ func (t *Test) CurlCall() string {
 	}
 
 	// The Body
-	if t.Request.Body != "" &&
-		(t.Request.Method == "POST" || t.Request.Method == "PUT") {
+	if r.Body != "" &&
+		(r.Method == "POST" || r.Method == "PUT") {
 		if nontrivial {
 			call += ` --data-binary "@$tmp"`
 		} else {
-			arg := escapeForBash(stripAtFile(t.Request.Body))
+			arg := escapeForBash(stripAtFile(r.Body))
 			call += fmt.Sprintf(" --data-binary %s", arg)
 		}
 	}
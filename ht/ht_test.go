@@ -5,9 +5,13 @@
 package ht
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"mime"
@@ -80,6 +84,52 @@ func TestSkippingChecks(t *testing.T) {
 
 }
 
+type expensiveCheck struct{ executed bool }
+
+func (*expensiveCheck) Cost() int { return CostExpensive }
+
+func (*expensiveCheck) Prepare() error { return nil }
+
+func (c *expensiveCheck) Execute(t *Test) error {
+	c.executed = true
+	return nil
+}
+
+func TestExpensiveChecksRunLast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		code     int
+		executed bool
+		status   Status
+	}{
+		{code: 200, executed: true, status: Pass},
+		{code: 404, executed: false, status: Skipped},
+	} {
+		expensive := &expensiveCheck{}
+		test := Test{
+			Request: Request{
+				Method: "GET",
+				URL:    ts.URL + "/",
+				Params: url.Values{
+					"status": []string{fmt.Sprintf("%d", tc.code)}},
+			},
+			Checks: []Check{
+				expensive,
+				StatusCode{Expect: 200},
+			},
+		}
+		test.Run()
+		if expensive.executed != tc.executed {
+			t.Errorf("%d: got executed=%t, want %t", i, expensive.executed, tc.executed)
+		}
+		if got := test.CheckResults[0].Status; got != tc.status {
+			t.Errorf("%d: got status %s, want %s", i, got, tc.status)
+		}
+	}
+}
+
 func TestParameterHandling(t *testing.T) {
 	test := Test{Request: Request{
 		Method: "POST",
@@ -465,8 +515,7 @@ func TestClientTimeout(t *testing.T) {
 			Params: url.Values{
 				"smin": {"100"}, "smax": {"110"},
 			},
-			FollowRedirects: false,
-			Timeout:         40 * time.Millisecond,
+			Timeout: 40 * time.Millisecond,
 		},
 		Checks: []Check{
 			StatusCode{200},
@@ -483,6 +532,366 @@ func TestClientTimeout(t *testing.T) {
 	}
 }
 
+func TestMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, strings.Repeat("x", 1000))
+		}))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		max    int64
+		status Status
+		length int
+	}{
+		{0, Pass, 1000},
+		{-1, Pass, 1000},
+		{1000, Pass, 1000},
+		{999, Error, 999},
+		{10, Error, 10},
+	} {
+		test := &Test{
+			Request: Request{URL: ts.URL, MaxBodySize: tc.max},
+			Checks:  CheckList{&Body{Prefix: "xxxxx"}},
+		}
+		test.Run()
+		if test.Status != tc.status {
+			t.Errorf("%d: got status %s, want %s (%v)", i, test.Status, tc.status, test.Error)
+		}
+		if got := len(test.Response.BodyStr); got != tc.length {
+			t.Errorf("%d: got body of length %d, want %d", i, got, tc.length)
+		}
+		if test.CheckResults[0].Status != Pass {
+			t.Errorf("%d: check on truncated body failed: %v", i, test.CheckResults[0].Error)
+		}
+	}
+}
+
+// roundTripFunc is a http.RoundTripper answering all requests itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTestTransport(t *testing.T) {
+	calls := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: 202,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("injected")),
+			Request:    req,
+		}, nil
+	})
+
+	test := &Test{
+		Request:   Request{URL: "http://nonexisting.example.org/"},
+		Checks:    CheckList{StatusCode{Expect: 202}, &Body{Equals: "injected"}},
+		Transport: transport,
+	}
+	test.Run()
+	if test.Status != Pass || calls != 1 {
+		t.Errorf("Got %s after %d calls: %v", test.Status, calls, test.Error)
+	}
+
+	// "auto" is the default behaviour and works with any RoundTripper.
+	test.Request.HTTPVersion = "auto"
+	test.Run()
+	if test.Status != Pass || calls != 2 {
+		t.Errorf("Got %s after %d calls: %v", test.Status, calls, test.Error)
+	}
+
+	test.Request.HTTPVersion = "1.1"
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got %s, want bogus for HTTPVersion on custom RoundTripper", test.Status)
+	}
+}
+
+func TestCompressBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var body io.Reader = r.Body
+			var err error
+			switch enc := r.Header.Get("Content-Encoding"); enc {
+			case "gzip":
+				body, err = gzip.NewReader(r.Body)
+			case "deflate":
+				body, err = zlib.NewReader(r.Body)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(body)
+			fmt.Fprintf(w, "%s:%s", r.Header.Get("Content-Encoding"), data)
+		}))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		compress string
+		want     string
+	}{
+		{"", ":Hello World"},
+		{"gzip", "gzip:Hello World"},
+		{"deflate", "deflate:Hello World"},
+	} {
+		test := &Test{
+			Request: Request{
+				Method:       "POST",
+				URL:          ts.URL,
+				Body:         "Hello World",
+				CompressBody: tc.compress,
+			},
+			Checks: CheckList{
+				StatusCode{Expect: 200},
+				&Body{Equals: tc.want},
+			},
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%d. %q: got %s: %v", i, tc.compress, test.Status, test.Error)
+		}
+		if test.Request.SentBody != "Hello World" {
+			t.Errorf("%d. %q: got SentBody %q", i, tc.compress, test.Request.SentBody)
+		}
+	}
+
+	test := &Test{
+		Request: Request{
+			Method:       "POST",
+			URL:          ts.URL,
+			Body:         "Hello World",
+			CompressBody: "brotli",
+		},
+	}
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got %s for unknown compression: %v", test.Status, test.Error)
+	}
+}
+
+func TestDisableKeepAlive(t *testing.T) {
+	remotes := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			remotes[r.RemoteAddr] = true
+			fmt.Fprint(w, "ok")
+		}))
+	defer ts.Close()
+
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+	test := &Test{
+		Request:   Request{URL: ts.URL},
+		Checks:    CheckList{StatusCode{Expect: 200}},
+		Transport: transport,
+	}
+	for i, want := range []bool{false, true, true} {
+		test.Run()
+		if test.Status != Pass {
+			t.Fatalf("%d. Got %s: %v", i, test.Status, test.Error)
+		}
+		if test.Response.ConnReused != want {
+			t.Errorf("%d. Got ConnReused=%t", i, test.Response.ConnReused)
+		}
+	}
+	if len(remotes) != 1 {
+		t.Errorf("Got %d connections, want 1", len(remotes))
+	}
+
+	test.Request.DisableKeepAlive = true
+	for i := 0; i < 2; i++ {
+		test.Run()
+		if test.Status != Pass || test.Response.ConnReused {
+			t.Errorf("%d. Got %s, ConnReused=%t: %v", i, test.Status,
+				test.Response.ConnReused, test.Error)
+		}
+	}
+	if len(remotes) != 3 {
+		t.Errorf("Got %d connections, want 3", len(remotes))
+	}
+}
+
+func TestTiming(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("part 1 "))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("part 2"))
+		}))
+	defer ts.Close()
+
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+	test := &Test{
+		Request:   Request{URL: ts.URL},
+		Transport: transport,
+	}
+	test.Run()
+	timing := test.Response.Timing
+	if test.Status != Pass {
+		t.Fatalf("Got %s: %v", test.Status, test.Error)
+	}
+	if timing.DNS != 0 || timing.TLS != 0 {
+		t.Errorf("Unexpected DNS or TLS phase in %+v", timing)
+	}
+	if timing.Connect <= 0 || timing.TTFB < 20*time.Millisecond ||
+		timing.Body < 20*time.Millisecond {
+		t.Errorf("Missing phases in %+v", timing)
+	}
+	if timing.TTFB+timing.Body > test.Response.Duration {
+		t.Errorf("Phases %+v exceed duration %s", timing, test.Response.Duration)
+	}
+
+	test.Run()
+	if timing = test.Response.Timing; timing.Connect != 0 || timing.TTFB <= 0 {
+		t.Errorf("Bad timing on reused connection %+v", timing)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	test := Test{
+		Name: "Canceled",
+		Request: Request{
+			Method: "GET",
+			URL:    ts.URL + "/",
+			Params: url.Values{
+				"smin": {"200"}, "smax": {"210"},
+			},
+			Timeout: time.Second,
+		},
+		Checks: []Check{
+			StatusCode{200},
+		},
+		Execution: Execution{
+			Tries: 3,
+			Wait:  time.Second,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	test.RunContext(ctx)
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("Took too long: %s", d)
+	}
+	if test.Status != Error {
+		t.Errorf("Got status %s, want Error", test.Status)
+	}
+	if test.Tries != 1 {
+		t.Errorf("Got %d tries, want 1", test.Tries)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			http.Error(w, "Proxied", http.StatusTeapot)
+		}))
+	defer proxy.Close()
+
+	test := Test{
+		Name: "Proxy",
+		Request: Request{
+			URL:   ts.URL + "/foo",
+			Proxy: proxy.URL,
+		},
+		Checks: []Check{
+			StatusCode{http.StatusTeapot},
+			&Body{Contains: "Proxied"},
+		},
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Errorf("Got status %s, want Pass: %s", test.Status, test.Error)
+	}
+	if proxied != ts.URL+"/foo" {
+		t.Errorf("Proxy got %q, want %q", proxied, ts.URL+"/foo")
+	}
+
+	test.Request.Proxy = "ftp://proxy.example.org"
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got status %s, want Bogus", test.Status)
+	}
+}
+
+func TestHTTPVersion(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(echoHandler))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	insecure := Transport.TLSClientConfig.InsecureSkipVerify
+	Transport.TLSClientConfig.InsecureSkipVerify = true
+	defer func() { Transport.TLSClientConfig.InsecureSkipVerify = insecure }()
+
+	for i, tc := range []struct {
+		version string
+		want    int
+	}{
+		{"1.1", 1},
+		{"2", 2},
+		{"1.1", 1},
+	} {
+		test := Test{
+			Name: "HTTP Version",
+			Request: Request{
+				URL:         ts.URL + "/",
+				HTTPVersion: tc.version,
+			},
+			Checks: []Check{StatusCode{200}},
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%d. %s: got status %s, want Pass: %s",
+				i, tc.version, test.Status, test.Error)
+			continue
+		}
+		if got := test.Response.Response.ProtoMajor; got != tc.want {
+			t.Errorf("%d. %s: got %s, want major version %d",
+				i, tc.version, test.Response.Response.Proto, tc.want)
+		}
+	}
+
+	test := Test{Request: Request{URL: ts.URL, HTTPVersion: "3"}}
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got status %s, want Bogus", test.Status)
+	}
+}
+
+func TestDerivedTransportsAreShared(t *testing.T) {
+	transport := func(version string) http.RoundTripper {
+		test := &Test{Request: Request{HTTPVersion: version}}
+		rt, err := test.transport()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return rt
+	}
+	a, b, c := transport("1.1"), transport("1.1"), transport("2")
+	if a != b {
+		t.Errorf("Same settings got different transports")
+	}
+	if a == c || a == Transport {
+		t.Errorf("Different settings got same transport")
+	}
+}
+
 func TestMerge(t *testing.T) {
 	a := &Test{}
 	b := &Test{}
@@ -509,7 +918,7 @@ func TestMerge(t *testing.T) {
 				{Name: "a", Value: "vaaaaalue"},
 				{Name: "session", Value: "deadbeef"},
 			},
-			FollowRedirects: true,
+			FollowRedirects: RedirectPolicy{Follow: true},
 			Chunked:         false,
 		},
 		Execution: Execution{
@@ -536,7 +945,7 @@ func TestMerge(t *testing.T) {
 				{Name: "b", Value: "vbbbbblue"},
 				{Name: "session", Value: "othersession"},
 			},
-			FollowRedirects: false,
+			FollowRedirects: RedirectPolicy{Follow: false},
 			Chunked:         true,
 			BasicAuthUser:   "foo.bar",
 			BasicAuthPass:   "secret",
@@ -583,9 +992,9 @@ func TestMerge(t *testing.T) {
 			c.Request.BasicAuthPass)
 	}
 
-	if c.Request.FollowRedirects != false || c.Request.Chunked != true {
+	if c.Request.FollowRedirects.Follow != false || c.Request.Chunked != true {
 		t.Errorf("FollowRedirect=%t Chunked=%t",
-			c.Request.FollowRedirects, c.Request.Chunked)
+			c.Request.FollowRedirects.Follow, c.Request.Chunked)
 	}
 
 	if c.Execution.PreSleep != 100 || c.Execution.InterSleep != 420 || c.Execution.PostSleep != 140 {
@@ -595,6 +1004,23 @@ func TestMerge(t *testing.T) {
 
 }
 
+func TestMergeMaxBodySize(t *testing.T) {
+	m, err := Merge(&Test{Request: Request{MaxBodySize: 10}}, &Test{})
+	if err != nil || m.Request.MaxBodySize != 10 {
+		t.Errorf("Got %d, %v", m.Request.MaxBodySize, err)
+	}
+	m, err = Merge(&Test{Request: Request{MaxBodySize: 10}},
+		&Test{Request: Request{MaxBodySize: 10}})
+	if err != nil || m.Request.MaxBodySize != 10 {
+		t.Errorf("Got %d, %v", m.Request.MaxBodySize, err)
+	}
+	_, err = Merge(&Test{Request: Request{MaxBodySize: 10}},
+		&Test{Request: Request{MaxBodySize: 20}})
+	if err == nil {
+		t.Errorf("Missing error for different MaxBodySize")
+	}
+}
+
 func bodyReadTestHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/hello":
@@ -614,6 +1040,49 @@ func bodyReadTestHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func TestRepeatWithHeaders(t *testing.T) {
+	test := &Test{
+		Name: "Item",
+		Request: Request{
+			URL:    "http://demo.test/item",
+			Header: http.Header{"Accept": {"*/*"}, "X-Foo": {"bar"}},
+		},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			ContentType{Is: "html"},
+		},
+	}
+	tests, err := RepeatWithHeaders(test, map[string]string{
+		"application/xml":  "xml",
+		"application/json": "json",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if len(tests) != 2 {
+		t.Fatalf("Got %d tests, want 2", len(tests))
+	}
+	for i, want := range []string{"json", "xml"} {
+		rt := tests[i]
+		if got := rt.Request.Header["Accept"]; len(got) != 1 ||
+			got[0] != "application/"+want {
+			t.Errorf("%d: got Accept header %v", i, got)
+		}
+		if got := rt.Request.Header.Get("X-Foo"); got != "bar" {
+			t.Errorf("%d: got X-Foo header %q", i, got)
+		}
+		if len(rt.Checks) != 2 {
+			t.Fatalf("%d: got %d checks, want 2", i, len(rt.Checks))
+		}
+		if ct, ok := rt.Checks[1].(ContentType); !ok || ct.Is != want {
+			t.Errorf("%d: got check %#v", i, rt.Checks[1])
+		}
+	}
+	if test.Request.Header.Get("Accept") != "*/*" {
+		t.Errorf("Original test modified")
+	}
+}
+
 func TestReadBody(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(bodyReadTestHandler))
 	defer ts.Close()
@@ -621,9 +1090,8 @@ func TestReadBody(t *testing.T) {
 	for _, path := range []string{"/redirect-plain", "/redirect-content"} {
 		test := Test{
 			Request: Request{
-				Method:          "GET",
-				URL:             ts.URL + path,
-				FollowRedirects: false,
+				Method: "GET",
+				URL:    ts.URL + path,
 			},
 			Checks: []Check{NoServerError{}},
 		}
@@ -761,9 +1229,9 @@ var testCurlCalls = []struct {
 	paramsAs string
 	want     string
 }{
-	{"URL", `curl -X POST -u 'root:secret' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' 'http://localhost:808/foo?bar=1&abc=12&abc=34'`},
-	{"body", `curl -X POST -u 'root:secret' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' -d 'abc=12' -d 'abc=34' 'http://localhost:808/foo?bar=1'`},
-	{"multipart", `curl -X POST -u 'root:secret' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' -F 'abc=12' -F 'abc=34' 'http://localhost:808/foo?bar=1'`},
+	{"URL", `curl -X POST -u 'root:REDACTED' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' 'http://localhost:808/foo?bar=1&abc=12&abc=34'`},
+	{"body", `curl -X POST -u 'root:REDACTED' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' -d 'abc=12' -d 'abc=34' 'http://localhost:808/foo?bar=1'`},
+	{"multipart", `curl -X POST -u 'root:REDACTED' -H 'X-Custom-A: go' -H 'X-Custom-A: fast' -H 'Accept: */*' -H 'User-Agent: unkown' -H 'Cookie: session=deadbeef' -F 'abc=12' -F 'abc=34' 'http://localhost:808/foo?bar=1'`},
 }
 
 func TestCurlCall(t *testing.T) {
@@ -858,3 +1326,165 @@ func TestCurlCallBody(t *testing.T) {
 		}
 	}
 }
+
+func TestToCurlRedacts(t *testing.T) {
+	r := Request{
+		Method: "GET",
+		URL:    "http://localhost/",
+		Header: http.Header{
+			"Authorization": {"Bearer s3cr3t"},
+			"X-Token":       {"abc"},
+		},
+	}
+	got := r.ToCurl()
+	if strings.Contains(got, "s3cr3t") ||
+		!strings.Contains(got, "-H 'Authorization: REDACTED'") ||
+		!strings.Contains(got, "-H 'X-Token: abc'") ||
+		!strings.HasSuffix(got, " 'http://localhost/'") {
+		t.Errorf("Got %s", got)
+	}
+}
+
+func TestHeadSkipsBodyChecks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method != "HEAD" {
+				w.Write([]byte(`{"foo": 1}`))
+			}
+		}))
+	defer ts.Close()
+
+	for _, method := range []string{"HEAD", "OPTIONS"} {
+		test := Test{
+			Name: method,
+			Request: Request{
+				Method: method,
+				URL:    ts.URL + "/",
+			},
+			Checks: []Check{
+				StatusCode{200},
+				&ContentType{Is: "json"},
+				&Body{Contains: "bar"},
+				&JSON{Element: "foo", Condition: Condition{Equals: "2"}},
+				&JWT{Element: "token"},
+				AnyOne{Of: CheckList{&Body{Contains: "bar"}}},
+				&Warn{Of: CheckList{StatusCode{200}}},
+			},
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%s: got status %s, want Pass: %s", method, test.Status, test.Error)
+		}
+		want := []Status{Pass, Pass, Skipped, Skipped, Skipped, Skipped, Pass}
+		for i, cr := range test.CheckResults {
+			if cr.Status != want[i] {
+				t.Errorf("%s: check %d got %s, want %s", method, i, cr.Status, want[i])
+			}
+		}
+	}
+}
+
+func TestSaveBodyTo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World"))
+		}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "savebody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, tc := range []struct {
+		checks CheckList
+		body   string
+	}{
+		{CheckList{StatusCode{200}}, ""},
+		{CheckList{StatusCode{200}, &Body{Contains: "World"}}, "Hello World"},
+	} {
+		name := fmt.Sprintf("%s/sub/body-%d.txt", dir, i)
+		test := Test{
+			Request: Request{
+				URL:        ts.URL + "/",
+				SaveBodyTo: name,
+			},
+			Checks: tc.checks,
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%d: got status %s, want Pass: %s", i, test.Status, test.Error)
+		}
+		if test.Response.BodyStr != tc.body {
+			t.Errorf("%d: got BodyStr %q, want %q", i, test.Response.BodyStr, tc.body)
+		}
+		saved, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+		} else if string(saved) != "Hello World" {
+			t.Errorf("%d: saved %q", i, saved)
+		}
+	}
+}
+
+func TestMultipartFileStreaming(t *testing.T) {
+	want, err := ioutil.ReadFile("testdata/greet-red.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filename, contentType, note string
+	var content []byte
+	var contentLength int64
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			contentLength = r.ContentLength
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			note = r.FormValue("note")
+			file, header, err := r.FormFile("upload")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			filename = header.Filename
+			contentType = header.Header.Get("Content-Type")
+			content, _ = ioutil.ReadAll(file)
+		}))
+	defer ts.Close()
+
+	test := Test{
+		Name: "Upload",
+		Request: Request{
+			Method:   "POST",
+			URL:      ts.URL + "/",
+			ParamsAs: "multipart",
+			Params: url.Values{
+				"note":   {"hello"},
+				"upload": {"@file:testdata/greet-red.png"},
+			},
+		},
+		Checks: []Check{StatusCode{200}},
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Fatalf("Got status %s: %s", test.Status, test.Error)
+	}
+	if filename != "greet-red.png" || contentType != "image/png" || note != "hello" {
+		t.Errorf("Got filename=%q contentType=%q note=%q", filename, contentType, note)
+	}
+	if string(content) != string(want) {
+		t.Errorf("Got %d bytes of content, want %d", len(content), len(want))
+	}
+	if contentLength <= int64(len(want)) {
+		t.Errorf("Bad Content-Length %d", contentLength)
+	}
+	if !strings.Contains(test.Request.SentBody, "[content of testdata/greet-red.png: 623 bytes]") {
+		t.Errorf("Bad SentBody %q", test.Request.SentBody)
+	}
+}
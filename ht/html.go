@@ -7,11 +7,14 @@
 package ht
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andybalholm/cascadia"
@@ -23,6 +26,7 @@ func init() {
 	RegisterCheck(&HTMLContains{})
 	RegisterCheck(W3CValidHTML{})
 	RegisterCheck(&Links{})
+	RegisterCheck(&RequestBudget{})
 }
 
 // ----------------------------------------------------------------------------
@@ -38,6 +42,12 @@ type W3CValidHTML struct {
 	IgnoredErrors []Condition `json:",omitempty"`
 }
 
+// Cost implements Coster: W3CValidHTML contacts the validator service.
+func (W3CValidHTML) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (W3CValidHTML) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (w W3CValidHTML) Execute(t *Test) error {
 	file := "@file:@sample.html:" + t.Response.BodyStr
@@ -64,7 +74,7 @@ func (w W3CValidHTML) Execute(t *Test) error {
 	// TODO: properly limit global rate at which we fire to W3C validator
 	time.Sleep(100 * time.Millisecond)
 
-	err := test.Run()
+	err := test.RunContext(t.context())
 	if err != nil {
 		return CantCheck{err}
 	}
@@ -145,7 +155,21 @@ func extractValidationIssue(node *html.Node) ValidationIssue {
 // ----------------------------------------------------------------------------
 // HTMLTag
 
-// HTMLTag checks for the existens of HTML elements selected by CSS selectors.
+// HTMLTag checks for the existens of HTML elements selected by CSS selectors
+// and optionally the values of their attributes. Example:
+//     {
+//         Check: "HTMLTag"
+//         Selector: "meta[name=robots]"
+//         Count: 1
+//         Attributes: { content: { Contains: "noindex" } }
+//     }
+// Pages with a variable number of elements can be checked with a Condition
+// on the number of matches, e.g. for at least 3 product cards:
+//     {
+//         Check: "HTMLTag"
+//         Selector: "div.product-card"
+//         Matches: { GreaterThan: 2 }
+//     }
 type HTMLTag struct {
 	// Selector is the CSS selector of the HTML elements.
 	Selector string
@@ -154,11 +178,25 @@ type HTMLTag struct {
 	//     < 0: no occurrence
 	//    == 0: one ore more occurrences
 	//     > 0: exactly that many occurrences
+	// Count is a shorthand for the most common Matches conditions.
 	Count int `json:",omitempty"`
 
+	// Matches is a Condition applied to the number of occurrences
+	// (formatted as a decimal number), e.g. { GreaterThan: 2, LessThan: 11 }
+	// to allow 3 to 10 occurrences. Matches and Count are mutually
+	// exclusive.
+	Matches *Condition `json:",omitempty"`
+
+	// Attributes maps attribute names to the condition their value must
+	// fulfill in each selected element. Missing attributes fail.
+	Attributes map[string]Condition `json:",omitempty"`
+
 	sel cascadia.Selector
 }
 
+// InspectsBody implements BodyInspector.
+func (*HTMLTag) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (c *HTMLTag) Execute(t *Test) error {
 	if c.sel == nil {
@@ -178,6 +216,10 @@ func (c *HTMLTag) Execute(t *Test) error {
 	matches := c.sel.MatchAll(doc)
 
 	switch {
+	case c.Matches != nil:
+		if err := c.Matches.Fulfilled(strconv.Itoa(len(matches))); err != nil {
+			return fmt.Errorf("number of matches: %s", err)
+		}
 	case c.Count < 0 && len(matches) > 0:
 		return ErrFoundForbidden
 	case c.Count == 0 && len(matches) == 0:
@@ -188,16 +230,55 @@ func (c *HTMLTag) Execute(t *Test) error {
 		}
 	}
 
+	if len(c.Attributes) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(c.Attributes))
+	for name := range c.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := ErrorList{}
+	for i, m := range matches {
+		for _, name := range names {
+			if !hasAttr(m, name) {
+				errs = append(errs, fmt.Errorf("element %d: missing attribute %s", i+1, name))
+				continue
+			}
+			cond := c.Attributes[name]
+			if err := cond.Fulfilled(attrValue(m, name)); err != nil {
+				errs = append(errs, fmt.Errorf("element %d: attribute %s: %s", i+1, name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
 // Prepare implements Check's Prepare method.
 func (c *HTMLTag) Prepare() (err error) {
+	if c.Matches != nil {
+		if c.Count != 0 {
+			return MalformedCheck{Err: errors.New("Count and Matches are mutually exclusive")}
+		}
+		if err := c.Matches.Compile(); err != nil {
+			return err
+		}
+	}
 	c.sel, err = cascadia.Compile(c.Selector)
 	if err != nil {
 		c.sel = nil
 		return MalformedCheck{Err: err}
 	}
+	for name, cond := range c.Attributes {
+		if err := cond.Compile(); err != nil {
+			c.sel = nil
+			return err
+		}
+		c.Attributes[name] = cond
+	}
 	return nil
 }
 
@@ -234,7 +315,8 @@ type HTMLContains struct {
 
 	// Complete makes sure that no excess HTML elements are found:
 	// If true the len(Text) must be equal to the number of HTML elements
-	// selected for the check to succeed.
+	// selected for the check to succeed. A failure reports the extra and
+	// the missing texts.
 	Complete bool `json:",omitempty"`
 
 	// InOrder makes the check fail if the selected HTML elements have a
@@ -244,6 +326,9 @@ type HTMLContains struct {
 	sel cascadia.Selector
 }
 
+// InspectsBody implements BodyInspector.
+func (*HTMLContains) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (c *HTMLContains) Execute(t *Test) error {
 	if c.sel == nil {
@@ -268,6 +353,13 @@ func (c *HTMLContains) Execute(t *Test) error {
 		actual[i] = TextContent(m, c.Raw)
 	}
 
+	if c.Complete {
+		extra, missing := textDiff(actual, c.Text)
+		if len(extra) > 0 || len(missing) > 0 {
+			return fmt.Errorf("extra: %q, missing: %q", extra, missing)
+		}
+	}
+
 	last := 0
 	for _, want := range c.Text {
 		found := -1
@@ -287,15 +379,35 @@ func (c *HTMLContains) Execute(t *Test) error {
 		}
 	}
 
-	if c.Complete && len(c.Text) != len(matches) {
-		return WrongCount{Got: len(matches), Want: len(c.Text)}
-
-	}
 	return nil
 }
 
 var errTagNotFound = fmt.Errorf("tag not found")
 
+// textDiff compares the multisets have and want and returns the elements
+// of have not in want and the elements of want not in have.
+func textDiff(have, want []string) (extra, missing []string) {
+	count := make(map[string]int, len(want))
+	for _, w := range want {
+		count[w]++
+	}
+	extra, missing = []string{}, []string{}
+	for _, h := range have {
+		if count[h] > 0 {
+			count[h]--
+			continue
+		}
+		extra = append(extra, h)
+	}
+	for _, w := range want {
+		if count[w] > 0 {
+			count[w]--
+			missing = append(missing, w)
+		}
+	}
+	return extra, missing
+}
+
 // Prepare implements Check's Prepare method.
 func (c *HTMLContains) Prepare() (err error) {
 	c.sel, err = cascadia.Compile(c.Selector)
@@ -394,6 +506,31 @@ func textContentRec(n *html.Node, raw bool) string {
 // ----------------------------------------------------------------------------
 // Links
 
+// MaxLinkConcurrency limits the total number of link requests made
+// concurrently by all Links checks, e.g. of tests executed in parallel.
+// A value of zero or below means unlimited. Tests with a LinkSemaphore
+// are bounded by their semaphore instead.
+var MaxLinkConcurrency = 0
+
+var (
+	linkSemMux sync.Mutex
+	linkSem    chan struct{}
+)
+
+// linkSemaphore returns the semaphore bounding the number of concurrent
+// link requests or nil if MaxLinkConcurrency is unlimited.
+func linkSemaphore() chan struct{} {
+	linkSemMux.Lock()
+	defer linkSemMux.Unlock()
+	if MaxLinkConcurrency <= 0 {
+		return nil
+	}
+	if cap(linkSem) != MaxLinkConcurrency {
+		linkSem = make(chan struct{}, MaxLinkConcurrency)
+	}
+	return linkSem
+}
+
 // Links checks links and references in HTML pages for availability.
 //
 // It can reports mixed content as a failure by setting FailMixedContent.
@@ -415,6 +552,8 @@ type Links struct {
 
 	// Concurrency determines how many of the found links are checked
 	// concurrently. A zero value indicates sequential checking.
+	// The total number of concurrent link requests of all Links checks
+	// is limited by MaxLinkConcurrency.
 	Concurrency int `json:",omitempty"`
 
 	// Timeout is the client timeout if different from main test.
@@ -424,6 +563,14 @@ type Links struct {
 	// all links.
 	OnlyLinks, IgnoredLinks []Condition `json:",omitempty"`
 
+	// SameHostOnly restricts checking to links to the host of the
+	// original request.
+	SameHostOnly bool `json:",omitempty"`
+
+	// RespectRobots skips links disallowed by the robots.txt of the
+	// linked host. The robots.txt files are cached per host for 24 hours.
+	RespectRobots bool `json:",omitempty"`
+
 	// FailMixedContent will report a failure for any mixed content, i.e.
 	// resources retrieved via http for a https HTML page.
 	FailMixedContent bool
@@ -435,10 +582,19 @@ type Links struct {
 	tags []string
 }
 
+// linkRef describes the usage of a link in a HTML document.
+type linkRef struct {
+	// isAnchor reports whether the URL is linked in a-tags only.
+	isAnchor bool
+
+	// from describes the first element referring to the URL.
+	from string
+}
+
 // collectURLs returns the URLs selected by Which and OnlyLinks and IgnoredLinks
 // as the map keys. The URLs are absolute URLs. The map value reports if the
-// URL is t
-func (c *Links) collectURLs(t *Test) (map[string]bool, error) {
+// URL is used in an anchor only and where it was found.
+func (c *Links) collectURLs(t *Test) (map[string]linkRef, error) {
 	if t.Response.BodyErr != nil {
 		return nil, ErrBadBody
 	}
@@ -448,11 +604,12 @@ func (c *Links) collectURLs(t *Test) (map[string]bool, error) {
 	}
 
 	// Collect all non-ignored URL as map keys (for automatic deduplication).
-	refs := make(map[string]bool)
+	refs := make(map[string]linkRef)
 	for _, tag := range c.tags {
 		isAnchor := tag == "a"
 	outer:
-		for _, u := range c.linkURL(doc, tag, t.Request.Request.URL) {
+		for _, link := range c.linkURL(doc, tag, t.Request.Request.URL) {
+			u := link.url
 			for i, cond := range c.OnlyLinks {
 				if cond.Fulfilled(u) == nil {
 					break
@@ -466,16 +623,40 @@ func (c *Links) collectURLs(t *Test) (map[string]bool, error) {
 					continue outer
 				}
 			}
+			if c.SameHostOnly || c.RespectRobots {
+				pu, err := url.Parse(u)
+				if err != nil {
+					continue
+				}
+				if c.SameHostOnly && pu.Host != t.Request.Request.URL.Host {
+					continue
+				}
+				if c.RespectRobots && pu.Host != "" &&
+					!robotsFor(t, pu, c.Timeout).allowed(pu.RequestURI()) {
+					continue
+				}
+			}
 			// As a-tags are processed first this will clear the
 			// isAnchor flag for URLs which are linked in a-tags
 			// and e.g. in an img-tag.
-			refs[u] = isAnchor
+			ref, ok := refs[u]
+			if !ok {
+				ref.from = link.from
+			}
+			ref.isAnchor = isAnchor
+			refs[u] = ref
 		}
 	}
 
 	return refs, nil
 }
 
+// Cost implements Coster: Links requests all linked resources.
+func (*Links) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (*Links) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (c *Links) Execute(t *Test) error {
 	refs, err := c.collectURLs(t)
@@ -500,21 +681,22 @@ func (c *Links) Execute(t *Test) error {
 	broken := ErrorList{} // List of all "broken links".
 
 	// Check for mixed content if desired and needed
-	var urefs map[string]bool // links after possible upgrading
+	var urefs map[string]linkRef // links after possible upgrading
 	if !c.FailMixedContent || !strings.HasPrefix(t.Request.URL, "https") {
 		// Original request was not https, so no mixed content.
 		urefs = refs
 	} else {
-		urefs = make(map[string]bool)
-		for r, isA := range refs {
+		urefs = make(map[string]linkRef)
+		for r, ref := range refs {
 			if strings.HasPrefix(r, "http:") {
 				if upgrade {
 					r = "https:" + r[5:]
-				} else if !isA {
+				} else if !ref.isAnchor {
 					broken = append(broken,
-						fmt.Errorf("%s  -->  un-upgraded mixed content", r))
+						fmt.Errorf("%s  -->  un-upgraded mixed content (from %s)",
+							r, ref.from))
 				}
-				urefs[r] = isA
+				urefs[r] = ref
 			}
 		}
 	}
@@ -526,7 +708,7 @@ func (c *Links) Execute(t *Test) error {
 			Request: Request{
 				Method:          method,
 				URL:             r,
-				FollowRedirects: true,
+				FollowRedirects: RedirectPolicy{Follow: true},
 				BasicAuthUser:   t.Request.BasicAuthUser,
 				BasicAuthPass:   t.Request.BasicAuthPass,
 				Timeout:         timeout,
@@ -537,7 +719,9 @@ func (c *Links) Execute(t *Test) error {
 			Execution: Execution{
 				Verbosity: t.Execution.Verbosity - 1,
 			},
+			Log: t.Log,
 		}
+		t.inheritTransport(test)
 		test.PopulateCookies(t.Jar, t.Request.Request.URL)
 		if ru, err := url.Parse(r); err == nil &&
 			ru.Host == t.Request.Request.URL.Host {
@@ -551,17 +735,27 @@ func (c *Links) Execute(t *Test) error {
 	if c.Concurrency > 1 {
 		conc = c.Concurrency
 	}
+	suite.sem = t.LinkSemaphore
+	if suite.sem == nil {
+		suite.sem = linkSemaphore()
+	}
+	suite.ctx = t.context()
 	started := time.Now()
 	suite.ExecuteConcurrent(conc, nil)
+	for _, test := range suite.Tests {
+		t.event(2, "DEBUG", "checked link", "url", test.Request.URL,
+			"status", test.Status.String(), "from", urefs[test.Request.URL].from)
+	}
 	if suite.Status != Pass {
 		for _, test := range suite.Tests {
+			from := urefs[test.Request.URL].from
 			if test.Status == Error || test.Status == Bogus {
-				broken = append(broken, fmt.Errorf("%s  -->  %s",
-					test.Request.URL, test.Error))
+				broken = append(broken, fmt.Errorf("%s  -->  %s (from %s)",
+					test.Request.URL, test.Error, from))
 			} else if test.Status == Fail {
-				broken = append(broken, fmt.Errorf("%s  -->  %d",
+				broken = append(broken, fmt.Errorf("%s  -->  %d (from %s)",
 					test.Request.URL,
-					test.Response.Response.StatusCode))
+					test.Response.Response.StatusCode, from))
 			}
 		}
 	}
@@ -579,32 +773,67 @@ func (c *Links) Execute(t *Test) error {
 	return nil
 }
 
+// foundLink is a link found in a HTML document.
+type foundLink struct {
+	url  string // the absolute URL
+	from string // description of the referring element
+}
+
 // linkURL will extract all links for the given tag type from the parsed
 // HTML document. All links are made absolute by parsing in the context of
 // requestURL
-func (Links) linkURL(document *html.Node, tag string, requestURL *url.URL) []string {
+func (Links) linkURL(document *html.Node, tag string, requestURL *url.URL) []foundLink {
 	href := linkURLattr[tag].sel
 	matches := href.MatchAll(document)
 	ak := linkURLattr[tag].attr
-	refs := []string{}
+	refs := []foundLink{}
 	for _, m := range matches {
 		for _, a := range m.Attr {
 			if a.Key != ak || a.Val == "#" ||
 				strings.HasPrefix(a.Val, "mailto:") {
 				continue
 			}
+			link := foundLink{from: describeElement(m)}
 			u, err := requestURL.Parse(a.Val)
 			if err != nil {
-				refs = append(refs, "Error: "+err.Error())
+				link.url = "Error: " + err.Error()
 			} else {
 				u.Fragment = "" // easier to clear here
-				refs = append(refs, u.String())
+				link.url = u.String()
 			}
+			refs = append(refs, link)
 		}
 	}
 	return refs
 }
 
+// describeElement produces a short description of n to help locating n
+// in a HTML document: The tag name and its text content or the id of n
+// or the nearest enclosing element with an id, e.g.
+//     <a> "Contact us"
+//     <img id="logo">
+//     <script> in #footer
+func describeElement(n *html.Node) string {
+	tag := "<" + n.Data + ">"
+	if text := TextContent(n, false); text != "" {
+		if r := []rune(text); len(r) > 40 {
+			text = string(r[:37]) + "..."
+		}
+		return fmt.Sprintf("%s %q", tag, text)
+	}
+	for p := n; p != nil; p = p.Parent {
+		id := attrValue(p, "id")
+		if id == "" {
+			continue
+		}
+		if p == n {
+			return fmt.Sprintf("<%s id=%q>", n.Data, id)
+		}
+		return tag + " in #" + id
+	}
+	return tag
+}
+
 // linkURLattr maps tags to the appropriate link attributes and CSS selectors.
 var linkURLattr = map[string]struct {
 	attr string
@@ -642,3 +871,109 @@ func (c *Links) Prepare() (err error) {
 	sort.Strings(c.tags) // Move a (if present) to front.
 	return nil
 }
+
+// ----------------------------------------------------------------------------
+// RequestBudget
+
+// RequestBudget enforces a performance budget on the number of requests
+// a browser needs to load a HTML page: The request itself, all redirections
+// and the distinct sub-resources referenced in the page. The sub-resources
+// are not requested. Example:
+//     {
+//         Check: "RequestBudget"
+//         Total: 40
+//         Scripts: 8
+//         Stylesheets: 3
+//         Redirects: -1
+//     }
+// Zero values do not limit the number of requests, negative values forbid
+// any request of that kind.
+type RequestBudget struct {
+	// Total limits the number of all requests.
+	Total int `json:",omitempty"`
+
+	// Redirects limits the number of redirections.
+	Redirects int `json:",omitempty"`
+
+	// Images, Scripts and Stylesheets limit the number of distinct
+	// images (img src), external scripts (script src) and stylesheets
+	// (link rel=stylesheet).
+	Images      int `json:",omitempty"`
+	Scripts     int `json:",omitempty"`
+	Stylesheets int `json:",omitempty"`
+
+	// Media limits the number of distinct video, audio and source
+	// elements, Frames the number of distinct iframes.
+	Media  int `json:",omitempty"`
+	Frames int `json:",omitempty"`
+}
+
+// requestBudgetKinds lists the kinds of sub-resources and their selectors.
+var requestBudgetKinds = []struct {
+	name string
+	sel  cascadia.Selector
+	attr string
+}{
+	{"images", cascadia.MustCompile("img[src]"), "src"},
+	{"scripts", cascadia.MustCompile("script[src]"), "src"},
+	{"stylesheets", cascadia.MustCompile("link[rel~=stylesheet][href]"), "href"},
+	{"media", cascadia.MustCompile("video[src], audio[src], source[src]"), "src"},
+	{"frames", cascadia.MustCompile("iframe[src]"), "src"},
+}
+
+// InspectsBody implements BodyInspector.
+func (*RequestBudget) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (b *RequestBudget) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	doc, err := html.Parse(t.Response.Body())
+	if err != nil {
+		return CantCheck{err}
+	}
+	base := &url.URL{}
+	if resp := t.Response.Response; resp != nil && resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL
+	} else if t.Request.Request != nil {
+		base = t.Request.Request.URL
+	}
+
+	errs := ErrorList{}
+	exceeds := func(n, budget int, what string) {
+		switch {
+		case budget > 0 && n > budget:
+			errs = append(errs, fmt.Errorf("%d %s exceed budget of %d", n, what, budget))
+		case budget < 0 && n > 0:
+			errs = append(errs, fmt.Errorf("%d %s exceed budget of 0", n, what))
+		}
+	}
+
+	redirects := len(t.Response.Redirections)
+	exceeds(redirects, b.Redirects, "redirects")
+	all := make(map[string]bool)
+	limits := []int{b.Images, b.Scripts, b.Stylesheets, b.Media, b.Frames}
+	for i, kind := range requestBudgetKinds {
+		urls := make(map[string]bool)
+		for _, n := range kind.sel.MatchAll(doc) {
+			u, err := base.Parse(strings.TrimSpace(attrValue(n, kind.attr)))
+			if err != nil || u.Scheme == "data" {
+				continue // Inline data needs no request.
+			}
+			u.Fragment = ""
+			urls[u.String()] = true
+			all[u.String()] = true
+		}
+		exceeds(len(urls), limits[i], kind.name)
+	}
+	exceeds(1+redirects+len(all), b.Total, "requests")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (b *RequestBudget) Prepare() error { return nil }
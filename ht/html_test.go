@@ -5,11 +5,16 @@
 package ht
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -48,6 +53,8 @@ var sampleHTML = `<!doctype html>
 var hcr = Response{
 	BodyStr: sampleHTML}
 
+var float1 float64 = 1
+
 var htmlTagTests = []TC{
 	{hcr, &HTMLTag{Selector: "h1"}, nil},
 	{hcr, &HTMLTag{Selector: "p.X", Count: 2}, nil},
@@ -55,8 +62,18 @@ var htmlTagTests = []TC{
 	{hcr, &HTMLTag{Selector: "h2"}, ErrNotFound},
 	{hcr, &HTMLTag{Selector: "h1", Count: 2}, someError},
 	{hcr, &HTMLTag{Selector: "h1", Count: -1}, ErrFoundForbidden},
+	{hcr, &HTMLTag{Selector: "p.X", Matches: &Condition{GreaterThan: &float1}}, nil},
+	{hcr, &HTMLTag{Selector: "p.X", Matches: &Condition{LessThan: &float1}}, someError},
+	{hcr, &HTMLTag{Selector: "h2", Matches: &Condition{LessThan: &float1}}, nil},
+	{hcr, &HTMLTag{Selector: "p.X", Count: 2, Matches: &Condition{GreaterThan: &float1}}, prepareError},
 	{hcr, &HTMLTag{Selector: "p.z"}, ErrNotFound},
 	{hcr, &HTMLTag{Selector: "#nil"}, ErrNotFound},
+	{hcr, &HTMLTag{Selector: "h1", Attributes: map[string]Condition{"id": {Equals: "mt"}}}, nil},
+	{hcr, &HTMLTag{Selector: "h1", Attributes: map[string]Condition{"id": {Equals: "xy"}}}, someError},
+	{hcr, &HTMLTag{Selector: "h1", Attributes: map[string]Condition{"class": {}}}, someError},
+	{hcr, &HTMLTag{Selector: "a", Attributes: map[string]Condition{"href": {Regexp: "^[#/.h]"}}}, nil},
+	{hcr, &HTMLTag{Selector: "a", Attributes: map[string]Condition{"href": {Prefix: "/"}}}, someError},
+	{hcr, &HTMLTag{Selector: "a", Attributes: map[string]Condition{"href": {Regexp: "["}}}, prepareError},
 }
 
 func TestHTMLTag(t *testing.T) {
@@ -115,6 +132,12 @@ var htmlContainsTests = []TC{
 	{hcr, &HTMLContains{Selector: "p.X",
 		Text: []string{"missing"}},
 		fmt.Errorf(`missing "missing", have ["Hello World" "Thanks!"]`)},
+	{hcr, &HTMLContains{Selector: "li", Complete: true,
+		Text: []string{"Foo", "Bar", "Bar", "One", "Second", "Four"}},
+		fmt.Errorf(`extra: ["Waz" "Three"], missing: ["Bar" "Four"]`)},
+	{hcr, &HTMLContains{Selector: "li", Complete: true,
+		Text: []string{"Foo", "Bar", "Waz", "One", "Second"}},
+		fmt.Errorf(`extra: ["Three"], missing: []`)},
 }
 
 func TestHTMLContains(t *testing.T) {
@@ -534,3 +557,273 @@ func TestLinksMixedContent(t *testing.T) {
 
 	}
 }
+
+func TestLinksMaxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inflight, peak := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		if inflight > peak {
+			peak = inflight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		http.Error(w, "Okay", http.StatusOK)
+	}))
+	defer ts.Close()
+
+	MaxLinkConcurrency = 3
+	defer func() { MaxLinkConcurrency = 0 }()
+
+	u, _ := url.Parse(ts.URL + "/page")
+	body := "<!doctype html><html><body>"
+	for i := 0; i < 8; i++ {
+		body += fmt.Sprintf(`<a href="/link%d"></a>`, i)
+	}
+	body += "</body></html>"
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			test := &Test{
+				Request: Request{
+					URL:     u.String(),
+					Request: &http.Request{URL: u},
+				},
+				Response: Response{BodyStr: body},
+			}
+			check := &Links{Which: "a", Concurrency: 4}
+			if err := check.Prepare(); err != nil {
+				t.Errorf("%d: unexpected error: %s", i, err)
+				return
+			}
+			if err := check.Execute(test); err != nil {
+				t.Errorf("%d: unexpected error: %s", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak > 3 {
+		t.Errorf("Got %d concurrent link requests, want at most 3", peak)
+	}
+}
+
+func TestLinksSameHostAndRobots(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprintln(w, "User-agent: *\nDisallow: /private")
+			return
+		}
+		http.Error(w, "Okay", http.StatusOK)
+	}))
+	defer ts.Close()
+
+	body := `<!doctype html>
+<html><body>
+  <a href="/public"></a>
+  <a href="/private/page"></a>
+  <a href="http://www.example.org/external"></a>
+</body></html>`
+	u, _ := url.Parse(ts.URL + "/page")
+	test := &Test{
+		Request: Request{
+			URL:     u.String(),
+			Request: &http.Request{URL: u},
+		},
+		Response: Response{BodyStr: body},
+	}
+
+	for i, tc := range []struct {
+		check *Links
+		want  []string
+	}{
+		{&Links{Which: "a", SameHostOnly: true},
+			[]string{ts.URL + "/public", ts.URL + "/private/page"}},
+		{&Links{Which: "a", SameHostOnly: true, RespectRobots: true},
+			[]string{ts.URL + "/public"}},
+		{&Links{Which: "a", SameHostOnly: true, RespectRobots: true,
+			IgnoredLinks: []Condition{{Contains: "public"}}},
+			[]string{}},
+	} {
+		if err := tc.check.Prepare(); err != nil {
+			t.Fatalf("%d: unexpected error: %s", i, err)
+		}
+		urls, err := tc.check.collectURLs(test)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %s", i, err)
+		}
+		if len(urls) != len(tc.want) {
+			t.Errorf("%d: got %v, want %v", i, urls, tc.want)
+			continue
+		}
+		for _, w := range tc.want {
+			if _, ok := urls[w]; !ok {
+				t.Errorf("%d: missing %s in %v", i, w, urls)
+			}
+		}
+	}
+}
+
+func TestLinksReportReferrer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/ok") {
+			http.Error(w, "Okay", http.StatusOK)
+			return
+		}
+		http.Error(w, "Gone", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	body := `<!doctype html>
+<html><body>
+  <a href="/ok">Home</a>
+  <a href="/contact">Contact us</a>
+  <img id="logo" src="/logo.png">
+  <div id="footer"><script src="/footer.js"></script></div>
+</body></html>`
+	u, _ := url.Parse(ts.URL + "/page")
+	test := &Test{
+		Request: Request{
+			URL:     u.String(),
+			Request: &http.Request{URL: u},
+		},
+		Response: Response{BodyStr: body},
+	}
+	check := &Links{Which: "a img script"}
+	if err := check.Prepare(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err := check.Execute(test)
+	if err == nil {
+		t.Fatalf("Missing error")
+	}
+	for _, want := range []string{
+		`/contact  -->  404 (from <a> "Contact us")`,
+		`/logo.png  -->  404 (from <img id="logo">)`,
+		`/footer.js  -->  404 (from <script> in #footer)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Missing %q in %s", want, err)
+		}
+	}
+}
+
+func TestLinksUseTransportOfTest(t *testing.T) {
+	var mu sync.Mutex
+	requested := []string{}
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requested = append(requested, req.URL.Path)
+		mu.Unlock()
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("ok")),
+			Request:    req,
+		}, nil
+	})
+
+	u, _ := url.Parse("http://nonexisting.example.org/page")
+	test := &Test{
+		Request: Request{
+			URL:     u.String(),
+			Request: &http.Request{URL: u},
+		},
+		Response:  Response{BodyStr: `<html><body><a href="/a">A</a><img src="/b.png"></body></html>`},
+		Transport: transport,
+	}
+	check := &Links{Which: "a img"}
+	if err := check.Prepare(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := check.Execute(test); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	sort.Strings(requested)
+	if got := strings.Join(requested, " "); got != "/a /b.png" {
+		t.Errorf("Got requests %q", got)
+	}
+}
+
+func TestLinksCanceled(t *testing.T) {
+	calls := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("unexpected request")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	u, _ := url.Parse("http://nonexisting.example.org/page")
+	test := &Test{
+		Request: Request{
+			URL:     u.String(),
+			Request: &http.Request{URL: u},
+		},
+		Response:  Response{BodyStr: `<html><body><a href="/a">A</a></body></html>`},
+		Transport: transport,
+		ctx:       ctx,
+	}
+	check := &Links{Which: "a"}
+	if err := check.Prepare(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err := check.Execute(test)
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("Got error %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Got %d requests after cancelation", calls)
+	}
+}
+
+var budgetHTML = `<!doctype html>
+<html><head>
+  <link rel="stylesheet" href="/css/main.css">
+  <link rel="stylesheet" href="/css/main.css#dup">
+  <link rel="icon" href="/favicon.ico">
+  <script src="/js/app.js"></script>
+  <script src="https://cdn.example.org/lib.js"></script>
+  <script>var inline = true;</script>
+</head><body>
+  <img src="/img/a.png"><img src="img/b.png"><img src="/img/a.png">
+  <img src="data:image/png;base64,iVBORw0KGgo=">
+  <video src="/movie.mp4"></video>
+</body></html>`
+
+var budgetResp = Response{
+	BodyStr:      budgetHTML,
+	Redirections: []string{"http://www.example.org/"},
+	Response: &http.Response{
+		StatusCode: 200,
+		Request:    &http.Request{URL: &url.URL{Scheme: "http", Host: "www.example.org", Path: "/"}},
+	},
+}
+
+var requestBudgetTests = []TC{
+	{budgetResp, &RequestBudget{}, nil},
+	// page + redirect + 1 css + 2 scripts + 2 images + 1 video
+	{budgetResp, &RequestBudget{Total: 8}, nil},
+	{budgetResp, &RequestBudget{Total: 7}, fmt.Errorf("8 requests exceed budget of 7")},
+	{budgetResp, &RequestBudget{Images: 2, Scripts: 2, Stylesheets: 1, Media: 1}, nil},
+	{budgetResp, &RequestBudget{Images: 1}, fmt.Errorf("2 images exceed budget of 1")},
+	{budgetResp, &RequestBudget{Frames: -1}, nil},
+	{budgetResp, &RequestBudget{Redirects: -1}, fmt.Errorf("1 redirects exceed budget of 0")},
+	{budgetResp, &RequestBudget{Scripts: 1, Stylesheets: -1}, ErrorList{
+		fmt.Errorf("2 scripts exceed budget of 1"),
+		fmt.Errorf("1 stylesheets exceed budget of 0"),
+	}},
+}
+
+func TestRequestBudget(t *testing.T) {
+	for i, tc := range requestBudgetTests {
+		runTest(t, i, tc)
+	}
+}
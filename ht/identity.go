@@ -7,8 +7,14 @@
 package ht
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 )
 
 func init() {
@@ -25,14 +31,31 @@ type Identity struct {
 	// E.g. 2ef7bde608ce5404e97d5f042f95f89f1c232871 for a "Hello World!"
 	// body (no newline).
 	SHA1 string
+
+	// Normalize the body before hashing to make the hash independent
+	// of insignificant formatting:
+	//   "none" or "" : hash the body as received
+	//   "json"       : hash the compact JSON encoding with sorted object keys
+	//   "xml"        : hash the XML without whitespace between elements,
+	//                  with sorted attributes and <a></a> written as <a/>
+	// The error message of a failing check reports the hash of the
+	// normalized body.
+	Normalize string `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector.
+func (Identity) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (i Identity) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
 		return CantCheck{t.Response.BodyErr}
 	}
-	hash := sha1.Sum([]byte(t.Response.BodyStr))
+	body, err := normalizeBody(t.Response.BodyStr, i.Normalize)
+	if err != nil {
+		return CantCheck{err}
+	}
+	hash := sha1.Sum([]byte(body))
 	s := fmt.Sprintf("%02x", hash)
 	if s == i.SHA1 {
 		return nil
@@ -41,4 +64,92 @@ func (i Identity) Execute(t *Test) error {
 }
 
 // Prepare implements Check's Prepare method.
-func (Identity) Prepare() error { return nil }
+func (i Identity) Prepare() error {
+	switch i.Normalize {
+	case "", "none", "json", "xml":
+		return nil
+	}
+	return MalformedCheck{fmt.Errorf("unknown normalization %q", i.Normalize)}
+}
+
+// normalizeBody canonicalizes body according to how ("none", "json" or "xml").
+func normalizeBody(body, how string) (string, error) {
+	switch how {
+	case "json":
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(v)
+		return string(data), err
+	case "xml":
+		return normalizeXML(body)
+	}
+	return body, nil
+}
+
+// normalizeXML drops whitespace between elements, sorts the attributes
+// and writes empty elements as <a/>. Namespace prefixes are kept as is.
+func normalizeXML(body string) (string, error) {
+	buf := &bytes.Buffer{}
+	dec := xml.NewDecoder(strings.NewReader(body))
+	qname := func(n xml.Name) string {
+		if n.Space == "" {
+			return n.Local
+		}
+		return n.Space + ":" + n.Local
+	}
+	var pending string // name of a start tag not yet closed by '>'
+	flush := func() {
+		if pending != "" {
+			buf.WriteString(">")
+			pending = ""
+		}
+	}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			flush()
+			attrs := make([]string, len(tok.Attr))
+			for i, a := range tok.Attr {
+				val := &bytes.Buffer{}
+				xml.EscapeText(val, []byte(a.Value))
+				attrs[i] = fmt.Sprintf(" %s=\"%s\"", qname(a.Name), val)
+			}
+			sort.Strings(attrs)
+			buf.WriteString("<" + qname(tok.Name) + strings.Join(attrs, ""))
+			pending = qname(tok.Name)
+		case xml.EndElement:
+			if pending != "" {
+				buf.WriteString("/>")
+				pending = ""
+			} else {
+				buf.WriteString("</" + qname(tok.Name) + ">")
+			}
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				continue
+			}
+			flush()
+			xml.EscapeText(buf, tok)
+		case xml.Comment:
+			flush()
+			buf.WriteString("<!--" + string(tok) + "-->")
+		case xml.ProcInst:
+			flush()
+			buf.WriteString("<?" + tok.Target + " " + string(tok.Inst) + "?>")
+		case xml.Directive:
+			flush()
+			buf.WriteString("<!" + string(tok) + ">")
+		}
+	}
+	return buf.String(), nil
+}
@@ -8,13 +8,45 @@ import "testing"
 
 var idr = Response{BodyStr: "Hello world"}
 
+// All of these have the same normalized hash.
+var idj1 = Response{BodyStr: `{"b": [1, 2.50], "a": "x"}`}
+var idj2 = Response{BodyStr: "{\n  \"a\": \"x\",\n  \"b\": [ 1, 2.50 ]\n}\n"}
+var idx1 = Response{BodyStr: `<r b="2" a="1"><c>text</c><d></d></r>`}
+var idx2 = Response{BodyStr: "<r a=\"1\" b=\"2\">\n  <c>text</c>\n  <d/>\n</r>\n"}
+
 var identityTests = []TC{
 	{idr, Identity{SHA1: "7b502c3a1f48c8609ae212cdfb639dee39673f5e"}, nil},
 	{idr, Identity{SHA1: "99992c3a1f48c8609ae212cdfb639dee39673f5e"}, someError},
+	{idr, Identity{SHA1: "7b502c3a1f48c8609ae212cdfb639dee39673f5e", Normalize: "none"}, nil},
+	{idr, Identity{SHA1: "7b502c3a1f48c8609ae212cdfb639dee39673f5e", Normalize: "yaml"}, prepareError},
+	{idr, Identity{SHA1: "7b502c3a1f48c8609ae212cdfb639dee39673f5e", Normalize: "json"}, someError},
 }
 
 func TestIdentity(t *testing.T) {
-	for i, tc := range imageTests {
+	for i, tc := range identityTests {
 		runTest(t, i, tc)
 	}
 }
+
+func TestIdentityNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		how  string
+		a, b Response
+		want string
+	}{
+		{"json", idj1, idj2, `{"a":"x","b":[1,2.50]}`},
+		{"xml", idx1, idx2, `<r a="1" b="2"><c>text</c><d/></r>`},
+	} {
+		na, err := normalizeBody(tc.a.BodyStr, tc.how)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", tc.how, err)
+		}
+		nb, err := normalizeBody(tc.b.BodyStr, tc.how)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", tc.how, err)
+		}
+		if na != tc.want || nb != tc.want {
+			t.Errorf("%s: got %q and %q, want %q", tc.how, na, nb, tc.want)
+		}
+	}
+}
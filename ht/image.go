@@ -44,6 +44,9 @@ type Image struct {
 	Threshold float64 `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector.
+func (Image) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (i Image) Execute(t *Test) error {
 	img, format, err := image.Decode(t.Response.Body())
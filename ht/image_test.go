@@ -6,6 +6,7 @@ package ht
 
 import (
 	"encoding/base64"
+	"strings"
 	"testing"
 )
 
@@ -75,3 +76,30 @@ func TestImage(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+func TestImageReportsAllFailures(t *testing.T) {
+	check := Image{Format: "jpeg", Width: 12, Height: 8,
+		Fingerprint: "ffffffffffffffff"}
+	if err := check.Prepare(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err := check.Execute(&Test{Response: imgr})
+	el, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Got %T %v, want ErrorList", err, err)
+	}
+	want := []string{
+		"got png image, want jpeg",
+		"got 8 px wide image, want 12",
+		"got 6 px heigh image, want 8",
+		"got BMV of ",
+	}
+	if len(el) != len(want) {
+		t.Fatalf("Got %d errors, want %d: %v", len(el), len(want), el)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(el[i].Error(), w) {
+			t.Errorf("%d. Got %q, want %q", i, el[i], w)
+		}
+	}
+}
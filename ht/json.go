@@ -9,15 +9,18 @@ package ht
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/nytlabs/gojee"
-	"github.com/nytlabs/gojsonexplode"
 )
 
 func init() {
 	RegisterCheck(&JSONExpr{})
 	RegisterCheck(&JSON{})
+	RegisterCheck(&JSONExists{})
+	RegisterCheck(&JSONChanged{})
 }
 
 // ----------------------------------------------------------------------------
@@ -61,6 +64,9 @@ func (c *JSONExpr) Prepare() (err error) {
 	return nil
 }
 
+// InspectsBody implements BodyInspector.
+func (*JSONExpr) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (c *JSONExpr) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
@@ -146,6 +152,9 @@ func (c *JSON) Prepare() error {
 	return nil
 }
 
+// InspectsBody implements BodyInspector.
+func (*JSON) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (c *JSON) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
@@ -156,28 +165,18 @@ func (c *JSON) Execute(t *Test) error {
 		sep = c.Sep
 	}
 
-	out, err := gojsonexplode.Explodejson([]byte(t.Response.BodyStr), sep)
+	flat, err := explodeJSON([]byte(t.Response.BodyStr), sep)
 	if err != nil {
 		return fmt.Errorf("unable to explode JSON: %s", err.Error())
 	}
-
-	var flat map[string]*json.RawMessage
-	err = json.Unmarshal(out, &flat)
-	if err != nil {
-		return fmt.Errorf("unable to parse exploded JSON: %s", err.Error())
-	}
 	if c.Element == "" && c.Embedded == nil {
 		return nil // JSON was welformed, no further checks.
 	}
 
-	val, ok := flat[c.Element]
+	sval, ok := flat[c.Element]
 	if !ok {
 		return fmt.Errorf("element %s not found", c.Element)
 	}
-	sval := "null"
-	if val != nil {
-		sval = string(*val)
-	}
 
 	if c.Embedded != nil {
 		unquoted, err := strconv.Unquote(sval)
@@ -193,3 +192,314 @@ func (c *JSON) Execute(t *Test) error {
 
 	return c.Fulfilled(sval)
 }
+
+// ----------------------------------------------------------------------------
+// JSONExists
+
+// JSONExists checks the presence and absence of elements in a JSON
+// document without looking at their values. Elements are selected like
+// in the JSON check but may also be objects or arrays, e.g. in
+//     { "foo": 5, "bar": [ 1, { "waz": null } ], "debug": {} }
+// the elements foo, bar, bar.0, bar.1, bar.1.waz and debug exist.
+// All violations are reported.
+type JSONExists struct {
+	// Paths are the elements which must exist.
+	Paths []string `json:",omitempty"`
+
+	// Absent are the elements which must not exist.
+	Absent []string `json:",omitempty"`
+
+	// Sep is the separator in the paths. A zero value is equivalent to ".".
+	Sep string `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (c *JSONExists) Prepare() error {
+	if len(c.Paths) == 0 && len(c.Absent) == 0 {
+		return MalformedCheck{Err: fmt.Errorf("neither Paths nor Absent given")}
+	}
+	return nil
+}
+
+// InspectsBody implements BodyInspector.
+func (*JSONExists) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (c *JSONExists) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	sep := "."
+	if c.Sep != "" {
+		sep = c.Sep
+	}
+
+	flat, err := explodeJSON([]byte(t.Response.BodyStr), sep)
+	if err != nil {
+		return err
+	}
+
+	errs := ErrorList{}
+	for _, path := range c.Paths {
+		if !jsonElementExists(flat, path, sep) {
+			errs = append(errs, fmt.Errorf("element %s not found", path))
+		}
+	}
+	for _, path := range c.Absent {
+		if jsonElementExists(flat, path, sep) {
+			errs = append(errs, fmt.Errorf("element %s present", path))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// jsonElementExists reports whether the element path exists in the
+// flattened JSON document flat, either as a leaf or as an object or array
+// containing leafs.
+func jsonElementExists(flat map[string]string, path, sep string) bool {
+	if _, ok := flat[path]; ok {
+		return true
+	}
+	for elem := range flat {
+		if strings.HasPrefix(elem, path+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// JSONChanged
+
+// JSONChanged compares the JSON body with an earlier JSON document stored
+// in a variable and checks that exactly the elements Changed differ.
+// It is useful to test update semantics of PATCH or PUT endpoints: GET the
+// resource and extract the whole body into a variable, e.g. with
+//     VarEx: { BEFORE: {Extractor: "BodyExtractor", Regexp: "(?s).*"} }
+// then PATCH the resource and GET it again with a check like
+//     {
+//         Check: "JSONChanged"
+//         Variable: "BEFORE"
+//         Changed: [ "name", "address.city" ]
+//         Ignore: [ "modified" ]
+//     }
+// Elements are selected like in the JSON check. Selecting an object or an
+// array includes all its elements, so "address" covers "address.city".
+// Each element in Changed must differ (or be added or removed) and all
+// elements not covered by Changed or Ignore must be unchanged.
+type JSONChanged struct {
+	// Variable is the name of the variable holding the earlier JSON
+	// document.
+	Variable string
+
+	// Changed are the elements which must have changed.
+	Changed []string `json:",omitempty"`
+
+	// Ignore are elements whose changes are irrelevant, e.g.
+	// modification timestamps.
+	Ignore []string `json:",omitempty"`
+
+	// Sep is the separator in the element selectors. A zero value is
+	// equivalent to ".".
+	Sep string `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (c *JSONChanged) Prepare() error {
+	if c.Variable == "" {
+		return MalformedCheck{Err: fmt.Errorf("missing Variable")}
+	}
+	return nil
+}
+
+// InspectsBody implements BodyInspector.
+func (*JSONChanged) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (c *JSONChanged) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	sep := "."
+	if c.Sep != "" {
+		sep = c.Sep
+	}
+	earlier, ok := t.Variables[c.Variable]
+	if !ok {
+		return fmt.Errorf("variable %s not set", c.Variable)
+	}
+
+	old, err := explodeJSON([]byte(earlier), sep)
+	if err != nil {
+		return fmt.Errorf("variable %s: %s", c.Variable, err)
+	}
+	cur, err := explodeJSON([]byte(t.Response.BodyStr), sep)
+	if err != nil {
+		return err
+	}
+
+	format := func(v string, ok bool) string {
+		if !ok {
+			return "<missing>"
+		}
+		return v
+	}
+
+	errs := ErrorList{}
+	changed := map[string]bool{}
+	elements := make([]string, 0, len(old)+len(cur))
+	for elem := range old {
+		elements = append(elements, elem)
+	}
+	for elem := range cur {
+		if _, ok := old[elem]; !ok {
+			elements = append(elements, elem)
+		}
+	}
+	sort.Strings(elements)
+	for _, elem := range elements {
+		ov, ook := old[elem]
+		cv, cok := cur[elem]
+		if ook == cok && ov == cv {
+			continue
+		}
+		for _, p := range c.Changed {
+			if jsonPathCovered(elem, []string{p}, sep) {
+				changed[p] = true
+			}
+		}
+		if jsonPathCovered(elem, c.Changed, sep) || jsonPathCovered(elem, c.Ignore, sep) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("element %s changed unexpectedly from %s to %s",
+			elem, format(ov, ook), format(cv, cok)))
+	}
+	for _, p := range c.Changed {
+		if !changed[p] {
+			errs = append(errs, fmt.Errorf("element %s unchanged", p))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// jsonPathCovered reports whether the element elem is one of paths or
+// lies below one of them.
+func jsonPathCovered(elem string, paths []string, sep string) bool {
+	for _, p := range paths {
+		if elem == p || strings.HasPrefix(elem, p+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// explodeJSON flattens the JSON document data: The returned map contains
+// the JSON encoding of each leaf element keyed by its element selector,
+// i.e. the object keys and array indices leading to the leaf joined by
+// sep. The encoding of strings and numbers is the one produced by
+// github.com/nytlabs/gojsonexplode. Empty objects and arrays are leafs
+// too and a document consisting of a single leaf is stored under sep.
+func explodeJSON(data []byte, sep string) (map[string]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]string)
+	explodeJSONElement(doc, "", sep, flat)
+	return flat, nil
+}
+
+// explodeJSONElement records the leafs of the decoded JSON element v
+// found under the element selector prefix in flat.
+func explodeJSONElement(v interface{}, prefix, sep string, flat map[string]string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + sep + name
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if len(x) > 0 {
+			for name, elem := range x {
+				explodeJSONElement(elem, join(name), sep, flat)
+			}
+			return
+		}
+	case []interface{}:
+		if len(x) > 0 {
+			for i, elem := range x {
+				explodeJSONElement(elem, join(strconv.Itoa(i)), sep, flat)
+			}
+			return
+		}
+	}
+	if prefix == "" {
+		prefix = sep
+	}
+	data, _ := json.Marshal(v)
+	flat[prefix] = string(data)
+}
+
+// selectJSON returns the element selectors of the leafs in the flattened
+// JSON document flat which match path in document order. A "*" in path
+// matches every index of an array. The second return value reports
+// whether objects or arrays match path.
+func selectJSON(flat map[string]string, path, sep string) ([]string, bool) {
+	pattern := strings.Split(path, sep)
+	match := func(parts []string) bool {
+		for i, p := range pattern {
+			if p == "*" {
+				if _, err := strconv.Atoi(parts[i]); err != nil {
+					return false
+				}
+			} else if p != parts[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	leafs, nested := []string{}, false
+	for elem := range flat {
+		parts := strings.Split(elem, sep)
+		if len(parts) < len(pattern) || !match(parts) {
+			continue
+		}
+		if len(parts) > len(pattern) {
+			nested = true
+			continue
+		}
+		switch flat[elem] {
+		case "{}", "[]":
+			nested = true
+			continue
+		}
+		leafs = append(leafs, elem)
+	}
+
+	// Array indices sort numerically to keep the document order.
+	sort.Slice(leafs, func(a, b int) bool {
+		pa, pb := strings.Split(leafs[a], sep), strings.Split(leafs[b], sep)
+		for i := range pa {
+			if pa[i] == pb[i] {
+				continue
+			}
+			ia, erra := strconv.Atoi(pa[i])
+			ib, errb := strconv.Atoi(pb[i])
+			if erra == nil && errb == nil {
+				return ia < ib
+			}
+			return pa[i] < pb[i]
+		}
+		return false
+	})
+	return leafs, nested
+}
@@ -5,8 +5,12 @@
 package ht
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/nytlabs/gojsonexplode"
 )
 
 var jr = Response{BodyStr: `{"foo": 5, "bar": [1,2,3]}`}
@@ -83,3 +87,108 @@ func TestJSONCondition(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+var jsonExistsTests = []TC{
+	{jr, &JSONExists{Paths: []string{"foo", "bar", "bar.2"}}, nil},
+	{jr, &JSONExists{Absent: []string{"debug", "bar.3", "foo.x"}}, nil},
+	{jr, &JSONExists{Paths: []string{"qux"}}, fmt.Errorf("element qux not found")},
+	{jr, &JSONExists{Absent: []string{"bar.1"}}, fmt.Errorf("element bar.1 present")},
+	{jr, &JSONExists{Paths: []string{"bar#0"}, Sep: "#"}, nil},
+	{jr, &JSONExists{Paths: []string{"qux", "bar.9"}, Absent: []string{"foo"}},
+		ErrorList{fmt.Errorf("element qux not found"),
+			fmt.Errorf("element bar.9 not found"),
+			fmt.Errorf("element foo present")}},
+	{jre, &JSONExists{Paths: []string{"nil"}}, nil},
+	{ar, &JSONExists{Paths: []string{"2.0.nodes.1.alias", "2.1"}}, nil},
+	{jrx, &JSONExists{Paths: []string{"foo"}}, someError},
+	{jr, &JSONExists{}, prepareError},
+}
+
+func TestJSONExists(t *testing.T) {
+	for i, tc := range jsonExistsTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestExplodeJSON(t *testing.T) {
+	// Without empty objects and arrays the result is the one of gojsonexplode.
+	for _, r := range []Response{jr, jre, jrs, jri, jrf, jrm,
+		{BodyStr: `{"a": [{"b": 1.5e300, "c": "<&>"}, 1.0, -2, null], "d": {"e": false}}`}} {
+		got, err := explodeJSON([]byte(r.BodyStr), ".")
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", r.BodyStr, err)
+			continue
+		}
+		out, err := gojsonexplode.Explodejson([]byte(r.BodyStr), ".")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", r.BodyStr, err)
+		}
+		var raw map[string]*json.RawMessage
+		if err := json.Unmarshal(out, &raw); err != nil {
+			t.Fatalf("%s: unexpected error %s", r.BodyStr, err)
+		}
+		want := map[string]string{}
+		for elem, val := range raw {
+			want[elem] = "null"
+			if val != nil {
+				want[elem] = string(*val)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", r.BodyStr, got, want)
+		}
+	}
+
+	got, err := explodeJSON([]byte(`{"a": {}, "b": [[], {"c": null}]}`), "/")
+	want := map[string]string{"a": "{}", "b/0": "[]", "b/1/c": "null"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestJSONChanged(t *testing.T) {
+	before := `{"name": "Anna", "age": 30, "address": {"city": "Bern", "zip": "3000"},
+                    "tags": ["a", "b"], "modified": "2016-01-01"}`
+	after := `{"name": "Berta", "age": 30, "address": {"city": "Basel", "zip": "4000"},
+                    "tags": ["a", "b", "c"], "modified": "2016-02-02", "extra": {}}`
+
+	for i, tc := range []struct {
+		check *JSONChanged
+		want  string
+	}{
+		{&JSONChanged{Variable: "BEFORE",
+			Changed: []string{"name", "address", "tags", "extra"},
+			Ignore:  []string{"modified"}}, ""},
+		{&JSONChanged{Variable: "BEFORE",
+			Changed: []string{"name", "address.city", "tags.2", "extra", "modified"}},
+			"element address.zip changed unexpectedly from \"3000\" to \"4000\""},
+		{&JSONChanged{Variable: "BEFORE",
+			Changed: []string{"name", "age", "address", "tags", "extra"},
+			Ignore:  []string{"modified"}},
+			"element age unchanged"},
+		{&JSONChanged{Variable: "BEFORE",
+			Changed: []string{"name", "address", "tags"},
+			Ignore:  []string{"modified"}},
+			"element extra changed unexpectedly from <missing> to {}"},
+		{&JSONChanged{Variable: "NONE"}, "variable NONE not set"},
+	} {
+		test := &Test{
+			Response:  Response{BodyStr: after},
+			Variables: map[string]string{"BEFORE": before},
+		}
+		if err := tc.check.Prepare(); err != nil {
+			t.Fatalf("%d. Unexpected error: %s", i, err)
+		}
+		got := ""
+		if err := tc.check.Execute(test); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+
+	if err := (&JSONChanged{}).Prepare(); err == nil {
+		t.Errorf("Missing error for missing Variable")
+	}
+}
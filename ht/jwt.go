@@ -0,0 +1,586 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// jwt.go contains a check for JSON Web Tokens.
+
+package ht
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA256 for crypto.Hash
+	_ "crypto/sha512" // register SHA384 and SHA512 for crypto.Hash
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+)
+
+func init() {
+	RegisterCheck(&JWT{})
+}
+
+// ----------------------------------------------------------------------------
+// JWT
+
+// JWT checks a JSON Web Token found in the response. The token is taken
+// from a header (a "Bearer " prefix is stripped), a cookie or a string
+// element of a JSON body. If Key or JWKS is given the signature of the
+// token is verified. Time based claims exp and nbf are always validated
+// if present. Example:
+//     {
+//         Check: "JWT"
+//         Element: "access_token"
+//         JWKS: "https://auth.example.org/.well-known/jwks.json"
+//         Issuer: "https://auth.example.org"
+//         Audience: "shop"
+//         Claims: {
+//             sub:   { Prefix: "user-" }
+//             scope: { Contains: "orders:read" }
+//         }
+//     }
+// All claim mismatches are reported.
+type JWT struct {
+	// Header, Cookie and Element select where the token is taken from:
+	// The named response header, the named cookie or the element of
+	// the JSON body (selected like in the JSON check). Exactly one
+	// must be given.
+	Header  string `json:",omitempty"`
+	Cookie  string `json:",omitempty"`
+	Element string `json:",omitempty"`
+
+	// Key is used to verify the signature: The shared secret for the
+	// HS256, HS384 and HS512 algorithms or a PEM encoded public key or
+	// certificate for the RS*, PS* and ES* algorithms.
+	Key string `json:",omitempty"`
+
+	// JWKS is the URL of a JSON Web Key Set used to verify the signature.
+	// The key is selected by the kid in the token header.
+	JWKS string `json:",omitempty"`
+
+	// Algorithms restricts the acceptable signing algorithms, e.g.
+	// ["RS256"]. The "none" algorithm is never acceptable if Key or
+	// JWKS is given.
+	Algorithms []string `json:",omitempty"`
+
+	// Issuer is the expected value of the iss claim.
+	Issuer string `json:",omitempty"`
+
+	// Audience must be contained in the aud claim.
+	Audience string `json:",omitempty"`
+
+	// Claims maps claim names to Conditions the claims must fulfill.
+	// String claims are checked unquoted, all other claims are checked
+	// in their JSON encoding.
+	Claims map[string]Condition `json:",omitempty"`
+
+	// Leeway is the allowed clock skew when validating exp and nbf.
+	Leeway time.Duration `json:",omitempty"`
+
+	pubKey crypto.PublicKey
+}
+
+// Cost implements Coster: JWKS needs an additional request.
+func (c JWT) Cost() int {
+	if c.JWKS != "" {
+		return CostExpensive
+	}
+	return CostCheap
+}
+
+// InspectsBody implements BodyInspector: Only a token taken from an
+// Element of the JSON body needs the body.
+func (c JWT) InspectsBody() bool { return c.Element != "" }
+
+// Prepare implements Check's Prepare method.
+func (c *JWT) Prepare() error {
+	sources := 0
+	for _, s := range []string{c.Header, c.Cookie, c.Element} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return MalformedCheck{errors.New("exactly one of Header, Cookie and Element must be given")}
+	}
+	if c.Key != "" && c.JWKS != "" {
+		return MalformedCheck{errors.New("Key and JWKS are mutually exclusive")}
+	}
+	c.pubKey = nil
+	if strings.HasPrefix(strings.TrimSpace(c.Key), "-----BEGIN") {
+		key, err := parsePEMPublicKey(c.Key)
+		if err != nil {
+			return MalformedCheck{err}
+		}
+		c.pubKey = key
+	}
+	for name, cond := range c.Claims {
+		if err := cond.Compile(); err != nil {
+			return err
+		}
+		c.Claims[name] = cond
+	}
+	return nil
+}
+
+// Execute implements Check's Execute method.
+func (c *JWT) Execute(t *Test) error {
+	token, err := c.token(t)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed JWT: need three parts")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return fmt.Errorf("malformed JWT header: %s", err)
+	}
+	claims := map[string]interface{}{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return fmt.Errorf("malformed JWT claims: %s", err)
+	}
+
+	if c.Key != "" || c.JWKS != "" {
+		if err := c.verify(t, header.Alg, header.Kid, parts); err != nil {
+			return err
+		}
+	}
+
+	errs := ErrorList{}
+	now := time.Now()
+	if exp, ok := claims["exp"]; ok {
+		if when, err := jwtTime(exp); err != nil {
+			errs = append(errs, fmt.Errorf("claim exp: %s", err))
+		} else if now.After(when.Add(c.Leeway)) {
+			errs = append(errs, fmt.Errorf("token expired at %s", when.Format(time.RFC3339)))
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		if when, err := jwtTime(nbf); err != nil {
+			errs = append(errs, fmt.Errorf("claim nbf: %s", err))
+		} else if now.Add(c.Leeway).Before(when) {
+			errs = append(errs, fmt.Errorf("token not valid before %s", when.Format(time.RFC3339)))
+		}
+	}
+	if c.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != c.Issuer {
+			errs = append(errs, fmt.Errorf("iss is %q, want %q", iss, c.Issuer))
+		}
+	}
+	if c.Audience != "" && !jwtAudienceContains(claims["aud"], c.Audience) {
+		errs = append(errs, fmt.Errorf("aud does not contain %q", c.Audience))
+	}
+
+	names := make([]string, 0, len(c.Claims))
+	for name := range c.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, ok := claims[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("claim %s missing", name))
+			continue
+		}
+		s, isString := v.(string)
+		if !isString {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		if err := c.Claims[name].Fulfilled(s); err != nil {
+			errs = append(errs, fmt.Errorf("claim %s: %s", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// token extracts the raw token from the response of t.
+func (c *JWT) token(t *Test) (string, error) {
+	switch {
+	case c.Header != "":
+		if t.Response.Response == nil {
+			return "", errors.New("no response to check")
+		}
+		v := t.Response.Response.Header.Get(c.Header)
+		if v == "" {
+			return "", fmt.Errorf("no header %s", c.Header)
+		}
+		if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			v = v[7:]
+		}
+		return strings.TrimSpace(v), nil
+	case c.Cookie != "":
+		if t.Response.Response == nil {
+			return "", errors.New("no response to check")
+		}
+		cookies := findCookiesByName(t, c.Cookie)
+		if len(cookies) == 0 {
+			return "", fmt.Errorf("no cookie %s", c.Cookie)
+		}
+		return cookies[len(cookies)-1].Value, nil
+	}
+
+	if t.Response.BodyErr != nil {
+		return "", ErrBadBody
+	}
+	flat, err := explodeJSON([]byte(t.Response.BodyStr), ".")
+	if err != nil {
+		return "", fmt.Errorf("unable to explode JSON: %s", err.Error())
+	}
+	val, ok := flat[c.Element]
+	if !ok || val == "null" {
+		return "", fmt.Errorf("element %s not found", c.Element)
+	}
+	var s string
+	if err := json.Unmarshal([]byte(val), &s); err != nil {
+		return "", fmt.Errorf("element %s is not a string", c.Element)
+	}
+	return s, nil
+}
+
+// verify the signature of the token parts signed with alg.
+func (c *JWT) verify(t *Test, alg, kid string, parts []string) error {
+	if alg == "" || alg == "none" {
+		return errors.New("unsigned JWT")
+	}
+	if len(c.Algorithms) > 0 {
+		acceptable := false
+		for _, a := range c.Algorithms {
+			acceptable = acceptable || a == alg
+		}
+		if !acceptable {
+			return fmt.Errorf("algorithm %s not acceptable", alg)
+		}
+	}
+
+	var key interface{}
+	switch {
+	case c.pubKey != nil:
+		key = c.pubKey
+	case c.Key != "":
+		key = []byte(c.Key)
+	default:
+		var err error
+		key, err = jwksKey(t, c.JWKS, kid)
+		if err != nil {
+			return CantCheck{err}
+		}
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return fmt.Errorf("malformed JWT signature: %s", err)
+	}
+	if err := verifyJWTSignature(alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return fmt.Errorf("bad JWT signature: %s", err)
+	}
+	return nil
+}
+
+// verifyJWTSignature checks that sig is the alg signature of signed
+// made with key.
+func verifyJWTSignature(alg string, key interface{}, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%s needs a shared secret", alg)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("mismatch")
+		}
+		return nil
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs a RSA public key", alg)
+		}
+		if alg[0] == 'R' {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+		return rsa.VerifyPSS(pub, hash, digest, sig,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs an ECDSA public key", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("mismatch")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %s", alg)
+}
+
+// decodeJWTPart decodes the base64url encoded JSON part into v.
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// jwtTime converts a NumericDate claim to a time.
+func jwtTime(v interface{}) (time.Time, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, errors.New("not a number")
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(f), 0), nil
+}
+
+// jwtAudienceContains reports whether the aud claim, a string or an array
+// of strings, contains audience.
+func jwtAudienceContains(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePEMPublicKey parses a PEM encoded public key or certificate.
+func parsePEMPublicKey(data string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("Key: no PEM data found")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Key: %s", err)
+		}
+		return cert.PublicKey, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Key: %s", err)
+	}
+	return key, nil
+}
+
+// ----------------------------------------------------------------------------
+// JSON Web Key Sets
+
+// jwk is a single JSON Web Key of the types RSA, EC and oct.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// key converts k to a *rsa.PublicKey, a *ecdsa.PublicKey or a []byte.
+func (k jwk) key() (interface{}, error) {
+	b64 := func(s string) []byte {
+		b, _ := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		return b
+	}
+	switch k.Kty {
+	case "RSA":
+		n, e := b64(k.N), b64(k.E)
+		if len(n) == 0 || len(e) == 0 {
+			return nil, errors.New("malformed RSA key")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(b64(k.X)),
+			Y:     new(big.Int).SetBytes(b64(k.Y)),
+		}, nil
+	case "oct":
+		return b64(k.K), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// jwksMaxAge is the duration a fetched JSON Web Key Set is cached.
+var jwksMaxAge = 10 * time.Minute
+
+type jwksEntry struct {
+	keys    []jwk
+	fetched time.Time
+}
+
+var (
+	jwksMux   sync.Mutex
+	jwksCache = make(map[string]jwksEntry)
+)
+
+// jwksKey returns the key with the given kid from the JSON Web Key Set
+// at url. If kid is empty the set must contain exactly one key.
+// Key sets are cached for jwksMaxAge; an unknown kid triggers one refetch
+// as the keys might have been rotated.
+func jwksKey(t *Test, url string, kid string) (interface{}, error) {
+	keys, fresh, err := cachedJWKS(t, url, false)
+	if err != nil {
+		return nil, err
+	}
+	k, err := findJWK(keys, kid)
+	if err == nil && k == nil && !fresh {
+		if keys, _, err = cachedJWKS(t, url, true); err != nil {
+			return nil, err
+		}
+		k, err = findJWK(keys, kid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, fmt.Errorf("no key with kid %q in JWKS", kid)
+	}
+	return k.key()
+}
+
+// cachedJWKS returns the JSON Web Key Set at url from the cache unless
+// refresh is set or the cached set is too old. fresh reports whether the
+// keys have just been fetched.
+func cachedJWKS(t *Test, url string, refresh bool) (keys []jwk, fresh bool, err error) {
+	jwksMux.Lock()
+	entry, ok := jwksCache[url]
+	jwksMux.Unlock()
+	if ok && !refresh && time.Since(entry.fetched) < jwksMaxAge {
+		return entry.keys, false, nil
+	}
+	keys, err = fetchJWKS(t, url)
+	if err != nil {
+		return nil, false, err
+	}
+	jwksMux.Lock()
+	jwksCache[url] = jwksEntry{keys: keys, fetched: time.Now()}
+	jwksMux.Unlock()
+	return keys, true, nil
+}
+
+// findJWK returns the key with the given kid or nil if there is none. If
+// kid is empty keys must contain exactly one key.
+func findJWK(keys []jwk, kid string) (*jwk, error) {
+	if kid == "" {
+		if len(keys) != 1 {
+			return nil, errors.New("JWT without kid but JWKS has not exactly one key")
+		}
+		return &keys[0], nil
+	}
+	for i := range keys {
+		if keys[i].Kid == kid {
+			return &keys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// fetchJWKS downloads the JSON Web Key Set from url through the transport
+// and subject to the rate limit of t.
+func fetchJWKS(t *Test, url string) ([]jwk, error) {
+	client, err := t.auxClient(0)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(t.context())
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	if err := t.RateLimiter.Wait(t.context()); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS: status %s", resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("JWKS: %s", err)
+	}
+	return set.Keys, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// makeJWT builds a token with the given header and claims signed by sign.
+func makeJWT(header, claims map[string]interface{}, sign func(string) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." +
+		base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed))
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	rsaPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	hs256 := func(s string) []byte {
+		m := hmac.New(sha256.New, []byte("s3cr3t"))
+		m.Write([]byte(s))
+		return m.Sum(nil)
+	}
+	rs256 := func(s string) []byte {
+		d := sha256.Sum256([]byte(s))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, d[:])
+		return sig
+	}
+	es256 := func(s string) []byte {
+		d := sha256.Sum256([]byte(s))
+		r, s2, _ := ecdsa.Sign(rand.Reader, ecKey, d[:])
+		sig := make([]byte, 64)
+		rb, sb := r.Bytes(), s2.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+		return sig
+	}
+	none := func(string) []byte { return nil }
+
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"keys": [
+{"kty": "RSA", "kid": "r1", "n": %q, "e": "AQAB"},
+{"kty": "EC", "kid": "e1", "crv": "P-256", "x": %q, "y": %q}]}`,
+				b64(rsaKey.N), b64(ecKey.X), b64(ecKey.Y))
+		}))
+	defer jwks.Close()
+
+	now := time.Now().Unix()
+	claims := map[string]interface{}{
+		"iss":   "https://auth.example.org",
+		"aud":   []string{"shop", "admin"},
+		"sub":   "user-1234",
+		"exp":   now + 3600,
+		"nbf":   now - 60,
+		"admin": true,
+	}
+	expired := map[string]interface{}{"iss": "other", "exp": now - 3600, "aud": "web"}
+
+	hsToken := makeJWT(map[string]interface{}{"alg": "HS256", "typ": "JWT"}, claims, hs256)
+	rsToken := makeJWT(map[string]interface{}{"alg": "RS256", "kid": "r1"}, claims, rs256)
+	esToken := makeJWT(map[string]interface{}{"alg": "ES256", "kid": "e1"}, claims, es256)
+	noneToken := makeJWT(map[string]interface{}{"alg": "none"}, claims, none)
+	expiredToken := makeJWT(map[string]interface{}{"alg": "HS256"}, expired, hs256)
+
+	withHeader := func(token string) Response {
+		return Response{Response: &http.Response{
+			Header: http.Header{"Authorization": {"Bearer " + token}}}}
+	}
+	withCookie := func(token string) Response {
+		return Response{Response: &http.Response{
+			Header: http.Header{"Set-Cookie": {"session=" + token + "; Path=/"}}}}
+	}
+	withBody := func(token string) Response {
+		return Response{BodyStr: `{"data": {"access_token": "` + token + `"}}`}
+	}
+
+	for i, tc := range []TC{
+		{withHeader(hsToken), &JWT{Header: "Authorization", Key: "s3cr3t"}, nil},
+		{withHeader(hsToken), &JWT{Header: "Authorization", Key: "wrong"}, someError},
+		{withCookie(rsToken), &JWT{Cookie: "session", Key: rsaPEM}, nil},
+		{withCookie(hsToken), &JWT{Cookie: "session", Key: rsaPEM}, someError},
+		{withBody(rsToken), &JWT{Element: "data.access_token", JWKS: jwks.URL}, nil},
+		{withBody(esToken), &JWT{Element: "data.access_token", JWKS: jwks.URL}, nil},
+		{withBody(esToken), &JWT{Element: "data.access_token", JWKS: jwks.URL,
+			Algorithms: []string{"RS256"}}, someError},
+		{withBody(noneToken), &JWT{Element: "data.access_token"}, nil},
+		{withBody(noneToken), &JWT{Element: "data.access_token", JWKS: jwks.URL}, someError},
+		{withHeader(hsToken), &JWT{
+			Header:   "Authorization",
+			Issuer:   "https://auth.example.org",
+			Audience: "shop",
+			Claims: map[string]Condition{
+				"sub":   {Prefix: "user-"},
+				"admin": {Equals: "true"},
+			},
+		}, nil},
+		{withHeader(expiredToken), &JWT{
+			Header:   "Authorization",
+			Issuer:   "https://auth.example.org",
+			Audience: "shop",
+			Claims:   map[string]Condition{"sub": {Prefix: "user-"}},
+		}, ErrorList{
+			fmt.Errorf("token expired at %s", time.Unix(now-3600, 0).Format(time.RFC3339)),
+			fmt.Errorf(`iss is "other", want "https://auth.example.org"`),
+			fmt.Errorf(`aud does not contain "shop"`),
+			fmt.Errorf("claim sub missing"),
+		}},
+		{withHeader(expiredToken), &JWT{Header: "Authorization", Leeway: 2 * time.Hour}, nil},
+		{withHeader("abc.def"), &JWT{Header: "Authorization"}, someError},
+		{withHeader(hsToken), &JWT{}, prepareError},
+		{withHeader(hsToken), &JWT{Header: "Authorization", Cookie: "session"}, prepareError},
+		{withHeader(hsToken), &JWT{Header: "Authorization", Key: "-----BEGIN PUBLIC KEY-----"}, prepareError},
+	} {
+		runTest(t, i, tc)
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := range keys {
+		var err error
+		if keys[i], err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := 0 // index of the key served in the JWKS
+	jwks := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			k := keys[current]
+			b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+			fmt.Fprintf(w, `{"keys": [{"kty": "EC", "kid": "k%d", "crv": "P-256", "x": %q, "y": %q}]}`,
+				current, b64(k.X), b64(k.Y))
+		}))
+	defer jwks.Close()
+
+	token := func(i int) string {
+		return makeJWT(map[string]interface{}{"alg": "ES256", "kid": fmt.Sprintf("k%d", i)},
+			map[string]interface{}{"sub": "joe"},
+			func(s string) []byte {
+				d := sha256.Sum256([]byte(s))
+				r, s2, _ := ecdsa.Sign(rand.Reader, keys[i], d[:])
+				sig := make([]byte, 64)
+				rb, sb := r.Bytes(), s2.Bytes()
+				copy(sig[32-len(rb):32], rb)
+				copy(sig[64-len(sb):], sb)
+				return sig
+			})
+	}
+
+	fetches := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetches++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	check := func(i int) error {
+		test := &Test{
+			Response:  Response{BodyStr: `{"token": "` + token(i) + `"}`},
+			Transport: transport,
+		}
+		c := &JWT{Element: "token", JWKS: jwks.URL}
+		if err := c.Prepare(); err != nil {
+			return err
+		}
+		return c.Execute(test)
+	}
+
+	for i, tc := range []struct {
+		key, current int
+		age          time.Duration // of the cached key set
+		fetches      int
+	}{
+		{0, 0, 0, 1},              // fetched through the test's transport
+		{0, 0, 0, 1},              // cached
+		{1, 1, 0, 2},              // unknown kid: refetched after rotation
+		{1, 1, 0, 2},              // cached again
+		{1, 1, 2 * jwksMaxAge, 3}, // outdated: refetched
+		{0, 1, 0, 4},              // rotated away: refetched but not found
+	} {
+		current = tc.current
+		if tc.age > 0 {
+			jwksMux.Lock()
+			e := jwksCache[jwks.URL]
+			e.fetched = time.Now().Add(-tc.age)
+			jwksCache[jwks.URL] = e
+			jwksMux.Unlock()
+		}
+		err := check(tc.key)
+		if (tc.key == tc.current) != (err == nil) {
+			t.Errorf("%d. unexpected error %v", i, err)
+		}
+		if fetches != tc.fetches {
+			t.Errorf("%d. got %d fetches, want %d", i, fetches, tc.fetches)
+		}
+	}
+}
@@ -72,6 +72,9 @@ type latLimit struct {
 	max time.Duration
 }
 
+// Cost implements Coster: Latency makes lots of requests.
+func (*Latency) Cost() int { return CostExpensive }
+
 // Execute implements Check's Execute method.
 func (L *Latency) Execute(t *Test) error {
 	var dumper io.Writer
@@ -105,6 +108,7 @@ func (L *Latency) Execute(t *Test) error {
 			return err
 		}
 		cpy.Name = fmt.Sprintf("Latency-Test %d", i+1)
+		t.inheritTransport(cpy)
 		checks := []Check{}
 		for _, c := range t.Checks {
 			if _, lt := c.(*Latency); L.SkipChecks || lt {
@@ -136,7 +140,7 @@ func (L *Latency) Execute(t *Test) error {
 			prewarmed++
 			wg.Add(1)
 			go func(ex *Test) {
-				ex.Run()
+				ex.RunContext(t.context())
 				wg.Done()
 			}(tests[i])
 		}
@@ -153,7 +157,7 @@ func (L *Latency) Execute(t *Test) error {
 		go func(ex *Test, id int) {
 			for {
 				wg2.Add(1)
-				ex.Run()
+				ex.RunContext(t.context())
 				results <- latencyResult{
 					status:   ex.Status,
 					started:  ex.Started,
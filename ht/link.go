@@ -0,0 +1,286 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// link.go contains a check and an extractor for RFC 5988 Link headers
+// as used for pagination.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterCheck(&FollowLink{})
+	RegisterExtractor(LinkExtractor{})
+}
+
+// webLink is one link of a Link header.
+type webLink struct {
+	URL string
+	Rel []string
+}
+
+// parseLinkHeader parses the values of Link headers as defined in
+// RFC 5988, e.g.
+//     <https://api.example.org/items?page=2>; rel="next", <...>; rel="last"
+func parseLinkHeader(values []string) ([]webLink, error) {
+	links := []webLink{}
+	for _, v := range values {
+		for v = strings.TrimSpace(v); v != ""; v = strings.TrimSpace(v) {
+			if v[0] != '<' {
+				return nil, fmt.Errorf("malformed Link header: missing '<' in %q", v)
+			}
+			end := strings.Index(v, ">")
+			if end == -1 {
+				return nil, fmt.Errorf("malformed Link header: missing '>' in %q", v)
+			}
+			link := webLink{URL: v[1:end]}
+			v = v[end+1:]
+
+			// Parameters up to the next comma outside of quotes.
+			for {
+				v = strings.TrimSpace(v)
+				if v == "" {
+					break
+				}
+				if v[0] == ',' {
+					v = v[1:]
+					break
+				}
+				if v[0] != ';' {
+					return nil, fmt.Errorf("malformed Link header: unexpected %q", v)
+				}
+				var name, value string
+				name, value, v = parseLinkParam(v[1:])
+				if strings.ToLower(name) == "rel" {
+					link.Rel = append(link.Rel, strings.Fields(strings.ToLower(value))...)
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// parseLinkParam parses one name=value parameter from the start of s and
+// returns the unparsed rest.
+func parseLinkParam(s string) (name, value, rest string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, "=;,")
+	if i == -1 {
+		return s, "", ""
+	}
+	name = strings.TrimSpace(s[:i])
+	if s[i] != '=' {
+		return name, "", s[i:]
+	}
+	s = strings.TrimSpace(s[i+1:])
+	if strings.HasPrefix(s, `"`) {
+		end := strings.Index(s[1:], `"`)
+		if end == -1 {
+			return name, s[1:], ""
+		}
+		return name, s[1 : end+1], s[end+2:]
+	}
+	j := strings.IndexAny(s, ";,")
+	if j == -1 {
+		return name, s, ""
+	}
+	return name, strings.TrimSpace(s[:j]), s[j:]
+}
+
+// findLink returns the URL of the first link with relation rel in the
+// Link headers of resp, resolved against the URL of the request.
+// An empty string is returned if there is no such link.
+func findLink(resp *http.Response, rel string) (string, error) {
+	links, err := parseLinkHeader(resp.Header["Link"])
+	if err != nil {
+		return "", err
+	}
+	rel = strings.ToLower(rel)
+	for _, link := range links {
+		for _, r := range link.Rel {
+			if r != rel {
+				continue
+			}
+			u, err := url.Parse(link.URL)
+			if err != nil {
+				return "", fmt.Errorf("malformed %s link %q: %s", rel, link.URL, err)
+			}
+			if resp.Request != nil && resp.Request.URL != nil {
+				u = resp.Request.URL.ResolveReference(u)
+			}
+			return u.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// ----------------------------------------------------------------------------
+// LinkExtractor
+
+// LinkExtractor extracts the URL of the link with the given relation from
+// the Link header of the response, e.g. the next page of a paginated
+// API. Relative URLs are resolved against the request URL.
+type LinkExtractor struct {
+	// Rel is the link relation to extract, e.g. "next".
+	Rel string
+}
+
+// Extract implements Extractor's Extract method.
+func (e LinkExtractor) Extract(t *Test) (string, error) {
+	if t.Response.Response == nil {
+		return "", errors.New("no response to extract from")
+	}
+	link, err := findLink(t.Response.Response, e.Rel)
+	if err != nil {
+		return "", err
+	}
+	if link == "" {
+		return "", fmt.Errorf("no %s link", e.Rel)
+	}
+	return link, nil
+}
+
+// ----------------------------------------------------------------------------
+// FollowLink
+
+// FollowLink walks a paginated resource by following the links with
+// relation Rel (default "next") in the Link header of the response and
+// of all subsequent pages until a page has no such link. At most MaxPages
+// pages (including the first one) are traversed; reaching MaxPages while
+// there is still a link to follow is reported as an error.
+// The additional pages are requested with GET and the same header as the
+// original request. Example:
+//     {
+//         Check: "FollowLink"
+//         Pages: 5
+//         Checks: [ {Check: "StatusCode", Expect: 200} ]
+//     }
+type FollowLink struct {
+	// Rel is the link relation to follow. Defaults to "next".
+	Rel string `json:",omitempty"`
+
+	// MaxPages limits the number of traversed pages. Defaults to 50.
+	MaxPages int `json:",omitempty"`
+
+	// Pages is the expected total number of pages (including the first
+	// one). Zero disables checking the number of pages.
+	Pages int `json:",omitempty"`
+
+	// Checks are applied to all further pages.
+	Checks CheckList `json:",omitempty"`
+}
+
+// Cost implements Coster: FollowLink makes additional requests.
+func (FollowLink) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (*FollowLink) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (f *FollowLink) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	rel, maxPages := f.Rel, f.MaxPages
+	if rel == "" {
+		rel = "next"
+	}
+	if maxPages == 0 {
+		maxPages = 50
+	}
+
+	errs := ErrorList{}
+	pages := 1
+	resp := t.Response.Response
+	seen := map[string]bool{}
+	if resp.Request != nil {
+		seen[resp.Request.URL.String()] = true
+	}
+	for {
+		next, err := findLink(resp, rel)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("page %d: %s", pages, err))
+			break
+		}
+		if next == "" {
+			break // Terminal page reached.
+		}
+		if seen[next] {
+			errs = append(errs, fmt.Errorf("page %d: %s link to already visited %s",
+				pages, rel, next))
+			break
+		}
+		if pages >= maxPages {
+			errs = append(errs, fmt.Errorf("page %d still has a %s link, giving up",
+				pages, rel))
+			break
+		}
+		seen[next] = true
+		pages++
+
+		page := f.pageTest(t, next, pages)
+		page.RunContext(t.context())
+		if page.Status > Pass {
+			errs = append(errs, fmt.Errorf("page %d (%s): %s %s",
+				pages, next, page.Status, page.Error))
+		}
+		if page.Response.Response == nil {
+			break
+		}
+		resp = page.Response.Response
+	}
+
+	if f.Pages > 0 && pages != f.Pages {
+		errs = append(errs, fmt.Errorf("traversed %d pages, want %d", pages, f.Pages))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// pageTest returns the test to fetch the n'th page from u.
+func (f *FollowLink) pageTest(orig *Test, u string, n int) *Test {
+	page := &Test{
+		Name: fmt.Sprintf("Page %d of %s", n, orig.Name),
+		Request: Request{
+			Method:          "GET",
+			URL:             u,
+			Header:          make(http.Header),
+			FollowRedirects: orig.Request.FollowRedirects,
+			BasicAuthUser:   orig.Request.BasicAuthUser,
+			BasicAuthPass:   orig.Request.BasicAuthPass,
+			Timeout:         orig.Request.Timeout,
+		},
+		Execution: Execution{
+			Verbosity: orig.Execution.Verbosity - 1,
+		},
+		Checks: f.Checks,
+		Jar:    orig.Jar,
+		Log:    orig.Log,
+	}
+	orig.inheritTransport(page)
+	for h, v := range orig.Request.Header {
+		page.Request.Header[h] = append([]string(nil), v...)
+	}
+	return page
+}
+
+// Prepare implements Check's Prepare method.
+func (f *FollowLink) Prepare() error {
+	if f.MaxPages < 0 || f.Pages < 0 {
+		return MalformedCheck{errors.New("negative number of pages")}
+	}
+	if f.MaxPages > 0 && f.Pages > f.MaxPages {
+		return MalformedCheck{errors.New("Pages > MaxPages")}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	links, err := parseLinkHeader([]string{
+		`<https://api.example.org/items?page=2>; rel="next", <https://api.example.org/items?page=9>; rel=last`,
+		`</items?page=1>; title="a, b;c"; rel="prev first"`,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	want := []webLink{
+		{"https://api.example.org/items?page=2", []string{"next"}},
+		{"https://api.example.org/items?page=9", []string{"last"}},
+		{"/items?page=1", []string{"prev", "first"}},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("Got %v, want %v", links, want)
+	}
+
+	for _, bad := range []string{`https://foo; rel=next`, `<https://foo; rel=next`} {
+		if _, err := parseLinkHeader([]string{bad}); err == nil {
+			t.Errorf("Missing error for %q", bad)
+		}
+	}
+}
+
+// paginatedHandler serves the pages 1 to N=last with a Link header.
+func paginatedHandler(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	last, _ := strconv.Atoi(r.URL.Query().Get("last"))
+	if page < last {
+		w.Header().Set("Link",
+			fmt.Sprintf(`</items?page=%d&last=%d>; rel="next"`, page+1, last))
+	}
+	if page > 3 {
+		http.Error(w, "Oooops", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Page %d", page)
+}
+
+func TestFollowLink(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(paginatedHandler))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		last  int
+		check *FollowLink
+		ok    bool
+	}{
+		{3, &FollowLink{Pages: 3}, true},
+		{1, &FollowLink{Pages: 1}, true},
+		{3, &FollowLink{Pages: 2}, false},
+		{3, &FollowLink{MaxPages: 2}, false},
+		{5, &FollowLink{Checks: CheckList{StatusCode{Expect: 200}}}, false},
+		{5, &FollowLink{Pages: 5}, true},
+	} {
+		test := &Test{
+			Request: Request{
+				URL: fmt.Sprintf("%s/items?page=1&last=%d", ts.URL, tc.last),
+			},
+			Checks: CheckList{tc.check},
+		}
+		test.Run()
+		if got := test.Status == Pass; got != tc.ok {
+			t.Errorf("%d: got status %s, want ok=%t: %v", i, test.Status, tc.ok, test.Error)
+		}
+	}
+
+	test := &Test{
+		Request: Request{URL: ts.URL + "/items?page=1&last=2"},
+		VarEx:   map[string]Extractor{"NEXT": LinkExtractor{Rel: "next"}},
+	}
+	test.Run()
+	if got, want := test.Extract()["NEXT"], ts.URL+"/items?page=2&last=2"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+}
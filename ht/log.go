@@ -0,0 +1,87 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// log.go contains the logging interface used by tests and suites.
+
+package ht
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// Logger is the interface used by Tests and Suites to log their progress.
+// Implementations may forward the structured Events to a log aggregation
+// system; a standard *log.Logger can be used via NewLogger.
+type Logger interface {
+	// Printf logs a preformatted message, e.g. dumps of requests.
+	Printf(format string, v ...interface{})
+
+	// Event logs msg at the given level ("ERROR", "INFO", "DEBUG" or
+	// "TRACE") with additional context provided as alternating keys
+	// and values in kv.
+	Event(level, msg string, kv ...interface{})
+}
+
+// NewLogger adapts l to the Logger interface. Events are printed in the
+// form
+//     LEVEL msg key1="value1" key2=123
+// A nil l yields a nil Logger which disables logging.
+func NewLogger(l *log.Logger) Logger {
+	if l == nil {
+		return nil
+	}
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	*log.Logger
+}
+
+func (l stdLogger) Event(level, msg string, kv ...interface{}) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%-5s %s", level, msg)
+	for i := 0; i < len(kv); i += 2 {
+		var value interface{} = "<missing>"
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+		if s, ok := value.(string); ok {
+			fmt.Fprintf(buf, " %v=%q", kv[i], s)
+		} else {
+			fmt.Fprintf(buf, " %v=%v", kv[i], value)
+		}
+	}
+	l.Print(buf.String())
+}
+
+// event logs msg with key/value pairs kv at level if the verbosity of t
+// is at least verbosity. The name of the test is added as key "test".
+func (t *Test) event(verbosity int, level, msg string, kv ...interface{}) {
+	if t.Execution.Verbosity < verbosity || t.Log == nil {
+		return
+	}
+	t.Log.Event(level, msg, append(kv, "test", t.Name)...)
+}
+
+func (t *Test) errorf(format string, v ...interface{}) {
+	t.event(0, "ERROR", fmt.Sprintf(format, v...))
+}
+
+func (t *Test) infof(format string, v ...interface{}) {
+	t.event(1, "INFO", fmt.Sprintf(format, v...))
+}
+
+func (t *Test) debugf(format string, v ...interface{}) {
+	t.event(2, "DEBUG", fmt.Sprintf(format, v...))
+}
+
+func (t *Test) tracef(format string, v ...interface{}) {
+	if t.Execution.Verbosity >= 3 && t.Log != nil {
+		format = "TRACE Begin [%q]" + format + "TRACE End"
+		v = append([]interface{}{t.Name}, v...)
+		t.Log.Printf(format, v...)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordingLogger struct {
+	events []string
+}
+
+func (r *recordingLogger) Printf(format string, v ...interface{}) {}
+
+func (r *recordingLogger) Event(level, msg string, kv ...interface{}) {
+	r.events = append(r.events, fmt.Sprint(level, " ", msg, " ", kv))
+}
+
+func TestStdLoggerEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(log.New(buf, "", 0))
+	logger.Event("INFO", "request", "method", "GET", "n", 3, "dangling")
+	want := `INFO  request method="GET" n=3 dangling="<missing>"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+
+	if NewLogger(nil) != nil {
+		t.Errorf("NewLogger(nil) should be nil")
+	}
+}
+
+func TestLoggerEvents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	logger := &recordingLogger{}
+	test := &Test{
+		Name: "Logging",
+		Request: Request{
+			Method: "HEAD",
+			URL:    ts.URL + "/",
+		},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			&Body{Contains: "foo"},
+		},
+		Execution: Execution{Verbosity: 1},
+		Log:       logger,
+	}
+	test.Run()
+
+	want := map[string]bool{
+		fmt.Sprintf("INFO request [method HEAD url %s/ test Logging]", ts.URL):                         false,
+		"INFO skipping check [check 2 type Body reason no response body to HEAD request test Logging]": false,
+	}
+	for _, e := range logger.events {
+		if _, ok := want[e]; ok {
+			want[e] = true
+		}
+	}
+	for e, seen := range want {
+		if !seen {
+			t.Errorf("Missing event %q in %q", e, logger.events)
+		}
+	}
+}
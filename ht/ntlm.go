@@ -0,0 +1,336 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ntlm.go contains an experimental NTLM authentication.
+
+package ht
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM describes authentication of a request with NTLM (version 2), e.g.
+//     NTLM: {
+//         Domain:      "CORP"
+//         User:        "tester"
+//         PasswordVar: "NTLM_PASSWORD"
+//     }
+// Support for NTLM is experimental.
+//
+// The NTLM handshake is a challenge-response protocol which authenticates
+// the connection and not the request: The negotiate message is sent
+// without the request body, the final authenticate message with the body
+// must be sent over the same connection. This is done by a per-test
+// transport limited to one connection per host. HTTP/2 is not used.
+type NTLM struct {
+	// Domain and User identify the account.
+	Domain string `json:",omitempty"`
+	User   string
+
+	// PasswordVar is the name of the variable which contains the
+	// password. Using a variable keeps the password out of the test files.
+	PasswordVar string
+
+	// Workstation is the optional name of the client workstation.
+	Workstation string `json:",omitempty"`
+
+	// Negotiate uses the "Negotiate" authentication scheme instead of
+	// "NTLM". The NTLM messages are sent unwrapped which is accepted
+	// by servers offering Negotiate with NTLM as a fallback.
+	Negotiate bool `json:",omitempty"`
+}
+
+// validate the configuration of n and the presence of the password.
+func (n *NTLM) validate(variables map[string]string) error {
+	if n.User == "" || n.PasswordVar == "" {
+		return errors.New("missing User or PasswordVar")
+	}
+	if _, ok := variables[n.PasswordVar]; !ok {
+		return fmt.Errorf("no variable %s", n.PasswordVar)
+	}
+	return nil
+}
+
+func (n *NTLM) scheme() string {
+	if n.Negotiate {
+		return "Negotiate"
+	}
+	return "NTLM"
+}
+
+// ntlmTransport returns a transport derived from rt which performs the
+// NTLM handshake for each request of t.
+func (t *Test) ntlmTransport(rt http.RoundTripper) (http.RoundTripper, error) {
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("NTLM needs an *http.Transport, have %T", rt)
+	}
+	transport := base.Clone()
+	transport.MaxConnsPerHost = 1
+	transport.DisableKeepAlives = false
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	t.ntlm = transport
+	return &ntlmRoundTripper{
+		transport: transport,
+		auth:      t.Request.NTLM,
+		password:  t.Variables[t.Request.NTLM.PasswordVar],
+		test:      t,
+	}, nil
+}
+
+// releaseTransport closes the idle connections of the NTLM transport of t
+// which, unlike other transports, is private to one run of t as NTLM
+// authenticates connections.
+func (t *Test) releaseTransport() {
+	if t.ntlm != nil {
+		t.ntlm.CloseIdleConnections()
+		t.ntlm = nil
+	}
+}
+
+// ntlmRoundTripper performs the NTLM handshake over transport which must
+// reuse the connection for the two requests of the handshake.
+type ntlmRoundTripper struct {
+	transport *http.Transport
+	auth      *NTLM
+	password  string
+	test      *Test
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *ntlmRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	scheme := rt.auth.scheme()
+
+	negotiate := req.Clone(req.Context())
+	negotiate.Body, negotiate.GetBody, negotiate.ContentLength = nil, nil, 0
+	negotiate.Header.Set("Authorization",
+		scheme+" "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+	resp, err := rt.transport.RoundTrip(negotiate)
+	if err != nil {
+		return nil, err
+	}
+	// Drain the body to allow reuse of the connection.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	var challenge []byte
+	for _, h := range resp.Header["Www-Authenticate"] {
+		if strings.HasPrefix(h, scheme+" ") {
+			challenge, err = base64.StdEncoding.DecodeString(strings.TrimSpace(h[len(scheme)+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("ntlm: malformed challenge: %s", err)
+			}
+		}
+	}
+	if resp.StatusCode != http.StatusUnauthorized || challenge == nil {
+		return nil, fmt.Errorf("ntlm: no %s challenge received (status %s)",
+			scheme, resp.Status)
+	}
+
+	authenticate, err := ntlmAuthenticateMessage(challenge, rt.auth.Domain,
+		rt.auth.User, rt.password, rt.auth.Workstation, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("Authorization",
+		scheme+" "+base64.StdEncoding.EncodeToString(authenticate))
+	rt.test.debugf("NTLM handshake with %s as %s\\%s", req.URL.Host,
+		rt.auth.Domain, rt.auth.User)
+	return rt.transport.RoundTrip(authenticated)
+}
+
+// ----------------------------------------------------------------------------
+// NTLM messages, see [MS-NLMP].
+
+const (
+	ntlmNegotiateUnicode     = 0x00000001
+	ntlmNegotiateOEM         = 0x00000002
+	ntlmRequestTarget        = 0x00000004
+	ntlmNegotiateNTLM        = 0x00000200
+	ntlmNegotiateAlwaysSign  = 0x00008000
+	ntlmNegotiateExtSecurity = 0x00080000
+	ntlmNegotiateTargetInfo  = 0x00800000
+	ntlmNegotiate128         = 0x20000000
+	ntlmNegotiate56          = 0x80000000
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE (type 1).
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateUnicode|ntlmNegotiateOEM|
+		ntlmRequestTarget|ntlmNegotiateNTLM|ntlmNegotiateAlwaysSign|
+		ntlmNegotiateExtSecurity|ntlmNegotiateTargetInfo|
+		ntlmNegotiate128|ntlmNegotiate56)
+	// Empty domain and workstation fields at 16 and 24.
+	return msg
+}
+
+// ntlmChallenge is the relevant content of a CHALLENGE_MESSAGE (type 2).
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+// parseNTLMChallenge parses the CHALLENGE_MESSAGE msg.
+func parseNTLMChallenge(msg []byte) (ntlmChallenge, error) {
+	c := ntlmChallenge{}
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) ||
+		binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return c, errors.New("ntlm: not a challenge message")
+	}
+	c.flags = binary.LittleEndian.Uint32(msg[20:])
+	c.challenge = msg[24:32]
+	if len(msg) >= 48 {
+		length := int(binary.LittleEndian.Uint16(msg[40:]))
+		offset := int(binary.LittleEndian.Uint32(msg[44:]))
+		if offset+length > len(msg) {
+			return c, errors.New("ntlm: malformed target info")
+		}
+		c.targetInfo = msg[offset : offset+length]
+	}
+	return c, nil
+}
+
+// ntlmAvTimestampOf returns the MsvAvTimestamp of the target info or nil.
+func ntlmAvTimestampOf(targetInfo []byte) []byte {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == ntlmAvEOL || 4+length > len(targetInfo) {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return targetInfo[4:12]
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	return nil
+}
+
+// ntlmAuthenticateMessage returns the AUTHENTICATE_MESSAGE (type 3) with
+// a NTLMv2 response to the given challenge message.
+func ntlmAuthenticateMessage(challengeMsg []byte, domain, user, password, workstation string, now time.Time) ([]byte, error) {
+	c, err := parseNTLMChallenge(challengeMsg)
+	if err != nil {
+		return nil, err
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	timestamp := ntlmAvTimestampOf(c.targetInfo)
+	lmResponse := make([]byte, 24) // LMv2 must be zero if the server sent a timestamp
+	if timestamp == nil {
+		timestamp = ntlmFiletime(now)
+		lmResponse = ntlmLMv2Response(domain, user, password, c.challenge, clientChallenge)
+	}
+	ntResponse := ntlmV2Response(domain, user, password, c.challenge,
+		clientChallenge, timestamp, c.targetInfo)
+
+	encode := func(s string) []byte { return []byte(s) }
+	if c.flags&ntlmNegotiateUnicode != 0 {
+		encode = utf16le
+	}
+	flags := c.flags &^ ntlmNegotiateOEM
+	if c.flags&ntlmNegotiateUnicode == 0 {
+		flags = c.flags &^ ntlmNegotiateUnicode
+	}
+
+	fields := [][]byte{lmResponse, ntResponse, encode(domain), encode(user),
+		encode(workstation), nil}
+	const headerSize = 64
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := headerSize
+	for i, field := range fields {
+		pos := 12 + 8*i
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		msg = append(msg, field...)
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	return msg, nil
+}
+
+// ntlmV2Hash computes NTOWFv2.
+func ntlmV2Hash(domain, user, password string) []byte {
+	h := md4.New()
+	h.Write(utf16le(password))
+	mac := hmac.New(md5.New, h.Sum(nil))
+	mac.Write(utf16le(strings.ToUpper(user) + domain))
+	return mac.Sum(nil)
+}
+
+// ntlmV2Response computes the NTLMv2 response, i.e. NTProofStr followed
+// by the client blob.
+func ntlmV2Response(domain, user, password string, serverChallenge, clientChallenge, timestamp, targetInfo []byte) []byte {
+	blob := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	blob = append(blob, timestamp...)
+	blob = append(blob, clientChallenge...)
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, targetInfo...)
+	blob = append(blob, 0, 0, 0, 0)
+
+	mac := hmac.New(md5.New, ntlmV2Hash(domain, user, password))
+	mac.Write(serverChallenge)
+	mac.Write(blob)
+	return append(mac.Sum(nil), blob...)
+}
+
+// ntlmLMv2Response computes the LMv2 response.
+func ntlmLMv2Response(domain, user, password string, serverChallenge, clientChallenge []byte) []byte {
+	mac := hmac.New(md5.New, ntlmV2Hash(domain, user, password))
+	mac.Write(serverChallenge)
+	mac.Write(clientChallenge)
+	return append(mac.Sum(nil), clientChallenge...)
+}
+
+// ntlmFiletime encodes t as a Windows FILETIME, i.e. 100ns intervals
+// since 1601-01-01.
+func ntlmFiletime(t time.Time) []byte {
+	const epochDiff = 116444736000000000 // 1601-01-01 to 1970-01-01 in 100ns
+	ft := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ft, uint64(t.UnixNano()/100+epochDiff))
+	return ft
+}
+
+// utf16le encodes s as UTF-16 little endian.
+func utf16le(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
@@ -0,0 +1,210 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test vectors from [MS-NLMP] section 4.2.4.
+func TestNTLMv2Response(t *testing.T) {
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge, _ := hex.DecodeString("aaaaaaaaaaaaaaaa")
+	targetInfo := append([]byte{2, 0, 12, 0}, utf16le("Domain")...)
+	targetInfo = append(targetInfo, 1, 0, 12, 0)
+	targetInfo = append(targetInfo, utf16le("Server")...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	if got := hex.EncodeToString(ntlmV2Hash("Domain", "User", "Password")); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("NTOWFv2: got %s", got)
+	}
+	lm := ntlmLMv2Response("Domain", "User", "Password", serverChallenge, clientChallenge)
+	if got := hex.EncodeToString(lm); got != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("LMv2: got %s", got)
+	}
+	nt := ntlmV2Response("Domain", "User", "Password", serverChallenge,
+		clientChallenge, make([]byte, 8), targetInfo)
+	if got := hex.EncodeToString(nt[:16]); got != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("NTProofStr: got %s", got)
+	}
+}
+
+// ntlmServer is a minimal NTLMv2 server which checks the NTProofStr and
+// connection affinity.
+type ntlmServer struct {
+	sync.Mutex
+	challenged map[string]bool // remote addresses challenged
+	password   string
+}
+
+var ntlmServerChallenge = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+func (s *ntlmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	unauthorized := func(challenge string) {
+		w.Header().Set("WWW-Authenticate", strings.TrimSpace("NTLM "+challenge))
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "unauthorized")
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "NTLM ") {
+		unauthorized("")
+		return
+	}
+	msg, _ := base64.StdEncoding.DecodeString(auth[5:])
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) {
+		unauthorized("")
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	switch binary.LittleEndian.Uint32(msg[8:]) {
+	case 1:
+		s.challenged[r.RemoteAddr] = true
+		challenge := make([]byte, 48)
+		copy(challenge, ntlmSignature)
+		binary.LittleEndian.PutUint32(challenge[8:], 2)
+		binary.LittleEndian.PutUint32(challenge[20:], ntlmNegotiateUnicode|ntlmNegotiateNTLM)
+		copy(challenge[24:], ntlmServerChallenge)
+		targetInfo := append([]byte{2, 0, 8, 0}, utf16le("CORP")...)
+		targetInfo = append(targetInfo, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+		binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+		binary.LittleEndian.PutUint32(challenge[44:], 48)
+		challenge = append(challenge, targetInfo...)
+		unauthorized(base64.StdEncoding.EncodeToString(challenge))
+	case 3:
+		if !s.challenged[r.RemoteAddr] {
+			http.Error(w, "not challenged on this connection", http.StatusBadRequest)
+			return
+		}
+		field := func(pos int) []byte {
+			length := binary.LittleEndian.Uint16(msg[pos:])
+			offset := binary.LittleEndian.Uint32(msg[pos+4:])
+			return msg[offset : offset+uint32(length)]
+		}
+		nt, domain, user := field(20), field(28), field(36)
+		mac := hmac.New(md5.New, ntlmV2Hash("CORP", "tester", s.password))
+		mac.Write(ntlmServerChallenge)
+		mac.Write(nt[16:])
+		if !bytes.Equal(domain, utf16le("CORP")) || !bytes.Equal(user, utf16le("tester")) ||
+			!hmac.Equal(mac.Sum(nil), nt[:16]) {
+			unauthorized("")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "Hello tester: %s", body)
+	}
+}
+
+func TestNTLM(t *testing.T) {
+	ts := httptest.NewServer(&ntlmServer{
+		challenged: make(map[string]bool),
+		password:   "s3cr3t",
+	})
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		password string
+		status   int
+	}{
+		{"s3cr3t", 200},
+		{"wrong", 401},
+	} {
+		test := &Test{
+			Request: Request{
+				Method: "POST",
+				URL:    ts.URL,
+				Body:   "some data",
+				NTLM: &NTLM{
+					Domain:      "CORP",
+					User:        "tester",
+					PasswordVar: "PASSWORD",
+				},
+			},
+			Checks: CheckList{
+				StatusCode{Expect: tc.status},
+			},
+			Variables: map[string]string{"PASSWORD": tc.password},
+		}
+		if tc.status == 200 {
+			test.Checks = append(test.Checks, &Body{Equals: "Hello tester: some data"})
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%d. Got %s: %v", i, test.Status, test.Error)
+		}
+	}
+
+	test := &Test{
+		Request: Request{
+			URL:  ts.URL,
+			NTLM: &NTLM{User: "tester", PasswordVar: "PASSWORD"},
+		},
+	}
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got %s, want Bogus for missing password variable", test.Status)
+	}
+}
+
+// recordingWrapper is a TransportWrapper recording the status codes of
+// the responses of the wrapped transport.
+type recordingWrapper struct {
+	status []int
+}
+
+func (w *recordingWrapper) RoundTrip(*http.Request) (*http.Response, error) {
+	panic("not wrapped")
+}
+
+func (w *recordingWrapper) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			w.status = append(w.status, resp.StatusCode)
+		}
+		return resp, err
+	})
+}
+
+func TestNTLMTransportWrapper(t *testing.T) {
+	ts := httptest.NewServer(&ntlmServer{
+		challenged: make(map[string]bool),
+		password:   "s3cr3t",
+	})
+	defer ts.Close()
+
+	wrapper := &recordingWrapper{}
+	test := &Test{
+		Request: Request{
+			URL:  ts.URL,
+			NTLM: &NTLM{Domain: "CORP", User: "tester", PasswordVar: "PASSWORD"},
+		},
+		Checks:    CheckList{StatusCode{Expect: 200}},
+		Variables: map[string]string{"PASSWORD": "s3cr3t"},
+		Transport: wrapper,
+	}
+	test.Run()
+	if test.Status != Pass {
+		t.Errorf("Got %s: %v", test.Status, test.Error)
+	}
+	// The wrapper sees the authenticated request only.
+	if len(wrapper.status) != 1 || wrapper.status[0] != 200 {
+		t.Errorf("Got %v", wrapper.status)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// openapi.go contains a check of responses against an OpenAPI spec.
+
+package ht
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/internal/openapi"
+)
+
+func init() {
+	RegisterCheck(&OpenAPIResponse{})
+}
+
+// ----------------------------------------------------------------------------
+// OpenAPIResponse
+
+// OpenAPIResponse checks the response against the declaration of the
+// operation in an OpenAPI 3 specification: The status code must be one of
+// the declared response codes of the operation (explicitly, as a range
+// like 2XX or via default) and a JSON body must conform to the schema
+// declared for this status code.
+//
+// The following subset of JSON schema is validated: type (including
+// nullable), enum, required, properties, additionalProperties, items,
+// allOf, anyOf, oneOf, minimum, maximum, minLength, maxLength, pattern,
+// minItems and maxItems. Only local references ($ref: '#/...') are
+// resolved.
+type OpenAPIResponse struct {
+	// Spec is the filename of the OpenAPI 3 specification in YAML or
+	// JSON format.
+	Spec string
+
+	// Operation is the operationId of the operation to check against.
+	Operation string
+
+	spec      *openapi.Spec
+	responses map[string]interface{}
+}
+
+// Prepare implements Check's Prepare method.
+func (c *OpenAPIResponse) Prepare() error {
+	if c.Operation == "" {
+		return fmt.Errorf("missing Operation")
+	}
+	data, err := ioutil.ReadFile(c.Spec)
+	if err != nil {
+		return err
+	}
+	c.spec, err = openapi.Decode(data)
+	if err != nil {
+		return fmt.Errorf("cannot decode %s: %s", c.Spec, err)
+	}
+
+	for _, item := range c.spec.Object(c.spec.Root["paths"]) {
+		for _, op := range c.spec.Object(item) {
+			op := c.spec.Object(op)
+			if op["operationId"] == c.Operation {
+				c.responses = c.spec.Object(op["responses"])
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no operation %q in %s", c.Operation, c.Spec)
+}
+
+// InspectsBody implements BodyInspector.
+func (*OpenAPIResponse) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (c *OpenAPIResponse) Execute(t *Test) error {
+	code := t.Response.Response.StatusCode
+	response, declared := c.response(code)
+	if !declared {
+		return fmt.Errorf("status code %d not declared for operation %s", code, c.Operation)
+	}
+
+	content := c.spec.Object(response["content"])
+	if len(content) == 0 {
+		return nil
+	}
+	ct := t.Response.Response.Header.Get("Content-Type")
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("bad Content-Type %q: %s", ct, err)
+	}
+	media, ok := c.mediaType(content, mt)
+	if !ok {
+		return fmt.Errorf("content type %s not declared for status %d", mt, code)
+	}
+	schema, hasSchema := media["schema"]
+	if !hasSchema || !strings.Contains(mt, "json") {
+		return nil
+	}
+
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	var body interface{}
+	if err := json.Unmarshal([]byte(t.Response.BodyStr), &body); err != nil {
+		return fmt.Errorf("invalid JSON: %s", err)
+	}
+	errs := ErrorList{}
+	c.validate(body, schema, "$", &errs, 0)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// response returns the declared response for the status code.
+func (c *OpenAPIResponse) response(code int) (map[string]interface{}, bool) {
+	s := strconv.Itoa(code)
+	for _, key := range []string{s, s[:1] + "XX", s[:1] + "xx", "default"} {
+		if r, ok := c.responses[key]; ok {
+			return c.spec.Object(r), true
+		}
+	}
+	return nil, false
+}
+
+// mediaType looks up the media type object for mt in content which may
+// contain wildcards like application/* or */*.
+func (c *OpenAPIResponse) mediaType(content map[string]interface{}, mt string) (map[string]interface{}, bool) {
+	candidates := []string{mt, mt[:strings.Index(mt+"/", "/")] + "/*", "*/*"}
+	for _, cand := range candidates {
+		for declared, media := range content {
+			dmt, _, err := mime.ParseMediaType(declared)
+			if err == nil && dmt == cand {
+				return c.spec.Object(media), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// validate v against schema and collect violations in errs.
+func (c *OpenAPIResponse) validate(v interface{}, sv interface{}, path string, errs *ErrorList, depth int) {
+	if depth > 64 {
+		return // Recursive schema; give up.
+	}
+	schema := c.spec.Object(sv)
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if v == nil {
+		if schema["nullable"] == true || schema["type"] == nil {
+			return
+		}
+		fail("null not allowed")
+		return
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		c.validate(v, sub, path, errs, depth+1)
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		alts := schemaList(schema[key])
+		if len(alts) == 0 {
+			continue
+		}
+		matching := 0
+		for _, sub := range alts {
+			sErrs := ErrorList{}
+			c.validate(v, sub, path, &sErrs, depth+1)
+			if len(sErrs) == 0 {
+				matching++
+			}
+		}
+		if matching == 0 || (key == "oneOf" && matching > 1) {
+			fail("matches %d schemas of %s", matching, key)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%v not in enum %v", v, enum)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if typ != "" && typ != "object" {
+			fail("got object, want %s", typ)
+			return
+		}
+		for _, r := range schemaList(schema["required"]) {
+			if name, ok := r.(string); ok {
+				if _, present := v[name]; !present {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+		props := c.spec.Object(schema["properties"])
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := props[k]; ok {
+				c.validate(v[k], ps, path+"."+k, errs, depth+1)
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					fail("unexpected property %q", k)
+				}
+			case map[string]interface{}:
+				c.validate(v[k], ap, path+"."+k, errs, depth+1)
+			}
+		}
+	case []interface{}:
+		if typ != "" && typ != "array" {
+			fail("got array, want %s", typ)
+			return
+		}
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			fail("%d items, want at least %g", len(v), n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			fail("%d items, want at most %g", len(v), n)
+		}
+		if items, ok := schema["items"]; ok {
+			for i, e := range v {
+				c.validate(e, items, fmt.Sprintf("%s[%d]", path, i), errs, depth+1)
+			}
+		}
+	case string:
+		if typ != "" && typ != "string" {
+			fail("got string, want %s", typ)
+			return
+		}
+		length := float64(len([]rune(v)))
+		if n, ok := number(schema["minLength"]); ok && length < n {
+			fail("length %g, want at least %g", length, n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && length > n {
+			fail("length %g, want at most %g", length, n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("bad pattern in schema: %s", err)
+			} else if !re.MatchString(v) {
+				fail("%q does not match pattern %s", v, pattern)
+			}
+		}
+	case float64:
+		if typ != "" && typ != "number" && typ != "integer" {
+			fail("got number, want %s", typ)
+			return
+		}
+		if typ == "integer" && v != math.Trunc(v) {
+			fail("got %g, want integer", v)
+		}
+		if n, ok := number(schema["minimum"]); ok && v < n {
+			fail("%g less than minimum %g", v, n)
+		}
+		if n, ok := number(schema["maximum"]); ok && v > n {
+			fail("%g greater than maximum %g", v, n)
+		}
+	case bool:
+		if typ != "" && typ != "boolean" {
+			fail("got boolean, want %s", typ)
+		}
+	}
+}
+
+func schemaList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"testing"
+)
+
+func openapiResponse(code int, body string) Response {
+	return Response{
+		Response: &http.Response{
+			StatusCode: code,
+			Header: http.Header{
+				"Content-Type": {"application/json; charset=utf-8"},
+			},
+		},
+		BodyStr: body,
+	}
+}
+
+// petstore is shared with the importer.
+const petstore = "../internal/openapi/testdata/petstore.yaml"
+
+var openapiResponseTests = []TC{
+	{openapiResponse(200, `{"id": 7, "name": "Rex", "tag": "dog"}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"}, nil},
+	{openapiResponse(200, `{"id": 7, "name": "Rex", "tag": null}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"}, nil},
+	{openapiResponse(404, `{"message": "no such pet"}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"}, nil},
+	{openapiResponse(200, `{"id": 0, "name": "", "tag": "bird", "age": 3}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"},
+		ErrorList{
+			errorString(`$: unexpected property "age"`),
+			errorString(`$.id: 0 less than minimum 1`),
+			errorString(`$.name: length 0, want at least 1`),
+			errorString(`$.tag: bird not in enum [dog cat]`),
+		}},
+	{openapiResponse(200, `{"id": 1.5}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"},
+		ErrorList{
+			errorString(`$: missing required property "name"`),
+			errorString(`$.id: got 1.5, want integer`),
+		}},
+	{openapiResponse(404, `{"id": 7, "name": "Rex"}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"},
+		ErrorList{errorString(`$: missing required property "message"`)}},
+	{openapiResponse(500, `{}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "showPetById"},
+		errorString("status code 500 not declared for operation showPetById")},
+	{openapiResponse(200, `{}`),
+		&OpenAPIResponse{Spec: petstore, Operation: "noSuchOperation"}, prepareError},
+	{openapiResponse(200, `{}`),
+		&OpenAPIResponse{Spec: "testdata/nosuchfile.yaml", Operation: "showPetById"}, prepareError},
+}
+
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+func TestOpenAPIResponse(t *testing.T) {
+	for i, tc := range openapiResponseTests {
+		runTest(t, i, tc)
+	}
+}
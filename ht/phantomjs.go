@@ -400,6 +400,12 @@ func newGeometry(s string) (geometry, error) {
 	return geom, nil
 }
 
+// Cost implements Coster: Screenshot launches a browser.
+func (*Screenshot) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (*Screenshot) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (s *Screenshot) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
@@ -620,6 +626,12 @@ func (r *RenderedHTML) Prepare() error {
 	return nil
 }
 
+// Cost implements Coster: RenderedHTML launches a browser.
+func (*RenderedHTML) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (*RenderedHTML) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (r *RenderedHTML) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
@@ -738,6 +750,12 @@ func (d *RenderingTime) Prepare() error {
 var phantomjsInvocationOverhead time.Duration
 var phantomjsOnce sync.Once
 
+// Cost implements Coster: RenderingTime launches a browser.
+func (*RenderingTime) Cost() int { return CostExpensive }
+
+// InspectsBody implements BodyInspector.
+func (*RenderingTime) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (d *RenderingTime) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
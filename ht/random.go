@@ -45,7 +45,7 @@ type randomFunc struct {
 	//    "#"     value should be an integer
 	//    "#3"    default value of 3 and interpreted as a number
 	args []string // defaults and int parsing
-	fn   func(args []interface{}) (string, error)
+	fn   func(rnd *rand.Rand, args []interface{}) (string, error)
 }
 
 var randomFuncs = []randomFunc{
@@ -72,35 +72,78 @@ var randomFuncs = []randomFunc{
 	},
 	{
 		// Random email address
-		//     RANDOM EMAIL [<domain>]
+		//     RANDOM EMAIL [<style>] [<domain>]
 		// produces a random email address with the given <domain>
-		// <domain> defautls to gmail.com
+		// where the local part is formed according to <style> which
+		// is one of first.last, last.first, first_last, f.last or first.
+		// <domain> defautls to gmail.com, the default <style> mixes
+		// first and last names and may contain a middle initial.
 		name: "EMAIL",
-		re:   regexp.MustCompile(`^([-a-z.]+)?$`),
-		args: []string{"gmail.com"},
+		re:   regexp.MustCompile(`^((first\.last|last\.first|first_last|f\.last|first)( +|$))?([-a-z0-9.]+)?$`),
+		args: []string{"", "", "", "gmail.com"},
 		fn:   randomEmail,
 	},
+	{
+		// Random UUID
+		//     RANDOM UUID
+		// produces a random (version 4) UUID as described in RFC 4122.
+		name: "UUID",
+		re:   regexp.MustCompile(`^$`),
+		args: []string{},
+		fn:   randomUUID,
+	},
 }
 
 // randomNumber produces a random integer number in the interval
 // [ args[1], args[2] ] formated as args[4].
-func randomNumber(args []interface{}) (string, error) {
+func randomNumber(rnd *rand.Rand, args []interface{}) (string, error) {
 	from, to, format := args[1].(int), args[2].(int), args[4].(string)
 	if span := (to - from + 1); span > 0 {
-		return fmt.Sprintf(format, from+Random.Intn(span)), nil
+		return fmt.Sprintf(format, from+rnd.Intn(span)), nil
 	}
 	return "", fmt.Errorf("ht: invalid range [%d,%d] for random number", from, to)
 }
 
-func randomEmail(args []interface{}) (string, error) {
-	domain := args[0].(string)
-	first := emailNameCorpus[Random.Intn(len(emailNameCorpus))]
-	middle := ""
-	last := emailNameCorpus[Random.Intn(len(emailNameCorpus))]
-	if r := Random.Intn(30); r < 26 {
-		middle = fmt.Sprintf(".%c", 'A'+r)
+func randomEmail(rnd *rand.Rand, args []interface{}) (string, error) {
+	style, domain := args[1].(string), args[3].(string)
+	if style == "" {
+		first := emailNameCorpus[rnd.Intn(len(emailNameCorpus))]
+		middle := ""
+		last := emailNameCorpus[rnd.Intn(len(emailNameCorpus))]
+		if r := rnd.Intn(30); r < 26 {
+			middle = fmt.Sprintf(".%c", 'A'+r)
+		}
+		return fmt.Sprintf("%s%s.%s@%s", first, middle, last, domain), nil
+	}
+
+	firstNames, lastNames := emailNameCorpus[:16], emailNameCorpus[16:]
+	first := firstNames[rnd.Intn(len(firstNames))]
+	last := lastNames[rnd.Intn(len(lastNames))]
+	var local string
+	switch style {
+	case "first.last":
+		local = first + "." + last
+	case "last.first":
+		local = last + "." + first
+	case "first_last":
+		local = first + "_" + last
+	case "f.last":
+		local = first[:1] + "." + last
+	case "first":
+		local = first
 	}
-	return fmt.Sprintf("%s%s.%s@%s", first, middle, last, domain), nil
+	return fmt.Sprintf("%s@%s", local, domain), nil
+}
+
+// randomUUID produces a random version 4 UUID.
+func randomUUID(rnd *rand.Rand, args []interface{}) (string, error) {
+	u := make([]byte, 16)
+	for i := range u {
+		u[i] = byte(rnd.Intn(256))
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant 10 of RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
 }
 
 // the 20 most popular first names (2014) and the 20 most common last names
@@ -151,7 +194,7 @@ var textCorpus = map[string]string{
 
 // randomText produces a random text of n words with n in [ args[3], args[4] ]
 // in the language args[1].
-func randomText(args []interface{}) (string, error) {
+func randomText(rnd *rand.Rand, args []interface{}) (string, error) {
 	lang, min, max := args[1].(string), args[3].(int), args[4].(int)
 	corpus, ok := textCorpus[lang]
 	if !ok {
@@ -161,13 +204,13 @@ func randomText(args []interface{}) (string, error) {
 	if span <= 0 {
 		return "", fmt.Errorf("ht: invalid range [%d,%d] for random text", min, max)
 	}
-	n := min + Random.Intn(span)
+	n := min + rnd.Intn(span)
 	if n == 0 {
 		return "", nil
 	}
 	words := strings.Split(corpus, " ")
 	w := len(words)
-	begin := Random.Intn(w - 1)
+	begin := rnd.Intn(w - 1)
 	if begin+n <= w {
 		return strings.Join(words[begin:begin+n], " "), nil
 	}
@@ -179,8 +222,40 @@ func randomText(args []interface{}) (string, error) {
 	return strings.Join(text[:n], " "), nil
 }
 
-// randomValue interpretes a r of the form "RANDOM <what> [parameters]".
+var randomRe = regexp.MustCompile(`\{\{(RANDOM [^}]+)\}\}`)
+
+// SetRandomVariables looks for all {{RANDOM ...}} constructs in texts and
+// stores a random value for each of them in vars. The values are drawn from
+// a random source seeded with seed or from Random if seed is 0.
+func SetRandomVariables(vars map[string]string, seed int64, texts ...string) error {
+	rnd := Random
+	if seed != 0 {
+		rnd = rand.New(rand.NewSource(seed))
+	} else {
+		randMux.Lock()
+		defer randMux.Unlock()
+	}
+	for _, text := range texts {
+		for _, m := range randomRe.FindAllStringSubmatch(text, -1) {
+			if err := setRandomVariableFrom(rnd, vars, m[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setRandomVariable interpretes a r of the form "RANDOM <what> [parameters]"
+// and stores a random value drawn from Random in vars[r].
 func setRandomVariable(vars map[string]string, r string) error {
+	randMux.Lock()
+	defer randMux.Unlock()
+	return setRandomVariableFrom(Random, vars, r)
+}
+
+// setRandomVariableFrom works like setRandomVariable but uses rnd as the
+// source of randomness.
+func setRandomVariableFrom(rnd *rand.Rand, vars map[string]string, r string) error {
 	if _, ok := vars[r]; ok {
 		return nil // This one was not a new one.
 	}
@@ -195,7 +270,7 @@ func setRandomVariable(vars map[string]string, r string) error {
 		if err != nil {
 			return err
 		}
-		value, err := rf.fn(arglist)
+		value, err := rf.fn(rnd, arglist)
 		if err != nil {
 			return err
 		}
@@ -34,6 +34,14 @@ func TestSetRandomVariable(t *testing.T) {
 		{r: "RANDOM TEXT tlh 1-2", want: "\uf8d1\uf8dd\uf8e1\uf8e3\uf8d0\uf8e2"},
 		{r: "RANDOM EMAIL", want: "Graf.M.Laura@gmail.com"},
 		{r: "RANDOM EMAIL web.de", want: "Graf.M.Laura@web.de"},
+		{r: "RANDOM EMAIL first.last", want: "Lara.Schneider@gmail.com"},
+		{r: "RANDOM EMAIL first.last corporate.com", want: "Lara.Schneider@corporate.com"},
+		{r: "RANDOM EMAIL last.first", want: "Schneider.Lara@gmail.com"},
+		{r: "RANDOM EMAIL first_last", want: "Lara_Schneider@gmail.com"},
+		{r: "RANDOM EMAIL f.last example.org", want: "L.Schneider@example.org"},
+		{r: "RANDOM EMAIL first first.ch", want: "Lara@first.ch"},
+		{r: "RANDOM EMAIL first.ch", want: "Graf.M.Laura@first.ch"},
+		{r: "RANDOM UUID", want: "e2aadcd8-68ce-4284-b7f8-6e430140149b"},
 	} {
 		vars := map[string]string{}
 		Random = rand.New(rand.NewSource(2))
@@ -58,3 +66,37 @@ func TestSetRandomVariable(t *testing.T) {
 		}
 	}
 }
+
+func TestSetRandomVariables(t *testing.T) {
+	text := `{"id": "{{RANDOM UUID}}", "mail": "{{RANDOM EMAIL first.last}}"}`
+
+	// Values drawn with a seed do not depend on the global Random.
+	Random = rand.New(rand.NewSource(2))
+	a := map[string]string{}
+	if err := SetRandomVariables(a, 77, text); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	Random.Intn(100)
+	b := map[string]string{}
+	if err := SetRandomVariables(b, 77, "{{RANDOM UUID}}", text); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(a) != 2 || a["RANDOM UUID"] != b["RANDOM UUID"] ||
+		a["RANDOM EMAIL first.last"] != b["RANDOM EMAIL first.last"] {
+		t.Errorf("Got %v and %v", a, b)
+	}
+
+	// Without a seed the global Random is used.
+	Random = rand.New(rand.NewSource(2))
+	c := map[string]string{}
+	if err := SetRandomVariables(c, 0, "{{RANDOM UUID}}"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := c["RANDOM UUID"]; got != "e2aadcd8-68ce-4284-b7f8-6e430140149b" {
+		t.Errorf("Got %q", got)
+	}
+
+	if err := SetRandomVariables(c, 0, "{{RANDOM FOO}}"); err == nil {
+		t.Errorf("Missing error for unknown random type")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ratelimit.go contains the rate limiter for outgoing requests.
+
+package ht
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of outgoing requests of all tests sharing
+// it, including requests made by checks like Links or Latency. It is a
+// token bucket of size one: Tokens are handed out in intervals of at
+// least 1/rate seconds. A nil RateLimiter does not limit at all.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // earliest time the next token is available
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate requests per second.
+// A rate <= 0 yields nil, i.e. no limiting.
+func NewRateLimiter(rate float64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait blocks until a token is available or ctx is done in which case
+// ctx.Err() is returned. The lock is not held while waiting so any number
+// of concurrent callers are served one after the other without
+// deadlocking.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if rl == nil {
+		return nil
+	}
+	rl.mu.Lock()
+	if rl.interval <= 0 {
+		rl.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	at := rl.next
+	rl.next = rl.next.Add(rl.interval)
+	rl.mu.Unlock()
+	if !sleep(ctx, at.Sub(now)) {
+		return ctx.Err()
+	}
+	return nil
+}
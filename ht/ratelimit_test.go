@@ -0,0 +1,65 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(50)
+	if rl.interval != 20*time.Millisecond {
+		t.Fatalf("got interval %s, want 20ms", rl.interval)
+	}
+
+	// More concurrent callers than tokens per second must not deadlock.
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rl.Wait(context.Background()); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := time.Since(start); got < 9*rl.interval {
+		t.Errorf("10 tokens in %s, want at least %s", got, 9*rl.interval)
+	}
+
+	// A nil RateLimiter does not limit at all.
+	if rl = NewRateLimiter(0); rl != nil {
+		t.Fatalf("got %v for rate 0, want nil", rl)
+	}
+	start = time.Now()
+	for i := 0; i < 1000; i++ {
+		rl.Wait(context.Background())
+	}
+	if got := time.Since(start); got > 100*time.Millisecond {
+		t.Errorf("unlimited took %s", got)
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	rl := NewRateLimiter(0.1) // one token every 10 seconds
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := time.Since(start); got > time.Second {
+		t.Errorf("canceled Wait took %s", got)
+	}
+}
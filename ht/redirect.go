@@ -0,0 +1,113 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// redirect.go contains the policy for following redirects.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/vdobler/ht/populate"
+)
+
+// RedirectPolicy determines if and how redirects are followed.
+// A plain boolean is accepted for backwards compatibility, i.e.
+//     FollowRedirects: true
+// is the same as
+//     FollowRedirects: { Follow: true }
+// If given as an object Follow defaults to true, so
+//     FollowRedirects: { Max: 3, SameHostOnly: true }
+// follows at most 3 redirects which stay on the same host.
+//
+// Note that Request.FollowRedirects used to be a bool: Go code must now
+// use RedirectPolicy{Follow: true} instead of true. Test files are not
+// affected as they may still use a plain boolean.
+type RedirectPolicy struct {
+	// Follow determines if automatic following of redirects
+	// should be done.
+	Follow bool `json:",omitempty"`
+
+	// Max is the maximum number of redirects to follow. Exceeding it
+	// is an error. Zero means 10.
+	Max int `json:",omitempty"`
+
+	// SameHostOnly stops following redirects to a different host:
+	// The redirect response itself becomes the final response.
+	SameHostOnly bool `json:",omitempty"`
+
+	// KeepAuthHeaderAcrossHosts sends the Authorization header also
+	// to a different host. By default the Authorization header is
+	// dropped when being redirected to a different host to prevent
+	// leaking credentials.
+	KeepAuthHeaderAcrossHosts bool `json:",omitempty"`
+}
+
+// Populate implements populate.Populator: RedirectPolicy may be given
+// as a boolean.
+func (p *RedirectPolicy) Populate(src interface{}) error {
+	if m, ok := src.(map[string]interface{}); ok {
+		type plain RedirectPolicy // plain has no Populate method.
+		x := plain{}
+		if err := populate.Strict(&x, m); err != nil {
+			return err
+		}
+		if _, ok := m["Follow"]; !ok {
+			x.Follow = true
+		}
+		*p = RedirectPolicy(x)
+		return nil
+	}
+
+	follow := false
+	if err := populate.Strict(&follow, src); err != nil {
+		return fmt.Errorf("FollowRedirects must be a boolean or an object: %s", err)
+	}
+	*p = RedirectPolicy{Follow: follow}
+	return nil
+}
+
+// max returns the maximum number of redirects to follow.
+func (p RedirectPolicy) max() int {
+	if p.Max > 0 {
+		return p.Max
+	}
+	return 10
+}
+
+// checkRedirect returns a http.Client.CheckRedirect function implementing
+// the redirect policy of t.
+func (t *Test) checkRedirect() func(*http.Request, []*http.Request) error {
+	policy := t.Request.FollowRedirects
+	if !policy.Follow {
+		return dontFollowRedirects
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > policy.max() {
+			return fmt.Errorf("stopped after %d redirects", policy.max())
+		}
+		orig := via[0]
+		if req.URL.Host != orig.URL.Host {
+			if policy.SameHostOnly {
+				t.debugf("Not following redirect to other host %s", req.URL.Host)
+				return redirectNofollow
+			}
+			if policy.KeepAuthHeaderAcrossHosts {
+				if auth := orig.Header.Get("Authorization"); auth != "" {
+					req.Header.Set("Authorization", auth)
+				}
+			} else {
+				req.Header.Del("Authorization")
+			}
+		} else if t.Request.BasicAuthUser != "" {
+			if user, pass, ok := orig.BasicAuth(); ok {
+				req.SetBasicAuth(user, pass)
+			}
+		}
+		t.Response.Redirections = append(t.Response.Redirections, req.URL.String())
+		return nil
+	}
+}
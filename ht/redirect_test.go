@@ -0,0 +1,105 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectPolicyPopulate(t *testing.T) {
+	for i, tc := range []struct {
+		src  interface{}
+		want RedirectPolicy
+	}{
+		{true, RedirectPolicy{Follow: true}},
+		{false, RedirectPolicy{}},
+		{"yes", RedirectPolicy{Follow: true}},
+		{map[string]interface{}{"Max": 3.0}, RedirectPolicy{Follow: true, Max: 3}},
+		{map[string]interface{}{"SameHostOnly": true},
+			RedirectPolicy{Follow: true, SameHostOnly: true}},
+		{map[string]interface{}{"Follow": false, "Max": 3.0}, RedirectPolicy{Max: 3}},
+	} {
+		var got RedirectPolicy
+		if err := got.Populate(tc.src); err != nil {
+			t.Errorf("%d. Unexpected error %s", i, err)
+		} else if got != tc.want {
+			t.Errorf("%d. Got %+v, want %+v", i, got, tc.want)
+		}
+	}
+
+	for i, src := range []interface{}{"maybe", map[string]interface{}{"Hops": 3.0}} {
+		var got RedirectPolicy
+		if err := got.Populate(src); err == nil {
+			t.Errorf("%d. Missing error for %v", i, src)
+		}
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "auth=%s", r.Header.Get("Authorization"))
+		}))
+	defer other.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/same":
+				http.Redirect(w, r, "/other", http.StatusFound)
+			case "/other":
+				http.Redirect(w, r, other.URL+"/", http.StatusFound)
+			default:
+				fmt.Fprintf(w, "auth=%s", r.Header.Get("Authorization"))
+			}
+		}))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		path   string
+		policy RedirectPolicy
+		status int
+		body   string
+		redirs int
+		result Status
+	}{
+		{"/other", RedirectPolicy{}, 302, "", 0, Pass},
+		{"/other", RedirectPolicy{Follow: true}, 200, "auth=", 1, Pass},
+		{"/other", RedirectPolicy{Follow: true, KeepAuthHeaderAcrossHosts: true},
+			200, "auth=Bearer token", 1, Pass},
+		{"/other", RedirectPolicy{Follow: true, SameHostOnly: true}, 302, "", 0, Pass},
+		{"/same", RedirectPolicy{Follow: true, SameHostOnly: true}, 302, "", 1, Pass},
+		{"/same", RedirectPolicy{Follow: true}, 200, "auth=", 2, Pass},
+		{"/same", RedirectPolicy{Follow: true, Max: 1}, 0, "", 0, Error},
+	} {
+		test := &Test{
+			Request: Request{
+				URL:             ts.URL + tc.path,
+				Header:          http.Header{"Authorization": {"Bearer token"}},
+				FollowRedirects: tc.policy,
+			},
+		}
+		test.Run()
+		if test.Status != tc.result {
+			t.Errorf("%d. Got status %s, want %s: %v", i, test.Status, tc.result, test.Error)
+			continue
+		}
+		if tc.result != Pass {
+			continue
+		}
+		if got := test.Response.Response.StatusCode; got != tc.status {
+			t.Errorf("%d. Got HTTP status %d, want %d", i, got, tc.status)
+		}
+		if tc.status == 200 && test.Response.BodyStr != tc.body {
+			t.Errorf("%d. Got body %q, want %q", i, test.Response.BodyStr, tc.body)
+		}
+		if got := len(test.Response.Redirections); got != tc.redirs {
+			t.Errorf("%d. Got %d redirections, want %d", i, got, tc.redirs)
+		}
+	}
+}
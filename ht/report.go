@@ -39,19 +39,79 @@ func (s Status) MarshalText() ([]byte, error) {
 	return []byte(s.String()), nil
 }
 
+// ----------------------------------------------------------------------------
+// Criticality
+
+// Criticality is the business criticality of a Test. Suites may declare
+// a threshold below which failures of a test are reported as warnings
+// instead of failing the whole suite.
+type Criticality int
+
+// Possible criticalities of Tests.
+const (
+	CritDefault Criticality = iota // Not set, treated as CritError
+	CritIgnore                     // Failures may be ignored
+	CritInfo                       // Failures are noteworthy
+	CritWarn                       // Failures are warnings
+	CritError                      // Failures are errors
+	CritFatal                      // Failures are fatal
+)
+
+var criticalityNames = []string{"Default", "Ignore", "Info", "Warn", "Error", "Fatal"}
+
+func (c Criticality) String() string {
+	if c < 0 || c > CritFatal {
+		return fmt.Sprintf("Criticality(%d)", int(c))
+	}
+	return criticalityNames[c]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Criticality) MarshalText() ([]byte, error) {
+	if c < 0 || c > CritFatal {
+		return []byte(""), fmt.Errorf("no such criticality %d", c)
+	}
+	return []byte(c.String()), nil
+}
+
+// Populate implements populate.Populator: Criticalities are given by
+// their (case insensitive) name, e.g. "Warn".
+func (c *Criticality) Populate(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("criticality must be a string, got %T", src)
+	}
+	for i, name := range criticalityNames {
+		if strings.EqualFold(s, name) {
+			*c = Criticality(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("no such criticality %q", s)
+}
+
+// Effective returns c with the default resolved to CritError.
+func (c Criticality) Effective() Criticality {
+	if c == CritDefault {
+		return CritError
+	}
+	return c
+}
+
 // ----------------------------------------------------------------------------
 // Templates to output
 
 var DefaultCheckTemplate = `{{define "CHECK"}}{{printf "%-7s %-15s %s" .Status .Name .JSON}}` +
 	`{{if eq .Status 3 5}}{{range .Error}}
-                {{.Error}}{{end}}{{end}}{{end}}`
+                {{.Error}}{{end}}{{end}}{{range .Warning}}
+                Warning: {{.Error}}{{end}}{{end}}`
 
 var DefaultTestTemplate = `{{define "TEST"}}{{ToUpper .Status.String}}: {{.Name}}{{if gt .Tries 1}}
   {{printf "(after %d tries)" .Tries}}{{end}}
   Started: {{.Started}}   Duration: {{.FullDuration}}   Request: {{.Duration}}{{if .Request.Request}}
   {{.Request.Request.Method}} {{.Request.Request.URL.String}}{{range .Response.Redirections}}
   GET {{.}}{{end}}{{end}}{{if .Response.Response}}
-  {{.Response.Response.Proto}} {{.Response.Response.Status}}{{end}}{{if .Error}}
+  {{.Response.Response.Proto}} {{.Response.Response.Status}}{{if .Response.ConnReused}} (reused connection){{end}}{{end}}{{if .Error}}
   Error: {{.Error}}{{end}}
 {{if eq .Status 2 3 4 5}}  {{if .CheckResults}}Checks:
 {{range $i, $c := .CheckResults}}{{printf "    %2d. " $i}}{{template "CHECK" .}}
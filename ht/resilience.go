@@ -117,9 +117,12 @@ type Resilience struct {
 	Values []string
 }
 
+// Cost implements Coster: Resilience makes lots of requests.
+func (Resilience) Cost() int { return CostExpensive }
+
 // Execute implements Check's Execute method.
 func (r Resilience) Execute(t *Test) error {
-	suite := &Collection{}
+	suite := &Collection{ctx: t.context()}
 
 	for _, method := range r.methods(t) {
 		// Just an other method.
@@ -489,18 +492,19 @@ func (r Resilience) resilienceTest(orig *Test, method string, paramsAs string) *
 	cpy := &Test{
 		Name: fmt.Sprintf("%s %s", method, paramsAs),
 		Request: Request{
-			Method:          method,
-			URL:             orig.Request.Request.URL.String(),
-			FollowRedirects: false,
-			ParamsAs:        paramsAs,
-			BasicAuthUser:   orig.Request.BasicAuthUser,
-			BasicAuthPass:   orig.Request.BasicAuthPass,
+			Method:        method,
+			URL:           orig.Request.Request.URL.String(),
+			ParamsAs:      paramsAs,
+			BasicAuthUser: orig.Request.BasicAuthUser,
+			BasicAuthPass: orig.Request.BasicAuthPass,
 		},
 		Execution: Execution{
 			Verbosity: orig.Execution.Verbosity - 1,
 			PreSleep:  10 * time.Millisecond,
 		},
+		Log: orig.Log,
 	}
+	orig.inheritTransport(cpy)
 
 	cpy.Request.Header = make(http.Header)
 	for h, v := range orig.Request.Header {
@@ -0,0 +1,160 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// robots.go contains a minimal robots.txt parser used by the Links check.
+
+package ht
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsRule is a single Allow or Disallow line of a robots.txt.
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// robotsRules are the rules of a robots.txt applicable to ht.
+type robotsRules []robotsRule
+
+// allowed reports whether path may be accessed. The longest matching
+// rule wins; paths not matched by any rule are allowed.
+func (rr robotsRules) allowed(path string) bool {
+	allow, length := true, -1
+	for _, r := range rr {
+		if strings.HasPrefix(path, r.path) && len(r.path) > length {
+			allow, length = r.allow, len(r.path)
+		}
+	}
+	return allow
+}
+
+// parseRobots parses a robots.txt and returns the rules for the given
+// user agent or, if there is no group for it, the rules for "*".
+func parseRobots(r io.Reader, userAgent string) robotsRules {
+	userAgent = strings.ToLower(userAgent)
+	var specific, generic robotsRules
+	foundSpecific := false
+	agents := []string{}
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = agents[:0]
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // empty Disallow allows everything
+			}
+			rule := robotsRule{allow: key == "allow", path: value}
+			for _, a := range agents {
+				if a == "*" {
+					generic = append(generic, rule)
+				} else if strings.Contains(userAgent, a) {
+					specific = append(specific, rule)
+					foundSpecific = true
+				}
+			}
+		}
+	}
+	if foundSpecific {
+		return specific
+	}
+	return generic
+}
+
+// robotsEntry is the cached robots.txt of one origin. Its mutex is held
+// while fetching so that a robots.txt is fetched only once per robotsTTL.
+type robotsEntry struct {
+	sync.Mutex
+	expires time.Time // zero if not fetched yet
+	rules   robotsRules
+}
+
+// robotsTTL is the time a fetched robots.txt is cached. RFC 9309 asks
+// crawlers not to use a cached robots.txt for more than 24 hours which
+// matters for long running monitors.
+const robotsTTL = 24 * time.Hour
+
+var (
+	robotsMux   sync.Mutex // protects robotsCache only
+	robotsCache = make(map[string]*robotsEntry)
+)
+
+// robotsFor returns the robots.txt rules for the scheme and host of u.
+// The rules are fetched through the transport of t and cached per host
+// for robotsTTL; a missing or unreadable robots.txt allows everything.
+// The fetch is subject to the rate limit of t. Nothing is cached if t is
+// canceled.
+func robotsFor(t *Test, u *url.URL, timeout time.Duration) robotsRules {
+	origin := u.Scheme + "://" + u.Host
+	robotsMux.Lock()
+	entry, ok := robotsCache[origin]
+	if !ok {
+		entry = &robotsEntry{}
+		robotsCache[origin] = entry
+	}
+	robotsMux.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+	if time.Now().Before(entry.expires) {
+		return entry.rules
+	}
+	rules, err := fetchRobots(t, origin, timeout)
+	if err != nil && t.context().Err() != nil {
+		return nil
+	}
+	entry.rules, entry.expires = rules, time.Now().Add(robotsTTL)
+	return rules
+}
+
+// fetchRobots fetches and parses the robots.txt of origin. A robots.txt
+// which is not served with status 200 yields no rules.
+func fetchRobots(t *Test, origin string, timeout time.Duration) (robotsRules, error) {
+	client, err := t.auxClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	if err := t.RateLimiter.Wait(t.context()); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(t.context()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	return parseRobots(resp.Body, DefaultUserAgent), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var robotsTxt = `# Sample robots.txt
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow:   # empty
+
+User-agent: go-http-tester
+User-agent: other
+Disallow: /tester
+`
+
+func TestParseRobots(t *testing.T) {
+	for i, tc := range []struct {
+		agent, path string
+		want        bool
+	}{
+		{"Mozilla/5.0", "/index.html", true},
+		{"Mozilla/5.0", "/private/secret", false},
+		{"Mozilla/5.0", "/private/public/page", true},
+		{"Mozilla/5.0", "/tester", true},
+		{"go-http-tester/1.0", "/private/secret", true},
+		{"go-http-tester/1.0", "/tester/x", false},
+		{"Googlebot/2.1", "/index.html", false},
+	} {
+		rules := parseRobots(strings.NewReader(robotsTxt), tc.agent)
+		if got := rules.allowed(tc.path); got != tc.want {
+			t.Errorf("%d: %s %s got %t, want %t", i, tc.agent, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRobotsFor(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	release := make(chan bool)
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		fetches[req.URL.Host]++
+		mu.Unlock()
+		if req.URL.Host == "slow.robots.test" {
+			<-release
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("User-agent: *\nDisallow: /private\n")),
+			Request:    req,
+		}, nil
+	})
+	test := &Test{Transport: transport}
+	fast, _ := url.Parse("http://fast.robots.test/page")
+	slow, _ := url.Parse("http://slow.robots.test/page")
+	canceled, _ := url.Parse("http://canceled.robots.test/page")
+
+	// A slow host does not block other hosts.
+	done := make(chan robotsRules)
+	go func() { done <- robotsFor(test, slow, time.Second) }()
+	for i := 0; i < 2; i++ {
+		if robotsFor(test, fast, time.Second).allowed("/private/x") {
+			t.Errorf("%d. /private allowed", i)
+		}
+	}
+	close(release)
+	if (<-done).allowed("/private/x") {
+		t.Errorf("/private allowed on slow host")
+	}
+	if fetches["fast.robots.test"] != 1 || fetches["slow.robots.test"] != 1 {
+		t.Errorf("Got fetches %v", fetches)
+	}
+
+	// Nothing is cached for a canceled test.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.ctx = ctx
+	if !robotsFor(test, canceled, time.Second).allowed("/private/x") {
+		t.Errorf("/private forbidden for canceled test")
+	}
+	test.ctx = nil
+	if robotsFor(test, canceled, time.Second).allowed("/private/x") {
+		t.Errorf("/private allowed after canceled test")
+	}
+	if fetches["canceled.robots.test"] != 1 {
+		t.Errorf("Got fetches %v", fetches)
+	}
+
+	// Expired entries are fetched again.
+	robotsMux.Lock()
+	robotsCache["http://fast.robots.test"].expires = time.Now().Add(-time.Second)
+	robotsMux.Unlock()
+	robotsFor(test, fast, time.Second)
+	robotsFor(test, fast, time.Second)
+	if fetches["fast.robots.test"] != 2 {
+		t.Errorf("Got fetches %v", fetches)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func init() {
+	RegisterCheck(&SEO{})
+}
+
+// ----------------------------------------------------------------------------
+// SEO
+
+var (
+	seoTitleSel       = cascadia.MustCompile("head title")
+	seoDescriptionSel = cascadia.MustCompile(`meta[name="description"]`)
+	seoCanonicalSel   = cascadia.MustCompile(`link[rel="canonical"]`)
+	seoH1Sel          = cascadia.MustCompile("h1")
+)
+
+// SEO checks a HTML document for the basic on-page requirements of search
+// engine optimization:
+//   - exactly one non-empty title, optionally of bounded length
+//   - a meta description, optionally fulfilling a condition
+//   - a canonical link pointing to the document itself
+//   - exactly one h1 heading
+// All violations are reported. Example:
+//     {
+//         Check: "SEO"
+//         TitleMax: 60
+//         Description: { Min: 50, Max: 160 }
+//     }
+type SEO struct {
+	// TitleMin and TitleMax limit the length of the title in characters.
+	// A zero value means no limit.
+	TitleMin int `json:",omitempty"`
+	TitleMax int `json:",omitempty"`
+
+	// Description is applied to the content of the meta description.
+	Description Condition `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector.
+func (*SEO) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (s *SEO) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	doc, err := html.Parse(t.Response.Body())
+	if err != nil {
+		return CantCheck{err}
+	}
+
+	errs := ErrorList{}
+
+	// Title
+	titles := seoTitleSel.MatchAll(doc)
+	if len(titles) != 1 {
+		errs = append(errs, fmt.Errorf("found %d titles, want 1", len(titles)))
+	} else {
+		title := TextContent(titles[0], false)
+		n := utf8.RuneCountInString(title)
+		switch {
+		case n == 0:
+			errs = append(errs, errors.New("empty title"))
+		case s.TitleMin > 0 && n < s.TitleMin:
+			errs = append(errs, fmt.Errorf("title has %d characters, want at least %d", n, s.TitleMin))
+		case s.TitleMax > 0 && n > s.TitleMax:
+			errs = append(errs, fmt.Errorf("title has %d characters, want at most %d", n, s.TitleMax))
+		}
+	}
+
+	// Meta description
+	if desc := seoDescriptionSel.MatchFirst(doc); desc == nil {
+		errs = append(errs, errors.New("missing meta description"))
+	} else if err := s.Description.Fulfilled(attrValue(desc, "content")); err != nil {
+		errs = append(errs, fmt.Errorf("meta description: %s", err))
+	}
+
+	// Canonical link
+	var page *url.URL
+	if resp := t.Response.Response; resp != nil && resp.Request != nil {
+		page = resp.Request.URL // Final URL after redirects.
+	}
+	if err := checkCanonical(seoCanonicalSel.MatchAll(doc), page); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Headings
+	if h1s := seoH1Sel.MatchAll(doc); len(h1s) != 1 {
+		errs = append(errs, fmt.Errorf("found %d h1, want 1", len(h1s)))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (s *SEO) Prepare() error {
+	if s.TitleMin < 0 || s.TitleMax < 0 {
+		return MalformedCheck{errors.New("negative title length")}
+	}
+	if s.TitleMax > 0 && s.TitleMax < s.TitleMin {
+		return MalformedCheck{errors.New("TitleMax < TitleMin")}
+	}
+	return s.Description.Compile()
+}
+
+// checkCanonical checks that links is a single canonical link pointing
+// to page.
+func checkCanonical(links []*html.Node, page *url.URL) error {
+	if len(links) != 1 {
+		return fmt.Errorf("found %d canonical links, want 1", len(links))
+	}
+	href := attrValue(links[0], "href")
+	canonical, err := url.Parse(href)
+	if err != nil {
+		return fmt.Errorf("malformed canonical link %q: %s", href, err)
+	}
+	if page == nil {
+		return nil // Nothing to compare against.
+	}
+	canonical = page.ResolveReference(canonical)
+	if normalizedURL(canonical) != normalizedURL(page) {
+		return fmt.Errorf("canonical link %s does not point to %s", canonical, page)
+	}
+	return nil
+}
+
+// normalizedURL returns u without fragment and with lowercase scheme and
+// host and a non-empty path.
+func normalizedURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	n.Fragment = ""
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func seoResponse(head, body string) Response {
+	u, _ := url.Parse("http://www.example.org/shop/item?id=7")
+	return Response{
+		Response: &http.Response{
+			StatusCode: 200,
+			Request:    &http.Request{URL: u},
+		},
+		BodyStr: "<!doctype html><html><head>" + head + "</head><body>" +
+			body + "</body></html>",
+	}
+}
+
+var (
+	seoHead = `<title>Red Shoes</title>
+<meta name="description" content="Buy red shoes online.">
+<link rel="canonical" href="/shop/item?id=7">`
+	seoBody = `<h1>Red Shoes</h1><p>Nice.</p>`
+)
+
+var seoTests = []TC{
+	{seoResponse(seoHead, seoBody), &SEO{}, nil},
+	{seoResponse(seoHead, seoBody), &SEO{TitleMin: 5, TitleMax: 20}, nil},
+	{seoResponse(seoHead, seoBody), &SEO{TitleMax: 5}, someError},
+	{seoResponse(seoHead, seoBody), &SEO{TitleMin: 12}, someError},
+	{seoResponse(seoHead, seoBody), &SEO{Description: Condition{Contains: "shoes"}}, nil},
+	{seoResponse(seoHead, seoBody), &SEO{Description: Condition{Min: 50}}, someError},
+	{seoResponse(seoHead, seoBody+"<h1>More</h1>"), &SEO{}, someError},
+	{seoResponse(seoHead, "<h2>No h1</h2>"), &SEO{}, someError},
+	{seoResponse(`<title>T</title><meta name="description" content="D">
+<link rel="canonical" href="HTTP://WWW.example.org/shop/item?id=7#top">`, seoBody), &SEO{}, nil},
+	{seoResponse(`<title>T</title><meta name="description" content="D">
+<link rel="canonical" href="http://www.example.org/shop/">`, seoBody), &SEO{}, someError},
+	{seoResponse(`<title>T</title><meta name="description" content="D">`, seoBody), &SEO{}, someError},
+	{seoResponse(`<title></title><meta name="description" content="D">
+<link rel="canonical" href="/shop/item?id=7">`, seoBody), &SEO{}, someError},
+	{seoResponse(`<title>T</title><link rel="canonical" href="/shop/item?id=7">`, seoBody), &SEO{}, someError},
+	{seoResponse(seoHead, seoBody), &SEO{TitleMin: 10, TitleMax: 5}, prepareError},
+	{seoResponse(seoHead, seoBody), &SEO{Description: Condition{Regexp: "["}}, prepareError},
+}
+
+func TestSEO(t *testing.T) {
+	for i, tc := range seoTests {
+		runTest(t, i, tc)
+	}
+}
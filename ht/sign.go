@@ -0,0 +1,384 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sign.go contains the signing of requests.
+
+package ht
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signing describes how a request is signed with a HMAC, e.g.
+//     Sign: {
+//         Algorithm: "HMAC-SHA256"
+//         SecretVar: "API_SECRET"
+//         Header:    "X-Signature"
+//         Template:  "{method}\n{path}\n{timestamp}\n{body}"
+//     }
+// The signature is computed directly before the request is sent (i.e.
+// after variable substitution and preparation of the body) over the
+// canonical form of the request produced by Template. The following
+// placeholders are replaced in Template:
+//     {method}        the request method, e.g. "POST"
+//     {path}          the escaped path, e.g. "/api/items"
+//     {query}         the raw query string
+//     {host}          the host (and port) of the request URL
+//     {body}          the request body as sent (i.e. after compression)
+//     {timestamp}     the current Unix time in seconds
+//     {header:Name}   the value of the request header Name
+// If {timestamp} is used the timestamp is sent in TimestampHeader too.
+type Signing struct {
+	// Algorithm is one of "HMAC-SHA1", "HMAC-SHA256" (the default)
+	// and "HMAC-SHA512".
+	Algorithm string `json:",omitempty"`
+
+	// SecretVar is the name of the variable which contains the secret.
+	// Using a variable keeps the secret out of the test files.
+	SecretVar string
+
+	// Header is the header the signature is sent in. It defaults
+	// to "X-Signature".
+	Header string `json:",omitempty"`
+
+	// Prefix is prepended to the signature in Header, e.g. "sha256=".
+	Prefix string `json:",omitempty"`
+
+	// Encoding of the signature: "hex" (the default) or "base64".
+	Encoding string `json:",omitempty"`
+
+	// Template is the canonicalization template. It defaults to
+	// "{method}\n{path}\n{timestamp}\n{body}".
+	Template string `json:",omitempty"`
+
+	// TimestampHeader is the header the timestamp is sent in. It
+	// defaults to "X-Timestamp".
+	TimestampHeader string `json:",omitempty"`
+}
+
+// signNow is the source of the timestamp used in signatures.
+var signNow = time.Now
+
+// newHash returns the hash constructor for the Algorithm of s.
+func (s *Signing) newHash() (func() hash.Hash, error) {
+	switch strings.ToUpper(s.Algorithm) {
+	case "HMAC-SHA1":
+		return sha1.New, nil
+	case "", "HMAC-SHA256":
+		return sha256.New, nil
+	case "HMAC-SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown signing algorithm %q", s.Algorithm)
+}
+
+// validate the configuration of s.
+func (s *Signing) validate() error {
+	if s.SecretVar == "" {
+		return errors.New("missing SecretVar")
+	}
+	if _, err := s.newHash(); err != nil {
+		return err
+	}
+	switch s.Encoding {
+	case "", "hex", "base64":
+	default:
+		return fmt.Errorf("unknown signature encoding %q", s.Encoding)
+	}
+	return nil
+}
+
+// sign computes the signature of the prepared request of t and adds it
+// (and the timestamp if used) to the request header.
+func (s *Signing) sign(t *Test) error {
+	newHash, err := s.newHash()
+	if err != nil {
+		return err
+	}
+	secret, ok := t.Variables[s.SecretVar]
+	if !ok {
+		return fmt.Errorf("signing: no variable %s", s.SecretVar)
+	}
+	body, err := t.Request.sentBytes()
+	if err != nil {
+		return err
+	}
+
+	req := t.Request.Request
+	tmpl := s.Template
+	if tmpl == "" {
+		tmpl = "{method}\n{path}\n{timestamp}\n{body}"
+	}
+	if strings.Contains(tmpl, "{timestamp}") {
+		timestamp := strconv.FormatInt(signNow().Unix(), 10)
+		tsHeader := s.TimestampHeader
+		if tsHeader == "" {
+			tsHeader = "X-Timestamp"
+		}
+		req.Header.Set(tsHeader, timestamp)
+		tmpl = strings.Replace(tmpl, "{timestamp}", timestamp, -1)
+	}
+	canonical := strings.NewReplacer(
+		"{method}", req.Method,
+		"{path}", req.URL.EscapedPath(),
+		"{query}", req.URL.RawQuery,
+		"{host}", req.URL.Host,
+		"{body}", string(body),
+	).Replace(expandHeaderPlaceholders(tmpl, req.Header.Get))
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(canonical))
+	sum := mac.Sum(nil)
+	signature := hex.EncodeToString(sum)
+	if s.Encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+	header := s.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	req.Header.Set(header, s.Prefix+signature)
+	t.debugf("signed canonical request %q", canonical)
+	return nil
+}
+
+// expandHeaderPlaceholders replaces the {header:Name} placeholders in tmpl.
+func expandHeaderPlaceholders(tmpl string, get func(string) string) string {
+	const start = "{header:"
+	out := ""
+	for {
+		i := strings.Index(tmpl, start)
+		if i == -1 {
+			return out + tmpl
+		}
+		j := strings.Index(tmpl[i:], "}")
+		if j == -1 {
+			return out + tmpl
+		}
+		out += tmpl[:i] + get(tmpl[i+len(start):i+j])
+		tmpl = tmpl[i+j+1:]
+	}
+}
+
+// sentBytes returns the body of the request as it is sent.
+func (r *Request) sentBytes() ([]byte, error) {
+	if r.openBody == nil {
+		return []byte(r.SentBody), nil
+	}
+	body, err := r.openBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// AWSSigV4 describes signing a request with AWS Signature Version 4, e.g.
+//     AWSSigV4: {
+//         Region:       "eu-central-1"
+//         Service:      "execute-api"
+//         AccessKeyVar: "AWS_ACCESS_KEY_ID"
+//         SecretKeyVar: "AWS_SECRET_ACCESS_KEY"
+//     }
+// The signature covers the method, the path, the query, the Host,
+// Content-Type and all X-Amz-* headers and the SHA256 hash of the payload
+// as sent. The credentials are taken from variables to keep them out of
+// the test files.
+type AWSSigV4 struct {
+	// Region and Service form the credential scope together with the
+	// date, e.g. "us-east-1" and "s3".
+	Region  string
+	Service string
+
+	// AccessKeyVar and SecretKeyVar are the names of the variables
+	// which contain the access key ID and the secret access key.
+	AccessKeyVar string
+	SecretKeyVar string
+
+	// SessionTokenVar is the name of the variable which contains the
+	// session token of temporary credentials. The token is sent in the
+	// X-Amz-Security-Token header.
+	SessionTokenVar string `json:",omitempty"`
+
+	// UnsignedPayload excludes the payload from the signature
+	// by using "UNSIGNED-PAYLOAD" as payload hash (S3 only).
+	UnsignedPayload bool `json:",omitempty"`
+}
+
+// validate the configuration of a and the presence of the variables.
+func (a *AWSSigV4) validate(variables map[string]string) error {
+	if a.Region == "" || a.Service == "" {
+		return errors.New("missing Region or Service")
+	}
+	if a.AccessKeyVar == "" || a.SecretKeyVar == "" {
+		return errors.New("missing AccessKeyVar or SecretKeyVar")
+	}
+	for _, name := range []string{a.AccessKeyVar, a.SecretKeyVar, a.SessionTokenVar} {
+		if _, ok := variables[name]; name != "" && !ok {
+			return fmt.Errorf("no variable %s", name)
+		}
+	}
+	return nil
+}
+
+// sign computes the SigV4 signature of the prepared request of t and sets
+// the X-Amz-Date and Authorization headers.
+func (a *AWSSigV4) sign(t *Test) error {
+	if err := a.validate(t.Variables); err != nil {
+		return err
+	}
+	body, err := t.Request.sentBytes()
+	if err != nil {
+		return err
+	}
+
+	req := t.Request.Request
+	now := signNow().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + a.Region + "/" + a.Service + "/aws4_request"
+
+	payloadHash := sha256Hex(body)
+	if a.UnsignedPayload {
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if a.SessionTokenVar != "" {
+		req.Header.Set("X-Amz-Security-Token", t.Variables[a.SessionTokenVar])
+	}
+
+	// Canonical headers: Host, Content-Type and all X-Amz-* headers.
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req.URL.Path, a.Service),
+		awsCanonicalQuery(req.URL.RawQuery),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + t.Variables[a.SecretKeyVar])
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.Variables[a.AccessKeyVar], scope, signedHeaders, signature))
+	t.debugf("signed canonical request %q", canonical)
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsCanonicalPath returns the URI-encoded path. All services but S3
+// require the path segments to be encoded twice.
+func awsCanonicalPath(path string, service string) string {
+	if path == "" {
+		return "/"
+	}
+	path = awsEscape(path, false)
+	if service != "s3" {
+		path = awsEscape(path, false)
+	}
+	return path
+}
+
+// awsCanonicalQuery returns the query with names and values URI-encoded
+// and sorted by encoded name and then by encoded value. Sorting the joined
+// "name=value" pairs would be wrong for names which are a prefix of other
+// names: "a=2" must precede "a-b=1".
+func awsCanonicalQuery(rawQuery string) string {
+	values, _ := url.ParseQuery(rawQuery)
+	type param struct{ name, value string }
+	params := []param{}
+	for name, vals := range values {
+		for _, v := range vals {
+			params = append(params, param{awsEscape(name, true), awsEscape(v, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.name + "=" + p.value
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape URI-encodes s as required by SigV4: Every byte but the
+// unreserved characters A-Z, a-z, 0-9, '-', '.', '_' and '~' is encoded
+// as %XY. Slashes are encoded only if encodeSlash is set.
+func awsEscape(s string, encodeSlash bool) string {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			buf = append(buf, c)
+			continue
+		}
+		buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+	}
+	return string(buf)
+}
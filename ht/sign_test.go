@@ -0,0 +1,187 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	defer func(now func() time.Time) { signNow = now }(signNow)
+	signNow = func() time.Time { return time.Unix(1500000000, 0) }
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s|%s|%s|%s", r.Header.Get("X-Timestamp"),
+				r.Header.Get("X-Signature"), r.Header.Get("Authorization"), body)
+		}))
+	defer ts.Close()
+
+	mac := func(canonical string) []byte {
+		m := hmac.New(sha256.New, []byte("s3cr3t"))
+		m.Write([]byte(canonical))
+		return m.Sum(nil)
+	}
+
+	for i, tc := range []struct {
+		sign *Signing
+		want string
+	}{
+		{
+			&Signing{SecretVar: "SECRET"},
+			"1500000000|" + hex.EncodeToString(mac("POST\n/api/items\n1500000000\n{\"id\":1}")) +
+				"||{\"id\":1}",
+		},
+		{
+			&Signing{
+				SecretVar: "SECRET",
+				Header:    "Authorization",
+				Prefix:    "HMAC ",
+				Encoding:  "base64",
+				Template:  "{method} {path}?{query} {header:Content-Type}",
+			},
+			"||HMAC " + base64.StdEncoding.EncodeToString(mac("POST /api/items?v=2 application/json")) +
+				"|{\"id\":1}",
+		},
+	} {
+		test := &Test{
+			Request: Request{
+				Method: "POST",
+				URL:    ts.URL + "/api/items?v=2",
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   `{"id":1}`,
+				Sign:   tc.sign,
+			},
+			Checks:    CheckList{&Body{Equals: tc.want}},
+			Variables: map[string]string{"SECRET": "s3cr3t"},
+		}
+		test.Run()
+		if test.Status != Pass {
+			t.Errorf("%d. Got %s: %v", i, test.Status, test.Error)
+		}
+	}
+
+	for i, sign := range []*Signing{
+		{SecretVar: "MISSING"},
+		{},
+		{SecretVar: "SECRET", Algorithm: "HMAC-MD4"},
+		{SecretVar: "SECRET", Encoding: "base32"},
+	} {
+		test := &Test{
+			Request:   Request{URL: ts.URL, Sign: sign},
+			Variables: map[string]string{"SECRET": "s3cr3t"},
+		}
+		test.Run()
+		if test.Status != Bogus {
+			t.Errorf("%d. Got %s, want Bogus: %v", i, test.Status, test.Error)
+		}
+	}
+}
+
+func TestAWSSigV4(t *testing.T) {
+	defer func(now func() time.Time) { signNow = now }(signNow)
+	signNow = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+	// Test vectors from the AWS Signature Version 4 test suite; the last
+	// one covers query names which are a prefix of other names.
+	form := "application/x-www-form-urlencoded"
+	for i, tc := range []struct {
+		method, url, body, contentType string
+		want                           string
+	}{
+		{"GET", "https://example.amazonaws.com/", "", "",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"POST", "https://example.amazonaws.com/", "Param1=value1", form,
+			"ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+		{"POST", "https://example.amazonaws.com/?a-b=1&a=2&a=1", "Param1=value1", form,
+			"6b458d0cf2f59ed013b0094fd9aeb8d92cd25152b9acf18ddfd868e7ad4d9ebe"},
+	} {
+		test := &Test{
+			Request: Request{
+				AWSSigV4: &AWSSigV4{
+					Region:       "us-east-1",
+					Service:      "service",
+					AccessKeyVar: "AK",
+					SecretKeyVar: "SK",
+				},
+			},
+			Variables: map[string]string{
+				"AK": "AKIDEXAMPLE",
+				"SK": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			},
+		}
+		test.Request.Request, _ = http.NewRequest(tc.method, tc.url, nil)
+		test.Request.SentBody = tc.body
+		signed := "host;x-amz-date"
+		if tc.contentType != "" {
+			test.Request.Request.Header.Set("Content-Type", tc.contentType)
+			signed = "content-type;" + signed
+		}
+		if err := test.Request.AWSSigV4.sign(test); err != nil {
+			t.Errorf("%d. Unexpected error %s", i, err)
+			continue
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=" + signed + ", Signature=" + tc.want
+		if got := test.Request.Request.Header.Get("Authorization"); got != want {
+			t.Errorf("%d. Got Authorization\n  %s\nwant\n  %s", i, got, want)
+		}
+	}
+
+	test := &Test{
+		Request: Request{
+			URL:      "http://example.amazonaws.com/",
+			AWSSigV4: &AWSSigV4{Region: "us-east-1", Service: "s3", AccessKeyVar: "AK", SecretKeyVar: "SK"},
+		},
+		Variables: map[string]string{"AK": "AKIDEXAMPLE"},
+	}
+	test.Run()
+	if test.Status != Bogus {
+		t.Errorf("Got %s, want Bogus for missing secret key: %v", test.Status, test.Error)
+	}
+}
+
+func TestAWSCanonicalQuery(t *testing.T) {
+	for i, tc := range []struct {
+		query, want string
+	}{
+		{"", ""},
+		{"b=2&a=1", "a=1&b=2"},
+		{"a-b=1&a=2&a=1", "a=1&a=2&a-b=1"},
+		{"x=%20&x=a+b&x=%7E", "x=%20&x=a%20b&x=~"},
+	} {
+		if got := awsCanonicalQuery(tc.query); got != tc.want {
+			t.Errorf("%d. awsCanonicalQuery(%q)=%q, want %q", i, tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestAWSCanonicalPath(t *testing.T) {
+	for i, tc := range []struct {
+		path, service, want string
+	}{
+		{"", "s3", "/"},
+		{"/bucket/my file.txt", "s3", "/bucket/my%20file.txt"},
+		{"/bucket/my file.txt", "execute-api", "/bucket/my%2520file.txt"},
+		{"/a-b_c.d~e", "execute-api", "/a-b_c.d~e"},
+	} {
+		if got := awsCanonicalPath(tc.path, tc.service); got != tc.want {
+			t.Errorf("%d. awsCanonicalPath(%q, %q)=%q, want %q",
+				i, tc.path, tc.service, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sse.go contains checks against Server-Sent Events streams.
+
+package ht
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCheck(&SSE{})
+}
+
+// ----------------------------------------------------------------------------
+// SSE
+
+// SSE checks a stream of Server-Sent Events (Content-Type
+// text/event-stream). As such a stream never ends the response body is
+// read only for the given Duration; the events received up to then are
+// checked. Duration must be shorter than the timeout of the request.
+// Example:
+//     {
+//         Check: "SSE"
+//         Duration: "5s"
+//         MinEvents: 2
+//         Each: { Prefix: "{" }
+//     }
+type SSE struct {
+	// Duration is the time the event stream is read.
+	Duration time.Duration
+
+	// MinEvents and MaxEvents are the minimum and maximum number of
+	// events which must be received during Duration. A zero MaxEvents
+	// means no upper limit.
+	MinEvents int `json:",omitempty"`
+	MaxEvents int `json:",omitempty"`
+
+	// Each is applied to the data of each event received.
+	Each Condition `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector.
+func (*SSE) InspectsBody() bool { return true }
+
+// Execute implements Check's Execute method.
+func (s *SSE) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	events := parseSSE(t.Response.BodyStr)
+	n := len(events)
+
+	errs := ErrorList{}
+	if n < s.MinEvents {
+		errs = append(errs, fmt.Errorf("got %d events in %s, want at least %d",
+			n, s.Duration, s.MinEvents))
+	}
+	if s.MaxEvents > 0 && n > s.MaxEvents {
+		errs = append(errs, fmt.Errorf("got %d events in %s, want at most %d",
+			n, s.Duration, s.MaxEvents))
+	}
+	for i, data := range events {
+		if err := s.Each.Fulfilled(data); err != nil {
+			errs = append(errs, fmt.Errorf("event %d: %s", i+1, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (s *SSE) Prepare() error {
+	if s.Duration <= 0 {
+		return MalformedCheck{errors.New("Duration must be positive")}
+	}
+	if s.MinEvents < 0 || s.MaxEvents < 0 {
+		return MalformedCheck{errors.New("negative number of events")}
+	}
+	if s.MaxEvents > 0 && s.MaxEvents < s.MinEvents {
+		return MalformedCheck{errors.New("MaxEvents < MinEvents")}
+	}
+	return s.Each.Compile()
+}
+
+// parseSSE returns the data of the complete events in the event stream
+// body. Events without data and a trailing incomplete event (as
+// produced when reading is stopped) are dropped.
+func parseSSE(body string) []string {
+	events := []string{}
+	data := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if len(data) > 0 {
+				events = append(events, strings.Join(data, "\n"))
+			}
+			data = data[:0]
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		if field == "data" {
+			data = append(data, value)
+		}
+	}
+	return events
+}
+
+// streamDuration returns the duration the response body of t should be
+// read before the stream is cut off or zero if the body should be read
+// completely.
+func (t *Test) streamDuration() time.Duration {
+	return streamDuration(t.Checks)
+}
+
+// streamDuration returns the longest Duration of the SSE checks in cl
+// including those nested in the combining checks.
+func streamDuration(cl CheckList) time.Duration {
+	var d time.Duration
+	for _, ck := range cl {
+		var sd time.Duration
+		switch c := ck.(type) {
+		case *SSE:
+			sd = c.Duration
+		case AnyOne:
+			sd = streamDuration(c.Of)
+		case *AnyOne:
+			sd = streamDuration(c.Of)
+		case None:
+			sd = streamDuration(c.Of)
+		case *None:
+			sd = streamDuration(c.Of)
+		case *When:
+			sd = streamDuration(c.Then)
+		case *IfThenElse:
+			sd = streamDuration(append(append(CheckList{c.If}, c.Then...), c.Else...))
+		case *Warn:
+			sd = streamDuration(c.Of)
+		}
+		if sd > d {
+			d = sd
+		}
+	}
+	return d
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSSE(t *testing.T) {
+	body := ": comment\n\ndata: one\n\nevent: foo\ndata: two\ndata:three\n\ndata: incomplete"
+	got := parseSSE(body)
+	want := []string{"one", "two\nthree"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+var sseBody = "data: {\"n\":1}\n\ndata: {\"n\":2}\n\ndata: {\"n\":3}\n\n"
+
+var sseTests = []TC{
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, MinEvents: 3}, nil},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, MinEvents: 4}, someError},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, MaxEvents: 3}, nil},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, MaxEvents: 2}, someError},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, Each: Condition{Prefix: "{"}}, nil},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, Each: Condition{Contains: "2"}}, someError},
+	{Response{BodyStr: sseBody}, &SSE{}, prepareError},
+	{Response{BodyStr: sseBody}, &SSE{Duration: 1, MinEvents: 3, MaxEvents: 2}, prepareError},
+}
+
+func TestSSE(t *testing.T) {
+	for i, tc := range sseTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestSSEStopsAtDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 1; ; i++ {
+				if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(20 * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}))
+	defer ts.Close()
+
+	sse := &SSE{Duration: 200 * time.Millisecond, MinEvents: 3, MaxEvents: 50}
+	for i, checks := range []CheckList{
+		{StatusCode{200}, sse},
+		{&When{Then: CheckList{sse}}},
+		{&Warn{Of: CheckList{AnyOne{Of: CheckList{sse}}}}},
+	} {
+		test := Test{
+			Request: Request{URL: ts.URL + "/", Timeout: 3 * time.Second},
+			Checks:  checks,
+		}
+		start := time.Now()
+		test.Run()
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("%d. took %s", i, d)
+		}
+		if test.Status != Pass {
+			t.Errorf("%d. got status %s, want Pass: %s", i, test.Status, test.Error)
+		}
+	}
+}
@@ -25,6 +25,9 @@ type StatusCode struct {
 	Expect int
 }
 
+// InspectsBody implements BodyInspector: StatusCode ignores the body.
+func (StatusCode) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (c StatusCode) Execute(t *Test) error {
 	if c.Expect < 10 {
@@ -52,6 +55,9 @@ func (StatusCode) Prepare() error { return nil }
 // and that the body could be read without errors or timeouts.
 type NoServerError struct{}
 
+// InspectsBody implements BodyInspector: NoServerError ignores the body.
+func (NoServerError) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (NoServerError) Execute(t *Test) error {
 	if t.Response.Response == nil {
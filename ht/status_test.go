@@ -22,10 +22,9 @@ func TestStatusCode(t *testing.T) {
 		test := Test{
 			Name: "A very basic test.",
 			Request: Request{
-				Method:          "GET",
-				URL:             ts.URL + "/",
-				Params:          url.Values{"status": []string{s}},
-				FollowRedirects: false,
+				Method: "GET",
+				URL:    ts.URL + "/",
+				Params: url.Values{"status": []string{s}},
 			},
 			Checks: []Check{
 				StatusCode{Expect: code},
@@ -52,10 +51,9 @@ func TestNoServerError(t *testing.T) {
 		test := Test{
 			Name: "A very basic test.",
 			Request: Request{
-				Method:          "GET",
-				URL:             ts.URL + "/",
-				Params:          url.Values{"status": []string{s}},
-				FollowRedirects: false,
+				Method: "GET",
+				URL:    ts.URL + "/",
+				Params: url.Values{"status": []string{s}},
 			},
 			Checks: []Check{NoServerError{}},
 		}
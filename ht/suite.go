@@ -5,6 +5,7 @@
 package ht
 
 import (
+	"context"
 	"sync"
 
 	"github.com/vdobler/ht/cookiejar"
@@ -18,6 +19,12 @@ type Collection struct {
 	// Populated during execution
 	Status Status
 	Error  error
+
+	// sem, if non-nil, is acquired around each test execution.
+	sem chan struct{}
+
+	// ctx, if non-nil, is the context the tests are run with.
+	ctx context.Context
 }
 
 // ExecuteConcurrent executes tests concurrently.
@@ -29,6 +36,10 @@ func (s *Collection) ExecuteConcurrent(maxConcurrent int, jar *cookiejar.Jar) er
 		maxConcurrent = len(s.Tests)
 	}
 
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	c := make(chan *Test, maxConcurrent)
 	wg := sync.WaitGroup{}
 	wg.Add(maxConcurrent)
@@ -36,7 +47,13 @@ func (s *Collection) ExecuteConcurrent(maxConcurrent int, jar *cookiejar.Jar) er
 		go func() {
 			defer wg.Done()
 			for test := range c {
-				test.Run()
+				if s.sem != nil {
+					s.sem <- struct{}{}
+				}
+				test.RunContext(ctx)
+				if s.sem != nil {
+					<-s.sem
+				}
 			}
 		}()
 	}
@@ -2,17 +2,20 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// time.go contains checks against the response time
+// time.go contains checks against the response time and the server clock
 
 package ht
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 )
 
 func init() {
 	RegisterCheck(ResponseTime{})
+	RegisterCheck(&ClockSkew{})
 }
 
 // ----------------------------------------------------------------------------
@@ -24,6 +27,9 @@ type ResponseTime struct {
 	Higher time.Duration `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector: ResponseTime ignores the body.
+func (ResponseTime) InspectsBody() bool { return false }
+
 // Execute implements Check's Execute method.
 func (c ResponseTime) Execute(t *Test) error {
 	actual := t.Response.Duration
@@ -43,3 +49,61 @@ func (c ResponseTime) Execute(t *Test) error {
 
 // Prepare implements Check's Prepare method.
 func (ResponseTime) Prepare() error { return nil }
+
+// ----------------------------------------------------------------------------
+// ClockSkew
+
+// ClockSkew checks that the clock of the server, as reported in the Date
+// header of the response, deviates at most MaxSkew from the local clock.
+// Skewed server clocks break caching and the validation of signatures
+// and tokens. As Date has a resolution of one second and the server may
+// generate it anytime while the request is processed, a Date between the
+// time the request was sent and the time the response was received is
+// considered to be without skew.
+type ClockSkew struct {
+	// MaxSkew is the allowed deviation. Zero means one second.
+	MaxSkew time.Duration `json:",omitempty"`
+}
+
+// InspectsBody implements BodyInspector: ClockSkew ignores the body.
+func (*ClockSkew) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (c *ClockSkew) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	date := t.Response.Response.Header.Get("Date")
+	if date == "" {
+		return errors.New("no Date header")
+	}
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return fmt.Errorf("malformed Date header %q", date)
+	}
+	if t.Response.Sent.IsZero() {
+		return CantCheck{errors.New("unknown request time")}
+	}
+
+	earliest := t.Response.Sent.Truncate(time.Second)
+	latest := t.Response.Sent.Add(t.Response.Duration)
+	maxSkew := c.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = time.Second
+	}
+	if skew := earliest.Sub(server); skew > maxSkew {
+		return fmt.Errorf("server clock is %s behind (allowed max %s)", skew, maxSkew)
+	}
+	if skew := server.Sub(latest); skew > maxSkew {
+		return fmt.Errorf("server clock is %s ahead (allowed max %s)", skew, maxSkew)
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *ClockSkew) Prepare() error {
+	if c.MaxSkew < 0 {
+		return MalformedCheck{errors.New("negative MaxSkew")}
+	}
+	return nil
+}
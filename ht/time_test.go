@@ -5,7 +5,9 @@
 package ht
 
 import (
+	"net/http"
 	"testing"
+	"time"
 )
 
 var responseTimeTests = []TC{
@@ -25,3 +27,30 @@ func TestResponseTime(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+func TestClockSkew(t *testing.T) {
+	sent := time.Date(2016, 5, 10, 12, 0, 0, 400*ms, time.UTC)
+	response := func(date string) Response {
+		return Response{
+			Sent:     sent,
+			Duration: 1500 * ms,
+			Response: &http.Response{Header: http.Header{"Date": {date}}},
+		}
+	}
+	for i, tc := range []TC{
+		{response("Tue, 10 May 2016 12:00:00 GMT"), &ClockSkew{}, nil},
+		{response("Tue, 10 May 2016 12:00:01 GMT"), &ClockSkew{}, nil},
+		{response("Tue, 10 May 2016 12:00:02 GMT"), &ClockSkew{}, nil},
+		{response("Tue, 10 May 2016 12:00:04 GMT"), &ClockSkew{},
+			errorString("server clock is 2.1s ahead (allowed max 1s)")},
+		{response("Tue, 10 May 2016 12:00:04 GMT"), &ClockSkew{MaxSkew: 5 * time.Second}, nil},
+		{response("Tue, 10 May 2016 11:59:58 GMT"), &ClockSkew{},
+			errorString("server clock is 2s behind (allowed max 1s)")},
+		{response("Tue, 10 May 2016 11:55:00 GMT"), &ClockSkew{MaxSkew: 10 * time.Minute}, nil},
+		{response("yesterday"), &ClockSkew{}, someError},
+		{Response{Response: &http.Response{}}, &ClockSkew{}, someError},
+		{response("Tue, 10 May 2016 12:00:00 GMT"), &ClockSkew{MaxSkew: -1}, prepareError},
+	} {
+		runTest(t, i, tc)
+	}
+}
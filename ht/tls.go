@@ -0,0 +1,263 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCheck(&TLSCert{})
+	RegisterCheck(&TLSVersion{})
+}
+
+// ----------------------------------------------------------------------------
+// TLSCert
+
+// tlsVersions maps the accepted names of TLS versions to their value.
+var tlsVersions = map[string]uint16{
+	"TLS1.0": tls.VersionTLS10,
+	"TLS1.1": tls.VersionTLS11,
+	"TLS1.2": tls.VersionTLS12,
+	"TLS1.3": tls.VersionTLS13,
+}
+
+// tlsVersionName returns the name of the TLS version v.
+func tlsVersionName(v uint16) string {
+	for name, version := range tlsVersions {
+		if version == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// TLSCert checks the certificate presented by the server and the
+// negotiated TLS connection. It allows to monitor certificate expiry
+// as part of normal tests. Example:
+//     {
+//         Check: "TLSCert"
+//         MinDaysValid: 14
+//         Issuer: { Contains: "Let's Encrypt" }
+//         DNSNames: [ "example.org", "www.example.org" ]
+//         MinVersion: "TLS1.2"
+//     }
+// All problems are reported, not just the first one.
+type TLSCert struct {
+	// MinDaysValid is the number of days the certificate must at least
+	// stay valid.
+	MinDaysValid int `json:",omitempty"`
+
+	// Issuer is applied to the distinguished name of the issuer of the
+	// certificate, e.g. "CN=Some CA,O=Some Org,C=US".
+	Issuer Condition `json:",omitempty"`
+
+	// SubjectCN is applied to the common name of the subject of the
+	// certificate.
+	SubjectCN Condition `json:",omitempty"`
+
+	// DNSNames must all be contained in the DNS names (the subject
+	// alternative names) of the certificate.
+	DNSNames []string `json:",omitempty"`
+
+	// MinVersion is the minimum TLS version which must have been
+	// negotiated. One of "TLS1.0", "TLS1.1", "TLS1.2" or "TLS1.3".
+	MinVersion string `json:",omitempty"`
+
+	minVersion uint16
+}
+
+// InspectsBody implements BodyInspector: TLSCert ignores the body.
+func (*TLSCert) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (c *TLSCert) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	state := t.Response.Response.TLS
+	if state == nil {
+		return errors.New("no TLS connection")
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no server certificate received")
+	}
+	cert := state.PeerCertificates[0]
+
+	errs := ErrorList{}
+	if c.minVersion != 0 && state.Version < c.minVersion {
+		errs = append(errs, fmt.Errorf("negotiated %s, want at least %s",
+			tlsVersionName(state.Version), c.MinVersion))
+	}
+	if c.MinDaysValid > 0 {
+		limit := time.Now().Add(time.Duration(c.MinDaysValid) * 24 * time.Hour)
+		if cert.NotAfter.Before(limit) {
+			errs = append(errs, fmt.Errorf("certificate expires %s, want valid for %d days",
+				cert.NotAfter.Format(time.RFC3339), c.MinDaysValid))
+		}
+	}
+	if err := c.Issuer.Fulfilled(cert.Issuer.String()); err != nil {
+		errs = append(errs, fmt.Errorf("issuer: %s", err))
+	}
+	if err := c.SubjectCN.Fulfilled(cert.Subject.CommonName); err != nil {
+		errs = append(errs, fmt.Errorf("subject CN: %s", err))
+	}
+	for _, name := range c.DNSNames {
+		found := false
+		for _, dns := range cert.DNSNames {
+			if strings.EqualFold(name, dns) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("missing DNS name %s", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *TLSCert) Prepare() error {
+	if c.MinDaysValid < 0 {
+		return MalformedCheck{errors.New("negative MinDaysValid")}
+	}
+	c.minVersion = 0
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[strings.ToUpper(c.MinVersion)]
+		if !ok {
+			return MalformedCheck{fmt.Errorf("unknown TLS version %q", c.MinVersion)}
+		}
+		c.minVersion = v
+	}
+	if err := c.Issuer.Compile(); err != nil {
+		return err
+	}
+	return c.SubjectCN.Compile()
+}
+
+// ----------------------------------------------------------------------------
+// TLSVersion
+
+// TLSVersion checks the negotiated TLS version and cipher suite of the
+// connection, e.g. to ensure compliance with a TLS policy:
+//     {
+//         Check: "TLSVersion"
+//         Min: "TLS1.2"
+//         Ciphers: [
+//             "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+//             "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"
+//             "TLS_AES_128_GCM_SHA256"
+//         ]
+//     }
+// Cipher suites are named like in package crypto/tls.
+type TLSVersion struct {
+	// Min and Max are the inclusive bounds of the negotiated TLS
+	// version. One of "TLS1.0", "TLS1.1", "TLS1.2" or "TLS1.3";
+	// empty values do not restrict the version.
+	Min string `json:",omitempty"`
+	Max string `json:",omitempty"`
+
+	// Ciphers is the set of allowed cipher suites. An empty list
+	// allows all cipher suites.
+	Ciphers []string `json:",omitempty"`
+
+	// NoInsecure forbids cipher suites with known security issues
+	// (see crypto/tls.InsecureCipherSuites).
+	NoInsecure bool `json:",omitempty"`
+
+	min, max uint16
+	ciphers  map[uint16]bool
+}
+
+// InspectsBody implements BodyInspector: TLSVersion ignores the body.
+func (*TLSVersion) InspectsBody() bool { return false }
+
+// Execute implements Check's Execute method.
+func (c *TLSVersion) Execute(t *Test) error {
+	if t.Response.Response == nil {
+		return errors.New("no response to check")
+	}
+	state := t.Response.Response.TLS
+	if state == nil {
+		return errors.New("no TLS connection")
+	}
+
+	errs := ErrorList{}
+	if c.min != 0 && state.Version < c.min {
+		errs = append(errs, fmt.Errorf("negotiated %s, want at least %s",
+			tlsVersionName(state.Version), c.Min))
+	}
+	if c.max != 0 && state.Version > c.max {
+		errs = append(errs, fmt.Errorf("negotiated %s, want at most %s",
+			tlsVersionName(state.Version), c.Max))
+	}
+	if len(c.ciphers) > 0 && !c.ciphers[state.CipherSuite] {
+		errs = append(errs, fmt.Errorf("cipher suite %s not allowed",
+			tls.CipherSuiteName(state.CipherSuite)))
+	}
+	if c.NoInsecure {
+		for _, cs := range tls.InsecureCipherSuites() {
+			if cs.ID == state.CipherSuite {
+				errs = append(errs, fmt.Errorf("insecure cipher suite %s", cs.Name))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *TLSVersion) Prepare() error {
+	version := func(name string) (uint16, error) {
+		if name == "" {
+			return 0, nil
+		}
+		v, ok := tlsVersions[strings.ToUpper(name)]
+		if !ok {
+			return 0, MalformedCheck{fmt.Errorf("unknown TLS version %q", name)}
+		}
+		return v, nil
+	}
+	var err error
+	if c.min, err = version(c.Min); err != nil {
+		return err
+	}
+	if c.max, err = version(c.Max); err != nil {
+		return err
+	}
+	if c.max != 0 && c.min > c.max {
+		return MalformedCheck{fmt.Errorf("Min %s > Max %s", c.Min, c.Max)}
+	}
+
+	c.ciphers = nil
+	if len(c.Ciphers) == 0 {
+		return nil
+	}
+	known := make(map[string]uint16)
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[cs.Name] = cs.ID
+	}
+	c.ciphers = make(map[uint16]bool, len(c.Ciphers))
+	for _, name := range c.Ciphers {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return MalformedCheck{fmt.Errorf("unknown cipher suite %q", name)}
+		}
+		c.ciphers[id] = true
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var tlsResponse = Response{Response: &http.Response{
+	StatusCode: 200,
+	TLS: &tls.ConnectionState{
+		Version: tls.VersionTLS12,
+		PeerCertificates: []*x509.Certificate{{
+			Subject:  pkix.Name{CommonName: "www.example.org"},
+			Issuer:   pkix.Name{CommonName: "Test CA", Organization: []string{"Acme"}},
+			NotAfter: time.Now().Add(30 * 24 * time.Hour),
+			DNSNames: []string{"example.org", "www.example.org"},
+		}},
+	},
+}}
+
+var tlsCertTests = []TC{
+	{tlsResponse, &TLSCert{MinDaysValid: 20}, nil},
+	{tlsResponse, &TLSCert{MinDaysValid: 40}, someError},
+	{tlsResponse, &TLSCert{Issuer: Condition{Contains: "CN=Test CA"}}, nil},
+	{tlsResponse, &TLSCert{Issuer: Condition{Contains: "Other CA"}}, someError},
+	{tlsResponse, &TLSCert{SubjectCN: Condition{Equals: "www.example.org"}}, nil},
+	{tlsResponse, &TLSCert{SubjectCN: Condition{Equals: "example.org"}}, someError},
+	{tlsResponse, &TLSCert{DNSNames: []string{"Example.org"}}, nil},
+	{tlsResponse, &TLSCert{DNSNames: []string{"example.org", "api.example.org"}}, someError},
+	{tlsResponse, &TLSCert{MinVersion: "TLS1.2"}, nil},
+	{tlsResponse, &TLSCert{MinVersion: "tls1.3"}, someError},
+	{tlsResponse, &TLSCert{MinVersion: "SSL3"}, prepareError},
+	{tlsResponse, &TLSCert{MinDaysValid: -1}, prepareError},
+	{tlsResponse, &TLSCert{Issuer: Condition{Regexp: "("}}, prepareError},
+	{Response{Response: &http.Response{StatusCode: 200}}, &TLSCert{}, someError},
+}
+
+func TestTLSCert(t *testing.T) {
+	for i, tc := range tlsCertTests {
+		runTest(t, i, tc)
+	}
+}
+
+func tlsConnResponse(version, cipher uint16) Response {
+	return Response{Response: &http.Response{
+		StatusCode: 200,
+		TLS:        &tls.ConnectionState{Version: version, CipherSuite: cipher},
+	}}
+}
+
+var tlsVersionTests = []TC{
+	{tlsConnResponse(tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256),
+		&TLSVersion{Min: "TLS1.2", Max: "TLS1.3"}, nil},
+	{tlsConnResponse(tls.VersionTLS11, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256),
+		&TLSVersion{Min: "TLS1.2"}, fmt.Errorf("negotiated TLS1.1, want at least TLS1.2")},
+	{tlsConnResponse(tls.VersionTLS13, tls.TLS_AES_128_GCM_SHA256),
+		&TLSVersion{Max: "tls1.2"}, fmt.Errorf("negotiated TLS1.3, want at most tls1.2")},
+	{tlsConnResponse(tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256),
+		&TLSVersion{Ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}, nil},
+	{tlsConnResponse(tls.VersionTLS12, tls.TLS_RSA_WITH_AES_128_CBC_SHA),
+		&TLSVersion{Ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		fmt.Errorf("cipher suite TLS_RSA_WITH_AES_128_CBC_SHA not allowed")},
+	{tlsConnResponse(tls.VersionTLS12, tls.TLS_RSA_WITH_RC4_128_SHA),
+		&TLSVersion{NoInsecure: true},
+		fmt.Errorf("insecure cipher suite TLS_RSA_WITH_RC4_128_SHA")},
+	{tlsConnResponse(tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256),
+		&TLSVersion{NoInsecure: true}, nil},
+	{tlsConnResponse(tls.VersionTLS12, 0), &TLSVersion{Min: "SSL3"}, prepareError},
+	{tlsConnResponse(tls.VersionTLS12, 0), &TLSVersion{Min: "TLS1.3", Max: "TLS1.2"}, prepareError},
+	{tlsConnResponse(tls.VersionTLS12, 0), &TLSVersion{Ciphers: []string{"ROT13"}}, prepareError},
+	{Response{Response: &http.Response{StatusCode: 200}}, &TLSVersion{}, someError},
+}
+
+func TestTLSVersion(t *testing.T) {
+	for i, tc := range tlsVersionTests {
+		runTest(t, i, tc)
+	}
+}
@@ -45,6 +45,9 @@ type ValidHTML struct {
 	Ignore string `json:",omitempty"`
 }
 
+// InspectsBody implements BodyInspector.
+func (ValidHTML) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (v ValidHTML) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
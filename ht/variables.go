@@ -0,0 +1,115 @@
+// Copyright 2015 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// variables.go contains the special {{NOW ...}} variable.
+
+package ht
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nowRe matches the {{NOW ...}} constructs.
+var nowRe = regexp.MustCompile(`\{\{(NOW(?:[ +\-|][^}]*)?)\}\}`)
+
+// nowSpecRe parses the content of a NOW construct: An optional offset
+// and an optional format in double quotes, optionally preceded by a
+// timezone in double quotes.
+var nowSpecRe = regexp.MustCompile(`^NOW *(([+-]) *([0-9a-z ]+?))? *(\| *"([^"]*)"( *"([^"]*)")?)? *$`)
+
+// nowOffsetRe matches one part of an offset like "12h".
+var nowOffsetRe = regexp.MustCompile(`([0-9]+)([a-z]+)`)
+
+// SetNowVariables computes the values of all {{NOW ...}} constructs found
+// in texts relative to now and stores them in vars.
+func SetNowVariables(vars map[string]string, now time.Time, texts ...string) error {
+	for _, text := range texts {
+		for _, m := range nowRe.FindAllStringSubmatch(text, -1) {
+			if _, ok := vars[m[1]]; ok {
+				continue
+			}
+			value, err := nowValue(now, m[1])
+			if err != nil {
+				return err
+			}
+			vars[m[1]] = value
+		}
+	}
+	return nil
+}
+
+// nowValue interpretes spec of the form
+//     NOW [(+|-) <offset>] [| ["<timezone>"] "<fmt>"]
+// Quotes may be backslash escaped as they appear in JSON strings.
+// The timezone is a location name understood by time.LoadLocation and
+// defaults to local time.
+func nowValue(now time.Time, spec string) (string, error) {
+	m := nowSpecRe.FindStringSubmatch(strings.Replace(spec, `\"`, `"`, -1))
+	if m == nil {
+		return "", fmt.Errorf("ht: malformed %q", spec)
+	}
+
+	if m[1] != "" {
+		offset, err := parseNowOffset(m[3])
+		if err != nil {
+			return "", fmt.Errorf("ht: bad offset in %q: %s", spec, err)
+		}
+		if m[2] == "-" {
+			offset = -offset
+		}
+		now = now.Add(offset)
+	}
+
+	format := time.RFC1123
+	if m[6] != "" {
+		loc, err := time.LoadLocation(m[5])
+		if err != nil {
+			return "", fmt.Errorf("ht: bad timezone in %q: %s", spec, err)
+		}
+		now = now.In(loc)
+		format = m[7]
+	} else if m[4] != "" {
+		format = m[5]
+	}
+	return now.Format(format), nil
+}
+
+// parseNowOffset parses offsets like "15s", "3d" or "1d12h" where the
+// units are s (seconds), m (minutes), h (hours), d (days) and w (weeks).
+func parseNowOffset(s string) (time.Duration, error) {
+	s = strings.Replace(s, " ", "", -1)
+	parts := nowOffsetRe.FindAllStringSubmatch(s, -1)
+	if parts == nil || len(nowOffsetRe.ReplaceAllString(s, "")) != 0 {
+		return 0, fmt.Errorf("cannot parse %q", s)
+	}
+
+	var offset time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part[1])
+		if err != nil {
+			return 0, err
+		}
+		d := time.Duration(n)
+		switch part[2] {
+		case "s":
+			d *= time.Second
+		case "m":
+			d *= time.Minute
+		case "h":
+			d *= time.Hour
+		case "d":
+			d *= 24 * time.Hour
+		case "w":
+			d *= 7 * 24 * time.Hour
+		default:
+			return 0, fmt.Errorf("unknown unit %q", part[2])
+		}
+		offset += d
+	}
+	return offset, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNowVariables(t *testing.T) {
+	now := time.Date(2014, 10, 1, 12, 22, 36, 0, time.UTC)
+	for i, tc := range []struct {
+		text, key, want, err string
+	}{
+		{text: "{{NOW}}", key: "NOW", want: "Wed, 01 Oct 2014 12:22:36 UTC"},
+		{text: "{{NOW + 15s}}", key: "NOW + 15s", want: "Wed, 01 Oct 2014 12:22:51 UTC"},
+		{text: `{{NOW + 25m | "15:04"}}`, key: `NOW + 25m | "15:04"`, want: "12:47"},
+		{text: `{{NOW + 3d | "2006-Jan-02"}}`, key: `NOW + 3d | "2006-Jan-02"`,
+			want: "2014-Oct-04"},
+		{text: `{{NOW + 2d | "2006-01-02 15:04"}}`, key: `NOW + 2d | "2006-01-02 15:04"`,
+			want: "2014-10-03 12:22"},
+		{text: `{{NOW - 1w | "2006-01-02 15:04"}}`, key: `NOW - 1w | "2006-01-02 15:04"`,
+			want: "2014-09-24 12:22"},
+		{text: `{{NOW +1d12h | "2006-01-02 15:04"}}`, key: `NOW +1d12h | "2006-01-02 15:04"`,
+			want: "2014-10-03 00:22"},
+		{text: `{{NOW - 1h 30m | "15:04"}}`, key: `NOW - 1h 30m | "15:04"`, want: "10:52"},
+		{text: `"Date": "{{NOW | \"15:04:05\"}}"`, key: `NOW | \"15:04:05\"`,
+			want: "12:22:36"},
+		{text: `{{NOW | "UTC" "2006-01-02T15:04:05Z07:00"}}`,
+			key:  `NOW | "UTC" "2006-01-02T15:04:05Z07:00"`,
+			want: "2014-10-01T12:22:36Z"},
+		{text: `{{NOW + 1h | "Europe/Zurich" "15:04 MST"}}`,
+			key:  `NOW + 1h | "Europe/Zurich" "15:04 MST"`,
+			want: "15:22 CEST"},
+		{text: `{{NOW | \"GMT\" \"Mon, 02 Jan 2006 15:04:05 MST\"}}`,
+			key:  `NOW | \"GMT\" \"Mon, 02 Jan 2006 15:04:05 MST\"`,
+			want: "Wed, 01 Oct 2014 12:22:36 GMT"},
+		{text: "{{NOWHERE}}", key: "NOWHERE"},
+		{text: `{{NOW | "Mars/Olympus" "15:04"}}`, err: "bad timezone"},
+		{text: "{{NOW + 3y}}", err: "unknown unit"},
+		{text: "{{NOW + d}}", err: "cannot parse"},
+		{text: "{{NOW * 3}}", err: "malformed"},
+	} {
+		vars := map[string]string{}
+		err := SetNowVariables(vars, now, tc.text)
+		if tc.err != "" {
+			if err == nil {
+				t.Errorf("%d: %q missing error, want %s", i, tc.text, tc.err)
+			} else if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%d: %q wrong error, got %s, want %s", i, tc.text, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %q unexpected error %s", i, tc.text, err)
+			continue
+		}
+		if got, ok := vars[tc.key]; tc.want == "" && ok {
+			t.Errorf("%d: %q unexpected value %q", i, tc.text, got)
+		} else if got != tc.want {
+			t.Errorf("%d: %q got %q, want %q", i, tc.text, got, tc.want)
+		}
+	}
+}
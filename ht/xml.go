@@ -26,6 +26,9 @@ type XML struct {
 	path *xmlpath.Path
 }
 
+// InspectsBody implements BodyInspector.
+func (*XML) InspectsBody() bool { return true }
+
 // Execute implements Check's Execute method.
 func (x *XML) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
@@ -0,0 +1,197 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// har.go contains the import of HAR (HTTP Archive) files.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vdobler/ht/recorder"
+)
+
+// harFile is a HAR file, see http://www.softwareishard.com/blog/har-12-spec/
+type harFile struct {
+	Log struct {
+		Pages []struct {
+			Title string `json:"title"`
+		} `json:"pages"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method      string   `json:"method"`
+		URL         string   `json:"url"`
+		Headers     []harNVP `json:"headers"`
+		QueryString []harNVP `json:"queryString"`
+		Cookies     []harNVP `json:"cookies"`
+		PostData    *harPost `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int      `json:"status"`
+		Headers []harNVP `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harNVP struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	FileName string `json:"fileName"`
+}
+
+type harPost struct {
+	MimeType string   `json:"mimeType"`
+	Params   []harNVP `json:"params"`
+	Text     string   `json:"text"`
+}
+
+// harCommonHeaders is the name of the mixin with the common request headers.
+const harCommonHeaders = "common-headers.mixin"
+
+// HAR converts the entries of the HAR (HTTP Archive) file read from r
+// into a suite and tests. Each entry becomes a test with a StatusCode and
+// a ContentType check. Request headers common to all entries are extracted
+// into a mixin like the recorder does. Query and form parameters are put
+// into Params and the host of the first entry is replaced by the
+// variable HOSTNAME.
+func HAR(r io.Reader) (*Result, error) {
+	har := harFile{}
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("cannot decode HAR file: %s", err)
+	}
+	entries := har.Log.Entries
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries in HAR file")
+	}
+
+	name := "Imported HAR"
+	if len(har.Log.Pages) > 0 && har.Log.Pages[0].Title != "" {
+		name = har.Log.Pages[0].Title
+	}
+	result := newResult(name)
+	result.Suite.KeepCookies = true
+
+	// Header of all requests, common ones are moved to the mixin.
+	events := make([]recorder.Event, len(entries))
+	for i, e := range entries {
+		header := make(http.Header)
+		for _, h := range e.Request.Headers {
+			switch ch := http.CanonicalHeaderKey(h.Name); {
+			case strings.HasPrefix(h.Name, ":"): // HTTP/2 pseudo header
+			case ch == "Cookie", ch == "Host", ch == "Content-Length":
+			default:
+				header.Add(ch, h.Value)
+			}
+		}
+		events[i].Request = &http.Request{Header: header}
+	}
+	common := recorder.ExtractCommonRequestHeaders(events)
+	var mixins []string
+	if len(common) > 0 {
+		result.Mixins[harCommonHeaders] = &Test{
+			Name:    fmt.Sprintf("Common Header of %s", name),
+			Request: Request{Header: common},
+		}
+		mixins = []string{harCommonHeaders}
+	}
+
+	var hostname string
+	for i, e := range entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %s", i+1, err)
+		}
+		if i == 0 {
+			hostname = u.Host
+			result.Suite.Variables = map[string]string{"HOSTNAME": hostname}
+		}
+
+		test := &Test{
+			Name:  fmt.Sprintf("%s %s", e.Request.Method, u.Path),
+			Mixin: mixins,
+			Request: Request{
+				Method: e.Request.Method,
+			},
+		}
+		header := events[i].Request.Header
+		if len(header) > 0 {
+			test.Request.Header = header
+		}
+
+		// Parameters in URL and body: Keep URL parameters in URL if
+		// both are present.
+		query := u.Query()
+		bodyParams, paramsAs := harBody(test, e.Request.PostData)
+		if len(bodyParams) > 0 {
+			test.Request.Params, test.Request.ParamsAs = bodyParams, paramsAs
+			test.Request.Header.Del("Content-Type")
+		} else if len(query) > 0 {
+			test.Request.Params = query
+			u.RawQuery = ""
+		}
+
+		if u.Host == hostname {
+			u.Host = "H.O.S.T.N.A.M.E"
+		}
+		test.Request.URL = strings.Replace(u.String(), "H.O.S.T.N.A.M.E", "{{HOSTNAME}}", 1)
+
+		// Checks.
+		addStatusCheck(test, fmt.Sprint(e.Response.Status))
+		if mt, _, err := mime.ParseMediaType(e.Response.Content.MimeType); err == nil && mt != "" {
+			test.addCheck("ContentType", "Is", mt)
+		}
+
+		result.addTest(test.Name, test)
+	}
+
+	return result, nil
+}
+
+// harBody sets the body of test from the post data pd or returns the
+// parameters in pd and how to send them.
+func harBody(test *Test, pd *harPost) (url.Values, string) {
+	if pd == nil {
+		return nil, ""
+	}
+	mt, _, _ := mime.ParseMediaType(pd.MimeType)
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		params := make(url.Values)
+		for _, p := range pd.Params {
+			params.Add(p.Name, p.Value)
+		}
+		if len(params) == 0 && pd.Text != "" {
+			params, _ = url.ParseQuery(pd.Text)
+		}
+		return params, "body"
+	case "multipart/form-data":
+		params := make(url.Values)
+		for _, p := range pd.Params {
+			if p.FileName != "" {
+				params.Add(p.Name, "@file:"+p.FileName)
+				test.TODO = append(test.TODO,
+					fmt.Sprintf("provide file %q for parameter %q", p.FileName, p.Name))
+				continue
+			}
+			params.Add(p.Name, p.Value)
+		}
+		if len(params) > 0 {
+			return params, "multipart"
+		}
+	}
+	test.Request.Body = pd.Text
+	return nil, ""
+}
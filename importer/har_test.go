@@ -0,0 +1,90 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/vdobler/ht/suite"
+)
+
+func TestHAR(t *testing.T) {
+	file, err := os.Open("testdata/capture.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := HAR(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if result.Suite.Name != "Shop Homepage" ||
+		result.Suite.Variables["HOSTNAME"] != "shop.example.org" {
+		t.Errorf("Bad suite %#v", result.Suite)
+	}
+	want := []Element{{"GET_search.ht"}, {"POST_cart.ht"}, {"GET_logo.png.ht"}}
+	if !reflect.DeepEqual(result.Suite.Main, want) {
+		t.Errorf("Got Main=%v", result.Suite.Main)
+	}
+
+	mixin := result.Mixins["common-headers.mixin"]
+	if mixin == nil || !reflect.DeepEqual(mixin.Request.Header,
+		http.Header{"User-Agent": {"Mozilla/5.0"}}) {
+		t.Fatalf("Bad mixin %#v", mixin)
+	}
+
+	search := result.Tests["GET_search.ht"]
+	if search.Request.URL != "https://{{HOSTNAME}}/search" ||
+		!reflect.DeepEqual(search.Request.Params, url.Values{"q": {"chair"}, "page": {"2"}}) ||
+		!reflect.DeepEqual(search.Request.Header, http.Header{"Accept-Language": {"de"}}) ||
+		!reflect.DeepEqual(search.Mixin, []string{"common-headers.mixin"}) {
+		t.Errorf("Bad search request %#v", search)
+	}
+	if fmt.Sprint(search.Checks) != "[map[Check:StatusCode Expect:200] map[Check:ContentType Is:text/html]]" {
+		t.Errorf("Bad search checks %v", search.Checks)
+	}
+
+	cart := result.Tests["POST_cart.ht"]
+	if cart.Request.URL != "https://{{HOSTNAME}}/cart?lang=de" ||
+		cart.Request.ParamsAs != "body" ||
+		!reflect.DeepEqual(cart.Request.Params, url.Values{"item": {"42"}, "qty": {"1"}}) ||
+		cart.Request.Header.Get("Content-Type") != "" ||
+		cart.Request.Header.Get("Content-Length") != "" {
+		t.Errorf("Bad cart request %#v", cart.Request)
+	}
+
+	logo := result.Tests["GET_logo.png.ht"]
+	if logo.Request.URL != "https://cdn.example.com/logo.png" || len(logo.Checks) != 1 {
+		t.Errorf("Bad logo test %#v", logo)
+	}
+
+	// The generated files must be loadable.
+	files, err := result.Files()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	archive := &bytes.Buffer{}
+	for name, data := range files {
+		fmt.Fprintf(archive, "# %s\n%s\n", name, data)
+	}
+	fs, err := suite.NewFileSystem(archive.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs, err := suite.LoadRawSuite(result.Filename, fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.Validate(nil); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
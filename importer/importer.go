@@ -0,0 +1,169 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package importer converts request collections of other tools (e.g.
+// Postman) into ht suites and tests.
+//
+// The generated tests and suites are plain JSON (and thus valid HJSON)
+// files. Things which could not be translated automatically are listed
+// as TODO comments at the top of the generated files.
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/sanitize"
+)
+
+// Test is a reduced version of ht.Test suitable for serialization.
+// Checks and extractors are kept as generic maps to control their
+// serialization, e.g. of durations.
+type Test struct {
+	Name        string
+	Description string   `json:",omitempty"`
+	Mixin       []string `json:",omitempty"`
+	Request     Request
+	Checks      []map[string]interface{}          `json:",omitempty"`
+	VarEx       map[string]map[string]interface{} `json:",omitempty"`
+	Variables   map[string]string                 `json:",omitempty"`
+
+	// TODO lists everything which could not be translated.
+	TODO []string `json:"-"`
+}
+
+// Request is a reduced version of ht.Request suitable for serialization.
+type Request struct {
+	Method        string      `json:",omitempty"`
+	URL           string      `json:",omitempty"`
+	Params        url.Values  `json:",omitempty"`
+	ParamsAs      string      `json:",omitempty"`
+	Header        http.Header `json:",omitempty"`
+	Body          string      `json:",omitempty"`
+	BasicAuthUser string      `json:",omitempty"`
+	BasicAuthPass string      `json:",omitempty"`
+}
+
+// Element is one test of a Suite.
+type Element struct {
+	File string
+}
+
+// Suite is a reduced version of suite.RawSuite suitable for serialization.
+type Suite struct {
+	Name        string
+	Description string `json:",omitempty"`
+	KeepCookies bool   `json:",omitempty"`
+	Main        []Element
+	Variables   map[string]string `json:",omitempty"`
+
+	// TODO lists everything which could not be translated.
+	TODO []string `json:"-"`
+}
+
+// Result of an import: A suite and its tests.
+type Result struct {
+	Filename string           // Filename of the suite.
+	Suite    *Suite           // Suite itself.
+	Tests    map[string]*Test // Tests of the suite keyed by filename.
+	Mixins   map[string]*Test // Mixins used by the tests keyed by filename.
+}
+
+// newResult with an empty suite named name.
+func newResult(name string) *Result {
+	filename := strings.ToLower(sanitize.Filename(name))
+	if filename == "" {
+		filename = "imported"
+	}
+	return &Result{
+		Filename: filename + ".suite",
+		Suite:    &Suite{Name: name},
+		Tests:    make(map[string]*Test),
+		Mixins:   make(map[string]*Test),
+	}
+}
+
+// addTest adds test to r under a unique filename derived from name.
+func (r *Result) addTest(name string, test *Test) {
+	base := sanitize.Filename(name)
+	if base == "" {
+		base = "test"
+	}
+	filename := base + ".ht"
+	for i := 2; r.Tests[filename] != nil; i++ {
+		filename = fmt.Sprintf("%s-%d.ht", base, i)
+	}
+	r.Tests[filename] = test
+	r.Suite.Main = append(r.Suite.Main, Element{File: filename})
+}
+
+// Files returns the content of the generated suite and test files
+// keyed by filename.
+func (r *Result) Files() (map[string][]byte, error) {
+	files := make(map[string][]byte, len(r.Tests)+len(r.Mixins)+1)
+	data, err := marshal(r.Suite, r.Suite.TODO)
+	if err != nil {
+		return nil, err
+	}
+	files[r.Filename] = data
+	for filename, test := range r.Tests {
+		data, err := marshal(test, test.TODO)
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = data
+	}
+	for filename, mixin := range r.Mixins {
+		data, err := marshal(mixin, mixin.TODO)
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = data
+	}
+	return files, nil
+}
+
+// Write all generated files to directory dir and return their names.
+func (r *Result) Write(dir string) ([]string, error) {
+	files, err := r.Files()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for filename := range files {
+		names = append(names, filename)
+	}
+	sort.Strings(names)
+	for i, filename := range names {
+		names[i] = path.Join(dir, filename)
+		err := ioutil.WriteFile(names[i], files[filename], 0666)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// marshal v to indented JSON preceded by the todos as comments.
+func marshal(v interface{}, todos []string) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	for _, todo := range todos {
+		todo = strings.Replace(todo, "\n", " ", -1)
+		fmt.Fprintf(buf, "// TODO: %s\n", todo)
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
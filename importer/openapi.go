@@ -0,0 +1,482 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// openapi.go contains the generation of smoke tests from OpenAPI specs.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/internal/openapi"
+)
+
+// openapiMethods are the operations of a path item in their output order.
+var openapiMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openapiSpec provides access to a decoded OpenAPI 3 specification.
+type openapiSpec struct {
+	*openapi.Spec
+}
+
+// OpenAPI generates smoke tests from the OpenAPI 3 specification read
+// from r which may be in YAML or JSON format:
+//   - Each operation becomes a test named by its operationId.
+//   - Path, query and header parameters as well as the request body are
+//     filled with the example values (or defaults) from the spec.
+//   - The lowest declared success status code and the content type of
+//     this response are checked.
+//   - The security schemes http basic and bearer map to basic auth and
+//     a bearer token; the credentials are referenced as variables.
+//
+// The base URL is taken from the first server and stored in the suite
+// variable BASE_URL. Parameters without example and credentials are
+// referenced as variables which are recorded as TODO but deliberately not
+// declared: The generated suite is bogus until they are provided, e.g. in
+// the suite's Variables or on the command line.
+func OpenAPI(r io.Reader) (*Result, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := openapi.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode OpenAPI spec: %s", err)
+	}
+	root := decoded.Root
+	if _, ok := root["openapi"]; !ok {
+		return nil, fmt.Errorf("not an OpenAPI 3 spec (missing openapi field)")
+	}
+	spec := &openapiSpec{decoded}
+
+	info := spec.Object(root["info"])
+	name := str(info["title"])
+	if name == "" {
+		name = "Imported OpenAPI"
+	}
+	result := newResult(name)
+	result.Suite.Description = str(info["description"])
+
+	baseURL := "http://localhost"
+	if servers, ok := root["servers"].([]interface{}); ok && len(servers) > 0 {
+		if u := str(spec.Object(servers[0])["url"]); u != "" {
+			baseURL = strings.TrimRight(u, "/")
+		}
+	}
+	result.Suite.Variables = map[string]string{"BASE_URL": baseURL}
+
+	paths := spec.Object(root["paths"])
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths in OpenAPI spec")
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	security, _ := root["security"].([]interface{})
+	for _, p := range sorted {
+		item := spec.Object(paths[p])
+		for _, method := range openapiMethods {
+			op := spec.Object(item[method])
+			if op == nil {
+				continue
+			}
+			test := spec.operationTest(result, p, strings.ToUpper(method), item, op, security)
+			result.addTest(test.Name, test)
+		}
+	}
+
+	return result, nil
+}
+
+// operationTest generates the test for the operation op of the given
+// method on path p.
+func (spec *openapiSpec) operationTest(result *Result, p, method string, item, op map[string]interface{}, security []interface{}) *Test {
+	name := str(op["operationId"])
+	if name == "" {
+		name = method + " " + p
+	}
+	test := &Test{
+		Name:        name,
+		Description: str(op["summary"]),
+		Request:     Request{Method: method},
+	}
+
+	// Operation parameters override path item parameters.
+	params := make(map[string]map[string]interface{})
+	var order []string
+	for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+		l, _ := list.([]interface{})
+		for _, pv := range l {
+			param := spec.Object(pv)
+			key := str(param["in"]) + ":" + str(param["name"])
+			if _, seen := params[key]; !seen {
+				order = append(order, key)
+			}
+			params[key] = param
+		}
+	}
+
+	path := p
+	for _, key := range order {
+		param := params[key]
+		pname := str(param["name"])
+		value, ok := spec.example(param)
+		if !ok {
+			if str(param["in"]) != "path" && param["required"] != true {
+				continue
+			}
+			value = "{{" + pname + "}}"
+			test.TODO = append(test.TODO,
+				fmt.Sprintf("provide value for %s parameter %q", param["in"], pname))
+		}
+		switch str(param["in"]) {
+		case "path":
+			if ok {
+				value = url.PathEscape(value)
+			}
+			path = strings.Replace(path, "{"+pname+"}", value, -1)
+		case "query":
+			if test.Request.Params == nil {
+				test.Request.Params = make(url.Values)
+			}
+			test.Request.Params.Add(pname, value)
+		case "header":
+			if test.Request.Header == nil {
+				test.Request.Header = make(http.Header)
+			}
+			test.Request.Header.Add(pname, value)
+		case "cookie":
+			test.TODO = append(test.TODO,
+				fmt.Sprintf("send cookie %s=%s", pname, value))
+		}
+	}
+	test.Request.URL = "{{BASE_URL}}" + path
+
+	spec.requestBody(test, spec.Object(op["requestBody"]))
+
+	if opSecurity, ok := op["security"].([]interface{}); ok {
+		security = opSecurity
+	}
+	spec.security(result, test, security)
+
+	spec.responses(test, spec.Object(op["responses"]))
+	return test
+}
+
+// requestBody fills the body of test from the example of the request
+// body rb. JSON is preferred over form data.
+func (spec *openapiSpec) requestBody(test *Test, rb map[string]interface{}) {
+	content := spec.Object(rb["content"])
+	if len(content) == 0 {
+		return
+	}
+	ct := preferredContentType(content)
+	media := spec.Object(content[ct])
+	example, ok := spec.mediaExample(media)
+	if !ok {
+		example = spec.synthesize(spec.Object(media["schema"]), 0)
+	}
+
+	switch ct {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		params := make(url.Values)
+		obj, _ := example.(map[string]interface{})
+		for k, v := range obj {
+			params.Set(k, scalarString(v))
+		}
+		test.Request.Params = params
+		test.Request.ParamsAs = "body"
+		if ct == "multipart/form-data" {
+			test.Request.ParamsAs = "multipart"
+		}
+		return
+	}
+
+	if test.Request.Header == nil {
+		test.Request.Header = make(http.Header)
+	}
+	test.Request.Header.Set("Content-Type", ct)
+	if s, ok := example.(string); ok && !strings.Contains(ct, "json") {
+		test.Request.Body = s
+	} else if example != nil {
+		body, _ := json.MarshalIndent(example, "", "    ")
+		test.Request.Body = string(body)
+	}
+	if !ok && rb["required"] == true {
+		test.TODO = append(test.TODO, "check synthesized request body")
+	}
+}
+
+// preferredContentType returns JSON if available in content, else form
+// data or the alphabetically first one.
+func preferredContentType(content map[string]interface{}) string {
+	types := make([]string, 0, len(content))
+	for ct := range content {
+		types = append(types, ct)
+	}
+	sort.Strings(types)
+	for _, want := range []string{"application/json", "json", "x-www-form-urlencoded", "multipart/form-data"} {
+		for _, ct := range types {
+			if strings.Contains(ct, want) {
+				return ct
+			}
+		}
+	}
+	return types[0]
+}
+
+// security adds authentication to test according to the first security
+// requirement which can be translated.
+func (spec *openapiSpec) security(result *Result, test *Test, requirements []interface{}) {
+	schemes := spec.Object(spec.Object(spec.Root["components"])["securitySchemes"])
+	for _, req := range requirements {
+		for schemeName := range spec.Object(req) {
+			scheme := spec.Object(schemes[schemeName])
+			typ, sch := str(scheme["type"]), strings.ToLower(str(scheme["scheme"]))
+			switch {
+			case typ == "http" && sch == "basic":
+				test.Request.BasicAuthUser = "{{USERNAME}}"
+				test.Request.BasicAuthPass = "{{PASSWORD}}"
+				todoVariable(result, "USERNAME", "PASSWORD")
+			case typ == "http" && sch == "bearer", typ == "oauth2", typ == "openIdConnect":
+				if test.Request.Header == nil {
+					test.Request.Header = make(http.Header)
+				}
+				test.Request.Header.Set("Authorization", "Bearer {{TOKEN}}")
+				todoVariable(result, "TOKEN")
+			case typ == "apiKey":
+				kname := str(scheme["name"])
+				switch str(scheme["in"]) {
+				case "header":
+					if test.Request.Header == nil {
+						test.Request.Header = make(http.Header)
+					}
+					test.Request.Header.Set(kname, "{{API_KEY}}")
+				case "query":
+					if test.Request.Params == nil {
+						test.Request.Params = make(url.Values)
+					}
+					test.Request.Params.Set(kname, "{{API_KEY}}")
+				default:
+					test.TODO = append(test.TODO,
+						fmt.Sprintf("send API key %q in %s", kname, scheme["in"]))
+				}
+				todoVariable(result, "API_KEY")
+			default:
+				test.TODO = append(test.TODO,
+					fmt.Sprintf("unsupported security scheme %q", schemeName))
+				continue
+			}
+			return
+		}
+	}
+}
+
+// todoVariable notes the credential variables names as TODO of the suite.
+// The variables are not declared so that forgetting to set them makes the
+// tests bogus instead of sending empty credentials.
+func todoVariable(result *Result, names ...string) {
+outer:
+	for _, name := range names {
+		todo := fmt.Sprintf("set variable %s", name)
+		for _, t := range result.Suite.TODO {
+			if t == todo {
+				continue outer
+			}
+		}
+		result.Suite.TODO = append(result.Suite.TODO, todo)
+	}
+}
+
+// responses adds a StatusCode check for the lowest declared success code
+// and a ContentType check for its first declared content.
+func (spec *openapiSpec) responses(test *Test, responses map[string]interface{}) {
+	best, bestCode := "", 1000
+	for code := range responses {
+		c := code
+		if strings.ToUpper(c) == "2XX" {
+			c = "299"
+		}
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 200 || n > 299 {
+			continue
+		}
+		if n < bestCode {
+			best, bestCode = code, n
+		}
+	}
+	if best == "" {
+		test.TODO = append(test.TODO, "no success response declared")
+		return
+	}
+
+	if strings.ToUpper(best) == "2XX" {
+		// StatusCode checks only the first digit for values below 10.
+		test.Checks = append(test.Checks,
+			map[string]interface{}{"Check": "StatusCode", "Expect": 2})
+	} else {
+		addStatusCheck(test, best)
+	}
+
+	content := spec.Object(spec.Object(responses[best])["content"])
+	types := make([]string, 0, len(content))
+	for ct := range content {
+		types = append(types, ct)
+	}
+	sort.Strings(types)
+	for _, ct := range types {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || strings.Contains(mt, "*") {
+			continue
+		}
+		test.addCheck("ContentType", "Is", mt)
+		break
+	}
+}
+
+// example returns the example value of the parameter param.
+func (spec *openapiSpec) example(param map[string]interface{}) (string, bool) {
+	v, ok := spec.mediaExample(param)
+	if !ok {
+		return "", false
+	}
+	return scalarString(v), true
+}
+
+// mediaExample returns the example of a parameter or media type object
+// obj which is looked up in its example, examples or its schema.
+func (spec *openapiSpec) mediaExample(obj map[string]interface{}) (interface{}, bool) {
+	if v, ok := obj["example"]; ok {
+		return v, true
+	}
+	for _, ex := range sortedValues(spec.Object(obj["examples"])) {
+		if v, ok := spec.Object(ex)["value"]; ok {
+			return v, true
+		}
+	}
+	return schemaExample(spec.Object(obj["schema"]), spec)
+}
+
+// schemaExample returns the example, default or first enum value of
+// the schema.
+func schemaExample(schema map[string]interface{}, spec *openapiSpec) (interface{}, bool) {
+	if v, ok := schema["example"]; ok {
+		return v, true
+	}
+	if v, ok := schema["default"]; ok {
+		return v, true
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], true
+	}
+	if items, ok := schema["items"]; ok && schema["type"] == "array" {
+		if v, ok := schemaExample(spec.Object(items), spec); ok {
+			return []interface{}{v}, true
+		}
+	}
+	return nil, false
+}
+
+// synthesize an example value for schema from the examples of its
+// properties or placeholder values.
+func (spec *openapiSpec) synthesize(schema map[string]interface{}, depth int) interface{} {
+	if v, ok := schemaExample(schema, spec); ok {
+		return v
+	}
+	if depth > 8 {
+		return nil
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, s := range all {
+			if obj, ok := spec.synthesize(spec.Object(s), depth+1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts, ok := schema[key].([]interface{}); ok && len(alts) > 0 {
+			return spec.synthesize(spec.Object(alts[0]), depth+1)
+		}
+	}
+
+	switch schema["type"] {
+	case "array":
+		return []interface{}{spec.synthesize(spec.Object(schema["items"]), depth+1)}
+	case "integer", "number":
+		return 0.0
+	case "boolean":
+		return false
+	case "string":
+		switch schema["format"] {
+		case "date":
+			return "2006-01-02"
+		case "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "uuid":
+			return "{{RANDOM UUID}}"
+		}
+		return "string"
+	}
+	obj := make(map[string]interface{})
+	for k, v := range spec.Object(schema["properties"]) {
+		obj[k] = spec.synthesize(spec.Object(v), depth+1)
+	}
+	return obj
+}
+
+// str returns v if it is a string and "" otherwise.
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// scalarString formats the example value v for use in URLs and headers.
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = scalarString(e)
+		}
+		return strings.Join(parts, ",")
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// sortedValues returns the values of m ordered by key.
+func sortedValues(m map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values
+}
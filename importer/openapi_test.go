@@ -0,0 +1,112 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/suite"
+)
+
+func TestOpenAPI(t *testing.T) {
+	file, err := os.Open("../internal/openapi/testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := OpenAPI(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if result.Suite.Name != "Petstore" ||
+		result.Suite.Description != "A sample API with pets." ||
+		!reflect.DeepEqual(result.Suite.Variables, map[string]string{
+			"BASE_URL": "https://petstore.example.org/v1"}) ||
+		!reflect.DeepEqual(result.Suite.TODO, []string{
+			"set variable TOKEN", "set variable USERNAME", "set variable PASSWORD"}) {
+		t.Errorf("Bad suite %#v", result.Suite)
+	}
+	want := []Element{{"listPets.ht"}, {"createPet.ht"}, {"showPetById.ht"}, {"DELETE_pets_petId_.ht"}}
+	if !reflect.DeepEqual(result.Suite.Main, want) {
+		t.Errorf("Got Main=%v", result.Suite.Main)
+	}
+
+	list := result.Tests["listPets.ht"]
+	if list.Request.URL != "{{BASE_URL}}/pets" ||
+		list.Description != "List all pets" ||
+		!reflect.DeepEqual(list.Request.Params, url.Values{"limit": {"20"}}) ||
+		!reflect.DeepEqual(list.Request.Header, http.Header{"Authorization": {"Bearer {{TOKEN}}"}}) {
+		t.Errorf("Bad listPets request %#v", list)
+	}
+	if fmt.Sprint(list.Checks) != "[map[Check:StatusCode Expect:200] map[Check:ContentType Is:application/json]]" {
+		t.Errorf("Bad listPets checks %v", list.Checks)
+	}
+
+	create := result.Tests["createPet.ht"]
+	if create.Request.BasicAuthUser != "{{USERNAME}}" ||
+		create.Request.Header.Get("Authorization") != "" ||
+		create.Request.Header.Get("Content-Type") != "application/json" ||
+		!strings.Contains(create.Request.Body, `"name": "Rex"`) ||
+		!strings.Contains(create.Request.Body, `"tag": "dog"`) {
+		t.Errorf("Bad createPet request %#v", create.Request)
+	}
+	if fmt.Sprint(create.Checks) != "[map[Check:StatusCode Expect:201] map[Check:ContentType Is:application/json]]" {
+		t.Errorf("Bad createPet checks %v", create.Checks)
+	}
+
+	show := result.Tests["showPetById.ht"]
+	if show.Request.URL != "{{BASE_URL}}/pets/{{petId}}" ||
+		show.Request.Header.Get("X-Request-ID") != "{{X-Request-ID}}" ||
+		len(show.TODO) != 2 {
+		t.Errorf("Bad showPetById test %#v", show)
+	}
+	if len(show.Variables) != 0 {
+		t.Errorf("Unexpected showPetById variables %v", show.Variables)
+	}
+	if fmt.Sprint(show.Checks) != "[map[Check:StatusCode Expect:2] map[Check:ContentType Is:application/json]]" {
+		t.Errorf("Bad showPetById checks %v", show.Checks)
+	}
+
+	del := result.Tests["DELETE_pets_petId_.ht"]
+	if del.Request.Method != "DELETE" || len(del.Checks) != 1 {
+		t.Errorf("Bad delete test %#v", del)
+	}
+
+	// The generated files must be loadable.
+	files, err := result.Files()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	archive := &bytes.Buffer{}
+	for name, data := range files {
+		fmt.Fprintf(archive, "# %s\n%s\n", name, data)
+	}
+	fs, err := suite.NewFileSystem(archive.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs, err := suite.LoadRawSuite(result.Filename, fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// Unless the TODO variables are provided the tests are bogus.
+	if err := rs.Validate(nil); err == nil ||
+		!strings.Contains(err.Error(), "undefined variables TOKEN, X-Request-ID, petId") {
+		t.Errorf("Got Validate error %v", err)
+	}
+	provided := map[string]string{"TOKEN": "t", "USERNAME": "u", "PASSWORD": "p",
+		"petId": "1", "X-Request-ID": "r"}
+	if err := rs.Validate(provided); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,520 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// postman.go contains the import of Postman collections.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postmanCollection is a Postman collection in format v2.0 or v2.1.
+type postmanCollection struct {
+	Info struct {
+		Name        string      `json:"name"`
+		Description interface{} `json:"description"`
+	} `json:"info"`
+	Item     []postmanItem  `json:"item"`
+	Event    []postmanEvent `json:"event"`
+	Variable []postmanKV    `json:"variable"`
+	Auth     *postmanAuth   `json:"auth"`
+}
+
+// postmanItem is either a folder (with Items) or a request.
+type postmanItem struct {
+	Name        string          `json:"name"`
+	Description interface{}     `json:"description"`
+	Item        []postmanItem   `json:"item"`
+	Request     json.RawMessage `json:"request"`
+	Event       []postmanEvent  `json:"event"`
+	Auth        *postmanAuth    `json:"auth"`
+}
+
+type postmanRequest struct {
+	Method      string       `json:"method"`
+	Header      []postmanKV  `json:"header"`
+	URL         postmanURL   `json:"url"`
+	Body        *postmanBody `json:"body"`
+	Auth        *postmanAuth `json:"auth"`
+	Description interface{}  `json:"description"`
+}
+
+// postmanURL is either a plain string or an object.
+type postmanURL struct {
+	Raw      string      `json:"raw"`
+	Variable []postmanKV `json:"variable"`
+}
+
+// UnmarshalJSON handles the string and the object form of an URL.
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &u.Raw)
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+type postmanKV struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type"`
+	Src      interface{} `json:"src"`
+	Disabled bool        `json:"disabled"`
+}
+
+func (kv postmanKV) value() string {
+	if kv.Value == nil {
+		return ""
+	}
+	return fmt.Sprint(kv.Value)
+}
+
+type postmanBody struct {
+	Mode       string      `json:"mode"`
+	Raw        string      `json:"raw"`
+	URLEncoded []postmanKV `json:"urlencoded"`
+	FormData   []postmanKV `json:"formdata"`
+}
+
+type postmanAuth struct {
+	Type   string      `json:"type"`
+	Basic  interface{} `json:"basic"`
+	Bearer interface{} `json:"bearer"`
+}
+
+// params returns the parameters of typ which are a list of key/value
+// objects in v2.1 and a plain object in v2.0.
+func (a *postmanAuth) params(typ interface{}) map[string]string {
+	params := make(map[string]string)
+	switch p := typ.(type) {
+	case []interface{}:
+		for _, kv := range p {
+			if m, ok := kv.(map[string]interface{}); ok {
+				params[fmt.Sprint(m["key"])] = fmt.Sprint(m["value"])
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range p {
+			params[k] = fmt.Sprint(v)
+		}
+	}
+	return params
+}
+
+type postmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec interface{} `json:"exec"`
+	} `json:"script"`
+}
+
+// source of the script of e.
+func (e postmanEvent) source() string {
+	switch exec := e.Script.Exec.(type) {
+	case string:
+		return exec
+	case []interface{}:
+		lines := make([]string, len(exec))
+		for i, l := range exec {
+			lines[i] = fmt.Sprint(l)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// description returns the textual description which is either a plain
+// string or an object with a content field.
+func description(d interface{}) string {
+	switch d := d.(type) {
+	case string:
+		return d
+	case map[string]interface{}:
+		if content, ok := d["content"].(string); ok {
+			return content
+		}
+	}
+	return ""
+}
+
+// Postman converts the Postman collection (format v2.0 or v2.1) read from
+// r into a suite and tests:
+//   - Each request becomes a test; the names of enclosing folders are
+//     prepended to the test name.
+//   - Collection variables become the suite's Variables. Postman and ht
+//     share the {{VAR}} syntax; path variables like :id are turned into
+//     {{id}} with the value as the test's default. Some dynamic variables
+//     like {{$guid}} are mapped to the equivalent ht random variables.
+//   - Basic and bearer authentication are translated.
+//   - Simple assertions in test scripts (status code, headers, body,
+//     response time and JSON values) are translated to checks, setting
+//     variables from JSON responses to JSON extractors.
+//
+// Everything else is recorded as a TODO.
+func Postman(r io.Reader) (*Result, error) {
+	collection := postmanCollection{}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("cannot decode Postman collection: %s", err)
+	}
+
+	result := newResult(collection.Info.Name)
+	result.Suite.Description = description(collection.Info.Description)
+	result.Suite.KeepCookies = true // like Postman does
+	for _, v := range collection.Variable {
+		if result.Suite.Variables == nil {
+			result.Suite.Variables = make(map[string]string)
+		}
+		result.Suite.Variables[v.Key] = mapDynamicVariables(v.value())
+	}
+
+	err := importPostmanItems(result, collection.Item, "", collection.Auth, collection.Event)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importPostmanItems adds items to result recursively. Names of folders are
+// accumulated in prefix, auth and events are inherited from the folders.
+func importPostmanItems(result *Result, items []postmanItem, prefix string, auth *postmanAuth, events []postmanEvent) error {
+	for _, item := range items {
+		name := item.Name
+		if prefix != "" {
+			name = prefix + " - " + name
+		}
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		itemEvents := append(events[:len(events):len(events)], item.Event...)
+
+		if len(item.Request) == 0 {
+			// A folder.
+			err := importPostmanItems(result, item.Item, name, itemAuth, itemEvents)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		req := postmanRequest{}
+		if item.Request[0] == '"' {
+			// Request is just the URL.
+			if err := json.Unmarshal(item.Request, &req.URL.Raw); err != nil {
+				return fmt.Errorf("item %q: %s", name, err)
+			}
+		} else if err := json.Unmarshal(item.Request, &req); err != nil {
+			return fmt.Errorf("item %q: %s", name, err)
+		}
+		if req.Auth != nil {
+			itemAuth = req.Auth
+		}
+
+		test := postmanTest(name, req, itemAuth)
+		test.Description = description(item.Description)
+		if test.Description == "" {
+			test.Description = description(req.Description)
+		}
+		for _, e := range itemEvents {
+			switch e.Listen {
+			case "test":
+				translateScript(test, e.source())
+			case "prerequest":
+				if src := strings.TrimSpace(e.source()); src != "" {
+					test.TODO = append(test.TODO, "pre-request script: "+src)
+				}
+			}
+		}
+		result.addTest(name, test)
+	}
+	return nil
+}
+
+// pathVariableRe matches path variables like ":id" in an URL.
+var pathVariableRe = regexp.MustCompile(`/:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// postmanTest produces a test from the request req.
+func postmanTest(name string, req postmanRequest, auth *postmanAuth) *Test {
+	test := &Test{Name: name}
+	test.Request.Method = strings.ToUpper(req.Method)
+	if test.Request.Method == "" {
+		test.Request.Method = "GET"
+	}
+
+	// URL and path variables.
+	test.Request.URL = pathVariableRe.ReplaceAllString(
+		mapDynamicVariables(req.URL.Raw), "/{{$1}}")
+	for _, v := range req.URL.Variable {
+		if test.Variables == nil {
+			test.Variables = make(map[string]string)
+		}
+		test.Variables[v.Key] = mapDynamicVariables(v.value())
+	}
+
+	// Header.
+	for _, h := range req.Header {
+		if h.Disabled {
+			continue
+		}
+		if test.Request.Header == nil {
+			test.Request.Header = make(http.Header)
+		}
+		test.Request.Header.Add(h.Key, mapDynamicVariables(h.value()))
+	}
+
+	// Body.
+	if body := req.Body; body != nil {
+		switch body.Mode {
+		case "raw":
+			test.Request.Body = mapDynamicVariables(body.Raw)
+		case "urlencoded":
+			test.Request.Params = postmanParams(body.URLEncoded, test)
+			test.Request.ParamsAs = "body"
+		case "formdata":
+			test.Request.Params = postmanParams(body.FormData, test)
+			test.Request.ParamsAs = "multipart"
+		case "":
+		default:
+			test.TODO = append(test.TODO,
+				fmt.Sprintf("body of mode %q not imported", body.Mode))
+		}
+	}
+
+	// Authentication.
+	if auth != nil {
+		switch auth.Type {
+		case "noauth", "":
+		case "basic":
+			p := auth.params(auth.Basic)
+			test.Request.BasicAuthUser = p["username"]
+			test.Request.BasicAuthPass = p["password"]
+		case "bearer":
+			if test.Request.Header == nil {
+				test.Request.Header = make(http.Header)
+			}
+			token := auth.params(auth.Bearer)["token"]
+			test.Request.Header.Set("Authorization", "Bearer "+token)
+		default:
+			test.TODO = append(test.TODO,
+				fmt.Sprintf("authentication of type %q not imported", auth.Type))
+		}
+	}
+
+	return test
+}
+
+// postmanParams converts the urlencoded or formdata list kvs to
+// parameters. Files become @file: parameters.
+func postmanParams(kvs []postmanKV, test *Test) url.Values {
+	params := make(url.Values)
+	for _, kv := range kvs {
+		if kv.Disabled {
+			continue
+		}
+		if kv.Type != "file" {
+			params.Add(kv.Key, mapDynamicVariables(kv.value()))
+			continue
+		}
+		src, ok := kv.Src.(string)
+		if !ok || src == "" {
+			test.TODO = append(test.TODO,
+				fmt.Sprintf("file for parameter %q not imported", kv.Key))
+			continue
+		}
+		params.Add(kv.Key, "@file:"+src)
+	}
+	return params
+}
+
+// dynamicVariables maps Postman's dynamic variables to ht's special variables.
+var dynamicVariables = strings.NewReplacer(
+	"{{$guid}}", "{{RANDOM UUID}}",
+	"{{$randomUUID}}", "{{RANDOM UUID}}",
+	"{{$randomInt}}", "{{RANDOM NUMBER 0-1000}}",
+	"{{$randomEmail}}", "{{RANDOM EMAIL}}",
+	"{{$isoTimestamp}}", `{{NOW | "UTC" "2006-01-02T15:04:05.000Z"}}`,
+)
+
+func mapDynamicVariables(s string) string {
+	return dynamicVariables.Replace(s)
+}
+
+// ----------------------------------------------------------------------------
+// Test scripts
+
+var (
+	// pmTestRe matches the start of a pm.test("name", function () { block.
+	pmTestRe = regexp.MustCompile(`^pm\.test\(\s*(["'])(.*?)["']\s*,\s*(function\s*\(\s*\)|\(\s*\)\s*=>)\s*\{`)
+
+	// ignoredStatementRe matches statements which need no translation.
+	ignoredStatementRe = regexp.MustCompile(`^(|\}\)?\)?|//.*|(var|let|const) +jsonData *= *(pm\.response\.json\(\)|JSON\.parse\(responseBody\)))$`)
+)
+
+// A statementTranslator translates a matching statement of a test script.
+type statementTranslator struct {
+	re        *regexp.Regexp
+	translate func(test *Test, m []string)
+}
+
+// jsonValue is the prefix of a JSON value in an assertion.
+const jsonValue = `(?:pm\.response\.json\(\)|jsonData)\.([\w.\[\]]+)`
+
+// quoted matches a single or double quoted string.
+const quoted = `(?:"([^"]*)"|'([^']*)')`
+
+var statementTranslators = []statementTranslator{
+	{
+		regexp.MustCompile(`^pm\.response\.to\.have\.status\((\d+)\)$`),
+		func(test *Test, m []string) { addStatusCheck(test, m[1]) },
+	},
+	{
+		regexp.MustCompile(`^pm\.expect\(pm\.response\.code\)\.to\.(?:eql|equal|be\.equal)\((\d+)\)$`),
+		func(test *Test, m []string) { addStatusCheck(test, m[1]) },
+	},
+	{
+		regexp.MustCompile(`^tests\[` + quoted + `\]\s*=\s*responseCode\.code\s*===?\s*(\d+)$`),
+		func(test *Test, m []string) { addStatusCheck(test, m[3]) },
+	},
+	{
+		regexp.MustCompile(`^pm\.response\.to\.be\.ok$`),
+		func(test *Test, m []string) { addStatusCheck(test, "200") },
+	},
+	{
+		regexp.MustCompile(`^pm\.response\.to\.be\.success$`),
+		func(test *Test, m []string) { addStatusCheck(test, "2") },
+	},
+	{
+		regexp.MustCompile(`^pm\.response\.to\.have\.header\(` + quoted + `\)$`),
+		func(test *Test, m []string) {
+			test.addCheck("Header", "Header", m[1]+m[2])
+		},
+	},
+	{
+		regexp.MustCompile(`^pm\.response\.to\.have\.header\(` + quoted + `\s*,\s*` + quoted + `\)$`),
+		func(test *Test, m []string) {
+			test.addCheck("Header", "Header", m[1]+m[2], "Equals", m[3]+m[4])
+		},
+	},
+	{
+		regexp.MustCompile(`^pm\.expect\(pm\.response\.text\(\)\)\.to\.include\(` + quoted + `\)$`),
+		func(test *Test, m []string) { test.addCheck("Body", "Contains", m[1]+m[2]) },
+	},
+	{
+		regexp.MustCompile(`^tests\[` + quoted + `\]\s*=\s*responseBody\.has\(` + quoted + `\)$`),
+		func(test *Test, m []string) { test.addCheck("Body", "Contains", m[3]+m[4]) },
+	},
+	{
+		regexp.MustCompile(`^pm\.response\.to\.have\.body\(` + quoted + `\)$`),
+		func(test *Test, m []string) { test.addCheck("Body", "Equals", m[1]+m[2]) },
+	},
+	{
+		regexp.MustCompile(`^pm\.expect\(pm\.response\.responseTime\)\.to\.be\.below\((\d+)\)$`),
+		func(test *Test, m []string) {
+			ms, _ := strconv.Atoi(m[1])
+			lower := time.Duration(ms) * time.Millisecond
+			test.addCheck("ResponseTime", "Lower", lower.String())
+		},
+	},
+	{
+		regexp.MustCompile(`^pm\.expect\(` + jsonValue + `\)\.to\.(?:eql|equal|be\.equal)\((.+)\)$`),
+		func(test *Test, m []string) {
+			test.addCheck("JSON", "Element", jsonElement(m[1]),
+				"Equals", jsonLiteral(m[2]))
+		},
+	},
+	{
+		regexp.MustCompile(`^pm\.expect\(` + jsonValue + `\)\.to\.exist$`),
+		func(test *Test, m []string) {
+			test.addCheck("JSON", "Element", jsonElement(m[1]))
+		},
+	},
+	{
+		regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables)\.set|postman\.setEnvironmentVariable|postman\.setGlobalVariable)\(` +
+			quoted + `\s*,\s*` + jsonValue + `\)$`),
+		func(test *Test, m []string) {
+			if test.VarEx == nil {
+				test.VarEx = make(map[string]map[string]interface{})
+			}
+			test.VarEx[m[1]+m[2]] = map[string]interface{}{
+				"Extractor": "JSONExtractor",
+				"Element":   jsonElement(m[3]),
+			}
+		},
+	},
+}
+
+// translateScript translates the statements in the test script src to
+// checks and extractors of test; untranslatable statements are added to
+// the TODOs of test.
+func translateScript(test *Test, src string) {
+	for _, line := range strings.Split(src, "\n") {
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			for {
+				m := pmTestRe.FindStringIndex(stmt)
+				if m == nil {
+					break
+				}
+				stmt = strings.TrimSpace(stmt[m[1]:])
+			}
+			if ignoredStatementRe.MatchString(stmt) {
+				continue
+			}
+			translated := false
+			for _, st := range statementTranslators {
+				if m := st.re.FindStringSubmatch(stmt); m != nil {
+					st.translate(test, m)
+					translated = true
+					break
+				}
+			}
+			if !translated {
+				test.TODO = append(test.TODO, "test script: "+stmt)
+			}
+		}
+	}
+}
+
+// addCheck of the given type with the field/value pairs fv to test.
+func (test *Test) addCheck(typ string, fv ...string) {
+	check := map[string]interface{}{"Check": typ}
+	for i := 0; i+1 < len(fv); i += 2 {
+		check[fv[i]] = fv[i+1]
+	}
+	test.Checks = append(test.Checks, check)
+}
+
+func addStatusCheck(test *Test, code string) {
+	expect, _ := strconv.Atoi(code)
+	test.Checks = append(test.Checks,
+		map[string]interface{}{"Check": "StatusCode", "Expect": expect})
+}
+
+// jsonElement converts a JavaScript element path like data[0].id to
+// the ht element selector data.0.id.
+func jsonElement(path string) string {
+	path = strings.Replace(path, "[", ".", -1)
+	path = strings.Replace(path, "]", "", -1)
+	return strings.Trim(path, ".")
+}
+
+// jsonLiteral converts the JavaScript literal s to its JSON form which is
+// used in the flattened JSON map checked by the JSON check.
+func jsonLiteral(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		b, _ := json.Marshal(s[1 : len(s)-1])
+		return string(b)
+	}
+	return s
+}
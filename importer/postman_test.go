@@ -0,0 +1,115 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/suite"
+)
+
+func TestPostman(t *testing.T) {
+	file, err := os.Open("testdata/collection.postman.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := Postman(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if result.Filename != "shop_api.suite" || result.Suite.Name != "Shop API" {
+		t.Errorf("Got suite %q named %q", result.Filename, result.Suite.Name)
+	}
+	if got := result.Suite.Variables["base"]; got != "http://localhost:8080" {
+		t.Errorf("Got base=%q", got)
+	}
+	want := []Element{{"Login.ht"}, {"Products_-_Get_Product.ht"},
+		{"Products_-_Upload_Image.ht"}}
+	if !reflect.DeepEqual(result.Suite.Main, want) {
+		t.Errorf("Got Main=%v", result.Suite.Main)
+	}
+
+	login := result.Tests["Login.ht"]
+	if login.Request.Method != "POST" || login.Request.ParamsAs != "body" ||
+		!reflect.DeepEqual(login.Request.Params, url.Values{"user": {"joe"}}) ||
+		login.Request.Header.Get("X-Request-Id") != "{{RANDOM UUID}}" ||
+		login.Request.Header.Get("Authorization") != "" {
+		t.Errorf("Bad login request %#v", login.Request)
+	}
+	if len(login.Checks) != 1 || login.Checks[0]["Expect"] != 200 {
+		t.Errorf("Bad login checks %v", login.Checks)
+	}
+	if ex := login.VarEx["session"]; ex["Element"] != "data.session" {
+		t.Errorf("Bad extractor %v", login.VarEx)
+	}
+	if len(login.TODO) != 0 {
+		t.Errorf("Unexpected TODOs %v", login.TODO)
+	}
+
+	product := result.Tests["Products_-_Get_Product.ht"]
+	if product.Request.URL != "{{base}}/products/{{id}}?full=1" ||
+		product.Variables["id"] != "42" ||
+		product.Request.Header.Get("Authorization") != "Bearer {{apikey}}" {
+		t.Errorf("Bad product request %#v", product.Request)
+	}
+	checks := []string{}
+	for _, c := range product.Checks {
+		checks = append(checks, fmt.Sprintf("%s %v", c["Check"], c))
+	}
+	sort.Strings(checks)
+	wantChecks := []string{
+		`Body map[Check:Body Contains:Chair]`,
+		`Header map[Check:Header Header:Content-Type]`,
+		`JSON map[Check:JSON Element:items.0.name Equals:"Chair"]`,
+		`ResponseTime map[Check:ResponseTime Lower:250ms]`,
+	}
+	if !reflect.DeepEqual(checks, wantChecks) {
+		t.Errorf("Got checks %v", checks)
+	}
+	if len(product.TODO) != 2 ||
+		!strings.Contains(product.TODO[0], "console.log") ||
+		!strings.HasPrefix(product.TODO[1], "pre-request script") {
+		t.Errorf("Got TODOs %q", product.TODO)
+	}
+
+	upload := result.Tests["Products_-_Upload_Image.ht"]
+	if upload.Request.BasicAuthUser != "admin" || upload.Request.ParamsAs != "multipart" ||
+		upload.Request.Params.Get("image") != "@file:img/front.png" {
+		t.Errorf("Bad upload request %#v", upload.Request)
+	}
+
+	// The generated files must be loadable.
+	files, err := result.Files()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !bytes.HasPrefix(files["Products_-_Get_Product.ht"], []byte("// TODO: test script: console.log")) {
+		t.Errorf("Missing TODO comment in\n%s", files["Products_-_Get_Product.ht"])
+	}
+	archive := &bytes.Buffer{}
+	for name, data := range files {
+		fmt.Fprintf(archive, "# %s\n%s\n", name, data)
+	}
+	fs, err := suite.NewFileSystem(archive.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs, err := suite.LoadRawSuite(result.Filename, fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.Validate(nil); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -63,6 +63,7 @@ func (p *hjsonParser) peek(offs int) byte {
 
 var escapee = map[byte]byte{
 	'"':  '"',
+	'\'': '\'',
 	'\\': '\\',
 	'/':  '/',
 	'b':  '\b',
@@ -78,11 +79,13 @@ func (p *hjsonParser) readString() (string, error) {
 	res := new(bytes.Buffer)
 
 	// When parsing for string values, we must look for " and \ characters.
-	if p.ch != '"' {
+	// Like in JSON5 strings may be enclosed in single quotes.
+	quote := p.ch
+	if quote != '"' && quote != '\'' {
 		return "", p.errAt("Bad string")
 	}
 	for p.next() {
-		if p.ch == '"' {
+		if p.ch == quote {
 			p.next()
 			return res.String(), nil
 		}
@@ -193,7 +196,7 @@ func (p *hjsonParser) readKeyname() (string, error) {
 	// quotes for keys are optional in Hjson
 	// unless they include {}[],: or whitespace.
 
-	if p.ch == '"' {
+	if p.ch == '"' || p.ch == '\'' {
 		return p.readString()
 	}
 
@@ -298,6 +301,9 @@ func (p *hjsonParser) readTfnns() (interface{}, error) {
 						return n, nil
 					}
 				}
+				if n, err := tryParseJSON5Number(value.Bytes()); err == nil {
+					return n, nil
+				}
 			}
 			if isEol {
 				// remove any whitespace at the end (ignored in quoteless strings)
@@ -407,6 +413,11 @@ func (p *hjsonParser) readValue() (interface{}, error) {
 		return p.readArray()
 	case '"':
 		return p.readString()
+	case '\'':
+		if p.peek(0) == '\'' && p.peek(1) == '\'' {
+			return p.readTfnns() // a multiline string
+		}
+		return p.readString()
 	default:
 		return p.readTfnns()
 	}
@@ -43,10 +43,14 @@ func fixJSON(data []byte) []byte {
 	return data
 }
 
+// json5Valid lists the fail tests which are valid JSON5 (hex numbers and
+// single quoted strings) and thus parse since JSON5 is accepted.
+var json5Valid = map[string]bool{"failJSON14": true, "failJSON24": true}
+
 func run(t *testing.T, file string) {
 	name := strings.TrimSuffix(file, "_test"+filepath.Ext(file))
 	t.Logf("running %s", name)
-	shouldFail := strings.HasPrefix(file, "fail")
+	shouldFail := strings.HasPrefix(file, "fail") && !json5Valid[name]
 
 	testContent := getTestContent(name)
 	var data interface{}
@@ -105,6 +109,31 @@ func TestHjson(t *testing.T) {
 	}
 }
 
+func TestJSON5(t *testing.T) {
+	for i, tc := range []struct {
+		in   string
+		want string
+	}{
+		{`{"hex": 0x14}`, `{"hex":20}`},
+		{`['single quote']`, `["single quote"]`},
+		{`{'a': 'it\'s', b: "x"}`, `{"a":"it's","b":"x"}`},
+		{`{a: +3, b: +1.5, c: -0xff}`, `{"a":3,"b":1.5,"c":-255}`},
+		{`{a: 1, b: [1, 2,], /* comment */ c: 'x', }`, `{"a":1,"b":[1,2],"c":"x"}`},
+		{"{a: '''\n  multi\n  line\n  '''}", `{"a":"multi\nline"}`},
+		{"{\na: 0x1F and more\n}", `{"a":"0x1F and more"}`},
+	} {
+		var data interface{}
+		if err := Unmarshal([]byte(tc.in), &data); err != nil {
+			t.Errorf("%d. %s: unexpected error %s", i, tc.in, err)
+			continue
+		}
+		got, _ := json.Marshal(data)
+		if string(got) != tc.want {
+			t.Errorf("%d. %s: got %s, want %s", i, tc.in, got, tc.want)
+		}
+	}
+}
+
 func TestIntegers(t *testing.T) {
 	for i, tc := range []struct {
 		in   string
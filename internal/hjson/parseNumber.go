@@ -1,9 +1,11 @@
 package hjson
 
 import (
+	"bytes"
 	"errors"
 	"math"
 	"strconv"
+	"strings"
 )
 
 type parseNumber struct {
@@ -128,3 +130,29 @@ func tryParseNumber(text []byte, stopAtNext bool) (interface{}, error) {
 	}
 	return number, nil
 }
+
+// tryParseJSON5Number parses the number formats allowed in JSON5 but not
+// in JSON: An explicit plus sign and hexadecimal integers, e.g. +1.5 or
+// 0x1F. Numbers with a leading or trailing decimal point like .5 stay
+// quoteless strings as in Hjson.
+func tryParseJSON5Number(text []byte) (interface{}, error) {
+	s := string(bytes.TrimSpace(text))
+	sign := ""
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		sign, s = s[:1], s[1:]
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		u, err := strconv.ParseUint(s[2:], 16, 63)
+		if err != nil {
+			return 0, errors.New("Invalid number")
+		}
+		if sign == "-" {
+			return -int64(u), nil
+		}
+		return int64(u), nil
+	}
+	if sign == "-" {
+		s = "-" + s
+	}
+	return tryParseNumber([]byte(s), false)
+}
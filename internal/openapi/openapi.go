@@ -0,0 +1,76 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package openapi provides access to OpenAPI 3 specifications shared by
+// the OpenAPIResponse check and the importer.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/vdobler/ht/internal/yaml"
+)
+
+// Spec is a decoded OpenAPI 3 specification.
+type Spec struct {
+	// Root is the top-level object of the specification.
+	Root map[string]interface{}
+}
+
+// Decode the specification data in JSON or YAML format.
+func Decode(data []byte) (*Spec, error) {
+	var doc interface{}
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		doc, err = yaml.Unmarshal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("spec is not an object")
+	}
+	return &Spec{Root: root}, nil
+}
+
+// Object returns v as an object resolving local references. Non-objects
+// and unresolvable or too deeply chained references yield nil.
+func (spec *Spec) Object(v interface{}) map[string]interface{} {
+	for i := 0; i < 10; i++ {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return obj
+		}
+		v = spec.Resolve(ref)
+	}
+	return nil
+}
+
+// Resolve the local reference ref like "#/components/schemas/Pet".
+func (spec *Spec) Resolve(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var v interface{} = spec.Root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.Replace(part, "~1", "/", -1)
+		part = strings.Replace(part, "~0", "~", -1)
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[part]
+	}
+	return v
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if spec.Root["openapi"] != "3.0.0" {
+		t.Errorf("Got openapi=%v", spec.Root["openapi"])
+	}
+
+	spec, err = Decode([]byte(` {"openapi": "3.0.1", "paths": {}}`))
+	if err != nil || spec.Root["openapi"] != "3.0.1" {
+		t.Errorf("Got %v, %v", spec, err)
+	}
+
+	for _, bad := range []string{`{"openapi": `, `[1, 2]`, `- a`} {
+		if _, err := Decode([]byte(bad)); err == nil {
+			t.Errorf("Missing error for %q", bad)
+		}
+	}
+}
+
+func TestObject(t *testing.T) {
+	spec, err := Decode([]byte(`{
+  "paths": {"/a/{b}": {"$ref": "#/components/items/~1a~1~0b~1"}},
+  "components": {
+    "items": {"/a/~b/": {"$ref": "#/components/real"}},
+    "real": {"get": {}},
+    "loop": {"$ref": "#/components/loop"},
+    "external": {"$ref": "other.yaml#/foo"}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	item := spec.Object(spec.Object(spec.Root["paths"])["/a/{b}"])
+	if _, ok := item["get"]; !ok {
+		t.Errorf("Got %v", item)
+	}
+
+	components := spec.Object(spec.Root["components"])
+	for _, name := range []string{"loop", "external", "missing"} {
+		if obj := spec.Object(components[name]); obj != nil {
+			t.Errorf("%s: got %v", name, obj)
+		}
+	}
+	if obj := spec.Object("string"); obj != nil {
+		t.Errorf("Got %v", obj)
+	}
+}
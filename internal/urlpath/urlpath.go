@@ -0,0 +1,22 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package urlpath contains helpers for URL paths shared by suite and
+// recorder.
+package urlpath
+
+import "strings"
+
+// SingleJoiningSlash joins a and b with exactly one slash.
+func SingleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
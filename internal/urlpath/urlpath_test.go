@@ -0,0 +1,24 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package urlpath
+
+import "testing"
+
+func TestSingleJoiningSlash(t *testing.T) {
+	for _, tc := range []struct {
+		a, b, want string
+	}{
+		{"http://host", "path", "http://host/path"},
+		{"http://host/", "path", "http://host/path"},
+		{"http://host", "/path", "http://host/path"},
+		{"http://host/", "/path", "http://host/path"},
+		{"", "", "/"},
+	} {
+		if got := SingleJoiningSlash(tc.a, tc.b); got != tc.want {
+			t.Errorf("SingleJoiningSlash(%q, %q) = %q, want %q",
+				tc.a, tc.b, got, tc.want)
+		}
+	}
+}
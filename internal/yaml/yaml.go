@@ -0,0 +1,560 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package yaml implements a decoder for the subset of YAML typically used
+// in configuration files and API specifications:
+//   - block mappings and block sequences,
+//   - plain, single and double quoted scalars,
+//   - literal (|) and folded (>) block scalars,
+//   - flow sequences [a, b] and flow mappings {a: 1} and
+//   - comments.
+// Anchors, aliases, tags, complex keys and multiple documents are not
+// supported.
+//
+// Decoded values use the same types as encoding/json when decoding into
+// an interface{}: map[string]interface{}, []interface{}, string, float64,
+// bool and nil.
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// line is a non-empty line of the input with comments removed.
+type line struct {
+	no     int    // line number, 1 based
+	indent int    // number of leading spaces
+	text   string // content without indentation
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// Unmarshal decodes the YAML document data.
+func Unmarshal(data []byte) (interface{}, error) {
+	p := &parser{}
+	scalar := -1 // indent of the key introducing a block scalar, -1 outside
+	for i, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimRight(l, " \t\r")
+		text := strings.TrimLeft(l, " ")
+		indent := len(l) - len(text)
+		if scalar >= 0 && (text == "" || indent > scalar) {
+			// Content of a block scalar is taken verbatim.
+			p.lines = append(p.lines, line{no: i + 1, indent: indent, text: text})
+			continue
+		}
+		scalar = -1
+		if l == "---" || strings.HasPrefix(l, "--- #") ||
+			(len(p.lines) == 0 && strings.HasPrefix(l, "%")) {
+			continue // document marker or directive
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tab in indentation", i+1)
+		}
+		if _, value, ok := splitKey(stripComment(text)); ok && value != "" &&
+			(value[0] == '|' || value[0] == '>') {
+			scalar = indent
+		}
+		p.lines = append(p.lines, line{no: i + 1, indent: indent, text: text})
+	}
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.parseNode(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content %q", p.lines[p.pos].text)
+	}
+	return v, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	no := 0
+	if p.pos < len(p.lines) {
+		no = p.lines[p.pos].no
+	} else if len(p.lines) > 0 {
+		no = p.lines[len(p.lines)-1].no
+	}
+	return fmt.Errorf("yaml: line %d: %s", no, fmt.Sprintf(format, args...))
+}
+
+// skipBlank advances over empty and comment lines.
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) {
+		t := stripComment(p.lines[p.pos].text)
+		if t != "" {
+			return
+		}
+		p.pos++
+	}
+}
+
+// stripComment removes a trailing comment from s.
+func stripComment(s string) string {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\\' && inDouble:
+			i++
+		case c == '#' && !inSingle && !inDouble && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+// parseNode parses the block node starting at the current line which is
+// indented by indent.
+func (p *parser) parseNode(indent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	text := stripComment(p.lines[p.pos].text)
+	if text == "-" || strings.HasPrefix(text, "- ") {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitKey(text); ok {
+		return p.parseMapping(indent)
+	}
+	// A multi-line plain or flow scalar.
+	p.pos++
+	for p.pos < len(p.lines) && p.lines[p.pos].indent >= indent {
+		t := stripComment(p.lines[p.pos].text)
+		if t != "" {
+			text += " " + t
+		}
+		p.pos++
+	}
+	return parseScalar(text)
+}
+
+// parseSequence parses a block sequence whose dashes are at indent.
+func (p *parser) parseSequence(indent int) ([]interface{}, error) {
+	seq := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+			return seq, nil
+		}
+		l := p.lines[p.pos]
+		text := stripComment(l.text)
+		if text != "-" && !strings.HasPrefix(text, "- ") {
+			return seq, nil
+		}
+		rest := strings.TrimLeft(text[1:], " ")
+		if rest == "" {
+			// Item on the following lines.
+			p.pos++
+			p.skipBlank()
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				seq = append(seq, nil)
+				continue
+			}
+			v, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+		// Item starts on the same line: Re-parse the rest as a line
+		// of its own with a larger indentation.
+		offset := len(l.text) - len(strings.TrimLeft(l.text[1:], " "))
+		p.lines[p.pos] = line{no: l.no, indent: indent + offset, text: l.text[offset:]}
+		v, err := p.parseNode(indent + offset)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+}
+
+// parseMapping parses a block mapping whose keys are at indent.
+func (p *parser) parseMapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+			return m, nil
+		}
+		text := stripComment(p.lines[p.pos].text)
+		key, value, ok := splitKey(text)
+		if !ok {
+			return nil, p.errorf("expected key: value, got %q", text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+
+		switch {
+		case value == "":
+			p.skipBlank()
+			if p.pos < len(p.lines) {
+				next := p.lines[p.pos]
+				nt := stripComment(next.text)
+				isSeq := nt == "-" || strings.HasPrefix(nt, "- ")
+				if next.indent > indent || (next.indent == indent && isSeq) {
+					v, err := p.parseNode(next.indent)
+					if err != nil {
+						return nil, err
+					}
+					m[key] = v
+					continue
+				}
+			}
+			m[key] = nil
+		case value[0] == '|' || value[0] == '>':
+			m[key] = p.parseBlockScalar(value, indent)
+		default:
+			// Flow collections and quoted strings may span lines.
+			for p.pos < len(p.lines) && p.lines[p.pos].indent > indent && unbalanced(value) {
+				value += " " + stripComment(p.lines[p.pos].text)
+				p.pos++
+			}
+			v, err := parseScalar(value)
+			if err != nil {
+				return nil, p.errorf("%s", err)
+			}
+			m[key] = v
+		}
+	}
+}
+
+// unbalanced reports whether s is an unfinished flow collection.
+func unbalanced(s string) bool {
+	if s[0] != '[' && s[0] != '{' {
+		return false
+	}
+	depth := 0
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\\' && inDouble:
+			i++
+		case inSingle || inDouble:
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// parseBlockScalar parses the literal or folded block scalar introduced by
+// header (e.g. "|" or ">-") in a node indented by indent.
+func (p *parser) parseBlockScalar(header string, indent int) string {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	if len(header) > 1 && (header[1] == '-' || header[1] == '+') {
+		chomp = header[1]
+	}
+
+	// Collect raw lines including empty ones; p.lines lacks empty lines
+	// so use the line numbers to restore them.
+	var lines []string
+	blockIndent := -1
+	lastNo := 0
+	if p.pos > 0 {
+		lastNo = p.lines[p.pos-1].no
+	}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text != "" && l.indent <= indent {
+			break
+		}
+		if blockIndent == -1 && l.text != "" {
+			blockIndent = l.indent
+		}
+		for n := lastNo + 1; n < l.no; n++ {
+			lines = append(lines, "")
+		}
+		if l.text == "" {
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, strings.Repeat(" ", l.indent-blockIndent)+l.text)
+		}
+		lastNo = l.no
+		p.pos++
+	}
+
+	// Trailing empty lines are subject to chomping.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var s string
+	if folded {
+		buf := ""
+		for i, l := range lines {
+			switch {
+			case i == 0:
+				buf = l
+			case l == "":
+				buf += "\n"
+			case lines[i-1] == "":
+				buf += l
+			case strings.HasPrefix(l, " ") || strings.HasPrefix(lines[i-1], " "):
+				buf += "\n" + l
+			default:
+				buf += " " + l
+			}
+		}
+		s = buf
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case '-':
+		return s
+	case '+':
+		return s + "\n" + strings.Repeat("\n", trailing)
+	}
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
+
+// splitKey splits text of the form "key: value" or "key:".
+func splitKey(text string) (key, value string, ok bool) {
+	if text == "" {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end == -1 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		k, err := parseQuoted(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(rest), true
+	}
+	if text[0] == '[' || text[0] == '{' || text[0] == '-' && (len(text) == 1 || text[1] == ' ') {
+		return "", "", false
+	}
+	i := strings.Index(text, ": ")
+	if i == -1 {
+		if strings.HasSuffix(text, ":") {
+			return strings.TrimSpace(text[:len(text)-1]), "", true
+		}
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+}
+
+// closingQuote returns the index of the quote closing the quoted string
+// at the start of s or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseQuoted unquotes the single or double quoted string s.
+func parseQuoted(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// parseScalar parses a single line scalar or flow collection.
+func parseScalar(s string) (interface{}, error) {
+	fp := &flowParser{s: s}
+	v, err := fp.value(false)
+	if err != nil {
+		return nil, err
+	}
+	fp.space()
+	if fp.i < len(fp.s) {
+		return nil, fmt.Errorf("unexpected %q after value", fp.s[fp.i:])
+	}
+	return v, nil
+}
+
+// flowParser parses flow style scalars and collections.
+type flowParser struct {
+	s string
+	i int
+}
+
+func (fp *flowParser) space() {
+	for fp.i < len(fp.s) && fp.s[fp.i] == ' ' {
+		fp.i++
+	}
+}
+
+// value parses the next value; inFlow indicates that the value is inside
+// a flow collection where ',', ']' and '}' terminate plain scalars.
+func (fp *flowParser) value(inFlow bool) (interface{}, error) {
+	fp.space()
+	if fp.i >= len(fp.s) {
+		return nil, nil
+	}
+	switch fp.s[fp.i] {
+	case '[':
+		fp.i++
+		seq := []interface{}{}
+		for {
+			fp.space()
+			if fp.i < len(fp.s) && fp.s[fp.i] == ']' {
+				fp.i++
+				return seq, nil
+			}
+			v, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err := fp.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		fp.i++
+		m := make(map[string]interface{})
+		for {
+			fp.space()
+			if fp.i < len(fp.s) && fp.s[fp.i] == '}' {
+				fp.i++
+				return m, nil
+			}
+			k, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			fp.space()
+			if fp.i >= len(fp.s) || fp.s[fp.i] != ':' {
+				return nil, fmt.Errorf("missing ':' in flow mapping")
+			}
+			fp.i++
+			v, err := fp.value(true)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+			if err := fp.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		end := closingQuote(fp.s[fp.i:])
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated string %s", fp.s[fp.i:])
+		}
+		s, err := parseQuoted(fp.s[fp.i : fp.i+end+1])
+		fp.i += end + 1
+		return s, err
+	}
+
+	start := fp.i
+	for fp.i < len(fp.s) {
+		c := fp.s[fp.i]
+		if inFlow && (c == ',' || c == ']' || c == '}' ||
+			c == ':' && (fp.i+1 == len(fp.s) || fp.s[fp.i+1] == ' ')) {
+			break
+		}
+		fp.i++
+	}
+	return plainScalar(strings.TrimSpace(fp.s[start:fp.i])), nil
+}
+
+// separator consumes a ',' or the closing delimiter.
+func (fp *flowParser) separator(closing byte) error {
+	fp.space()
+	if fp.i >= len(fp.s) {
+		return fmt.Errorf("missing '%c'", closing)
+	}
+	switch fp.s[fp.i] {
+	case ',':
+		fp.i++
+		return nil
+	case closing:
+		return nil
+	}
+	return fmt.Errorf("unexpected %q in flow collection", fp.s[fp.i:])
+}
+
+// plainScalar resolves the type of the plain scalar s.
+func plainScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if c := s[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		if n, ok := parseInt(s); ok {
+			return float64(n)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// parseInt parses s as a decimal integer or, with a prefix of 0x or 0o,
+// as a hexadecimal or octal integer. Unlike strconv.ParseInt with base 0
+// a leading zero does not make s octal: "010" is 10.
+func parseInt(s string) (int64, bool) {
+	sign, digits := "", s
+	if digits[0] == '-' || digits[0] == '+' {
+		sign, digits = digits[:1], digits[1:]
+	}
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x':
+			base, digits = 16, digits[2:]
+		case 'o':
+			base, digits = 8, digits[2:]
+		}
+	}
+	if digits == "" || digits[0] == '-' || digits[0] == '+' {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(sign+digits, base, 64)
+	return n, err == nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yaml
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var unmarshalTests = []struct {
+	yaml string
+	want string // JSON encoding of the result
+}{
+	{"", `null`},
+	{"foo", `"foo"`},
+	{"a: 1\nb: true\nc: ~\nd: hello world", `{"a":1,"b":true,"c":null,"d":"hello world"}`},
+	{"a: '1'\nb: \"x\\ty\"\nc: 'it''s'", `{"a":"1","b":"x\ty","c":"it's"}`},
+	{"# comment\na: b # trailing\nc: 'd # e'", `{"a":"b","c":"d # e"}`},
+	{"---\na:\n  b:\n    c: 1\n  d: 2\ne: 3", `{"a":{"b":{"c":1},"d":2},"e":3}`},
+	{"- 1\n- two\n-\n  - x\n  - y", `[1,"two",["x","y"]]`},
+	{"list:\n- a\n- b\nnext: c", `{"list":["a","b"],"next":"c"}`},
+	{"list:\n  - name: a\n    in: path\n  - name: b", `{"list":[{"in":"path","name":"a"},{"name":"b"}]}`},
+	{"a: [1, 'x', {b: c}]\nd: {e: [], f: {}}", `{"a":[1,"x",{"b":"c"}],"d":{"e":[],"f":{}}}`},
+	{"a: [1,\n  2]", `{"a":[1,2]}`},
+	{"'200':\n  ok: yes\n\"/x/{id}\": 1", `{"/x/{id}":1,"200":{"ok":"yes"}}`},
+	{"url: http://example.org/a:b", `{"url":"http://example.org/a:b"}`},
+	{"a: |\n  line 1\n\n  line 2\nb: x", `{"a":"line 1\n\nline 2\n","b":"x"}`},
+	{"a: >-\n  folded\n  text\n\n  para\n", `{"a":"folded text\npara"}`},
+	{"a: |\n  x\n\n  ---\n  %y\n    z\nb: 1", `{"a":"x\n\n---\n%y\n  z\n","b":1}`},
+	{"%YAML 1.2\n--- # doc\na: 1", `{"a":1}`},
+	{"a: 010\nb: 0x1F\nc: 0o17\nd: -007\ne: 0b1", `{"a":10,"b":31,"c":15,"d":-7,"e":"0b1"}`},
+	{"n: -1.5e2\nh: 0x1F\nv: 1.2.3", `{"h":31,"n":-150,"v":"1.2.3"}`},
+}
+
+func TestUnmarshal(t *testing.T) {
+	for i, tc := range unmarshalTests {
+		v, err := Unmarshal([]byte(tc.yaml))
+		if err != nil {
+			t.Errorf("%d. Unexpected error: %s", i, err)
+			continue
+		}
+		got, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("%d. Unexpected error: %s", i, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%d. Got %s, want %s", i, got, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for i, src := range []string{
+		"a: 1\na: 2",
+		"a: [1, 2",
+		"a:\n\tb: 1",
+		"a: 'open",
+	} {
+		if _, err := Unmarshal([]byte(src)); err == nil {
+			t.Errorf("%d. Missing error for %q", i, src)
+		}
+	}
+}
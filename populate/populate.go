@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -162,8 +163,9 @@ func setDuration(dst, src reflect.Value, elem string) error {
 		return nil
 	case reflect.Float64, reflect.Float32:
 		dst.SetInt(int64(1e9 * src.Float())) // nanoseconds
+		return nil
 	case reflect.String:
-		d, err := time.ParseDuration(src.String())
+		d, err := parseDuration(src.String())
 		if err != nil {
 			return fmt.Errorf("cannot set %s <Duration> to %s", elem, src.String())
 		}
@@ -175,6 +177,28 @@ func setDuration(dst, src reflect.Value, elem string) error {
 		elem, src.Interface(), src.Kind())
 }
 
+// parseDuration parses s like time.ParseDuration but allows an additional
+// leading number of days, e.g. "2d", "1.5d" or "1d12h30m".
+func parseDuration(s string) (time.Duration, error) {
+	i := strings.Index(s, "d")
+	if i == -1 {
+		return time.ParseDuration(s)
+	}
+	days, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("bad number of days in duration %q", s)
+	}
+	d := time.Duration(days * float64(24*time.Hour))
+	if rest := s[i+1:]; rest != "" {
+		r, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, err
+		}
+		d += r
+	}
+	return d, nil
+}
+
 func setUint(dst, src reflect.Value, elem string) error {
 	panic("not implemented")
 }
@@ -268,3 +268,47 @@ func TestEmbedding(t *testing.T) {
 		t.Errorf("v.A=%d, want 124", v.A)
 	}
 }
+
+func TestDurationFormats(t *testing.T) {
+	for i, tc := range []struct {
+		in   interface{}
+		want time.Duration
+	}{
+		{"2m03s", 123 * time.Second},
+		{"123ms", 123 * time.Millisecond},
+		{"1500ms", 1500 * time.Millisecond},
+		{"2m30s", 150 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1.5s", 1500 * time.Millisecond},
+		{"1h2m3.5s", time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{"2d", 48 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"1d12h30m", 36*time.Hour + 30*time.Minute},
+		{float64(2), 2 * time.Second},
+		{float64(0.25), 250 * time.Millisecond},
+	} {
+		v := T{}
+		err := Strict(&v, map[string]interface{}{"Duration": tc.in})
+		if err != nil {
+			t.Errorf("%d. %v: unexpected error %s", i, tc.in, err)
+			continue
+		}
+		if v.Duration != tc.want {
+			t.Errorf("%d. %v: got %s, want %s", i, tc.in, v.Duration, tc.want)
+		}
+
+		// Round trip through the String representation.
+		w := T{}
+		err = Strict(&w, map[string]interface{}{"Duration": v.Duration.String()})
+		if err != nil || w.Duration != v.Duration {
+			t.Errorf("%d. round trip of %s: got %s, %v", i, v.Duration, w.Duration, err)
+		}
+	}
+
+	for _, bogus := range []string{"2 minutes", "xd", "-1d", "1d2x"} {
+		v := T{}
+		if err := Strict(&v, map[string]interface{}{"Duration": bogus}); err == nil {
+			t.Errorf("missing error for bogus duration %q", bogus)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/sha1"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -33,6 +34,7 @@ import (
 	"github.com/andybalholm/cascadia"
 	"github.com/vdobler/ht/fingerprint"
 	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/urlpath"
 	"github.com/vdobler/ht/sanitize"
 )
 
@@ -45,8 +47,9 @@ type Event struct {
 	Response     *httptest.ResponseRecorder // The recorded response.
 	RequestBody  string                     // The captured body.
 	ResponseBody string
-	Timestamp    time.Time // Timestamp when caputred.
-	Name         string    // Used during dumping.
+	Timestamp    time.Time     // Timestamp when the request was received.
+	Latency      time.Duration // Time taken by remote to answer the request.
+	Name         string        // Used during dumping.
 }
 
 // extractName tries to come up with a useful and representative name for
@@ -102,6 +105,68 @@ type Options struct {
 
 	// Rewrite determines what is rewritten.
 	Rewrite Rewriter
+
+	// ExtractJSON turns the top-level scalar fields of JSON request
+	// bodies into variables of the generated test. Without it JSON
+	// request bodies are just pretty printed.
+	ExtractJSON bool
+
+	// Redact lists patterns of sensitive request headers, cookies,
+	// parameters and JSON body fields. The values of all headers, cookies,
+	// parameters and fields whose name matches one of these patterns are
+	// replaced by the variable {{REDACTED}} in the generated tests.
+	Redact []*regexp.Regexp
+
+	// TLS makes the reverse proxy serve HTTPS instead of plain HTTP.
+	// The needed certificates are issued on the fly by a freshly
+	// generated CA whose certificate is printed on startup and which
+	// must be trusted by the browser.
+	TLS bool
+
+	// MaxBodySize limits the size of request and response bodies the
+	// reverse proxy reads into memory. Zero means ht.DefaultMaxBodySize,
+	// a negative value means unlimited. Requests and responses with
+	// larger bodies are rejected and not captured.
+	MaxBodySize int64
+
+	// Annotate is called for each event dumped by DumpEvents; its
+	// output becomes the Description of the generated test. If nil or
+	// if it returns the empty string a generic description is used.
+	Annotate func(Event) string
+}
+
+// maxBodySize returns the limit of the body size, a negative value means
+// unlimited.
+func (o Options) maxBodySize() int64 {
+	if o.MaxBodySize != 0 {
+		return o.MaxBodySize
+	}
+	return ht.DefaultMaxBodySize
+}
+
+// redactedValue replaces the values of sensitive data.
+const redactedValue = "{{REDACTED}}"
+
+// redacts reports whether the value of name must be redacted.
+func (o Options) redacts(name string) bool {
+	for _, re := range o.Redact {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValues replaces all values in vv whose name is redacted.
+func (o Options) redactValues(vv map[string][]string) {
+	for name, values := range vv {
+		if !o.redacts(name) {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
 }
 
 func (o Options) ignore(e Event) bool {
@@ -223,19 +288,36 @@ func StartReverseProxy(port string, remoteURL *url.URL, opts Options) error {
 
 	remote := remoteURL.Host
 
-	proxy := newSingleHostReverseProxy(remoteURL)
-	http.HandleFunc("/", handler(proxy, requests, opts.Rewrite))
+	proxy := newSingleHostReverseProxy(remoteURL, opts.maxBodySize())
+	http.HandleFunc("/", handler(proxy, requests, opts))
 	log.Println("Staring reverse proxy")
-	log.Printf("Proxying from http://recorder.ht%s to %s", port, remote)
-	return http.ListenAndServe(port, nil)
+	if !opts.TLS {
+		log.Printf("Proxying from http://recorder.ht%s to %s", port, remote)
+		return http.ListenAndServe(port, nil)
+	}
+
+	ca, err := newCertAuthority()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Certificates are issued by the following CA which must be trusted:\n%s", ca.pem)
+	server := &http.Server{
+		Addr:      port,
+		TLSConfig: &tls.Config{GetCertificate: ca.getCertificate},
+	}
+	log.Printf("Proxying from https://recorder.ht%s to %s", port, remote)
+	return server.ListenAndServeTLS("", "")
 }
 
-func newSingleHostReverseProxy(target *url.URL) *httputil.ReverseProxy {
+// newSingleHostReverseProxy returns a reverse proxy to target which reads
+// at most one byte more than max of the response body so that too large
+// responses can be detected without buffering them completely.
+func newSingleHostReverseProxy(target *url.URL, max int64) *httputil.ReverseProxy {
 	targetQuery := target.RawQuery
 	director := func(req *http.Request) {
 		req.URL.Scheme = target.Scheme
 		req.URL.Host = target.Host
-		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
+		req.URL.Path = urlpath.SingleJoiningSlash(target.Path, req.URL.Path)
 		if targetQuery == "" || req.URL.RawQuery == "" {
 			req.URL.RawQuery = targetQuery + req.URL.RawQuery
 		} else {
@@ -251,25 +333,36 @@ func newSingleHostReverseProxy(target *url.URL) *httputil.ReverseProxy {
 		req.Header.Del("If-Modified-Since")
 		req.Header.Del("If-None-Match")
 	}
-	return &httputil.ReverseProxy{Director: director}
+	proxy := &httputil.ReverseProxy{Director: director}
+	if max >= 0 {
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(resp.Body, max+1), resp.Body}
+			return nil
+		}
+	}
+	return proxy
 }
 
-func singleJoiningSlash(a, b string) string {
-	aslash := strings.HasSuffix(a, "/")
-	bslash := strings.HasPrefix(b, "/")
-	switch {
-	case aslash && bslash:
-		return a + b[1:]
-	case !aslash && !bslash:
-		return a + "/" + b
+// readBody reads r completely and reports whether r contained more than
+// max bytes. A negative max means unlimited.
+func readBody(r io.Reader, max int64) ([]byte, bool, error) {
+	if max < 0 {
+		body, err := ioutil.ReadAll(r)
+		return body, false, err
 	}
-	return a + b
+	body, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	return body, int64(len(body)) > max, err
 }
 
 // handler produces a http.HandlerFunc which routes the request via the
 // reverse proxy p, records the request and the response and sends these
-// to events.
-func handler(p *httputil.ReverseProxy, events chan Event, rewrite Rewriter) func(http.ResponseWriter, *http.Request) {
+// to events. Requests and responses with bodies larger than
+// opts.MaxBodySize are rejected and not sent to events.
+func handler(p *httputil.ReverseProxy, events chan Event, opts Options) func(http.ResponseWriter, *http.Request) {
+	rewrite, max := opts.Rewrite, opts.maxBodySize()
 
 	log.Printf("Rewriting %d\n", rewrite.what)
 	log.Printf("   %s  -->  %s\n", rewrite.remoteRe.String(), rewrite.remoteSub)
@@ -277,32 +370,47 @@ func handler(p *httputil.ReverseProxy, events chan Event, rewrite Rewriter) func
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Handling", r.URL.String())
 		rr := httptest.NewRecorder()
-		requestBody, err := ioutil.ReadAll(r.Body)
+		requestBody, tooLarge, err := readBody(r.Body, max)
 		if err != nil {
 			panic(err.Error()) // Harsh but what else?
 		}
+		if tooLarge {
+			log.Printf("Rejected %s: request body exceeds %d bytes", r.URL, max)
+			http.Error(w, fmt.Sprintf("ht recorder: request body exceeds %d bytes", max),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
 
 		fheader, fbody := rewrite.Request(r.Header, requestBody)
 		r.Header = fheader
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(fbody))
 
+		started := time.Now()
 		p.ServeHTTP(rr, r)
+		latency := time.Since(started)
 
 		// Read response body, transparently unzip if needed
-		var respBodyReader io.Reader
-		gzipped := false
-		if rr.HeaderMap.Get("Content-Encoding") == "gzip" {
-			respBodyReader, err = gzip.NewReader(rr.Body)
+		var body []byte
+		gzipped := rr.HeaderMap.Get("Content-Encoding") == "gzip"
+		tooLarge = max >= 0 && int64(rr.Body.Len()) > max
+		if !tooLarge {
+			var respBodyReader io.Reader = rr.Body
+			if gzipped {
+				respBodyReader, err = gzip.NewReader(rr.Body)
+				if err != nil {
+					panic(err) // TODO
+				}
+			}
+			body, tooLarge, err = readBody(respBodyReader, max)
 			if err != nil {
 				panic(err) // TODO
 			}
-			gzipped = true
-		} else {
-			respBodyReader = rr.Body
 		}
-		body, err := ioutil.ReadAll(respBodyReader)
-		if err != nil {
-			panic(err) // TODO
+		if tooLarge {
+			log.Printf("Rejected %s: response body exceeds %d bytes", r.URL, max)
+			http.Error(w, fmt.Sprintf("ht recorder: response body exceeds %d bytes", max),
+				http.StatusBadGateway)
+			return
 		}
 
 		events <- Event{
@@ -310,7 +418,8 @@ func handler(p *httputil.ReverseProxy, events chan Event, rewrite Rewriter) func
 			RequestBody:  string(requestBody),
 			Response:     rr,
 			ResponseBody: string(body),
-			Timestamp:    time.Now(),
+			Timestamp:    started,
+			Latency:      latency,
 		}
 
 		rheader, rbody := rewrite.Response(rr.HeaderMap, body)
@@ -369,7 +478,8 @@ type Test struct {
 	Description string   `json:",omitempty"`
 	BasedOn     []string `json:",omitempty"`
 	Request     ht.Request
-	Checks      ht.CheckList `json:",omitempty"`
+	Checks      ht.CheckList      `json:",omitempty"`
+	Variables   map[string]string `json:",omitempty"`
 }
 
 // Suite is a reduced version of ht.Suite suitable to serialization to JSON.
@@ -381,12 +491,27 @@ type Suite struct {
 }
 
 // DumpEvents writes events to directory, it extracts common request headers.
-func DumpEvents(events []Event, directory string, suitename string) error {
+// The time and latency of the original request are recorded in the
+// variables RECORDED_AT and RECORDED_LATENCY of each generated test.
+func DumpEvents(events []Event, directory string, suitename string, opts Options) error {
 	err := os.MkdirAll(directory, 0777)
 	if err != nil {
 		return err
 	}
 
+	// Redact headers before they are distributed to the mixin and tests.
+	// Cookies are redacted individually below.
+	for _, e := range events {
+		for h, vv := range e.Request.Header {
+			if h == "Cookie" || !opts.redacts(h) {
+				continue
+			}
+			for i := range vv {
+				vv[i] = redactedValue
+			}
+		}
+	}
+
 	// extract all common headers into mixin
 	commonHeaders := ExtractCommonRequestHeaders(events)
 	commonHeadersName := "common-headers.mixin"
@@ -410,26 +535,42 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 			"HOSTNAME": remoteHost,
 		},
 	}
+	if len(opts.Redact) > 0 {
+		suite.Variables["REDACTED"] = ""
+	}
 
 	for _, e := range events {
+		// Annotate the unmodified event.
+		description := ""
+		if opts.Annotate != nil {
+			description = opts.Annotate(e)
+		}
+
 		host := e.Request.URL.Host
 		e.Request.URL.Host = "H.O.S.T.N.A.M.E"
 		cookies := []ht.Cookie{}
 		for _, c := range e.Request.Cookies() {
-			cookies = append(cookies, ht.Cookie{Name: c.Name, Value: c.Value})
+			value := c.Value
+			if opts.redacts(c.Name) {
+				value = redactedValue
+			}
+			cookies = append(cookies, ht.Cookie{Name: c.Name, Value: value})
 		}
 		e.Request.Header.Del("Cookie")
 
 		// Inspect body and extract parameters if appropriate.
 		queryParams := e.Request.URL.Query()
-		rawQuery := e.Request.URL.RawQuery
 		e.Request.URL.RawQuery = "" // clear to prevent reparsing when body is analyzed
-		body, bodyParams, paramsAs := scanRequestBody(&e)
+		body, bodyParams, paramsAs, bodyVars := scanRequestBody(&e, opts)
 
 		var params url.Values
 		if len(queryParams) > 0 && len(bodyParams) > 0 {
-			// Parameters in URL _and_ body: Must keep both
-			e.Request.URL.RawQuery = rawQuery
+			// Parameters in URL _and_ body: Must keep both. The query
+			// is rebuilt to redact it but the placeholders must stay
+			// unescaped to be substituted.
+			opts.redactValues(queryParams)
+			e.Request.URL.RawQuery = strings.Replace(queryParams.Encode(),
+				url.QueryEscape(redactedValue), redactedValue, -1)
 			params = bodyParams
 		} else {
 			// Just one "type" of parameters.
@@ -440,6 +581,7 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 				params = bodyParams
 			}
 		}
+		opts.redactValues(params)
 
 		urlString := e.Request.URL.String()
 		urlString = strings.Replace(urlString, "H.O.S.T.N.A.M.E", "{{HOSTNAME}}", 1)
@@ -447,10 +589,19 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 		dropUnnecessaryHeaders(e.Request.Header)
 
 		checks := extractChecks(e)
+		for _, ck := range checks {
+			if sc, ok := ck.(*ht.SetCookie); ok && opts.redacts(sc.Name) {
+				sc.Value = ht.Condition{} // Recorded value is useless anyway.
+			}
+		}
+
+		if description == "" {
+			description = fmt.Sprintf("Recorded from %s on %s", host, time.Now())
+		}
 
 		test := &Test{
 			Name:        e.Name,
-			Description: fmt.Sprintf("Recorded from %s on %s", host, time.Now()),
+			Description: description,
 			BasedOn:     []string{commonHeadersName},
 			Request: ht.Request{
 				Method:   e.Request.Method,
@@ -462,6 +613,13 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 				Body:     body,
 			},
 			Checks: checks,
+			Variables: map[string]string{
+				"RECORDED_AT":      e.Timestamp.Format(time.RFC3339Nano),
+				"RECORDED_LATENCY": e.Latency.String(),
+			},
+		}
+		for v, val := range bodyVars {
+			test.Variables[v] = val
 		}
 
 		name := sanitize.Filename(e.Name) + ".ht"
@@ -490,15 +648,21 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 	return nil
 }
 
-func scanRequestBody(e *Event) (body string, params url.Values, as string) {
+// scanRequestBody analyses the body of the request in e. Form data is
+// returned as params, JSON bodies are redacted, pretty printed and, if
+// opts.ExtractJSON is set, their top-level scalar fields are turned into
+// variables.
+func scanRequestBody(e *Event, opts Options) (body string, params url.Values, as string, vars map[string]string) {
 	if len(e.RequestBody) == 0 {
-		return "", nil, ""
+		return "", nil, "", nil
 	}
 
-	if e.Request.Method != "POST" {
+	switch e.Request.Method {
+	case "POST", "PUT", "PATCH":
+	default:
 		log.Printf("Ooops: Don't know how to treat %s-Request with non-empty body.",
 			e.Request.Method)
-		return e.RequestBody, nil, ""
+		return e.RequestBody, nil, "", nil
 	}
 
 	// Repopulate the request body with an "unconsumed" writer (the original
@@ -517,13 +681,135 @@ func scanRequestBody(e *Event) (body string, params url.Values, as string) {
 			log.Printf("Error parsing multipart form: %s", err)
 		}
 		as = "multipart"
+	case strings.HasPrefix(contentType, "application/json"):
+		jbody, jvars, err := scanJSONBody(redactJSON(e.RequestBody, opts), opts.ExtractJSON)
+		if err != nil {
+			log.Printf("Error parsing JSON body: %s", err)
+			return e.RequestBody, nil, "", nil
+		}
+		return jbody, nil, "", jvars
 	default:
 		log.Printf("Ooops: Don't know how to treat Content-Type %s with non-empty body.",
 			contentType)
-		return e.RequestBody, nil, ""
+		return e.RequestBody, nil, "", nil
+	}
+
+	return "", e.Request.Form, as, nil
+}
+
+// scanJSONBody pretty prints the JSON in raw and templates the remote
+// host. If extract is set and raw is a JSON object its top-level fields
+// with string, number or boolean values are replaced by variables named
+// like the field; vars contains the recorded values.
+func scanJSONBody(raw string, extract bool) (body string, vars map[string]string, err error) {
+	if !extract {
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, []byte(raw), "", "    "); err != nil {
+			return "", nil, err
+		}
+		return templateHost(buf.String()), nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		// Valid JSON but not an object: Nothing to extract.
+		return scanJSONBody(raw, false)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars = map[string]string{}
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(name)
+		fmt.Fprintf(buf, "\n    %s: ", key)
+
+		value := fields[name]
+		var v interface{}
+		json.Unmarshal(value, &v)
+		switch v := v.(type) {
+		case string:
+			if v == redactedValue ||
+				(remoteHost != "" && strings.Contains(v, remoteHost)) {
+				break // Keep in body.
+			}
+			quoted, _ := json.Marshal(v)
+			vars[name] = string(quoted[1 : len(quoted)-1])
+			fmt.Fprintf(buf, `"{{%s}}"`, name)
+			continue
+		case float64, bool:
+			vars[name] = string(value)
+			fmt.Fprintf(buf, "{{%s}}", name)
+			continue
+		}
+		indented := &bytes.Buffer{}
+		json.Indent(indented, value, "    ", "    ")
+		buf.Write(indented.Bytes())
+	}
+	if len(names) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}")
+
+	return templateHost(buf.String()), vars, nil
+}
+
+// redactJSON replaces the values of all fields in the JSON raw which
+// opts redacts.
+func redactJSON(raw string, opts Options) string {
+	if len(opts.Redact) == 0 {
+		return raw
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return raw
+	}
+
+	redacted := false
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for name, field := range v {
+				if opts.redacts(name) {
+					v[name] = redactedValue
+					redacted = true
+				} else {
+					walk(field)
+				}
+			}
+		case []interface{}:
+			for _, elem := range v {
+				walk(elem)
+			}
+		}
 	}
+	walk(v)
+	if !redacted {
+		return raw
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return string(data)
+}
 
-	return "", e.Request.Form, as
+// templateHost replaces the remote host in s by the HOSTNAME variable.
+func templateHost(s string) string {
+	if remoteHost == "" {
+		return s
+	}
+	return strings.Replace(s, remoteHost, "{{HOSTNAME}}", -1)
 }
 
 func writeTest(test *Test, filename string) error {
@@ -790,7 +1076,7 @@ func extractCommonHeaders(headers []http.Header) http.Header {
 	for h, v := range headers[0] {
 		vs := fmt.Sprintf("%v", v)
 		identical := true
-		for j := 2; j < len(headers); j++ {
+		for j := 1; j < len(headers); j++ {
 			if vs != fmt.Sprintf("%v", headers[j][h]) {
 				identical = false
 				break
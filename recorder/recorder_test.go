@@ -0,0 +1,138 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDumpEventsRedactsQueryAndBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	body := "user=joe&password=geheim"
+	req := httptest.NewRequest("POST", "http://example.org/login?token=secret&page=2",
+		strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	home := httptest.NewRequest("GET", "http://example.org/", nil)
+	events := []Event{
+		{Request: home, Response: httptest.NewRecorder(), Name: "home"},
+		{Request: req, Response: httptest.NewRecorder(), RequestBody: body, Name: "login"},
+	}
+
+	opts := Options{Redact: []*regexp.Regexp{regexp.MustCompile(`^(token|password)$`)}}
+	if err := DumpEvents(events, dir, "redact", opts); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "login.ht"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := string(data)
+	for _, secret := range []string{"secret", "geheim"} {
+		if strings.Contains(test, secret) {
+			t.Errorf("Found %q in\n%s", secret, test)
+		}
+	}
+	for _, want := range []string{"token={{REDACTED}}", "page=2", "joe"} {
+		if !strings.Contains(test, want) {
+			t.Errorf("Missing %q in\n%s", want, test)
+		}
+	}
+}
+
+func TestExtractCommonHeaders(t *testing.T) {
+	headers := []http.Header{
+		{"Accept": {"text/html"}, "Cookie": {"a=1"}},
+		{"Accept": {"text/html"}, "Cookie": {"a=2"}},
+		{"Accept": {"text/html"}, "Cookie": {"a=1"}},
+	}
+	common := extractCommonHeaders(headers)
+	if len(common) != 1 || common.Get("Accept") != "text/html" {
+		t.Errorf("Got common headers %v", common)
+	}
+	for i, want := range []string{"a=1", "a=2", "a=1"} {
+		if got := headers[i].Get("Cookie"); got != want {
+			t.Errorf("Event %d: got Cookie %q, want %q", i, got, want)
+		}
+		if _, ok := headers[i]["Accept"]; ok {
+			t.Errorf("Event %d: Accept not removed", i)
+		}
+	}
+}
+
+func TestHandlerMaxBodySize(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/big":
+				w.Write(bytes.Repeat([]byte("x"), 100))
+			case "/bomb":
+				// Small on the wire but large once decompressed.
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				gz.Write(bytes.Repeat([]byte("0"), 1000))
+				gz.Close()
+			default:
+				w.Write([]byte("small"))
+			}
+		}))
+	defer backend.Close()
+	remote, _ := url.Parse(backend.URL)
+
+	events := make(chan Event, 10)
+	opts := Options{MaxBodySize: 50, Rewrite: NewRewriter("localhost", remote.Host, 0)}
+	proxy := newSingleHostReverseProxy(remote, opts.maxBodySize())
+	ts := httptest.NewServer(http.HandlerFunc(handler(proxy, events, opts)))
+	defer ts.Close()
+
+	for i, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/small", "", http.StatusOK},
+		{"GET", "/big", "", http.StatusBadGateway},
+		{"GET", "/bomb", "", http.StatusBadGateway},
+		{"POST", "/small", strings.Repeat("y", 51), http.StatusRequestEntityTooLarge},
+	} {
+		req, _ := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%d. Unexpected error: %s", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%d. %s %s: got status %d, want %d",
+				i, tc.method, tc.path, resp.StatusCode, tc.want)
+		}
+	}
+
+	// Only the small response has been captured.
+	close(events)
+	n := 0
+	for e := range events {
+		n++
+		if e.Request.URL.Path != "/small" || e.ResponseBody != "small" {
+			t.Errorf("Captured %s %q", e.Request.URL, e.ResponseBody)
+		}
+	}
+	if n != 1 {
+		t.Errorf("Captured %d events, want 1", n)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestReverseProxyTLS(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("Hello " + r.URL.Path))
+		}))
+	defer backend.Close()
+	remote, _ := url.Parse(backend.URL)
+
+	// Grab a free port for the recorder.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	_, port, _ := net.SplitHostPort(addr)
+
+	opts := Options{TLS: true, Rewrite: NewRewriter("localhost:"+port, remote.Host, 0)}
+	go StartReverseProxy(":"+port, remote, opts)
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			// The CA is private to the recorder, its chain is
+			// verified manually below.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		resp, err = client.Get("https://localhost:" + port + "/greeting")
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Cannot reach recorder: %s", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "Hello /greeting" {
+		t.Errorf("Got body %q", body)
+	}
+
+	// The certificate for localhost is issued by the recorder's CA.
+	certs := resp.TLS.PeerCertificates
+	if len(certs) != 2 || certs[1].Subject.CommonName != "ht recorder CA" {
+		t.Fatalf("Got certificate chain %v", certs)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(certs[1])
+	if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots}); err != nil {
+		t.Errorf("Bad certificate: %s", err)
+	}
+
+	// The request has been recorded.
+	for deadline := time.Now().Add(2 * time.Second); len(Events) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(Events) != 1 {
+		t.Fatalf("Got %d events, want 1", len(Events))
+	}
+	if e := Events[0]; e.Request.URL.Path != "/greeting" || e.ResponseBody != "Hello /greeting" {
+		t.Errorf("Got event %s %s: %q", e.Request.Method, e.Request.URL, e.ResponseBody)
+	}
+}
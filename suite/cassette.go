@@ -0,0 +1,236 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cassette.go contains recording and replaying of HTTP responses.
+
+package suite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// CassetteMode determines whether a Cassette records or replays.
+type CassetteMode int
+
+const (
+	// CassetteAuto replays from an existing cassette file and records
+	// a new one if the file does not exist.
+	CassetteAuto CassetteMode = iota
+
+	// CassetteRecord makes real requests and records the responses,
+	// overwriting an existing cassette file.
+	CassetteRecord
+
+	// CassetteReplay replays recorded responses only; requests without
+	// a recorded response fail.
+	CassetteReplay
+)
+
+// An episode is one recorded response.
+type episode struct {
+	Method       string
+	URL          string
+	BodyHash     string
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	Uncompressed bool `json:",omitempty"`
+}
+
+// Cassette is a http.RoundTripper which records responses to a file and
+// replays them later, e.g. to run suites without network access.
+// Requests are identified by method, URL and the SHA-256 hash of the
+// request body. Identical requests are replayed in the order they were
+// recorded; the last response is repeated if more identical requests
+// are made than were recorded.
+type Cassette struct {
+	// Filename is the file the cassette is stored in.
+	Filename string
+
+	// Transport is used by RoundTrip to make the real requests while
+	// recording. If nil the global ht.Transport is used. Tests using c
+	// as their Transport record through their own transport, see Wrap.
+	Transport http.RoundTripper
+
+	recording bool
+
+	mu       sync.Mutex
+	episodes map[string][]episode
+	played   map[string]int
+	order    []string // keys in order of recording
+}
+
+// OpenCassette opens the cassette stored in filename in the given mode.
+func OpenCassette(filename string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{
+		Filename: filename,
+		episodes: make(map[string][]episode),
+		played:   make(map[string]int),
+	}
+	data, err := ioutil.ReadFile(filename)
+	switch {
+	case mode == CassetteRecord, mode == CassetteAuto && os.IsNotExist(err):
+		c.recording = true
+		return c, nil
+	case err != nil:
+		return nil, err
+	}
+
+	recorded := []episode{}
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("malformed cassette %s: %s", filename, err)
+	}
+	for _, e := range recorded {
+		c.add(e)
+	}
+	return c, nil
+}
+
+// Recording reports whether c records (or replays).
+func (c *Cassette) Recording() bool { return c.recording }
+
+func (c *Cassette) add(e episode) {
+	key := e.Method + " " + e.URL + " " + e.BodyHash
+	if _, ok := c.episodes[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.episodes[key] = append(c.episodes[key], e)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := c.Transport
+	if transport == nil {
+		transport = ht.Transport
+	}
+	return c.roundTrip(req, transport)
+}
+
+// Wrap implements ht.TransportWrapper: The returned RoundTripper records
+// the responses of requests made through transport (or replays them).
+func (c *Cassette) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return cassetteTransport{cassette: c, transport: transport}
+}
+
+// cassetteTransport records through transport to cassette.
+type cassetteTransport struct {
+	cassette  *Cassette
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (ct cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return ct.cassette.roundTrip(req, ct.transport)
+}
+
+func (c *Cassette) roundTrip(req *http.Request, transport http.RoundTripper) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	hash := sha256.Sum256(body)
+	e := episode{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		BodyHash: hex.EncodeToString(hash[:]),
+	}
+
+	if c.recording {
+		return c.record(req, e, transport)
+	}
+	return c.replay(req, e)
+}
+
+func (c *Cassette) record(req *http.Request, e episode, transport http.RoundTripper) (*http.Response, error) {
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	e.Body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	e.StatusCode, e.Header, e.Uncompressed = resp.StatusCode, resp.Header, resp.Uncompressed
+	resp.Body = ioutil.NopCloser(bytes.NewReader(e.Body))
+
+	c.mu.Lock()
+	c.add(e)
+	c.mu.Unlock()
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, e episode) (*http.Response, error) {
+	key := e.Method + " " + e.URL + " " + e.BodyHash
+	c.mu.Lock()
+	recorded := c.episodes[key]
+	n := c.played[key]
+	c.played[key]++
+	c.mu.Unlock()
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s %s in cassette %s",
+			e.Method, e.URL, c.Filename)
+	}
+	if n >= len(recorded) {
+		n = len(recorded) - 1
+	}
+	r := recorded[n]
+	header := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Uncompressed:  r.Uncompressed,
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded responses to the cassette file. Save is a
+// no-op for replaying cassettes.
+func (c *Cassette) Save() error {
+	if !c.recording {
+		return nil
+	}
+	c.mu.Lock()
+	recorded := []episode{}
+	for _, key := range c.order {
+		recorded = append(recorded, c.episodes[key]...)
+	}
+	c.mu.Unlock()
+
+	data, err := json.MarshalIndent(recorded, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Filename, data, 0666)
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Call", fmt.Sprintf("%d", calls))
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+		}))
+
+	txt := `
+# cassette.suite
+{
+    Name: "Cassette"
+    Main: [
+        {File: "get.ht"}
+        {File: "post.ht", Variables: {DATA: "one"}}
+        {File: "post.ht", Variables: {DATA: "two"}}
+        {File: "get.ht"}
+    ]
+}
+
+# get.ht
+{
+    Request: { URL: "{{URL}}/get" }
+    Checks: [ {Check: "Body", Equals: "GET /get "} ]
+}
+
+# post.ht
+{
+    Request: { Method: "POST", URL: "{{URL}}/post", Body: "{{DATA}}" }
+    Checks: [ {Check: "Body", Suffix: "{{DATA}}"} ]
+}
+`
+	rs, err := parseRawSuite("cassette.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs.Cassette = filepath.Join(dir, "sub", "test.cassette")
+	global := map[string]string{"URL": ts.URL}
+
+	// Recording makes real requests.
+	s := rs.Execute(global, nil, logger())
+	if s.Status != ht.Pass || calls != 4 {
+		t.Fatalf("Recording: got %s after %d calls: %v", s.Status, calls, s.Error)
+	}
+	if _, err := os.Stat(rs.Cassette); err != nil {
+		t.Fatalf("Cassette not saved: %s", err)
+	}
+
+	// Replaying works without the server.
+	ts.Close()
+	rs.CassetteMode = CassetteReplay
+	s = rs.Execute(global, nil, logger())
+	if s.Status != ht.Pass || calls != 4 {
+		t.Fatalf("Replaying: got %s after %d calls: %v", s.Status, calls, s.Error)
+	}
+	if got := s.Tests[3].Response.Response.Header.Get("X-Call"); got != "4" {
+		t.Errorf("Replayed wrong response to repeated request: X-Call=%s", got)
+	}
+
+	// Unrecorded requests fail.
+	global["URL"] = "http://unrecorded.example.org"
+	s = rs.Execute(global, nil, logger())
+	if s.Status != ht.Error {
+		t.Errorf("Unrecorded: got %s, want error", s.Status)
+	}
+}
+
+func TestCassetteSubrequests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.URL.Path == "/page" {
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, `<html><body><a href="/a">A</a><img src="/b.png"></body></html>`)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		}))
+
+	txt := `
+# links.suite
+{
+    Name: "Links"
+    Main: [ {File: "page.ht"} ]
+}
+
+# page.ht
+{
+    Request: { URL: "{{URL}}/page", HTTPVersion: "1.1" }
+    Checks: [ {Check: "Links", Which: "a img"} ]
+}
+`
+	rs, err := parseRawSuite("links.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs.Cassette = filepath.Join(dir, "links.cassette")
+	global := map[string]string{"URL": ts.URL}
+
+	s := rs.Execute(global, nil, logger())
+	if s.Status != ht.Pass || calls != 3 {
+		t.Fatalf("Recording: got %s after %d calls: %v", s.Status, calls, s.Error)
+	}
+
+	// The link checks are replayed too.
+	ts.Close()
+	rs.CassetteMode = CassetteReplay
+	s = rs.Execute(global, nil, logger())
+	if s.Status != ht.Pass || calls != 3 {
+		t.Fatalf("Replaying: got %s after %d calls: %v", s.Status, calls, s.Error)
+	}
+}
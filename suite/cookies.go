@@ -0,0 +1,160 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cookies.go contains checks of the final state of the cookie jar of a suite.
+
+package suite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+)
+
+// FinalCookie is the expected state of a cookie in the cookie jar of a
+// suite after all tests (including the Teardown tests) have been executed,
+// e.g. to make sure a logout really clears the session cookie:
+//     FinalCookies: [
+//         { Name: "session", Absent: true }
+//         { Name: "prefs", Domain: "example.org", Type: "persistent secure" }
+//     ]
+// Checking the final cookies requires KeepCookies.
+type FinalCookie struct {
+	// Name is the name of the cookie.
+	Name string
+
+	// Domain and Path restrict the check to cookies with exactly this
+	// domain and path. Empty values match any domain or path.
+	Domain string `json:",omitempty"`
+	Path   string `json:",omitempty"`
+
+	// Value is applied to the cookie value.
+	Value ht.Condition `json:",omitempty"`
+
+	// Absent indicates that no cookie Name must be stored in the jar.
+	Absent bool `json:",omitempty"`
+
+	// Type is the type of the cookie. It is a space separated string of
+	// the same keywords as in the SetCookie check: "session",
+	// "persistent", "secure", "unsafe", "httpOnly" and "exposed".
+	Type string `json:",omitempty"`
+}
+
+// prepare compiles the Value condition and validates Type.
+func (fc *FinalCookie) prepare() error {
+	if fc.Name == "" {
+		return fmt.Errorf("final cookie without Name")
+	}
+	if err := fc.Value.Compile(); err != nil {
+		return fmt.Errorf("final cookie %s: %s", fc.Name, err)
+	}
+	fc.Type = strings.ToLower(fc.Type)
+	x := strings.Replace(fc.Type, ",", " ", -1)
+	for _, t := range strings.Fields("session persistent secure unsafe httponly exposed") {
+		x = strings.TrimSpace(strings.Replace(x, t, "", -1))
+	}
+	if x != "" {
+		return fmt.Errorf("final cookie %s: unknown stuff in type %q", fc.Name, x)
+	}
+	return nil
+}
+
+// check fc against the cookies stored in jar.
+func (fc *FinalCookie) check(jar *cookiejar.Jar) error {
+	var found []cookiejar.Entry
+	for _, domain := range jar.ETLDsPlus1(nil) {
+		for _, e := range jar.Entries(domain, nil) {
+			if e.Name != fc.Name ||
+				(fc.Domain != "" && e.Domain != strings.TrimPrefix(fc.Domain, ".")) ||
+				(fc.Path != "" && e.Path != fc.Path) {
+				continue
+			}
+			found = append(found, e)
+		}
+	}
+
+	if fc.Absent {
+		if len(found) > 0 {
+			return fmt.Errorf("final cookie %s present (%s)", fc.Name, found[0].ID())
+		}
+		return nil
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("final cookie %s missing", fc.Name)
+	}
+
+	errs := ht.ErrorList{}
+	for _, e := range found {
+		if err := fc.Value.Fulfilled(e.Value); err != nil {
+			errs = append(errs, fmt.Errorf("final cookie %s: bad value: %s", e.ID(), err))
+		}
+		if err := fc.checkType(e); err != nil {
+			errs = append(errs, fmt.Errorf("final cookie %s: %s", e.ID(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (fc *FinalCookie) checkType(e cookiejar.Entry) error {
+	t := fc.Type
+	if strings.Contains(t, "session") && e.Persistent {
+		return fmt.Errorf("persistent cookie")
+	} else if strings.Contains(t, "persistent") && !e.Persistent {
+		return fmt.Errorf("session cookie")
+	}
+	if strings.Contains(t, "secure") && !e.Secure {
+		return fmt.Errorf("not a secure cookie")
+	} else if strings.Contains(t, "unsafe") && e.Secure {
+		return fmt.Errorf("secure cookie")
+	}
+	if strings.Contains(t, "httponly") && !e.HttpOnly {
+		return fmt.Errorf("not a http-only cookie")
+	} else if strings.Contains(t, "exposed") && e.HttpOnly {
+		return fmt.Errorf("http-only cookie")
+	}
+	return nil
+}
+
+// prepareFinalCookies prepares fcs and reports malformed final cookies
+// as well as final cookies in a suite which does not keep its cookies.
+func prepareFinalCookies(fcs []FinalCookie, keepCookies bool) ht.ErrorList {
+	errs := ht.ErrorList{}
+	for i := range fcs {
+		if err := fcs[i].prepare(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 && len(fcs) > 0 && !keepCookies {
+		errs = append(errs, fmt.Errorf("FinalCookies need KeepCookies"))
+	}
+	return errs
+}
+
+// checkFinalCookies checks the FinalCookies of suite. The returned status
+// is Pass, Fail or Bogus (for malformed FinalCookies) and NotRun if there
+// is nothing to check.
+func (suite *Suite) checkFinalCookies() (ht.Status, ht.ErrorList) {
+	if len(suite.FinalCookies) == 0 {
+		return ht.NotRun, nil
+	}
+	if errs := prepareFinalCookies(suite.FinalCookies, suite.Jar != nil); len(errs) > 0 {
+		return ht.Bogus, errs
+	}
+
+	errs := ht.ErrorList{}
+	for i := range suite.FinalCookies {
+		if err := suite.FinalCookies[i].check(suite.Jar); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return ht.Fail, errs
+	}
+	return ht.Pass, nil
+}
@@ -39,6 +39,189 @@ Here "merging" means that the variables in the Variables section are
 added to the scope if not already present. I.e. the variables from outer scope
 dominate variables from inner scopes.
 
+The values in a Variables section may reference other variables of the
+same section, in any order:
+    Variables: {
+        LOGIN: "{{BASE}}/login"
+        BASE:  "https://{{HOST}}"
+    }
+References are resolved before the variables are merged; a reference
+cycle (e.g. A: "{{B}}" and B: "{{A}}") in the suite's Variables makes the
+suite bogus.
+
+A test which references undefined variables (e.g. a typo like "{{HSOT}}")
+is bogus; all undefined variables are reported. All fields are checked,
+including the request Body and files read via @vfile:. References which
+are no variable names like Go templates {{.Name}} are ignored.
+Variables extracted via VarEx count as defined for all later tests; if the
+extraction failed during execution the later tests referencing them are
+skipped. The special NOW and RANDOM constructs are not variables and never
+undefined.
+
+Computed Variables
+
+The ComputedVariables section of a suite is evaluated exactly once: After
+all Setup tests have been executed and before the first Main test is
+constructed. Their values may reference any variable in the suite scope at
+that moment, especially values extracted during setup (e.g. a session
+token from a login):
+    ComputedVariables: {
+        AUTH: "Bearer {{TOKEN}}"
+    }
+The evaluated values are added to the suite scope and frozen: Variable
+extraction in later tests cannot change them. Like all other variables
+computed variables are dominated by the global scope: A computed variable
+which is also set from the outside (e.g. via -D) is not evaluated at all
+and the global value is used.
+
+Repeating Tests
+
+A test can be executed several times with different values of its call
+variables by listing the values in Repeat. The i'th repetition uses the
+i'th value of each listed variable, e.g. to check content negotiation:
+    Main: [
+        {
+            File: "item.ht"
+            Repeat: {
+                ACCEPT: [ "application/json", "application/xml" ]
+                TYPE:   [ "json", "xml" ]
+            }
+        }
+    ]
+where item.ht sends the header Accept: "{{ACCEPT}}" and checks
+{Check: "ContentType", Is: "{{TYPE}}"}.
+
+Larger sets of test data can be read from a CSV or JSON-lines file
+(relative to the suite) with DataFrom:
+    Main: [ {File: "item.ht", DataFrom: "data/items.csv"} ]
+The test is executed once per row with the columns of the row as
+variables. The first line of a CSV file names the variables.
+
+Including Suites
+
+A suite may include other suites to share common tests:
+    Include: [ "common/smoke.suite" ]
+The filenames are relative to the including suite. The Setup, Main and
+Teardown tests of an included suite are executed before the respective
+tests of the including suite. Its Variables, ComputedVariables, Metadata,
+DefaultHeaders, Environments, OnFailure and BaseURL are used as defaults
+for the including suite. Local tests and settings take precedence: An
+included test read from the same file as a local test of the same section
+is dropped. The FinalCookies of an included suite are not inherited as
+they describe the cookie jar after its own tests only. Cyclic includes
+are reported as an error.
+
+Metadata
+
+Information which helps to triage a failing suite can be given as Metadata:
+    Metadata: {
+        Owner:  "team-checkout"
+        Ticket: "https://issues.example.org/SHOP-42"
+        Env:    "{{ENV}}"
+    }
+Variables are expanded in the values. The Metadata is shown in the text
+and HTML reports and as properties (prefixed with "metadata.") in the
+JUnit report.
+
+Criticality
+
+Tests may declare their Criticality (one of "Ignore", "Info", "Warn",
+"Error" or "Fatal"; unset means "Error"). A suite with a Threshold
+    Threshold: "Error"
+fails only if a test at least as critical as the Threshold fails or
+errors: Failures of less critical tests are reported as Warnings of the
+suite but do not influence its Status. Bogus tests always count.
+
+Rate Limiting
+
+To keep the load on shared environments low a suite may limit the rate
+of all its outgoing requests:
+    MaxRequestsPerSecond: 5
+This includes requests made by checks like Links, Latency or Resilience.
+Requests exceeding the rate are delayed, never dropped. The limit applies
+per suite; suites executed concurrently are limited independently.
+Likewise the number of link requests made concurrently by all Links
+checks of a suite can be bounded:
+    MaxLinkConcurrency: 4
+
+
+Base URL
+
+Tests may use relative request URLs if the suite provides a base URL:
+    BaseURL: "https://{{HOST}}/api"
+The BaseURL is prepended to each request URL which is not absolute after
+variable substitution, joined with exactly one slash.
+
+
+Default Headers
+
+Headers common to all tests can be given once in the suite:
+    DefaultHeaders: {
+        User-Agent: "ht-{{ENV}}"
+        Accept:     "application/json"
+    }
+They are added to each request which does not set the header itself.
+Variables are expanded in the values.
+
+
+Environments
+
+The same suite can be run against different environments by providing
+the environment specific variables in the suite:
+    Variables: { URL: "http://localhost:8080" }
+    Environments: {
+        staging: { URL: "https://staging.example.org" }
+        prod:    { URL: "https://www.example.org" }
+    }
+The variables of the environment selected with the -env flag of ht
+overwrite the suite's Variables but not the ones set via -D or -Dfile.
+
+
+Suite Timeout
+
+The total execution time of a suite can be limited:
+    Timeout: "15m"
+Once the timeout is exceeded the request in flight is aborted, no further
+tries of a polling test are made and all remaining tests (including the
+Teardown tests) are skipped. The suite errors.
+
+With
+    FailFast: true
+the remaining Setup and Main tests are skipped once a test fails or errors.
+The Teardown tests are executed nevertheless.
+
+
+Final Cookies
+
+A suite which keeps cookies may check the state of its cookie jar after
+all tests (including the Teardown tests) have been executed:
+    KeepCookies: true
+    FinalCookies: [
+        { Name: "session", Absent: true }
+    ]
+Missing or unexpected cookies fail the suite, see FinalCookie.
+
+
+Diagnostics on Failure
+
+The OnFailure test of a suite is executed after each failing or erroring
+test, e.g. to capture the state of the server at the moment of failure:
+    OnFailure: { File: "debug-vars.ht" }
+It sees all variables of the failing test plus FAILED_TEST and FAILED_SEQNO
+(the name and sequence number of the failing test). The executed OnFailure
+tests are reported as Diagnostics and do not influence the suite's status.
+
+
+Record and Replay
+
+The responses of a suite can be recorded to a cassette file (relative
+to the suite file) and replayed later without network access:
+    Cassette: "testdata/api.cassette"
+If the cassette file does not exist the suite makes real requests and
+records the responses, otherwise the responses are replayed. Requests are
+matched by method, URL and a hash of the request body. The -record and
+-replay flags of ht exec force recording or replaying.
+
 
 */
 package suite
@@ -0,0 +1,218 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// monitor.go contains the continuous monitoring mode which exposes the
+// outcome of repeated suite executions as Prometheus metrics.
+
+package suite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+)
+
+// MonitorBuckets are the upper bounds (in seconds) of the buckets of the
+// request duration histograms.
+var MonitorBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// RunLoop executes rs every interval until ctx is done. The outcome of
+// each execution is recorded in m (if non-nil) and passed to done (if
+// non-nil). Each execution starts with a fresh copy of global.
+func (rs *RawSuite) RunLoop(ctx context.Context, interval time.Duration, global map[string]string, jar *cookiejar.Jar, logger ht.Logger, m *Monitor, done func(*Suite)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		vars := make(map[string]string, len(global))
+		for n, v := range global {
+			vars[n] = v
+		}
+		s := rs.ExecuteContext(ctx, vars, jar, logger)
+		if ctx.Err() != nil {
+			return // Don't record an interrupted execution.
+		}
+		if m != nil {
+			m.Record(s)
+		}
+		if done != nil {
+			done(s)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Monitor collects metrics of executed suites and exposes them in the
+// Prometheus text format. Monitor implements http.Handler. The metrics
+// are labeled by suite and test name:
+//     ht_suite_runs_total      Number of executions of the suite.
+//     ht_suite_status          Status of the last execution (as ht.Status).
+//     ht_test_up               1 if the test passed in the last execution, else 0.
+//     ht_test_status           Status of the test in the last execution.
+//     ht_test_duration_seconds Histogram of the request durations.
+//     ht_check_failures_total  Number of failures per check (labeled by check name).
+// Tests which are skipped or not run do not update the test metrics.
+type Monitor struct {
+	mu     sync.Mutex
+	suites map[string]*suiteMetrics
+	names  []string // suite names in order of first recording
+}
+
+type suiteMetrics struct {
+	runs   int
+	status ht.Status
+	tests  map[string]*testMetrics
+	names  []string // test names in order of first recording
+}
+
+type testMetrics struct {
+	status   ht.Status
+	buckets  []int // cumulative counts, one per MonitorBuckets
+	count    int
+	sum      float64
+	failures map[string]int // check name -> number of failures
+}
+
+// NewMonitor returns an empty Monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{suites: make(map[string]*suiteMetrics)}
+}
+
+// Record updates the metrics with the outcome of s.
+func (m *Monitor) Record(s *Suite) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sm, ok := m.suites[s.Name]
+	if !ok {
+		sm = &suiteMetrics{tests: make(map[string]*testMetrics)}
+		m.suites[s.Name] = sm
+		m.names = append(m.names, s.Name)
+	}
+	sm.runs++
+	sm.status = s.Status
+
+	for _, test := range s.Tests {
+		if test.Status == ht.NotRun || test.Status == ht.Skipped {
+			continue
+		}
+		tm, ok := sm.tests[test.Name]
+		if !ok {
+			tm = &testMetrics{
+				buckets:  make([]int, len(MonitorBuckets)),
+				failures: make(map[string]int),
+			}
+			sm.tests[test.Name] = tm
+			sm.names = append(sm.names, test.Name)
+		}
+		tm.status = test.Status
+		d := test.Duration.Seconds()
+		for i, le := range MonitorBuckets {
+			if d <= le {
+				tm.buckets[i]++
+			}
+		}
+		tm.count++
+		tm.sum += d
+		for _, cr := range test.CheckResults {
+			if cr.Status == ht.Fail {
+				tm.failures[cr.Name]++
+			}
+		}
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteMetrics(w)
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format to w.
+func (m *Monitor) WriteMetrics(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	each := func(fn func(suite, test string, tm *testMetrics)) {
+		for _, sn := range m.names {
+			sm := m.suites[sn]
+			for _, tn := range sm.names {
+				fn(sn, tn, sm.tests[tn])
+			}
+		}
+	}
+
+	header("ht_suite_runs_total", "counter", "Number of executions of the suite.")
+	for _, sn := range m.names {
+		fmt.Fprintf(w, "ht_suite_runs_total{suite=%s} %d\n", labelValue(sn), m.suites[sn].runs)
+	}
+	header("ht_suite_status", "gauge", "Status of the last execution of the suite.")
+	for _, sn := range m.names {
+		fmt.Fprintf(w, "ht_suite_status{suite=%s} %d\n", labelValue(sn), m.suites[sn].status)
+	}
+
+	header("ht_test_up", "gauge", "Whether the test passed in the last execution.")
+	each(func(sn, tn string, tm *testMetrics) {
+		up := 0
+		if tm.status == ht.Pass {
+			up = 1
+		}
+		fmt.Fprintf(w, "ht_test_up{%s} %d\n", testLabels(sn, tn), up)
+	})
+	header("ht_test_status", "gauge", "Status of the test in the last execution.")
+	each(func(sn, tn string, tm *testMetrics) {
+		fmt.Fprintf(w, "ht_test_status{%s} %d\n", testLabels(sn, tn), tm.status)
+	})
+
+	header("ht_test_duration_seconds", "histogram", "Duration of the request of the test.")
+	each(func(sn, tn string, tm *testMetrics) {
+		labels := testLabels(sn, tn)
+		for i, le := range MonitorBuckets {
+			fmt.Fprintf(w, "ht_test_duration_seconds_bucket{%s,le=\"%g\"} %d\n",
+				labels, le, tm.buckets[i])
+		}
+		fmt.Fprintf(w, "ht_test_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, tm.count)
+		fmt.Fprintf(w, "ht_test_duration_seconds_sum{%s} %g\n", labels, tm.sum)
+		fmt.Fprintf(w, "ht_test_duration_seconds_count{%s} %d\n", labels, tm.count)
+	})
+
+	header("ht_check_failures_total", "counter", "Number of failures of the check.")
+	each(func(sn, tn string, tm *testMetrics) {
+		checks := make([]string, 0, len(tm.failures))
+		for name := range tm.failures {
+			checks = append(checks, name)
+		}
+		sort.Strings(checks)
+		for _, name := range checks {
+			fmt.Fprintf(w, "ht_check_failures_total{%s,check=%s} %d\n",
+				testLabels(sn, tn), labelValue(name), tm.failures[name])
+		}
+	})
+}
+
+func testLabels(suite, test string) string {
+	return "suite=" + labelValue(suite) + ",test=" + labelValue(test)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue quotes v for use as a label value.
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,56 @@
+// Copyright 2016 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor()
+	for _, status := range []ht.Status{ht.Fail, ht.Pass} {
+		m.Record(&Suite{
+			Name:   "Shop",
+			Status: status,
+			Tests: []*ht.Test{
+				{
+					Name:     "Home \"page\"",
+					Status:   status,
+					Duration: 30 * time.Millisecond,
+					CheckResults: []ht.CheckResult{
+						{Name: "StatusCode", Status: ht.Pass},
+						{Name: "Body", Status: status},
+					},
+				},
+				{Name: "Skipped", Status: ht.Skipped},
+			},
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	m.WriteMetrics(buf)
+	metrics := buf.String()
+	for _, want := range []string{
+		`ht_suite_runs_total{suite="Shop"} 2`,
+		`ht_suite_status{suite="Shop"} 2`,
+		`ht_test_up{suite="Shop",test="Home \"page\""} 1`,
+		`ht_test_duration_seconds_bucket{suite="Shop",test="Home \"page\"",le="0.025"} 0`,
+		`ht_test_duration_seconds_bucket{suite="Shop",test="Home \"page\"",le="0.05"} 2`,
+		`ht_test_duration_seconds_count{suite="Shop",test="Home \"page\""} 2`,
+		`ht_check_failures_total{suite="Shop",test="Home \"page\"",check="Body"} 1`,
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("Missing %s in\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, "Skipped") {
+		t.Errorf("Skipped test reported:\n%s", metrics)
+	}
+}
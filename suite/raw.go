@@ -5,13 +5,18 @@
 package suite
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/http"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/ht"
@@ -338,6 +343,37 @@ func (rt *RawTest) ToTest(variables map[string]string) (*ht.Test, error) {
 		}
 	}
 
+	// Substitute the {{NOW ...}}, {{RANDOM ...}} and {{= ...}} constructs,
+	// the RANDOMs possibly with values from the test's private random source.
+	seed, err := substituted.randomSeed()
+	if err != nil {
+		return bogus, err
+	}
+	special := make(map[string]string)
+	texts := []string{substituted.File.Data}
+	for _, mixin := range substituted.Mixins {
+		texts = append(texts, mixin.File.Data)
+	}
+	err = ht.SetNowVariables(special, time.Now(), texts...)
+	if err != nil {
+		return bogus, err
+	}
+	err = ht.SetRandomVariables(special, seed, texts...)
+	if err != nil {
+		return bogus, err
+	}
+	err = ht.SetExpressionVariables(special, variables, texts...)
+	if err != nil {
+		return bogus, err
+	}
+	if len(special) > 0 {
+		replacer = varReplacer(special)
+		substituted.File.Data = replacer.Replace(substituted.File.Data)
+		for _, mixin := range substituted.Mixins {
+			mixin.File.Data = replacer.Replace(mixin.File.Data)
+		}
+	}
+
 	test, err := substituted.toTest(variables)
 	if err != nil {
 		return bogus, err
@@ -367,6 +403,116 @@ func (rt *RawTest) ToTest(variables map[string]string) (*ht.Test, error) {
 	return merged, nil
 }
 
+// undefinedVariables returns the sorted names of all variables referenced
+// in rt (and its mixins) which are undefined in variables. Names in
+// extracted are variables which are extracted by earlier tests; references
+// to them are returned in unextracted if they are still undefined. All
+// fields are scanned, including the request Body and the content of files
+// referenced via @vfile: in the Body or the Params. References to the
+// special NOW and RANDOM constructs and things which do not look like a
+// variable name (e.g. Go templates like {{.Name}}) are not reported.
+func (rt *RawTest) undefinedVariables(variables map[string]string, extracted map[string]bool) (undefined, unextracted []string) {
+	replacer := varReplacer(variables)
+	files := []*File{rt.File}
+	for _, mixin := range rt.Mixins {
+		files = append(files, mixin.File)
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		var soup interface{}
+		if err := hjson.Unmarshal([]byte(replacer.Replace(f.Data)), &soup); err != nil {
+			continue // reported while decoding the test
+		}
+		texts := stringsIn(soup, nil)
+		for _, text := range texts {
+			if content, ok := vfileContent(text); ok {
+				texts = append(texts, content)
+			}
+		}
+		for _, text := range texts {
+			for _, m := range varReference.FindAllStringSubmatch(text, -1) {
+				name := m[1]
+				if seen[name] || !variableName.MatchString(name) ||
+					name == "NOW" || name == "RANDOM" {
+					continue
+				}
+				seen[name] = true
+				if _, ok := variables[name]; ok {
+					continue
+				}
+				if extracted[name] {
+					unextracted = append(unextracted, name)
+				} else {
+					undefined = append(undefined, name)
+				}
+			}
+		}
+	}
+	sort.Strings(undefined)
+	sort.Strings(unextracted)
+	return undefined, unextracted
+}
+
+// vfileContent returns the content of the file referenced by a value of
+// the form "@vfile:/path/to/file" which undergoes variable substitution.
+// Inline data of the form "@vfile:@name:data" is part of the value itself
+// and unreadable files are reported when the test is executed.
+func vfileContent(text string) (string, bool) {
+	if !strings.HasPrefix(text, "@vfile:") {
+		return "", false
+	}
+	file := strings.TrimPrefix(text, "@vfile:")
+	if file == "" || (file[0] == '@' && strings.Contains(file, ":")) {
+		return "", false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// stringsIn appends all strings (values and object keys) in the decoded
+// hjson soup to texts.
+func stringsIn(soup interface{}, texts []string) []string {
+	switch v := soup.(type) {
+	case string:
+		texts = append(texts, v)
+	case []interface{}:
+		for _, e := range v {
+			texts = stringsIn(e, texts)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			texts = append(texts, k)
+			texts = stringsIn(e, texts)
+		}
+	}
+	return texts
+}
+
+// variableName matches what looks like a (non-special) variable name.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// randomSeed returns the first non-zero RandomSeed of rt and its mixins.
+func (rt *RawTest) randomSeed() (int64, error) {
+	files := []*File{rt.File}
+	for _, mixin := range rt.Mixins {
+		files = append(files, mixin.File)
+	}
+	for _, f := range files {
+		x := &struct{ RandomSeed int64 }{}
+		if err := f.decodeLaxTo(x); err != nil {
+			return 0, err
+		}
+		if x.RandomSeed != 0 {
+			return x.RandomSeed, nil
+		}
+	}
+	return 0, nil
+}
+
 func (m *Mixin) toTest() (*ht.Test, error) {
 	rt := &RawTest{
 		File: &File{
@@ -407,20 +553,218 @@ type RawElement struct {
 	File      string
 	Variables map[string]string
 
+	// Repeat executes the test once for each value of the listed
+	// variables: The i'th repetition uses the i'th value of each
+	// variable. All lists must have the same length. Example to check
+	// content negotiation:
+	//     Repeat: {
+	//         ACCEPT: [ "application/json", "application/xml" ]
+	//         TYPE:   [ "json", "xml" ]
+	//     }
+	Repeat map[string][]string `json:",omitempty"`
+
+	// DataFrom is a CSV (*.csv) or JSON-lines file (relative to the
+	// suite) with test data. The test is executed once per row with
+	// the columns of the row as additional variables. The first line
+	// of a CSV file contains the variable names; each line of a
+	// JSON-lines file is an object mapping variable names to values.
+	// Repeat and DataFrom are mutually exclusive.
+	DataFrom string `json:",omitempty"`
+
 	Test map[string]interface{}
 }
 
+// expandRepeats replaces each element in elems which has a Repeat or
+// a DataFrom by its repetitions. DataFrom files are loaded from fs
+// relative to dir.
+func expandRepeats(elems []RawElement, which string, dir string, fs FileSystem) ([]RawElement, error) {
+	expanded := make([]RawElement, 0, len(elems))
+	for i, elem := range elems {
+		var rows []map[string]string
+		switch {
+		case len(elem.Repeat) > 0 && elem.DataFrom != "":
+			return nil, fmt.Errorf("Repeat and DataFrom must not both be given in %d. %s",
+				i+1, which)
+		case len(elem.Repeat) > 0:
+			n := -1
+			for name, values := range elem.Repeat {
+				if n != -1 && len(values) != n {
+					return nil, fmt.Errorf("Repeat variables of different length in %d. %s (%s has %d values, want %d)",
+						i+1, which, name, len(values), n)
+				}
+				n = len(values)
+			}
+			for r := 0; r < n; r++ {
+				row := make(map[string]string, len(elem.Repeat))
+				for name, values := range elem.Repeat {
+					row[name] = values[r]
+				}
+				rows = append(rows, row)
+			}
+		case elem.DataFrom != "":
+			var err error
+			rows, err = loadTestData(path.Join(dir, elem.DataFrom), fs)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load test data for %d. %s: %s",
+					i+1, which, err)
+			}
+		default:
+			expanded = append(expanded, elem)
+			continue
+		}
+
+		for _, row := range rows {
+			rep := elem
+			rep.Repeat, rep.DataFrom = nil, ""
+			rep.Variables = make(map[string]string, len(elem.Variables)+len(row))
+			for name, value := range elem.Variables {
+				rep.Variables[name] = value
+			}
+			for name, value := range row {
+				rep.Variables[name] = value
+			}
+			expanded = append(expanded, rep)
+		}
+	}
+	return expanded, nil
+}
+
+// loadTestData loads the rows of the CSV or JSON-lines file filename.
+// Non-string JSON values are used in their JSON encoding.
+func loadTestData(filename string, fs FileSystem) ([]map[string]string, error) {
+	file, err := fs.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+
+	if strings.ToLower(path.Ext(filename)) == ".csv" {
+		records, err := csv.NewReader(strings.NewReader(file.Data)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("%s: missing header line", filename)
+		}
+		header := records[0]
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(header))
+			for c, name := range header {
+				row[strings.TrimSpace(name)] = record[c]
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	for n, line := range strings.Split(file.Data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, n+1, err)
+		}
+		row := make(map[string]string, len(obj))
+		for name, value := range obj {
+			if s, ok := value.(string); ok {
+				row[name] = s
+				continue
+			}
+			data, _ := json.Marshal(value)
+			row[name] = string(data)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
 // RawSuite represents a suite as represented on disk as a HJSON file.
 type RawSuite struct {
 	*File
 	Name, Description     string
+	Include               []string
 	Setup, Main, Teardown []RawElement
 	KeepCookies           bool
 	OmitChecks            bool
 	Variables             map[string]string
+	ComputedVariables     map[string]string
 	Verbosity             int
 
-	tests []*RawTest
+	// Metadata like "Owner" or "Ticket" is shown in the reports, see
+	// Suite.Metadata. Variables are expanded in the values.
+	Metadata map[string]string
+
+	// BaseURL is prepended to the relative request URLs of all tests,
+	// see Suite.BaseURL.
+	BaseURL string
+
+	// Environments maps environment names (e.g. "dev" or "prod") to
+	// variables. The variables of the environment selected with
+	// SelectEnvironment overwrite the suite's Variables.
+	Environments map[string]map[string]string
+
+	// Threshold is the minimal criticality a failing test must have to
+	// fail the suite, see Suite.Threshold.
+	Threshold ht.Criticality
+
+	// MaxRequestsPerSecond limits the rate of outgoing requests of
+	// the suite, see Suite.MaxRequestsPerSecond.
+	MaxRequestsPerSecond float64
+
+	// MaxLinkConcurrency limits the number of concurrent link requests
+	// of the suite, see Suite.MaxLinkConcurrency.
+	MaxLinkConcurrency int
+
+	// Timeout limits the execution time of the whole suite, see
+	// Suite.Timeout.
+	Timeout time.Duration
+
+	// BeforeEach and AfterEach are hooks called around the execution
+	// of each test, see the fields of the same name in Suite.
+	BeforeEach func(test *ht.Test)            `json:"-"`
+	AfterEach  func(test *ht.Test, err error) `json:"-"`
+
+	// Transport is used for all requests of the suite, see
+	// Suite.Transport.
+	Transport http.RoundTripper `json:"-"`
+
+	// FailFast skips all remaining Setup and Main tests once a test
+	// failed, see Suite.FailFast.
+	FailFast bool
+
+	// DefaultHeaders are added to the requests of all tests, see
+	// Suite.DefaultHeaders.
+	DefaultHeaders http.Header
+
+	// FinalCookies are the expected cookies in the cookie jar after
+	// all tests have been executed, see Suite.FinalCookies.
+	FinalCookies []FinalCookie
+
+	// Cassette is the file (relative to the suite) in which the responses
+	// are recorded and from which they are replayed, see Cassette.
+	// The cassette is not used if Transport is set.
+	Cassette string
+
+	// CassetteMode determines whether the Cassette is recorded or
+	// replayed.
+	CassetteMode CassetteMode `json:"-"`
+
+	// OnFailure is the test executed after each failing test to capture
+	// diagnostics, see Suite.OnFailure.
+	OnFailure *RawElement
+
+	tests     []*RawTest
+	onFailure *RawTest
+}
+
+// cassettePath returns the filename of the cassette of rs.
+func (rs *RawSuite) cassettePath() string {
+	if filepath.IsAbs(rs.Cassette) || rs.File == nil {
+		return rs.Cassette
+	}
+	return filepath.Join(filepath.FromSlash(rs.File.Dirname()), rs.Cassette)
 }
 
 // RawTests return all tests in rs.
@@ -443,7 +787,33 @@ func parseRawSuite(name string, txt string) (*RawSuite, error) {
 }
 
 // LoadRawSuite with the given filename from fs.
+//
+// The suites listed in Include (relative to the directory of filename)
+// are loaded too and merged into the returned suite: Their Setup, Main
+// and Teardown tests are executed before the tests of the including
+// suite and their Variables, ComputedVariables, Metadata, DefaultHeaders,
+// Environments, OnFailure and BaseURL serve as defaults. Tests and
+// settings of the including suite take precedence: An included test
+// loaded from the same file as a local test in the same section is
+// dropped. FinalCookies of included suites are not inherited.
+// Cyclic includes are reported as an error.
 func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
+	return loadRawSuite(filename, fs, nil)
+}
+
+// loadRawSuite loads filename from fs; stack contains the suites
+// currently being loaded and is used to detect cyclic includes.
+func loadRawSuite(filename string, fs FileSystem, stack []string) (*RawSuite, error) {
+	filename = path.Clean(filepath.ToSlash(filename))
+	for i, name := range stack {
+		if name == filename {
+			cycle := append(stack[i:], filename)
+			return nil, fmt.Errorf("cyclic include %s",
+				strings.Join(cycle, " -> "))
+		}
+	}
+	stack = append(stack[:len(stack):len(stack)], filename)
+
 	raw, err := fs.Load(filename)
 	if err != nil {
 		return nil, err
@@ -456,7 +826,17 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 	}
 	rs.File = raw // re-set as decodeStritTo clears rs
 	dir := rs.File.Dirname()
-	load := func(elems []RawElement, which string) error {
+	if rs.Setup, err = expandRepeats(rs.Setup, "Setup", dir, fs); err != nil {
+		return nil, err
+	}
+	if rs.Main, err = expandRepeats(rs.Main, "Main", dir, fs); err != nil {
+		return nil, err
+	}
+	if rs.Teardown, err = expandRepeats(rs.Teardown, "Teardown", dir, fs); err != nil {
+		return nil, err
+	}
+	load := func(elems []RawElement, which string) ([]*RawTest, error) {
+		tests := make([]*RawTest, 0, len(elems))
 		for i, elem := range elems {
 			var err error
 			var rt *RawTest
@@ -465,7 +845,7 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 				filename = path.Join(dir, elem.File)
 				rt, err = LoadRawTest(filename, fs)
 				if err != nil {
-					return fmt.Errorf("unable to load %s (%d. %s): %s",
+					return nil, fmt.Errorf("unable to load %s (%d. %s): %s",
 						filename, i+1, which, err)
 				}
 			} else if len(elem.Test) != 0 {
@@ -473,34 +853,123 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 					rs.File.Name, i+1, which)
 				rt, err = rawTestFromInline(name, dir, fs, elem.Test)
 				if err != nil {
-					return fmt.Errorf("unable to parse inline test (%d. %s): %s",
+					return nil, fmt.Errorf("unable to parse inline test (%d. %s): %s",
 						i+1, which, err)
 
 				}
 			} else {
-				return fmt.Errorf("File and Test must not both be empty in %d. %s", i+1, which)
+				return nil, fmt.Errorf("File and Test must not both be empty in %d. %s", i+1, which)
 			}
 			rt.contextVars = elem.Variables
-			rs.tests = append(rs.tests, rt)
+			tests = append(tests, rt)
 		}
-		return nil
+		return tests, nil
 	}
-	err = load(rs.Setup, "Setup")
+	setup, err := load(rs.Setup, "Setup")
 	if err != nil {
 		return nil, err
 	}
-	err = load(rs.Main, "Main")
+	main, err := load(rs.Main, "Main")
 	if err != nil {
 		return nil, err
 	}
-	err = load(rs.Teardown, "Teardown")
+	teardown, err := load(rs.Teardown, "Teardown")
 	if err != nil {
 		return nil, err
 	}
+	if rs.OnFailure != nil {
+		onFailure, err := load([]RawElement{*rs.OnFailure}, "OnFailure")
+		if err != nil {
+			return nil, err
+		}
+		rs.onFailure = onFailure[0]
+	}
+
+	for _, inc := range rs.Include {
+		is, err := loadRawSuite(path.Join(dir, inc), fs, stack)
+		if err != nil {
+			return nil, fmt.Errorf("unable to include %s: %s", inc, err)
+		}
+		ns, nm := len(is.Setup), len(is.Main)
+		rs.Setup, setup = mergeRawElements(is.Setup, rs.Setup, is.tests[:ns], setup)
+		rs.Main, main = mergeRawElements(is.Main, rs.Main, is.tests[ns:ns+nm], main)
+		rs.Teardown, teardown = mergeRawElements(is.Teardown, rs.Teardown, is.tests[ns+nm:], teardown)
+		for name, value := range is.Variables {
+			if _, ok := rs.Variables[name]; ok {
+				continue
+			}
+			if rs.Variables == nil {
+				rs.Variables = make(map[string]string)
+			}
+			rs.Variables[name] = value
+		}
+		for name, value := range is.ComputedVariables {
+			if _, ok := rs.ComputedVariables[name]; ok {
+				continue
+			}
+			if rs.ComputedVariables == nil {
+				rs.ComputedVariables = make(map[string]string)
+			}
+			rs.ComputedVariables[name] = value
+		}
+		for key, value := range is.Metadata {
+			if _, ok := rs.Metadata[key]; ok {
+				continue
+			}
+			if rs.Metadata == nil {
+				rs.Metadata = make(map[string]string)
+			}
+			rs.Metadata[key] = value
+		}
+		for name, values := range is.DefaultHeaders {
+			if _, ok := rs.DefaultHeaders[name]; ok {
+				continue
+			}
+			if rs.DefaultHeaders == nil {
+				rs.DefaultHeaders = make(http.Header)
+			}
+			rs.DefaultHeaders[name] = values
+		}
+		if rs.onFailure == nil {
+			rs.OnFailure, rs.onFailure = is.OnFailure, is.onFailure
+		}
+		if rs.BaseURL == "" {
+			rs.BaseURL = is.BaseURL
+		}
+		for env, vars := range is.Environments {
+			if _, ok := rs.Environments[env]; ok {
+				continue
+			}
+			if rs.Environments == nil {
+				rs.Environments = make(map[string]map[string]string)
+			}
+			rs.Environments[env] = vars
+		}
+	}
+	rs.tests = append(append(setup, main...), teardown...)
 
 	return rs, nil
 }
 
+// mergeRawElements prepends the included elements and tests to the local
+// ones. Included tests read from the same file as a local test are dropped.
+func mergeRawElements(incElems, elems []RawElement, incTests, tests []*RawTest) ([]RawElement, []*RawTest) {
+	local := make(map[string]bool, len(tests))
+	for _, rt := range tests {
+		local[rt.File.Name] = true
+	}
+	mergedElems := make([]RawElement, 0, len(incElems)+len(elems))
+	mergedTests := make([]*RawTest, 0, len(incTests)+len(tests))
+	for i, rt := range incTests {
+		if local[rt.File.Name] {
+			continue
+		}
+		mergedElems = append(mergedElems, incElems[i])
+		mergedTests = append(mergedTests, rt)
+	}
+	return append(mergedElems, elems...), append(mergedTests, tests...)
+}
+
 func rawTestFromInline(name, dir string, fs FileSystem, inline map[string]interface{}) (*RawTest, error) {
 	mixins := []*Mixin{}
 	if m, ok := inline["Mixins"]; ok {
@@ -533,25 +1002,77 @@ func rawTestFromInline(name, dir string, fs FileSystem, inline map[string]interf
 	}, nil
 }
 
+// SelectEnvironment merges the variables of the environment name into
+// the Variables of rs. Global variables (e.g. from the command line) still
+// take precedence.
+func (rs *RawSuite) SelectEnvironment(name string) error {
+	env, ok := rs.Environments[name]
+	if !ok {
+		return fmt.Errorf("no environment %q in suite %s", name, rs.File.Name)
+	}
+	if rs.Variables == nil {
+		rs.Variables = make(map[string]string, len(env))
+	}
+	for n, v := range env {
+		rs.Variables[n] = v
+	}
+	return nil
+}
+
+// scope returns the scope of rt when called from outer: The variables of
+// the calling element, the defaults of rt and TEST_DIR and TEST_NAME.
+func (rt *RawTest) scope(outer map[string]string) (map[string]string, error) {
+	callScope, err := newScope(outer, rt.contextVars, true)
+	if err != nil {
+		return nil, err
+	}
+	testScope, err := newScope(callScope, rt.Variables, false)
+	if err != nil {
+		return nil, err
+	}
+	testScope["TEST_DIR"] = rt.File.Dirname()
+	testScope["TEST_NAME"] = rt.File.Basename()
+	return testScope, nil
+}
+
 // Validate rs to make sure it can be decoded into welformed ht.Tests.
 func (rs *RawSuite) Validate(global map[string]string) error {
-	suiteScope := newScope(global, rs.Variables, true)
+	el := ht.ErrorList{}
+	suiteScope, err := newScope(global, rs.Variables, true)
+	if err != nil {
+		el = append(el, err)
+	}
 	suiteScope["SUITE_DIR"] = rs.File.Dirname()
 	suiteScope["SUITE_NAME"] = rs.File.Basename()
 
-	el := ht.ErrorList{}
+	extracted := make(map[string]bool)
 	for _, rt := range rs.tests {
-		callScope := newScope(suiteScope, rt.contextVars, true)
-		testScope := newScope(callScope, rt.Variables, false)
-		testScope["TEST_DIR"] = rt.File.Dirname()
-		testScope["TEST_NAME"] = rt.File.Basename()
-		_, err := rt.ToTest(testScope)
+		testScope, err := rt.scope(suiteScope)
+		if err != nil {
+			el = append(el, fmt.Errorf("invalid test %s (included by %s): %s",
+				rt.File.Name, rs.File.Name, err))
+			continue
+		}
+		test, err := rt.ToTest(testScope)
+		if err == nil {
+			if undef, _ := rt.undefinedVariables(testScope, extracted); len(undef) > 0 {
+				err = fmt.Errorf("undefined variables %s", strings.Join(undef, ", "))
+			}
+		}
+		declareExtracted(extracted, test)
 		if err != nil {
 			err := fmt.Errorf("invalid test %s (included by %s): %s",
 				rt.File.Name, rs.File.Name, err)
 			el = append(el, err)
 		}
 	}
+	if rs.onFailure != nil {
+		if err := prepareOnFailure(suiteScope, rs.onFailure); err != nil {
+			el = append(el, fmt.Errorf("invalid OnFailure test %s (included by %s): %s",
+				rs.onFailure.File.Name, rs.File.Name, err))
+		}
+	}
+	el = append(el, prepareFinalCookies(rs.FinalCookies, rs.KeepCookies)...)
 	if len(el) > 0 {
 		return el
 	}
@@ -576,8 +1097,35 @@ func (rs *RawSuite) Validate(global map[string]string) error {
 //      Teardown-1    Pass     Pass
 //      Teardown-2    Fail     Error
 //      Teardown-3    Pass     Pass
-func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger *log.Logger) *Suite {
+func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger ht.Logger) *Suite {
+	return rs.ExecuteContext(context.Background(), global, jar, logger)
+}
+
+// ExecuteContext is like Execute but aborts the execution once ctx is
+// done: The test in flight is canceled and all remaining tests (including
+// the Teardown tests) are skipped.
+func (rs *RawSuite) ExecuteContext(ctx context.Context, global map[string]string, jar *cookiejar.Jar, logger ht.Logger) *Suite {
 	suite := NewFromRaw(rs, global, jar, logger)
+	if suite.varErr != nil {
+		suite.Status = ht.Bogus
+		suite.Error = suite.varErr
+		return suite
+	}
+	if rs.Cassette != "" && suite.Transport == nil {
+		cassette, err := OpenCassette(rs.cassettePath(), rs.CassetteMode)
+		if err != nil {
+			suite.Status = ht.Bogus
+			suite.Error = err
+			return suite
+		}
+		suite.Transport = cassette
+		defer func() {
+			if err := cassette.Save(); err != nil {
+				suite.Status = ht.Error
+				suite.Error = fmt.Errorf("cannot save cassette: %s", err)
+			}
+		}()
+	}
 	N := len(rs.tests)
 	setup, main, teardown := len(rs.Setup), len(rs.Main), len(rs.Teardown)
 	i := 0
@@ -585,6 +1133,7 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 	isMain := func() bool { return i > setup && i <= setup+main }
 	isSetupOrMain := func() bool { return i <= setup+main }
 	setupfailures := false
+	failed := false // some Setup or Main test failed, used for FailFast
 
 	executor := func(test *ht.Test) error {
 		i++
@@ -596,12 +1145,26 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 			test.Reporting.SeqNo = fmt.Sprintf("Teardown-%02d", i-setup-main)
 		}
 
+		reason := ""
 		switch {
+		case suite.TimedOut:
+			reason = "suite timeout exceeded"
+		case suite.Canceled:
+			reason = "suite canceled"
 		case test.Status == ht.Skipped:
-			fallthrough
+			reason = "skipped before execution"
 		case !rs.tests[i-1].IsEnabled():
-			fallthrough
+			reason = "disabled"
 		case setupfailures && isSetupOrMain():
+			reason = "failed setup"
+		case suite.FailFast && failed && isSetupOrMain():
+			reason = "fail fast"
+		}
+		if reason != "" {
+			if rs.Verbosity >= 1 {
+				suite.Log.Event("INFO", "skipping test", "test", test.Name,
+					"seqno", test.Reporting.SeqNo, "reason", reason)
+			}
 			test.Status = ht.Skipped
 			return nil
 		}
@@ -609,25 +1172,35 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 		if test.Status != ht.Bogus {
 			// Run only non-bogus tests.
 			test.Execution.Verbosity = rs.Verbosity
-			test.Run()
+			test.RunContext(ctx)
 		}
 		if test.Status > ht.Pass && isSetup() {
 			setupfailures = true
 		}
+		if test.Status > ht.Pass && isSetupOrMain() {
+			failed = true
+		}
+		if (test.Status == ht.Fail || test.Status == ht.Error) && suite.OnFailure != nil {
+			suite.runOnFailure(ctx, test)
+		}
 		return nil
 	}
 
 	// Overall Suite status is computetd from Setup and Main tests only.
-	suite.Iterate(executor)
+	suite.IterateContext(ctx, executor)
 	status := ht.NotRun
 	errors := ht.ErrorList{}
+	suite.Warnings = nil
 	for i := 0; i < N-teardown && i < len(suite.Tests); i++ {
-		if ts := suite.Tests[i].Status; ts > status {
-			status = ts
-		}
-		if err := suite.Tests[i].Error; err != nil {
-			errors = append(errors, err)
+		status = suite.combine(status, &errors, suite.Tests[i])
+	}
+	status = suite.combineAbort(status, &errors)
+	if !suite.Canceled && !suite.TimedOut {
+		cs, cerrs := suite.checkFinalCookies()
+		if cs > status {
+			status = cs
 		}
+		errors = append(errors, cerrs...)
 	}
 
 	suite.Status = status
@@ -640,6 +1213,64 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 	return suite
 }
 
+// DryRun constructs all tests of rs and prepares their checks and requests
+// without executing them. Disabled tests are Skipped, malformed tests are
+// Bogus and all others stay NotRun. A malformed OnFailure test or malformed
+// FinalCookies make the suite Bogus. Variables extracted during a real execution are unavailable and
+// their references are left untouched.
+func (rs *RawSuite) DryRun(global map[string]string, logger ht.Logger) *Suite {
+	suite := NewFromRaw(rs, global, nil, logger)
+	suite.Started = time.Now()
+	status := ht.NotRun
+	errors := ht.ErrorList{}
+	if suite.varErr != nil {
+		status = ht.Bogus
+		errors = append(errors, suite.varErr)
+	}
+	for i, rt := range suite.tests {
+		if i == suite.setup {
+			suite.computeVariables()
+		}
+		test, err := suite.makeTest(rt)
+		declareExtracted(suite.extracted, test)
+		if _, ok := err.(notExtractedError); ok {
+			err = nil // extracted only during a real execution
+		}
+		if !rt.IsEnabled() {
+			test.Status, test.Error = ht.Skipped, nil
+		} else {
+			if err == nil {
+				err = test.Prepare()
+			}
+			if err != nil {
+				errors = append(errors, fmt.Errorf("%s: %s", rt.File.Name, err))
+			}
+		}
+		if test.Status > status {
+			status = test.Status
+		}
+		suite.Tests = append(suite.Tests, test)
+	}
+	if suite.OnFailure != nil {
+		if err := prepareOnFailure(suite.scope, suite.OnFailure); err != nil {
+			status = ht.Bogus
+			errors = append(errors, fmt.Errorf("OnFailure %s: %s",
+				suite.OnFailure.File.Name, err))
+		}
+	}
+	if errs := prepareFinalCookies(suite.FinalCookies, suite.KeepCookies); len(errs) > 0 {
+		status = ht.Bogus
+		errors = append(errors, errs...)
+	}
+	suite.Duration = time.Since(suite.Started)
+	suite.Status = status
+	if len(errors) > 0 {
+		suite.Error = errors
+	}
+
+	return suite
+}
+
 // ----------------------------------------------------------------------------
 // FileSystem
 
@@ -707,6 +1338,7 @@ type RawScenario struct {
 	MaxThreads int               // MaxThreads to use for this scenario. 0 means unlimited.
 	Variables  map[string]string // Variables used.
 	OmitChecks bool              // OmitChecks in the tests.
+	Weights    map[string]int    // Weights of the Main tests, see Scenario.
 
 	rawSuite *RawSuite
 }
@@ -761,22 +1393,30 @@ func LoadRawLoadtest(filename string, fs FileSystem) (*RawLoadTest, error) {
 	return rlt, nil
 }
 
-// ToScenario produces a list of scenarios from raw.
-func (raw *RawLoadTest) ToScenario(globals map[string]string) []Scenario {
+// ToScenario produces a list of scenarios from raw. An error is returned
+// for reference cycles in the variables of raw or its scenarios.
+func (raw *RawLoadTest) ToScenario(globals map[string]string) ([]Scenario, error) {
 	scenarios := []Scenario{}
-	ltscope := newScope(globals, raw.Variables, true)
+	ltscope, err := newScope(globals, raw.Variables, true)
+	if err != nil {
+		return nil, err
+	}
 	for _, rs := range raw.Scenarios {
-		callscope := newScope(ltscope, rs.Variables, true)
+		callscope, err := newScope(ltscope, rs.Variables, true)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %s", rs.Name, err)
+		}
 		scen := Scenario{
 			Name:       rs.Name,
 			RawSuite:   rs.rawSuite,
 			Percentage: rs.Percentage,
 			MaxThreads: rs.MaxThreads,
+			Weights:    rs.Weights,
 			globals:    callscope,
 		}
 
 		scenarios = append(scenarios, scen)
 	}
 
-	return scenarios
+	return scenarios, nil
 }
@@ -6,9 +6,15 @@ package suite
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/vdobler/ht/ht"
 )
@@ -91,7 +97,10 @@ func TestRawTestToTest(t *testing.T) {
 	variables := map[string]string{
 		"VAR_B": "zulu",
 	}
-	testScope := newScope(variables, raw.Variables, false)
+	testScope, err := newScope(variables, raw.Variables, false)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
 	test, err := raw.ToTest(testScope)
 	if err != nil {
 		t.Fatalf("Unexpected error %s", err)
@@ -476,7 +485,10 @@ func TestLoadRawLoadtest(t *testing.T) {
 		"GLOBALVAR": "globalvar",
 	}
 
-	scenarios := raw.ToScenario(global)
+	scenarios, err := raw.ToScenario(global)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
 	for i, scen := range scenarios {
 		fmt.Printf("%d. %d%% %q (max %d threads)\n",
 			i, scen.Percentage, scen.RawSuite.Name, scen.MaxThreads)
@@ -522,3 +534,895 @@ func TestInlineTests(t *testing.T) {
 	fmt.Println(s.Status)
 	fmt.Println(s.Tests[0].PrintReport(os.Stdout))
 }
+
+func TestSuiteInclude(t *testing.T) {
+	txt := `
+# main.suite
+{
+    Name: "Main Suite"
+    Include: [ "common/smoke.suite" ]
+    Main: [
+        {File: "local.ht"}
+        {File: "common/shared.ht"}
+    ]
+    Variables: { HOST: "local.example.org" }
+    ComputedVariables: { STAMP: "{{NOW | \"2006\"}}" }
+}
+
+# common/smoke.suite
+{
+    Name: "Smoke"
+    Setup: [ {File: "login.ht"} ]
+    Main: [ {File: "home.ht"}, {File: "shared.ht"} ]
+    Teardown: [ {File: "logout.ht"} ]
+    Variables: { HOST: "smoke.example.org", USER: "joe" }
+    ComputedVariables: { STAMP: "{{RANDOM 9}}", ID: "{{RANDOM 99}}" }
+    BaseURL: "http://smoke.example.org"
+    FinalCookies: [ {Name: "session", Absent: true} ]
+}
+
+# common/login.ht
+{Name: "Login", Request: {URL: "http://{{HOST}}/login"}}
+
+# common/home.ht
+{Name: "Home", Request: {URL: "http://{{HOST}}/"}}
+
+# common/shared.ht
+{Name: "Shared", Request: {URL: "http://{{HOST}}/shared"}}
+
+# common/logout.ht
+{Name: "Logout", Request: {URL: "http://{{HOST}}/logout"}}
+
+# local.ht
+{Name: "Local", Request: {URL: "http://{{HOST}}/local"}}
+`
+	rs, err := parseRawSuite("main.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(rs.Setup) != 1 || len(rs.Main) != 3 || len(rs.Teardown) != 1 {
+		t.Errorf("Got %d/%d/%d tests", len(rs.Setup), len(rs.Main), len(rs.Teardown))
+	}
+	got := []string{}
+	for _, rt := range rs.RawTests() {
+		got = append(got, rt.File.Name)
+	}
+	want := []string{"common/login.ht", "common/home.ht", "local.ht",
+		"common/shared.ht", "common/logout.ht"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got tests %v, want %v", got, want)
+	}
+	if rs.Variables["HOST"] != "local.example.org" || rs.Variables["USER"] != "joe" {
+		t.Errorf("Got variables %v", rs.Variables)
+	}
+	if want := map[string]string{"STAMP": `{{NOW | "2006"}}`, "ID": "{{RANDOM 99}}"}; !reflect.DeepEqual(rs.ComputedVariables, want) {
+		t.Errorf("Got computed variables %v", rs.ComputedVariables)
+	}
+	if rs.BaseURL != "http://smoke.example.org" {
+		t.Errorf("Got BaseURL %q", rs.BaseURL)
+	}
+	if len(rs.FinalCookies) != 0 {
+		t.Errorf("Got FinalCookies %v", rs.FinalCookies)
+	}
+}
+
+func TestSuiteIncludeCycle(t *testing.T) {
+	txt := `
+# a.suite
+{Name: "A", Include: [ "sub/b.suite" ]}
+
+# sub/b.suite
+{Name: "B", Include: [ "../a.suite" ]}
+`
+	_, err := parseRawSuite("a.suite", txt)
+	if err == nil || !strings.Contains(err.Error(), "cyclic include a.suite -> sub/b.suite -> a.suite") {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { requests++ }))
+	defer ts.Close()
+
+	txt := `
+# dry.suite
+{
+    Name: "Dry Run"
+    Main: [
+        {File: "good.ht"}
+        {File: "bad.ht"}
+    ]
+    Variables: { URL: "` + ts.URL + `" }
+}
+
+# good.ht
+{
+    Name: "Good"
+    Request: { URL: "{{URL}}/good" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# bad.ht
+{
+    Name: "Bad"
+    Request: { URL: "{{URL}}/bad" }
+    Checks: [ {Check: "Body", Regexp: "[a-"} ]
+}
+`
+	rs, err := parseRawSuite("dry.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.DryRun(nil, nil)
+	if requests != 0 {
+		t.Errorf("Got %d requests", requests)
+	}
+	if len(s.Tests) != 2 {
+		t.Fatalf("Got %d tests", len(s.Tests))
+	}
+	if s.Tests[0].Status != ht.NotRun || s.Tests[1].Status != ht.Bogus {
+		t.Errorf("Got %s and %s", s.Tests[0].Status, s.Tests[1].Status)
+	}
+	if s.Status != ht.Bogus || s.Error == nil ||
+		!strings.Contains(s.Error.Error(), "bad.ht") {
+		t.Errorf("Got %s %v", s.Status, s.Error)
+	}
+}
+
+func TestSuiteTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				time.Sleep(500 * time.Millisecond)
+			}
+		}))
+	defer ts.Close()
+
+	txt := `
+# timeout.suite
+{
+    Name: "Timeout"
+    Timeout: "200ms"
+    Main: [ {File: "fast.ht"}, {File: "slow.ht"}, {File: "fast.ht"} ]
+    Variables: { URL: "` + ts.URL + `" }
+}
+
+# fast.ht
+{
+    Name: "Fast"
+    Request: { URL: "{{URL}}/fast" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# slow.ht
+{
+    Name: "Slow"
+    Request: { URL: "{{URL}}/slow" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+`
+	rs, err := parseRawSuite("timeout.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	start := time.Now()
+	s := rs.Execute(nil, nil, logger())
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("Suite took %s", d)
+	}
+	if !s.TimedOut {
+		t.Errorf("Suite did not time out")
+	}
+	if len(s.Tests) != 3 {
+		t.Fatalf("Got %d tests", len(s.Tests))
+	}
+	if s.Tests[0].Status != ht.Pass || s.Tests[1].Status != ht.Error ||
+		s.Tests[2].Status != ht.Skipped {
+		t.Errorf("Got %s, %s and %s", s.Tests[0].Status,
+			s.Tests[1].Status, s.Tests[2].Status)
+	}
+	if s.Status != ht.Error || s.Error == nil ||
+		!strings.Contains(s.Error.Error(), "suite timeout of 200ms exceeded") {
+		t.Errorf("Got %s %v", s.Status, s.Error)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	txt := `
+# env.suite
+{
+    Name: "Environments"
+    Main: [ {File: "a.ht"} ]
+    Variables: { URL: "http://localhost", USER: "tester", PASS: "secret" }
+    Environments: {
+        prod: { URL: "https://www.example.org", USER: "monitor" }
+    }
+}
+
+# a.ht
+{
+    Name: "A"
+    Request: { URL: "{{URL}}/a" }
+}
+`
+	rs, err := parseRawSuite("env.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.SelectEnvironment("staging"); err == nil {
+		t.Errorf("Missing error for unknown environment")
+	}
+	if err := rs.SelectEnvironment("prod"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := NewFromRaw(rs, map[string]string{"USER": "admin"}, nil, logger())
+	if got := matchVars(s.Variables,
+		"URL=https://www.example.org USER=admin PASS=secret"); got != "" {
+		t.Error(got)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	txt := `
+# base.suite
+{
+    Name: "Base URL"
+    BaseURL: "{{HOST}}/api/"
+    Main: [
+        {File: "a.ht", Variables: {PATH: "/status"}}
+        {File: "a.ht", Variables: {PATH: "items?page=2"}}
+        {File: "a.ht", Variables: {PATH: "http://other.example.org/x"}}
+    ]
+    Variables: { HOST: "http://www.example.org" }
+}
+
+# a.ht
+{
+    Name: "A"
+    Request: { URL: "{{PATH}}" }
+}
+`
+	rs, err := parseRawSuite("base.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.DryRun(nil, nil)
+	for i, want := range []string{
+		"http://www.example.org/api/status",
+		"http://www.example.org/api/items?page=2",
+		"http://other.example.org/x",
+	} {
+		if got := s.Tests[i].Request.URL; got != want {
+			t.Errorf("%d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+// roundTripFunc is a http.RoundTripper answering all requests itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSuiteTransport(t *testing.T) {
+	txt := `
+# transport.suite
+{
+    Name: "Transport"
+    Main: [ {File: "a.ht"}, {File: "a.ht"} ]
+}
+
+# a.ht
+{
+    Request: { URL: "http://nonexisting.example.org/" }
+    Checks: [ {Check: "StatusCode", Expect: 418} ]
+}
+`
+	rs, err := parseRawSuite("transport.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	calls := 0
+	rs.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: 418,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	})
+
+	s := rs.Execute(nil, nil, logger())
+	if s.Status != ht.Pass || calls != 2 {
+		t.Errorf("Got %s after %d calls: %v", s.Status, calls, s.Error)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	txt := `
+# repeat.suite
+{
+    Name: "Repeat"
+    Main: [
+        {
+            File: "item.ht"
+            Variables: { ID: "7" }
+            Repeat: {
+                ACCEPT: [ "application/json", "application/xml" ]
+                TYPE:   [ "json", "xml" ]
+            }
+        }
+        {File: "item.ht", Variables: { ID: "8", ACCEPT: "text/html", TYPE: "html" }}
+    ]
+}
+
+# item.ht
+{
+    Name: "Item {{ID}} as {{TYPE}}"
+    Request: {
+        URL: "http://www.example.org/item/{{ID}}"
+        Header: { Accept: "{{ACCEPT}}" }
+    }
+    Checks: [ {Check: "ContentType", Is: "{{TYPE}}"} ]
+}
+`
+	rs, err := parseRawSuite("repeat.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.DryRun(nil, nil)
+	if len(s.Tests) != 3 {
+		t.Fatalf("Got %d tests, want 3", len(s.Tests))
+	}
+	for i, want := range []string{
+		"Item 7 as json application/json",
+		"Item 7 as xml application/xml",
+		"Item 8 as html text/html",
+	} {
+		test := s.Tests[i]
+		if got := test.Name + " " + test.Request.Header.Get("Accept"); got != want {
+			t.Errorf("%d: got %q, want %q", i, got, want)
+		}
+	}
+
+	txt = strings.Replace(txt, `"xml" ]`, `"xml", "html" ]`, 1)
+	if _, err := parseRawSuite("repeat.suite", txt); err == nil {
+		t.Errorf("Missing error for Repeat lists of different length")
+	}
+}
+
+func TestDataFrom(t *testing.T) {
+	txt := `
+# data.suite
+{
+    Name: "DataFrom"
+    Main: [
+        {File: "item.ht", DataFrom: "data/items.csv", Variables: { TYPE: "json" }}
+        {File: "item.ht", DataFrom: "data/items.jsonl"}
+    ]
+}
+
+# data/items.csv
+ID,TYPE
+7,xml
+"8",html
+
+# data/items.jsonl
+{"ID": 9, "TYPE": "json"}
+
+{"ID": "10", "TYPE": "text"}
+
+# item.ht
+{
+    Name: "Item {{ID}} as {{TYPE}}"
+    Request: { URL: "http://www.example.org/item/{{ID}}" }
+}
+`
+	rs, err := parseRawSuite("data.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.DryRun(nil, nil)
+	want := []string{"Item 7 as xml", "Item 8 as html", "Item 9 as json", "Item 10 as text"}
+	if len(s.Tests) != len(want) {
+		t.Fatalf("Got %d tests, want %d", len(s.Tests), len(want))
+	}
+	for i, w := range want {
+		if got := s.Tests[i].Name; got != w {
+			t.Errorf("%d: got %q, want %q", i, got, w)
+		}
+	}
+
+	bad := strings.Replace(txt, `DataFrom: "data/items.jsonl"`,
+		`DataFrom: "data/items.jsonl", Repeat: {ID: ["1"]}`, 1)
+	if _, err := parseRawSuite("data.suite", bad); err == nil {
+		t.Errorf("Missing error for Repeat and DataFrom")
+	}
+	bad = strings.Replace(txt, "items.jsonl\"}", "missing.jsonl\"}", 1)
+	if _, err := parseRawSuite("data.suite", bad); err == nil {
+		t.Errorf("Missing error for missing data file")
+	}
+}
+
+func TestSuiteThreshold(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/broken" {
+				http.Error(w, "oops", http.StatusInternalServerError)
+			}
+		}))
+	defer ts.Close()
+
+	suite := func(threshold, crit string) string {
+		return `
+# crit.suite
+{
+    Name: "Criticality"
+    Main: [ {File: "ok.ht"}, {File: "broken.ht"} ]
+    Variables: { URL: "` + ts.URL + `" }
+    ` + threshold + `
+}
+
+# ok.ht
+{
+    Name: "OK"
+    Request: { URL: "{{URL}}/ok" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# broken.ht
+{
+    Name: "Broken"
+    ` + crit + `
+    Request: { URL: "{{URL}}/broken" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+`
+	}
+
+	for i, tc := range []struct {
+		threshold, crit string
+		status          ht.Status
+		warnings        int
+	}{
+		{``, ``, ht.Fail, 0},
+		{``, `Criticality: "Info"`, ht.Fail, 0},
+		{`Threshold: "Error"`, ``, ht.Fail, 0},
+		{`Threshold: "Error"`, `Criticality: "Warn"`, ht.Pass, 1},
+		{`Threshold: "Fatal"`, ``, ht.Pass, 1},
+		{`Threshold: "Warn"`, `Criticality: "Warn"`, ht.Fail, 0},
+	} {
+		rs, err := parseRawSuite("crit.suite", suite(tc.threshold, tc.crit))
+		if err != nil {
+			t.Fatalf("%d. Unexpected error: %s", i, err)
+		}
+		s := rs.Execute(nil, nil, logger())
+		if s.Status != tc.status || len(s.Warnings) != tc.warnings {
+			t.Errorf("%d. Got %s with %d warnings (%v), want %s with %d",
+				i, s.Status, len(s.Warnings), s.Warnings, tc.status, tc.warnings)
+		}
+		if tc.warnings > 0 && s.Error != nil {
+			t.Errorf("%d. Unexpected error %s", i, s.Error)
+		}
+	}
+}
+
+func TestFinalCookies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/login":
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123",
+					Path: "/", HttpOnly: true})
+				http.SetCookie(w, &http.Cookie{Name: "prefs", Value: "dark",
+					Path: "/", MaxAge: 3600})
+			case "/logout":
+				http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+			}
+		}))
+	defer ts.Close()
+
+	txt := `
+# cookies.suite
+{
+    KeepCookies: true
+    Main: [
+        {File: "req.ht", Variables: {PATH: "login"}}
+        {File: "req.ht", Variables: {PATH: "{{LOGOUT}}"}}
+    ]
+}
+
+# req.ht
+{
+    Request: { URL: "{{URL}}/{{PATH}}" }
+}
+`
+	for i, tc := range []struct {
+		logout string
+		final  []FinalCookie
+		want   ht.Status
+	}{
+		{"logout", []FinalCookie{{Name: "session", Absent: true}}, ht.Pass},
+		{"stay", []FinalCookie{{Name: "session", Absent: true}}, ht.Fail},
+		{"stay", []FinalCookie{{Name: "session", Type: "session httpOnly",
+			Value: ht.Condition{Equals: "abc123"}}}, ht.Pass},
+		{"stay", []FinalCookie{{Name: "session", Type: "persistent"}}, ht.Fail},
+		{"logout", []FinalCookie{{Name: "prefs", Type: "persistent exposed", Path: "/"}}, ht.Pass},
+		{"logout", []FinalCookie{{Name: "prefs", Domain: "other.example.org"}}, ht.Fail},
+		{"logout", []FinalCookie{{Name: "prefs", Type: "tasty"}}, ht.Bogus},
+	} {
+		rs, err := parseRawSuite("cookies.suite", txt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		rs.FinalCookies = tc.final
+		global := map[string]string{"URL": ts.URL, "LOGOUT": tc.logout}
+		s := rs.Execute(global, nil, logger())
+		if s.Status != tc.want {
+			t.Errorf("%d. Got %s, want %s: %v", i, s.Status, tc.want, s.Error)
+		}
+	}
+
+	// Malformed FinalCookies are reported without executing the suite.
+	for i, tc := range []struct {
+		keep  bool
+		final []FinalCookie
+		want  string
+	}{
+		{true, []FinalCookie{{Name: "prefs", Type: "tasty"}}, "unknown stuff"},
+		{true, []FinalCookie{{Type: "session"}}, "without Name"},
+		{false, []FinalCookie{{Name: "session", Absent: true}}, "need KeepCookies"},
+	} {
+		rs, err := parseRawSuite("cookies.suite", txt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		rs.KeepCookies = tc.keep
+		rs.FinalCookies = tc.final
+		global := map[string]string{"URL": ts.URL, "LOGOUT": "logout"}
+		if err := rs.Validate(global); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%d. Validate: got %v, want %q", i, err, tc.want)
+		}
+		s := rs.DryRun(global, nil)
+		if s.Status != ht.Bogus || s.Error == nil || !strings.Contains(s.Error.Error(), tc.want) {
+			t.Errorf("%d. DryRun: got %s %v, want %q", i, s.Status, s.Error, tc.want)
+		}
+	}
+}
+
+func TestMaxLinkConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inflight, peak := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/page" {
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, "<!doctype html><html><body>")
+				for i := 0; i < 8; i++ {
+					fmt.Fprintf(w, `<a href="/link%d"></a>`, i)
+				}
+				fmt.Fprint(w, "</body></html>")
+				return
+			}
+			mu.Lock()
+			inflight++
+			if inflight > peak {
+				peak = inflight
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+		}))
+	defer ts.Close()
+
+	txt := `
+# links.suite
+{
+    MaxLinkConcurrency: {{MAX}}
+    Main: [ {File: "links.ht"} ]
+}
+
+# links.ht
+{
+    Request: { URL: "{{URL}}/page" }
+    Checks: [ {Check: "Links", Which: "a", Concurrency: 8} ]
+}
+`
+	for _, max := range []int{0, 2} {
+		rs, err := parseRawSuite("links.suite", strings.Replace(txt, "{{MAX}}", fmt.Sprint(max), 1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		peak = 0
+		s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+		if s.Status != ht.Pass {
+			t.Errorf("%d: Got %s: %v", max, s.Status, s.Error)
+		}
+		if max == 0 && peak <= 2 {
+			t.Errorf("Unlimited: got only %d concurrent link requests", peak)
+		} else if max > 0 && peak > max {
+			t.Errorf("Got %d concurrent link requests, want at most %d", peak, max)
+		}
+	}
+}
+
+func TestMaxBodySizeFromFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, strings.Repeat("x", 100))
+		}))
+	defer ts.Close()
+
+	txt := `
+# limit.suite
+{
+    Main: [ {File: "limit.ht"} ]
+}
+
+# limit.ht
+{
+    Request: { URL: "{{URL}}/big", MaxBodySize: 10 }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+`
+	rs, err := parseRawSuite("limit.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	test := s.Tests[0]
+	if test.Request.MaxBodySize != 10 || test.Status != ht.Error ||
+		!test.Response.Truncated || len(test.Response.BodyStr) != 10 {
+		t.Errorf("Got MaxBodySize=%d status %s, truncated=%t, %d bytes: %v",
+			test.Request.MaxBodySize, test.Status, test.Response.Truncated,
+			len(test.Response.BodyStr), test.Error)
+	}
+}
+
+func TestJSONChangedFlow(t *testing.T) {
+	var mu sync.Mutex
+	resource := `{"name": "Joe", "address": {"city": "Bern", "zip": "3000"}, "modified": 1}`
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Method == "PATCH" {
+				resource = `{"name": "Jo", "address": {"city": "Basel", "zip": "3000"}, "modified": 2}`
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, resource)
+		}))
+	defer ts.Close()
+
+	txt := `
+# patch.suite
+{
+    Main: [ {File: "get.ht"}, {File: "patch.ht"} ]
+}
+
+# get.ht
+{
+    Request: { URL: "{{URL}}/resource" }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+    ]
+    VarEx: { BEFORE: {Extractor: "BodyExtractor", Regexp: "(?s).*"} }
+}
+
+# patch.ht
+{
+    Request: { Method: "PATCH", URL: "{{URL}}/resource" }
+    Checks: [
+        {Check: "JSONChanged", Variable: "BEFORE", Changed: [ "name", "address.city" ], Ignore: [ "modified" ]}
+    ]
+}
+`
+	rs, err := parseRawSuite("patch.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if s.Status != ht.Pass {
+		for _, test := range s.Tests {
+			t.Logf("%s: %s %v", test.Name, test.Status, test.Error)
+		}
+		t.Errorf("Got %s: %v", s.Status, s.Error)
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	txt := `
+# headers.suite
+{
+    Variables: { ENV: "qa" }
+    DefaultHeaders: {
+        User-Agent: [ "ht-{{ENV}}" ]
+        Accept: [ "application/json" ]
+    }
+    Main: [ {File: "a.ht"}, {File: "b.ht"}, {File: "c.ht"} ]
+}
+
+# a.ht
+{
+    Request: { URL: "http://www.example.org/a" }
+}
+
+# b.ht
+{
+    Request: {
+        URL: "http://www.example.org/b"
+        Header: { Accept: "text/html" }
+    }
+}
+
+# c.ht
+{
+    Request: {
+        URL: "http://www.example.org/c"
+        Header: { user-agent: "x" }
+    }
+}
+`
+	rs, err := parseRawSuite("headers.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.DryRun(nil, nil)
+	if len(s.Tests) != 3 {
+		t.Fatalf("Got %d tests, want 3", len(s.Tests))
+	}
+	for i, want := range []string{"ht-qa application/json", "ht-qa text/html",
+		"x application/json"} {
+		h := http.Header{}
+		for name, values := range s.Tests[i].Request.Header {
+			for _, v := range values {
+				h.Add(name, v)
+			}
+		}
+		got := strings.Join(h["User-Agent"], ",") + " " + strings.Join(h["Accept"], ",")
+		if got != want {
+			t.Errorf("%d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/fail" {
+				http.Error(w, "failed", http.StatusInternalServerError)
+			}
+		}))
+	defer ts.Close()
+
+	txt := `
+# failfast.suite
+{
+    Main: [
+        {File: "req.ht", Variables: {PATH: "ok"}}
+        {File: "req.ht", Variables: {PATH: "fail"}}
+        {File: "req.ht", Variables: {PATH: "ok"}}
+    ]
+    Teardown: [
+        {File: "req.ht", Variables: {PATH: "ok"}}
+    ]
+}
+
+# req.ht
+{
+    Request: { URL: "{{URL}}/{{PATH}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+`
+	for _, failFast := range []bool{false, true} {
+		rs, err := parseRawSuite("failfast.suite", txt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		rs.FailFast = failFast
+		s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+		want := []ht.Status{ht.Pass, ht.Fail, ht.Pass, ht.Pass}
+		if failFast {
+			want[2] = ht.Skipped
+		}
+		for i, test := range s.Tests {
+			if test.Status != want[i] {
+				t.Errorf("FailFast=%t, test %d: got %s, want %s",
+					failFast, i, test.Status, want[i])
+			}
+		}
+		if s.Status != ht.Fail {
+			t.Errorf("FailFast=%t: got suite status %s", failFast, s.Status)
+		}
+	}
+}
+
+func TestOnFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/fail":
+				http.Error(w, "failed", http.StatusInternalServerError)
+			case "/debug/vars":
+				fmt.Fprintf(w, "failed=%s", r.URL.Query().Get("test"))
+			}
+		}))
+	defer ts.Close()
+
+	txt := `
+# onfailure.suite
+{
+    Main: [
+        {File: "req.ht", Variables: {PATH: "ok"}}
+        {File: "req.ht", Variables: {PATH: "fail"}}
+    ]
+    OnFailure: {File: "debug.ht"}
+}
+
+# req.ht
+{
+    Name: "Request {{PATH}}"
+    Request: { URL: "{{URL}}/{{PATH}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# debug.ht
+{
+    Name: "Debug {{FAILED_SEQNO}}"
+    Request: {
+        URL: "{{URL}}/debug/vars"
+        Params: { test: "{{FAILED_TEST}} ({{PATH}})" }
+    }
+    Checks: [ {Check: "StatusCode", Expect: 500} ]
+}
+`
+	rs, err := parseRawSuite("onfailure.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if s.Status != ht.Fail {
+		t.Errorf("Got suite status %s, want FAIL", s.Status)
+	}
+	if len(s.Diagnostics) != 1 {
+		t.Fatalf("Got %d diagnostics, want 1", len(s.Diagnostics))
+	}
+	diag := s.Diagnostics[0]
+	if diag.Name != "Debug Main-02" || diag.Reporting.SeqNo != "Main-02-OnFailure" {
+		t.Errorf("Got name %q and seqno %q", diag.Name, diag.Reporting.SeqNo)
+	}
+	if diag.Status != ht.Fail { // expected 500 but got 200
+		t.Errorf("Got diagnostics status %s: %v", diag.Status, diag.Error)
+	}
+	if got := diag.Response.BodyStr; got != "failed=Request fail (fail)" {
+		t.Errorf("Got diagnostics body %q", got)
+	}
+
+	// Validate and DryRun prepare the OnFailure test; variables of the
+	// failing test like PATH are not reported as undefined.
+	global := map[string]string{"URL": ts.URL}
+	if err := rs.Validate(global); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if s := rs.DryRun(global, nil); s.Status != ht.NotRun {
+		t.Errorf("Got dry run status %s: %v", s.Status, s.Error)
+	}
+	bad := strings.Replace(txt, `{Check: "StatusCode", Expect: 500}`,
+		`{Check: "Body", Regexp: "[a-"}`, 1)
+	if rs, err = parseRawSuite("onfailure.suite", bad); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.Validate(global); err == nil || !strings.Contains(err.Error(), "debug.ht") {
+		t.Errorf("Got %v", err)
+	}
+	if s := rs.DryRun(global, nil); s.Status != ht.Bogus ||
+		s.Error == nil || !strings.Contains(s.Error.Error(), "debug.ht") {
+		t.Errorf("Got dry run status %s: %v", s.Status, s.Error)
+	}
+}
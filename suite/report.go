@@ -40,6 +40,7 @@ var htmlCheckTmpl = `{{define "CHECK"}}
       <div>Checking took {{niceduration .Check.Duration}}</div>
       <div><code>{{.Check.JSON}}</code></div>
       {{if eq .Check.Status 3 5}}<pre class="description">{{.Check.Error.Error}}</pre>{{end}}
+      {{if .Check.Warning}}<pre class="description">Warning: {{.Check.Warning.Error}}</pre>{{end}}
     </div>
   </div>
 </div>
@@ -75,6 +76,8 @@ var htmlTestTmpl = `{{define "TEST"}}
 	Full Duration: {{niceduration .FullDuration}} <br/>
         Number of tries: {{.Tries}} <br/>
         Request Duration: {{niceduration .Duration}} <br/>
+        {{if .Response.Response}}Protocol: {{.Response.Response.Proto}}{{if .Request.HTTPVersion}} (requested HTTP/{{.Request.HTTPVersion}}){{end}} <br/>
+        Connection: {{if .Response.ConnReused}}reused{{else}}new{{end}} <br/>{{end}}
         {{if .Error}}<br/><strong>Error:</strong> {{.Error}}<br/>{{end}}
       </div>
       {{if .Request.Request}}{{template "REQUEST" .}}{{end}}
@@ -157,6 +160,14 @@ var htmlRequestTmpl = `{{define "REQUEST"}}
       </code>
       {{template "HEADER" .Request.Request.Header}}
 <pre>{{clean .Request.SentBody}}</pre>
+      {{with .Response.Timing}}{{if .TTFB}}<div class="timing">
+        Timing:
+        DNS {{niceduration .DNS}},
+        Connect {{niceduration .Connect}},
+        TLS {{niceduration .TLS}},
+        TTFB {{niceduration .TTFB}},
+        Body {{niceduration .Body}}
+      </div>{{end}}{{end}}
     </div>
   </div>
 </div>
@@ -205,13 +216,19 @@ var htmlVariablesTmpl = `{{define "VARIABLES"}}
 {{end}}
 `
 
-var defaultSuiteTmpl = `{{Box (printf "%s: %s" (ToUpper .Status.String) .Name) ""}}{{if .Error}}
-Error: {{.Error}}{{end}}
+var defaultSuiteTmpl = `{{Box (printf "%s: %s" (ToUpper .Status.String) .Name) ""}}{{if .Description}}
+{{.Description}}{{end}}{{range $k, $v := .Metadata}}
+{{$k}}: {{$v}}{{end}}{{if .Error}}
+Error: {{.Error}}{{end}}{{if .Warnings}}
+Warnings: {{.Warnings}}{{end}}
 Started: {{.Started}}   Duration: {{niceduration .Duration}}
 
 {{range .Tests}}{{template "TEST" .}}
-{{end}}
-`
+{{end}}{{if .Diagnostics}}
+Diagnostics of failed tests:
+
+{{range .Diagnostics}}{{template "TEST" .}}
+{{end}}{{end}}`
 
 var shortSuiteTmpl = `======  Result of {{.Name}} =======
 {{range .Tests}}{{template "SHORTTEST" .}}{{end}}{{printf "===> %s <=== %s" (ToUpper .Status.String) .Name}}
@@ -307,12 +324,18 @@ var htmlSuiteTmpl = `<!DOCTYPE html>
 
 <div class="summary">
   Status: <span class="{{ToUpper .Status.String}}">{{ToUpper .Status.String}}</span> <br/>
-  Started: {{.Started}} <br/>
+  {{range $k, $v := .Metadata}}{{$k}}: {{$v}} <br/>
+  {{end}}Started: {{.Started}} <br/>
   Full Duration: {{niceduration .Duration}}
 </div>
 
 {{range .Tests}}{{template "TEST" .}}{{end}}
 
+{{if .Diagnostics}}
+<h1>Diagnostics of Failed Tests</h1>
+{{range .Diagnostics}}{{template "TEST" .}}{{end}}
+{{end}}
+
 </body>
 </html>
 `
@@ -416,44 +439,119 @@ func roundDuration(d time.Duration) time.Duration {
 	return d
 }
 
-func init() {
-	fm := make(template.FuncMap)
-	//fm["Underline"] = Underline
-	fm["Box"] = ht.Box
-	fm["ToUpper"] = strings.ToUpper
-	fm["nicetime"] = roundTimeToMS
-	fm["niceduration"] = roundDuration
+// niceDuration formats d rounded like roundDuration. Durations of an hour
+// or longer are rounded to full minutes and durations of a day or longer
+// are shown with days, e.g. "2d3h4m".
+func niceDuration(d time.Duration) string {
+	if d < time.Hour {
+		return roundDuration(d).String()
+	}
+	m := (d + 30*time.Second) / time.Minute
+	days, hours, mins := m/(24*60), (m/60)%24, m%60
+	if days == 0 {
+		return fmt.Sprintf("%dh%dm", hours, mins)
+	}
+	return fmt.Sprintf("%dd%dh%dm", days, hours, mins)
+}
+
+// textFuncs are the functions available in the text templates.
+var textFuncs = template.FuncMap{
+	"Underline":    ht.Underline,
+	"Box":          ht.Box,
+	"ToUpper":      strings.ToUpper,
+	"nicetime":     roundTimeToMS,
+	"niceduration": niceDuration,
+}
 
+// htmlFuncs are the functions available in the HTML templates.
+var htmlFuncs = htmltemplate.FuncMap{
+	"ToUpper":      strings.ToUpper,
+	"Summary":      ht.Summary,
+	"loop":         loopIteration,
+	"dict":         dict,
+	"clean":        cleanSentBody,
+	"nicetime":     roundTimeToMS,
+	"niceduration": niceDuration,
+}
+
+func init() {
 	SuiteTmpl = template.New("SUITE")
-	SuiteTmpl.Funcs(fm)
+	SuiteTmpl.Funcs(textFuncs)
 	SuiteTmpl = template.Must(SuiteTmpl.Parse(defaultSuiteTmpl))
 	SuiteTmpl = template.Must(SuiteTmpl.Parse(ht.DefaultTestTemplate))
 	SuiteTmpl = template.Must(SuiteTmpl.Parse(ht.DefaultCheckTemplate))
 
 	ShortSuiteTmpl = template.New("SHORTSUITE")
-	ShortSuiteTmpl.Funcs(fm)
+	ShortSuiteTmpl.Funcs(textFuncs)
 	ShortSuiteTmpl = template.Must(ShortSuiteTmpl.Parse(shortSuiteTmpl))
 	ShortSuiteTmpl = template.Must(ShortSuiteTmpl.Parse(ht.ShortTestTemplate))
 
-	HtmlSuiteTmpl = htmltemplate.New("SUITE")
-	HtmlSuiteTmpl.Funcs(htmltemplate.FuncMap{
-		"ToUpper":      strings.ToUpper,
-		"Summary":      ht.Summary,
-		"loop":         loopIteration,
-		"dict":         dict,
-		"clean":        cleanSentBody,
-		"nicetime":     roundTimeToMS,
-		"niceduration": roundDuration,
-	})
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlSuiteTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlTestTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlCheckTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlResponseTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlRequestTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlHeaderTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlFormdataTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlVariablesTmpl))
-	HtmlSuiteTmpl = htmltemplate.Must(HtmlSuiteTmpl.Parse(htmlStyleTmpl))
+	HtmlSuiteTmpl = htmltemplate.Must(parseHTMLTemplate(htmlSuiteTmpl))
+}
+
+// parseHTMLTemplate parses src as the HTML suite template. The default
+// sub-templates (TEST, CHECK, RESPONSE, REQUEST, HEADER, FORMDATA,
+// VARIABLES and STYLE) are available unless src defines its own.
+func parseHTMLTemplate(src string) (*htmltemplate.Template, error) {
+	tmpl, err := htmltemplate.New("SUITE").Funcs(htmlFuncs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range []struct{ name, src string }{
+		{"TEST", htmlTestTmpl},
+		{"CHECK", htmlCheckTmpl},
+		{"RESPONSE", htmlResponseTmpl},
+		{"REQUEST", htmlRequestTmpl},
+		{"HEADER", htmlHeaderTmpl},
+		{"FORMDATA", htmlFormdataTmpl},
+		{"VARIABLES", htmlVariablesTmpl},
+		{"STYLE", htmlStyleTmpl},
+	} {
+		if tmpl.Lookup(sub.name) != nil {
+			continue
+		}
+		if tmpl, err = tmpl.Parse(sub.src); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// SetTextTemplate replaces the template used by PrintReport with src.
+// The template is executed with the Suite as data and has access to the
+// functions ToUpper, Box, Underline, nicetime and niceduration. The
+// default TEST and CHECK sub-templates are available unless src defines
+// its own.
+func SetTextTemplate(src string) error {
+	tmpl, err := template.New("SUITE").Funcs(textFuncs).Parse(src)
+	if err != nil {
+		return err
+	}
+	for _, sub := range []struct{ name, src string }{
+		{"TEST", ht.DefaultTestTemplate},
+		{"CHECK", ht.DefaultCheckTemplate},
+	} {
+		if tmpl.Lookup(sub.name) != nil {
+			continue
+		}
+		if tmpl, err = tmpl.Parse(sub.src); err != nil {
+			return err
+		}
+	}
+	SuiteTmpl = tmpl
+	return nil
+}
+
+// SetHTMLTemplate replaces the template used by HTMLReport with src.
+// The template is executed with the Suite as data. Functions and default
+// sub-templates are the same as for the builtin HTML report.
+func SetHTMLTemplate(src string) error {
+	tmpl, err := parseHTMLTemplate(src)
+	if err != nil {
+		return err
+	}
+	HtmlSuiteTmpl = tmpl
+	return nil
 }
 
 // PrintReport outputs a textual report of s to w.
@@ -492,7 +590,8 @@ func guessResponseExtension(test *ht.Test) string {
 func HTMLReport(dir string, s *Suite) error {
 	errs := ht.ErrorList{}
 
-	for _, test := range s.Tests {
+	tests := append(append([]*ht.Test{}, s.Tests...), s.Diagnostics...)
+	for _, test := range tests {
 		if tn, ok := test.Variables["TEST_NAME"]; ok {
 			test.Reporting.Filename = tn
 		} else {
@@ -535,24 +634,37 @@ func HTMLReport(dir string, s *Suite) error {
 // NotRun checks are reported as Skipped and Bogus checks are counted as
 // Errored tests.
 func (s *Suite) JUnit4XML() (string, error) {
+	return s.junit4XML(0)
+}
+
+// JUnit4XMLWithOutput works like JUnit4XML but the system-out of each
+// testcase contains the request URL and the first snippet bytes of the
+// response body of the test the check belongs to. A non-positive snippet
+// defaults to 200 bytes.
+func (s *Suite) JUnit4XMLWithOutput(snippet int) (string, error) {
+	if snippet <= 0 {
+		snippet = 200
+	}
+	return s.junit4XML(snippet)
+}
+
+// junit4XML produces the JUnit report; a positive snippet enables the
+// per testcase output.
+func (s *Suite) junit4XML(snippet int) (string, error) {
 	// Local types used for XML encoding
 	type SysOut struct {
 		XMLName xml.Name `xml:"system-out"`
 		Data    string   `xml:",innerxml"`
 	}
-	type ErrorMsg struct {
-		Message string `xml:"message,attr"`
-		Typ     string `xml:"type,attr"`
-	}
 	type Testcase struct {
-		XMLName   xml.Name  `xml:"testcase"`
-		Name      string    `xml:"name,attr"`
-		Classname string    `xml:"classname,attr"`
-		Time      float64   `xml:"time,attr"`
-		Skipped   *struct{} `xml:"Skipped,omitempty"`
-		Error     *ErrorMsg `xml:"error,omitempty"`
-		Failure   *ErrorMsg `xml:"failure,omitempty"`
-		SystemOut string    `xml:"system-out,omitempty"`
+		XMLName   xml.Name       `xml:"testcase"`
+		Name      string         `xml:"name,attr"`
+		Classname string         `xml:"classname,attr"`
+		Time      float64        `xml:"time,attr"`
+		Skipped   *struct{}      `xml:"Skipped,omitempty"`
+		Error     *junitErrorMsg `xml:"error,omitempty"`
+		Failure   *junitErrorMsg `xml:"failure,omitempty"`
+		SystemOut string         `xml:"system-out,omitempty"`
 	}
 	type Property struct {
 		Name  string `xml:"name,attr"`
@@ -576,15 +688,19 @@ func (s *Suite) JUnit4XML() (string, error) {
 	skipped, passed, failed, errored := 0, 0, 0, 0
 	testcases := []Testcase{}
 	for _, test := range s.Tests {
+		testOut := ""
+		if snippet > 0 {
+			testOut = junitTestOutput(test, snippet)
+		}
 		if test.Status >= ht.Error {
 			// report all checks as errored but with special message
 			for _, cr := range test.CheckResults {
 				tc := Testcase{
 					Name:      cr.Name,
 					Classname: test.Name,
-					SystemOut: cr.JSON,
+					SystemOut: cr.JSON + testOut,
 				}
-				tc.Error = &ErrorMsg{
+				tc.Error = &junitErrorMsg{
 					Message: test.Error.Error(),
 					Typ:     fmt.Sprintf("main test error, check not run"),
 				}
@@ -598,7 +714,7 @@ func (s *Suite) JUnit4XML() (string, error) {
 					Name:      cr.Name,
 					Classname: test.Name,
 					Time:      float64(cr.Duration) / 1e9,
-					SystemOut: cr.JSON,
+					SystemOut: cr.JSON + testOut,
 				}
 
 				switch cr.Status {
@@ -606,18 +722,15 @@ func (s *Suite) JUnit4XML() (string, error) {
 					tc.Skipped = &struct{}{}
 					skipped++
 				case ht.Pass:
+					if len(cr.Warning) > 0 {
+						tc.SystemOut = "Warning: " + cr.Warning.Error() + "\n" + tc.SystemOut
+					}
 					passed++
 				case ht.Fail:
-					tc.Failure = &ErrorMsg{
-						Message: cr.Error.Error(),
-						Typ:     fmt.Sprintf("%T", test.Error),
-					}
+					tc.Failure = newJUnitErrorMsg(cr.Error)
 					failed++
 				case ht.Error, ht.Bogus:
-					tc.Error = &ErrorMsg{
-						Message: test.Error.Error(),
-						Typ:     fmt.Sprintf("%T", test.Error),
-					}
+					tc.Error = newJUnitErrorMsg(cr.Error)
 					errored++
 				default:
 					panic(cr.Status)
@@ -653,6 +766,12 @@ func (s *Suite) JUnit4XML() (string, error) {
 	for k, v := range s.Variables {
 		ts.Properties = append(ts.Properties, Property{Name: k, Value: v})
 	}
+	if s.Description != "" {
+		ts.Properties = append(ts.Properties, Property{Name: "description", Value: s.Description})
+	}
+	for k, v := range s.Metadata {
+		ts.Properties = append(ts.Properties, Property{Name: "metadata." + k, Value: v})
+	}
 
 	data, err := xml.MarshalIndent(ts, "", "  ")
 	if err != nil {
@@ -687,3 +806,40 @@ func xmlEscapeChars(s []byte) string {
 	}
 	return buf.String()
 }
+
+// junitErrorMsg is the content of a JUnit failure or error element.
+type junitErrorMsg struct {
+	Message string `xml:"message,attr"`
+	Typ     string `xml:"type,attr"`
+}
+
+// newJUnitErrorMsg reports the errors el of a single check.
+func newJUnitErrorMsg(el ht.ErrorList) *junitErrorMsg {
+	if len(el) == 0 {
+		return &junitErrorMsg{Message: "unknown error"}
+	}
+	return &junitErrorMsg{
+		Message: strings.Join(el.AsStrings(), "; "),
+		Typ:     fmt.Sprintf("%T", el[0]),
+	}
+}
+
+// junitTestOutput formats the request URL and the first snippet bytes of
+// the response body of test.
+func junitTestOutput(test *ht.Test, snippet int) string {
+	buf := &bytes.Buffer{}
+	if req := test.Request.Request; req != nil {
+		fmt.Fprintf(buf, "\n%s %s\n", req.Method, req.URL)
+	} else {
+		fmt.Fprintf(buf, "\n%s %s\n", test.Request.Method, test.Request.URL)
+	}
+	if resp := test.Response.Response; resp != nil {
+		fmt.Fprintf(buf, "%s\n", resp.Status)
+	}
+	body := test.Response.BodyStr
+	if len(body) > snippet {
+		body = body[:snippet] + "..."
+	}
+	buf.WriteString(body)
+	return buf.String()
+}
@@ -5,8 +5,16 @@
 package suite
 
 import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
 	"testing"
+	"text/template"
 	"time"
+
+	"github.com/vdobler/ht/ht"
 )
 
 var (
@@ -49,3 +57,165 @@ func TestRoundDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestNiceDuration(t *testing.T) {
+	hour, day := 60*min, 24*60*min
+	for i, tc := range []struct {
+		in   time.Duration
+		want string
+	}{
+		{59*sec + 789*ms, "59.8s"},
+		{59*min + 59*sec, "59m59s"},
+		{2 * hour, "2h0m"},
+		{2*hour + 29*sec, "2h0m"},
+		{2*hour + 3*min + 31*sec, "2h4m"},
+		{day, "1d0h0m"},
+		{2*day + 3*hour + 4*min, "2d3h4m"},
+	} {
+		if got := niceDuration(tc.in); got != tc.want {
+			t.Errorf("%d. niceDuration(%s) = %s, want %s",
+				i, tc.in, got, tc.want)
+		}
+	}
+}
+
+var testcaseRe = regexp.MustCompile(`(?s)<testcase .*?</testcase>`)
+
+func TestJUnit4XML(t *testing.T) {
+	test := &ht.Test{
+		Name:   "Test",
+		Status: ht.Fail,
+		Error:  errors.New("test level error"),
+		Request: ht.Request{
+			Method: "GET",
+			URL:    "http://example.org/foo",
+		},
+		Response: ht.Response{BodyStr: "Hello World"},
+		CheckResults: []ht.CheckResult{
+			{Name: "StatusCode", Status: ht.Pass},
+			{Name: "Body", Status: ht.Fail,
+				Error: ht.ErrorList{errors.New("body check failed")}},
+			{Name: "Header", Status: ht.Bogus,
+				Error: ht.ErrorList{errors.New("header check bogus")}},
+		},
+	}
+	s := &Suite{Name: "Suite", Tests: []*ht.Test{test}}
+
+	junit, err := s.JUnit4XML()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	for _, want := range []string{
+		`<failure message="body check failed"`,
+		`<error message="header check bogus"`,
+	} {
+		if !strings.Contains(junit, want) {
+			t.Errorf("Missing %s in\n%s", want, junit)
+		}
+	}
+	// The suite level system-out contains the full text report, so
+	// look at the testcases only.
+	testcases := strings.Join(testcaseRe.FindAllString(junit, -1), "\n")
+	if len(testcases) == 0 {
+		t.Fatalf("No testcase in\n%s", junit)
+	}
+	if strings.Contains(testcases, "test level error") {
+		t.Errorf("Check reported test level error:\n%s", testcases)
+	}
+	if strings.Contains(testcases, "example.org/foo") {
+		t.Errorf("Unexpected test output:\n%s", testcases)
+	}
+
+	junit, err = s.JUnit4XMLWithOutput(5)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	for _, want := range []string{"GET http://example.org/foo", "Hello..."} {
+		if n := strings.Count(junit, want); n != 3 {
+			t.Errorf("Got %d times %q, want 3:\n%s", n, want, junit)
+		}
+	}
+}
+
+func TestReportMetadata(t *testing.T) {
+	s := &Suite{
+		Name:        "Suite",
+		Description: "Checks the shop API",
+		Status:      ht.Pass,
+		Metadata: map[string]string{
+			"Owner":  "team-checkout",
+			"Ticket": "https://issues.example.org/SHOP-42",
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.PrintReport(buf); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	text := buf.String()
+	for _, want := range []string{
+		"\nChecks the shop API\n",
+		"\nOwner: team-checkout\nTicket: https://issues.example.org/SHOP-42\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Missing %q in text report\n%s", want, text)
+		}
+	}
+
+	buf.Reset()
+	if err := HtmlSuiteTmpl.Execute(buf, s); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if html := buf.String(); !strings.Contains(html, "Owner: team-checkout <br/>") {
+		t.Errorf("Missing metadata in HTML report\n%s", html)
+	}
+
+	junit, err := s.JUnit4XML()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	for _, want := range []string{
+		`<property name="description" value="Checks the shop API"></property>`,
+		`<property name="metadata.Owner" value="team-checkout"></property>`,
+	} {
+		if !strings.Contains(junit, want) {
+			t.Errorf("Missing %s in\n%s", want, junit)
+		}
+	}
+}
+
+func TestSetTemplates(t *testing.T) {
+	defer func(text *template.Template, html *htmltemplate.Template) {
+		SuiteTmpl, HtmlSuiteTmpl = text, html
+	}(SuiteTmpl, HtmlSuiteTmpl)
+
+	s := &Suite{Name: "Demo", Status: ht.Pass}
+	if err := SetTextTemplate(`ACME {{ToUpper .Name}}{{range .Tests}}{{template "TEST" .}}{{end}}`); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := s.PrintReport(buf); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if got := buf.String(); got != "ACME DEMO" {
+		t.Errorf("Got %q", got)
+	}
+
+	if err := SetHTMLTemplate(`<h1>ACME {{.Name}}</h1>{{template "STYLE"}}`); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	buf.Reset()
+	if err := HtmlSuiteTmpl.Execute(buf, s); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "<h1>ACME Demo</h1>") {
+		t.Errorf("Got %q", got)
+	}
+
+	if err := SetTextTemplate("{{Unknown .Name}}"); err == nil {
+		t.Errorf("Missing error for unknown function")
+	}
+	if err := SetHTMLTemplate("{{if .Name}}"); err == nil {
+		t.Errorf("Missing error for malformed template")
+	}
+}
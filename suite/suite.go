@@ -5,15 +5,22 @@
 package suite
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/urlpath"
 )
 
 // A Suite is a collection of Tests which can be executed sequentily with the
@@ -23,21 +30,104 @@ type Suite struct {
 	Description string // Description of what's going on here.
 	KeepCookies bool   // KeepCookies in a cookie jar common to all Tests.
 
+	// Metadata like the owning team, a ticket link or the environment
+	// is shown in all reports to ease triage of failing suites.
+	Metadata map[string]string
+
 	Status   ht.Status     // Status is the overall status of the whole suite.
 	Error    error         // Error encountered during execution of the suite.
 	Started  time.Time     // Start of the execution.
 	Duration time.Duration // Duration of the execution.
 
+	// Threshold is the minimal criticality of tests whose failures
+	// contribute to Status and Error. Failures of less critical tests
+	// are collected in Warnings instead.
+	Threshold ht.Criticality
+	Warnings  ht.ErrorList
+
+	// MaxRequestsPerSecond, if positive, limits the rate of all requests
+	// made by the tests of this suite, including sub-requests of checks
+	// like Links. The limit is per suite: Suites iterated concurrently
+	// do not share it.
+	MaxRequestsPerSecond float64
+
+	// MaxLinkConcurrency, if positive, limits the number of link requests
+	// made concurrently by the Links checks of all tests of this suite.
+	// Like MaxRequestsPerSecond it is per suite. Without it only the
+	// package-wide ht.MaxLinkConcurrency applies.
+	MaxLinkConcurrency int
+
+	// Timeout, if positive, limits the execution time of the whole
+	// suite. Requests in flight when the timeout is exceeded are
+	// aborted and all remaining tests are skipped. TimedOut reports
+	// whether this happened.
+	Timeout  time.Duration
+	TimedOut bool
+
+	// Canceled reports whether the iteration was canceled through
+	// the context passed to IterateContext.
+	Canceled bool
+
+	// BaseURL, if non-empty, is prepended to all request URLs which are
+	// not absolute (after variable substitution). This allows to write
+	// tests with relative URLs like "/api/status".
+	BaseURL string
+
+	// FailFast stops the execution once a Setup or Main test failed or
+	// errored: All remaining Setup and Main tests are skipped, the
+	// Teardown tests are still executed.
+	FailFast bool
+
+	// DefaultHeaders are added to the request of each test which does not
+	// set the respective header itself. Variables are expanded in the
+	// header values, e.g. to send "User-Agent: ht-{{ENV}}".
+	DefaultHeaders http.Header
+
+	// Transport, if non-nil, is used for the requests of all tests which
+	// do not set their own Transport. The precedence is: Test.Transport,
+	// Suite.Transport and finally the package global ht.Transport.
+	Transport http.RoundTripper
+
+	// FinalCookies are the expected cookies in Jar after all tests have
+	// been executed. Missing or unexpected cookies fail the suite.
+	FinalCookies []FinalCookie
+
+	// OnFailure, if non-nil, is executed after each failing or erroring
+	// test to capture diagnostics like the state of the server. It is
+	// made from the variables of the failing test plus FAILED_TEST and
+	// FAILED_SEQNO (the name and sequence number of the failing test).
+	// The executed OnFailure tests are collected in Diagnostics; their
+	// outcome does not influence the Status of the suite.
+	OnFailure   *RawTest
+	Diagnostics []*ht.Test
+
 	Tests []*ht.Test // The Tests to execute
 
 	Variables      map[string]string // The initial variable assignemnt
 	FinalVariables map[string]string // The final set of variables.
 	Jar            *cookiejar.Jar    // The cookie jar used
-	Log            *log.Logger       // The logger used.
+	Log            ht.Logger         // The logger used.
 	Verbosity      int
 
+	// BeforeEach, if non-nil, is called before each test is executed.
+	// Modifications of the test made by BeforeEach take effect.
+	BeforeEach func(test *ht.Test)
+
+	// AfterEach, if non-nil, is called after each test was executed
+	// with the error of the test (which is nil for passing tests).
+	AfterEach func(test *ht.Test, err error)
+
 	scope map[string]string
 	tests []*RawTest
+
+	setup     int               // number of setup tests in tests
+	global    map[string]string // the global scope
+	computed  map[string]string // the ComputedVariables of the RawSuite
+	frozen    map[string]bool   // variables which must not be changed
+	extracted map[string]bool   // variables extracted by some VarEx so far
+	varErr    error             // reference cycle in the suite's Variables
+	limiter   *ht.RateLimiter   // shared by all tests, nil if unlimited
+	linkSem   chan struct{}     // shared by all tests, nil if unlimited
 }
 
 func shouldRun(t int, rs *RawSuite, s *Suite) bool {
@@ -54,7 +144,9 @@ func shouldRun(t int, rs *RawSuite, s *Suite) bool {
 	return true
 }
 
-func newScope(outer, inner map[string]string, auto bool) map[string]string {
+// newScope returns a new scope made from outer and the defaults in inner.
+// The error reports reference cycles in inner.
+func newScope(outer, inner map[string]string, auto bool) (map[string]string, error) {
 	// 1. Copy of outer scope
 	scope := make(map[string]string, len(outer)+len(inner)+2)
 	for gn, gv := range outer {
@@ -64,23 +156,83 @@ func newScope(outer, inner map[string]string, auto bool) map[string]string {
 		scope["COUNTER"] = strconv.Itoa(<-GetCounter)
 		scope["RANDOM"] = strconv.Itoa(100000 + ht.RandomIntn(900000))
 	}
-	replacer := varReplacer(scope)
 
 	// 2. Merging inner defaults, allow substitutions from outer scope
-	for name, val := range inner {
+	// and from other inner variables.
+	err := resolveVariables(scope, inner)
+
+	return scope, err
+}
+
+// varReference matches a reference {{name}} to a variable.
+var varReference = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// resolveVariables merges vars into scope: Variables already present in
+// scope are not overwritten. References in the values of vars to variables
+// in scope and to other variables in vars are expanded; the variables in
+// vars may reference each other in any order. Variables which are part of
+// a reference cycle are merged with these references unexpanded and the
+// cycles are reported as an error.
+func resolveVariables(scope, vars map[string]string) error {
+	const (
+		unvisited = iota
+		visiting
+		resolved
+	)
+	state := make(map[string]int, len(vars))
+	errs := ht.ErrorList{}
+
+	var resolve func(name string, path []string)
+	resolve = func(name string, path []string) {
+		state[name] = visiting
+		path = append(path, name)
+		val := varReference.ReplaceAllStringFunc(vars[name], func(ref string) string {
+			n := ref[2 : len(ref)-2]
+			if _, ok := vars[n]; ok {
+				switch state[n] {
+				case unvisited:
+					resolve(n, path)
+				case visiting:
+					errs = append(errs, fmt.Errorf("variable cycle %s -> %s",
+						strings.Join(path, " -> "), n))
+					return ref
+				}
+			}
+			if v, ok := scope[n]; ok {
+				return v
+			}
+			return ref
+		})
+		scope[name] = val
+		state[name] = resolved
+	}
+
+	// Process variables in sorted order to get deterministic errors.
+	names := make([]string, 0, len(vars))
+	for name := range vars {
 		if _, ok := scope[name]; ok {
 			// Variable name exists in outer scope, do not
-			// overwrite with suite defaults.
+			// overwrite with inner defaults.
+			state[name] = resolved
 			continue
 		}
-		scope[name] = replacer.Replace(val)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if state[name] == unvisited {
+			resolve(name, nil)
+		}
 	}
 
-	return scope
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // NewFromRaw sets up a new Suite from rs, read to be Iterated.
-func NewFromRaw(rs *RawSuite, global map[string]string, jar *cookiejar.Jar, logger *log.Logger) *Suite {
+func NewFromRaw(rs *RawSuite, global map[string]string, jar *cookiejar.Jar, logger ht.Logger) *Suite {
 	// Create cookie jar if needed.
 	if rs.KeepCookies {
 		if jar == nil {
@@ -92,7 +244,7 @@ func NewFromRaw(rs *RawSuite, global map[string]string, jar *cookiejar.Jar, logg
 	}
 
 	if logger == nil {
-		logger = log.New(ioutil.Discard, "", 0)
+		logger = ht.NewLogger(log.New(ioutil.Discard, "", 0))
 	}
 
 	suite := &Suite{
@@ -103,21 +255,44 @@ func NewFromRaw(rs *RawSuite, global map[string]string, jar *cookiejar.Jar, logg
 
 		Tests: make([]*ht.Test, 0, len(rs.tests)),
 
-		Variables:      make(map[string]string),
-		FinalVariables: make(map[string]string),
-		Jar:            jar,
-		Log:            logger,
-		Verbosity:      rs.Verbosity,
-		tests:          rs.tests,
+		Variables:            make(map[string]string),
+		FinalVariables:       make(map[string]string),
+		Jar:                  jar,
+		Log:                  logger,
+		Verbosity:            rs.Verbosity,
+		Threshold:            rs.Threshold,
+		MaxRequestsPerSecond: rs.MaxRequestsPerSecond,
+		MaxLinkConcurrency:   rs.MaxLinkConcurrency,
+		Timeout:              rs.Timeout,
+		Transport:            rs.Transport,
+		FinalCookies:         rs.FinalCookies,
+		DefaultHeaders:       rs.DefaultHeaders,
+		FailFast:             rs.FailFast,
+		OnFailure:            rs.onFailure,
+		BeforeEach:           rs.BeforeEach,
+		AfterEach:            rs.AfterEach,
+		tests:                rs.tests,
+		setup:                len(rs.Setup),
+		global:               global,
+		computed:             rs.ComputedVariables,
+		frozen:               make(map[string]bool),
+		extracted:            make(map[string]bool),
 	}
 
-	suite.scope = newScope(global, rs.Variables, true)
+	suite.scope, suite.varErr = newScope(global, rs.Variables, true)
 	suite.scope["SUITE_DIR"] = rs.File.Dirname()
 	suite.scope["SUITE_NAME"] = rs.File.Basename()
 	replacer := varReplacer(suite.scope)
 
 	suite.Name = replacer.Replace(rs.Name)
 	suite.Description = replacer.Replace(rs.Description)
+	suite.BaseURL = replacer.Replace(rs.BaseURL)
+	if len(rs.Metadata) > 0 {
+		suite.Metadata = make(map[string]string, len(rs.Metadata))
+		for k, v := range rs.Metadata {
+			suite.Metadata[k] = replacer.Replace(v)
+		}
+	}
 
 	for n, v := range suite.scope {
 		suite.Variables[n] = v
@@ -137,40 +312,91 @@ var (
 
 // Iterate the suite through the given executor.
 func (suite *Suite) Iterate(executor Executor) {
+	suite.IterateContext(context.Background(), executor)
+}
+
+// IterateContext is like Iterate but skips all tests once ctx is done.
+// The executor is responsible to cancel a test in flight.
+func (suite *Suite) IterateContext(ctx context.Context, executor Executor) {
 	now := time.Now()
 	now = now.Add(-time.Duration(now.Nanosecond()))
 	suite.Started = now
 
+	suite.limiter = ht.NewRateLimiter(suite.MaxRequestsPerSecond)
+	suite.linkSem = nil
+	if suite.MaxLinkConcurrency > 0 {
+		suite.linkSem = make(chan struct{}, suite.MaxLinkConcurrency)
+	}
+
+	var deadline time.Time
+	if suite.Timeout > 0 {
+		deadline = time.Now().Add(suite.Timeout)
+	}
+
 	overall := ht.NotRun
 	errors := ht.ErrorList{}
 
-	for _, rt := range suite.tests {
+	for i, rt := range suite.tests {
+		if i == suite.setup {
+			suite.computeVariables()
+		}
 		// suite.Log.Printf("Executing Test %q\n", rt.File.Name)
-		callScope := newScope(suite.scope, rt.contextVars, true)
-		testScope := newScope(callScope, rt.Variables, false)
-		testScope["TEST_DIR"] = rt.File.Dirname()
-		testScope["TEST_NAME"] = rt.File.Basename()
-		test, err := rt.ToTest(testScope)
-		if err != nil {
-			test.Status = ht.Bogus
-			test.Error = err
-		}
-		test.Jar = suite.Jar
-		test.Log = suite.Log
+		test, err := suite.makeTest(rt)
+		declareExtracted(suite.extracted, test)
+		if ne, ok := err.(notExtractedError); ok {
+			suite.Log.Event("INFO", "skipping test", "test", rt.File.Name,
+				"reason", ne.Error())
+			test.Status, err = ht.Skipped, nil
+		}
+		if err == nil && test.Request.FromForm != nil {
+			var prev *ht.Test
+			if len(suite.Tests) > 0 {
+				prev = suite.Tests[len(suite.Tests)-1]
+			}
+			if err := test.PopulateFromForm(prev); err != nil {
+				test.Status = ht.Bogus
+				test.Error = fmt.Errorf("cannot populate from form: %s", err)
+			}
+		}
+		if test.RandomSeed != 0 {
+			suite.Log.Event("INFO", "using random seed",
+				"test", rt.File.Name, "seed", test.RandomSeed)
+		}
 
+		if !deadline.IsZero() {
+			test.Deadline = deadline
+			if !time.Now().Before(deadline) {
+				suite.TimedOut = true
+			}
+		}
+		if ctx.Err() != nil {
+			suite.Canceled = true
+		}
+		if suite.TimedOut || suite.Canceled {
+			test.Status, test.Error = ht.Skipped, nil
+		}
+
+		if suite.BeforeEach != nil {
+			suite.BeforeEach(test)
+		}
 		exstat := executor(test)
+		if suite.AfterEach != nil {
+			suite.AfterEach(test, test.Error)
+		}
 		if test.Status == ht.Pass {
 			suite.updateVariables(test)
 		}
 
-		suite.Tests = append(suite.Tests, test)
-		if test.Status > overall {
-			overall = test.Status
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			suite.TimedOut = true
 		}
-		if err := test.Error; err != nil {
-			errors = append(errors, err)
+		if ctx.Err() != nil {
+			suite.Canceled = true
 		}
 
+		suite.Tests = append(suite.Tests, test)
+		overall = suite.combine(overall, &errors, test)
+
 		if exstat == ErrAbortExecution {
 			break
 		}
@@ -178,6 +404,7 @@ func (suite *Suite) Iterate(executor Executor) {
 	suite.Duration = time.Since(suite.Started)
 	clip := suite.Duration.Nanoseconds() % 1000000
 	suite.Duration -= time.Duration(clip)
+	overall = suite.combineAbort(overall, &errors)
 	suite.Status = overall
 	if len(errors) == 0 {
 		suite.Error = nil
@@ -190,31 +417,224 @@ func (suite *Suite) Iterate(executor Executor) {
 	}
 }
 
+// critical reports whether test is at least as critical as the suite's
+// Threshold.
+func (suite *Suite) critical(test *ht.Test) bool {
+	return suite.Threshold == ht.CritDefault ||
+		test.Criticality.Effective() >= suite.Threshold
+}
+
+// combine the status of test into status and collect its error in errors
+// and returns the new status. Failing or erroring tests which are not
+// critical contribute only a Pass to status and their error to
+// suite.Warnings.
+func (suite *Suite) combine(status ht.Status, errors *ht.ErrorList, test *ht.Test) ht.Status {
+	ts, err := test.Status, test.Error
+	if (ts == ht.Fail || ts == ht.Error) && !suite.critical(test) {
+		ts = ht.Pass
+		if err == nil {
+			err = fmt.Errorf("%s: %s", test.Name, test.Status)
+		}
+		suite.Warnings = append(suite.Warnings, err)
+		err = nil
+	}
+	if ts > status {
+		status = ts
+	}
+	if err != nil {
+		*errors = append(*errors, err)
+	}
+	return status
+}
+
+// combineAbort adds an error to errors if the suite timed out or was
+// canceled and returns the new status.
+func (suite *Suite) combineAbort(status ht.Status, errors *ht.ErrorList) ht.Status {
+	switch {
+	case suite.TimedOut:
+		*errors = append(*errors, fmt.Errorf("suite timeout of %s exceeded", suite.Timeout))
+	case suite.Canceled:
+		*errors = append(*errors, fmt.Errorf("suite canceled"))
+	default:
+		return status
+	}
+	if status < ht.Error {
+		status = ht.Error
+	}
+	return status
+}
+
+// makeTest produces a ht.Test from rt in the current suite scope. The
+// returned test is Bogus if err != nil unless err is a notExtractedError
+// in which case the test is fine but must not be executed.
+func (suite *Suite) makeTest(rt *RawTest) (*ht.Test, error) {
+	return suite.makeTestIn(suite.scope, rt)
+}
+
+// makeTestIn is like makeTest but uses outer instead of the suite scope.
+func (suite *Suite) makeTestIn(outer map[string]string, rt *RawTest) (*ht.Test, error) {
+	testScope, err := rt.scope(outer)
+	if err != nil {
+		return &ht.Test{Name: rt.File.Name, Status: ht.Bogus, Error: err}, err
+	}
+	test, err := rt.ToTest(testScope)
+	if err == nil {
+		undef, unextracted := rt.undefinedVariables(testScope, suite.extracted)
+		if len(undef) > 0 {
+			err = fmt.Errorf("undefined variables %s", strings.Join(undef, ", "))
+		} else if len(unextracted) > 0 {
+			return test, notExtractedError(unextracted)
+		}
+	}
+	if err != nil {
+		test.Status = ht.Bogus
+		test.Error = err
+	} else if suite.BaseURL != "" {
+		if u, err := url.Parse(test.Request.URL); err == nil && !u.IsAbs() {
+			test.Request.URL = urlpath.SingleJoiningSlash(suite.BaseURL, test.Request.URL)
+		}
+	}
+	if len(suite.DefaultHeaders) > 0 {
+		if test.Request.Header == nil {
+			test.Request.Header = make(http.Header)
+		}
+		own := make(map[string]bool, len(test.Request.Header))
+		for name := range test.Request.Header {
+			own[http.CanonicalHeaderKey(name)] = true
+		}
+		replacer := varReplacer(testScope)
+		for name, values := range suite.DefaultHeaders {
+			name = http.CanonicalHeaderKey(name)
+			if own[name] {
+				continue // The test's own header wins.
+			}
+			for _, v := range values {
+				test.Request.Header.Add(name, replacer.Replace(v))
+			}
+		}
+	}
+	test.Jar = suite.Jar
+	test.Log = suite.Log
+	if test.Transport == nil {
+		test.Transport = suite.Transport
+	}
+	test.RateLimiter = suite.limiter
+	test.LinkSemaphore = suite.linkSem
+	return test, err
+}
+
+// runOnFailure executes the OnFailure test of suite for the failed test
+// and adds it to the Diagnostics.
+func (suite *Suite) runOnFailure(ctx context.Context, failed *ht.Test) {
+	scope := make(map[string]string, len(failed.Variables)+2)
+	for name, value := range failed.Variables {
+		scope[name] = value
+	}
+	scope["FAILED_TEST"] = failed.Name
+	scope["FAILED_SEQNO"] = failed.Reporting.SeqNo
+
+	diag, _ := suite.makeTestIn(scope, suite.OnFailure)
+	diag.Reporting.SeqNo = failed.Reporting.SeqNo + "-OnFailure"
+	diag.Deadline = failed.Deadline
+	if !diag.Deadline.IsZero() && !time.Now().Before(diag.Deadline) {
+		diag.Status = ht.Skipped
+	} else if diag.Status != ht.Bogus {
+		diag.Execution.Verbosity = suite.Verbosity
+		diag.RunContext(ctx)
+	}
+	if suite.Verbosity >= 1 {
+		suite.Log.Event("INFO", "executed OnFailure", "test", failed.Name,
+			"seqno", failed.Reporting.SeqNo, "status", diag.Status.String())
+	}
+	suite.Diagnostics = append(suite.Diagnostics, diag)
+}
+
+// prepareOnFailure constructs the OnFailure test rt in the scope outer and
+// prepares it. Undefined variables are not reported as they might be
+// provided by the failing test.
+func prepareOnFailure(outer map[string]string, rt *RawTest) error {
+	scope := make(map[string]string, len(outer)+2)
+	for name, value := range outer {
+		scope[name] = value
+	}
+	scope["FAILED_TEST"] = ""
+	scope["FAILED_SEQNO"] = ""
+	testScope, err := rt.scope(scope)
+	if err != nil {
+		return err
+	}
+	test, err := rt.ToTest(testScope)
+	if err != nil {
+		return err
+	}
+	return test.Prepare()
+}
+
+// computeVariables evaluates the computed variables in the current suite
+// scope and freezes them. Variables from the global scope are not
+// recomputed.
+func (suite *Suite) computeVariables() {
+	replacer := varReplacer(suite.scope)
+	for name, value := range suite.computed {
+		if _, ok := suite.global[name]; ok {
+			continue
+		}
+		value = replacer.Replace(value)
+		if suite.Verbosity >= 2 {
+			suite.Log.Event("DEBUG", "computed variable", "name", name, "value", value)
+		}
+		suite.scope[name] = value
+		suite.frozen[name] = true
+	}
+}
+
 func (suite *Suite) updateVariables(test *ht.Test) {
 	if test.Status != ht.Pass {
 		return
 	}
 
 	for varname, value := range test.Extract() {
+		if suite.frozen[varname] {
+			if suite.Verbosity >= 2 {
+				suite.Log.Event("DEBUG", "ignoring variable", "name", varname,
+					"reason", "computed variables are frozen")
+			}
+			continue
+		}
 		if suite.Verbosity >= 2 {
+			msg := "setting variable"
 			if old, ok := suite.scope[varname]; ok {
 				if value != old {
-					suite.Log.Printf("Updating variable %q to %q\n",
-						varname, value)
+					msg = "updating variable"
 				} else {
-					suite.Log.Printf("Keeping  variable %q as %q\n",
-						varname, value)
+					msg = "keeping variable"
 				}
-			} else {
-				suite.Log.Printf("Setting  variable %q to %q\n",
-					varname, value)
 			}
+			suite.Log.Event("DEBUG", msg, "name", varname, "value", value)
 		}
 
 		suite.scope[varname] = value
 	}
 }
 
+// declareExtracted adds the names of the variables extracted by test to
+// extracted: Their values are known only once test has been executed, but
+// referencing them in later tests is fine. If test fails to extract a
+// variable, later tests referencing it are skipped instead of being Bogus.
+func declareExtracted(extracted map[string]bool, test *ht.Test) {
+	for varname := range test.VarEx {
+		extracted[varname] = true
+	}
+}
+
+// notExtractedError lists variables which are referenced by a test but
+// have not been extracted by the earlier tests which should have done so.
+type notExtractedError []string
+
+func (e notExtractedError) Error() string {
+	return "variables " + strings.Join(e, ", ") + " not extracted"
+}
+
 func (suite *Suite) updateStatus(test *ht.Test) {
 	if test.Status <= suite.Status {
 		return
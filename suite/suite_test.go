@@ -12,16 +12,17 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vdobler/ht/ht"
 )
 
-func logger() *log.Logger {
+func logger() ht.Logger {
 	if testing.Verbose() {
-		return log.New(os.Stdout, "", 0)
+		return ht.NewLogger(log.New(os.Stdout, "", 0))
 	}
 
-	return log.New(ioutil.Discard, "", 0)
+	return ht.NewLogger(log.New(ioutil.Discard, "", 0))
 }
 
 // Variables in outer scopes dominate those in inner scopes.
@@ -74,6 +75,185 @@ func TestVariableDominance(t *testing.T) {
 	}
 }
 
+func TestResolveVariables(t *testing.T) {
+	scope := map[string]string{"HOST": "global.example.org", "A": "global"}
+	vars := map[string]string{
+		"LOGIN":  "{{BASE}}/login",
+		"BASE":   "https://{{HOST}}{{PREFIX}}",
+		"PREFIX": "/api",
+		"A":      "suite",
+		"X":      "{{A}}-{{UNKNOWN}}",
+	}
+	if err := resolveVariables(scope, vars); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for name, want := range map[string]string{
+		"LOGIN": "https://global.example.org/api/login",
+		"BASE":  "https://global.example.org/api",
+		"A":     "global",
+		"X":     "global-{{UNKNOWN}}",
+	} {
+		if got := scope[name]; got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	scope = map[string]string{}
+	vars = map[string]string{"A": "{{B}}", "B": "x{{C}}", "C": "{{A}}", "D": "{{A}}"}
+	err := resolveVariables(scope, vars)
+	if err == nil || err.Error() != "variable cycle A -> B -> C -> A" {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestVariableCycleInSuite(t *testing.T) {
+	txt := `
+# cycle.suite
+{
+    Variables: { A: "{{B}}", B: "{{A}}" }
+    Main: [ {File: "a.ht"} ]
+}
+
+# a.ht
+{
+    Request: { URL: "http://localhost/{{A}}" }
+}`
+	rs, err := parseRawSuite("cycle.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.Validate(nil); err == nil {
+		t.Errorf("Missing error from Validate")
+	}
+	s := rs.Execute(nil, nil, logger())
+	if s.Status != ht.Bogus || len(s.Tests) != 0 {
+		t.Errorf("Got %s with %d tests: %v", s.Status, len(s.Tests), s.Error)
+	}
+}
+
+func TestVariableCycleInTest(t *testing.T) {
+	txt := `
+# cycle.suite
+{
+    Main: [ {File: "a.ht"}, {File: "b.ht", Variables: { X: "{{Y}}", Y: "{{X}}" }} ]
+}
+
+# a.ht
+{
+    Variables: { A: "{{B}}", B: "{{A}}" }
+    Request: { URL: "http://localhost/{{A}}" }
+}
+
+# b.ht
+{
+    Request: { URL: "http://localhost/{{X}}" }
+}`
+	rs, err := parseRawSuite("cycle.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err = rs.Validate(nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid test a.ht") ||
+		!strings.Contains(err.Error(), "invalid test b.ht") {
+		t.Errorf("Got Validate error %v", err)
+	}
+	s := rs.DryRun(nil, nil)
+	for i, test := range s.Tests {
+		if test.Status != ht.Bogus || !strings.Contains(test.Error.Error(), "variable cycle") {
+			t.Errorf("%d. got %s: %v", i, test.Status, test.Error)
+		}
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	txt := `
+# undefined.suite
+{
+    Variables: { HOST: "localhost" }
+    Main: [ {File: "login.ht"}, {File: "typo.ht"}, {File: "use.ht"}, {File: "body.ht"} ]
+}
+
+# login.ht
+{
+    Request: { URL: "http://{{HOST}}/login?t={{NOW}}" }
+    VarEx: {
+        SESSION: {Extractor: "CookieExtractor", Name: "JSESSIONID"}
+    }
+}
+
+# typo.ht
+{
+    Request: { URL: "http://{{HSOT}}/{{PATH}}/{{HSOT}}" }
+}
+
+# use.ht
+{
+    Description: "Show the account of {{.User}}"
+    Request: {
+        URL: "http://{{HOST}}/account"
+        Header: { "X-Session": "{{SESSION}}" }
+        Body: "Hello {{HOST}}"
+    }
+}
+
+# body.ht
+{
+    Description: "Update {{user}}"
+    Request: {
+        Method: "POST"
+        URL: "http://{{HOST}}/account"
+        Body: "{\"id\": \"{{MISSING}}\"}"
+        ParamsAs: "multipart"
+        Params: {
+            data: "@vfile:{{VFILE}}"
+            inline: "@vfile:@inline.txt:{{INLINE}}"
+        }
+    }
+}`
+	vfile, err := ioutil.TempFile("", "vfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(vfile.Name())
+	vfile.WriteString("in file {{FROMFILE}} and {{HOST}}")
+	vfile.Close()
+	txt = strings.Replace(txt, `Variables: { HOST: "localhost" }`,
+		`Variables: { HOST: "localhost", VFILE: "`+vfile.Name()+`" }`, 1)
+
+	rs, err := parseRawSuite("undefined.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err = rs.Validate(nil)
+	if err == nil || !strings.Contains(err.Error(), "undefined variables HSOT, PATH") ||
+		!strings.Contains(err.Error(), "undefined variables FROMFILE, INLINE, MISSING, user") ||
+		strings.Contains(err.Error(), "SESSION") || strings.Contains(err.Error(), "use.ht") {
+		t.Errorf("Got Validate error %v", err)
+	}
+
+	s := rs.DryRun(nil, nil)
+	want := []ht.Status{ht.NotRun, ht.Bogus, ht.NotRun, ht.Bogus}
+	for i, test := range s.Tests {
+		if test.Status != want[i] {
+			t.Errorf("%d. %s: got %s, want %s: %v", i, test.Name,
+				test.Status, want[i], test.Error)
+		}
+	}
+
+	// SESSION is not extracted as login.ht fails: use.ht is skipped
+	// instead of sending "{{SESSION}}".
+	s = rs.Execute(map[string]string{"HOST": "localhost:1"}, nil, logger())
+	if len(s.Tests) != 4 {
+		t.Fatalf("Got %d tests, want 4", len(s.Tests))
+	}
+	if got := s.Tests[2].Status; got != ht.Skipped {
+		t.Errorf("use.ht: got %s, want Skipped: %v", got, s.Tests[2].Error)
+	}
+	if _, ok := s.FinalVariables["SESSION"]; ok {
+		t.Errorf("Unextracted SESSION in final variables")
+	}
+}
+
 // Variables are handed down from scope to scope. Replacement works.
 func TestVariableHanddown(t *testing.T) {
 	txt := `
@@ -246,6 +426,193 @@ func TestVariableExtraction(t *testing.T) {
 	}
 }
 
+// Computed variables are evaluated after the setup tests and are frozen.
+func TestComputedVariables(t *testing.T) {
+	txt := `
+# computed.suite
+{
+    Name: Testsuite for computed variables
+    Variables: {
+        TOKEN: "initial"
+    }
+    ComputedVariables: {
+        AUTH: "Bearer {{TOKEN}}"
+        FIXED: "computed"
+    }
+    Setup: [
+        { File: "login.ht" }
+    ]
+    Main: [
+        { File: "test.ht" }
+        { File: "test.ht" }
+    ]
+}
+
+# login.ht
+{
+    Name: Login
+    Request: { URL: "file:///etc/passwd" }
+    VarEx: {
+        TOKEN: {Extractor: "SetVariable", To: "secret" }
+    }
+}
+
+# test.ht
+{
+    Name: Use computed variable
+    Request: { URL: "file:///etc/passwd" }
+    Variables: {
+        Auth: "{{AUTH}}"
+    }
+    VarEx: {
+        AUTH: {Extractor: "SetVariable", To: "overwritten" }
+    }
+}`
+
+	rs, err := parseRawSuite("computed.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"FIXED": "global"}, nil, logger())
+
+	for i := 1; i <= 2; i++ {
+		if got := s.Tests[i].Variables["Auth"]; got != "Bearer secret" {
+			s.PrintReport(os.Stdout)
+			t.Errorf("Test %d: got Auth=%q, want \"Bearer secret\"", i, got)
+		}
+	}
+	if s.FinalVariables["AUTH"] != "Bearer secret" ||
+		s.FinalVariables["FIXED"] != "global" {
+		t.Errorf("Bad computed variables. Got %v", s.FinalVariables)
+	}
+}
+
+// Tests with a RandomSeed get reproducible random values.
+func TestRandomSeed(t *testing.T) {
+	txt := `
+# seed.suite
+{
+    Name: Testsuite for seeded random values
+    Main: [
+        { File: "seeded.ht" }
+        { File: "unseeded.ht" }
+        { File: "seeded.ht" }
+    ]
+}
+
+# seeded.ht
+{
+    Name: "Seeded {{RANDOM NUMBER 1000000}}"
+    Description: "{{RANDOM UUID}}"
+    Request: { URL: "file:///etc/passwd" }
+    RandomSeed: 123
+}
+
+# unseeded.ht
+{
+    Name: "Unseeded {{RANDOM NUMBER 1000000}}"
+    Request: { URL: "file:///etc/passwd" }
+}`
+
+	rs, err := parseRawSuite("seed.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(nil, nil, logger())
+
+	first, second := s.Tests[0], s.Tests[2]
+	if first.Name != second.Name || first.Description != second.Description {
+		t.Errorf("Got %q/%q and %q/%q", first.Name, first.Description,
+			second.Name, second.Description)
+	}
+	if strings.Contains(first.Name, "RANDOM") ||
+		strings.Contains(s.Tests[1].Name, "RANDOM") {
+		t.Errorf("RANDOM not substituted: %q, %q", first.Name, s.Tests[1].Name)
+	}
+	if first.RandomSeed != 123 {
+		t.Errorf("Got RandomSeed=%d", first.RandomSeed)
+	}
+}
+
+// The special {{NOW ...}} variable is substituted.
+func TestNowVariable(t *testing.T) {
+	txt := `
+# now.suite
+{
+    Name: Testsuite for NOW
+    Main: [ { File: "now.ht" } ]
+}
+
+# now.ht
+{
+    Name: "Year {{NOW + 1w | \"2006\"}}"
+    Request: { URL: "file:///etc/passwd" }
+}`
+
+	rs, err := parseRawSuite("now.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(nil, nil, logger())
+
+	want := "Year " + time.Now().Add(7*24*time.Hour).Format("2006")
+	if got := s.Tests[0].Name; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+}
+
+// BeforeEach and AfterEach hooks are called around each test.
+func TestBeforeAfterEachHooks(t *testing.T) {
+	txt := `
+# hooks.suite
+{
+    Name: Testsuite for hooks
+    Main: [
+        { File: "a.ht" }
+        { File: "b.ht" }
+    ]
+}
+
+# a.ht
+{
+    Name: "A"
+    Request: { URL: "file:///etc/passwd" }
+}
+
+# b.ht
+{
+    Name: "B"
+    Request: { URL: "file:///etc/passwd" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`
+
+	rs, err := parseRawSuite("hooks.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	before, after := []string{}, []string{}
+	rs.BeforeEach = func(test *ht.Test) {
+		before = append(before, test.Name)
+		if test.Name == "B" {
+			test.Request.URL = "file:///nonexisting/file"
+		}
+	}
+	rs.AfterEach = func(test *ht.Test, err error) {
+		after = append(after, fmt.Sprintf("%s=%t", test.Name, err != nil))
+	}
+	s := rs.Execute(nil, nil, logger())
+
+	if got := strings.Join(before, " "); got != "A B" {
+		t.Errorf("BeforeEach got %q", got)
+	}
+	if got := strings.Join(after, " "); got != "A=false B=true" {
+		t.Errorf("AfterEach got %q", got)
+	}
+	if s.Tests[1].Status == ht.Pass {
+		t.Errorf("Modification in BeforeEach had no effect")
+	}
+}
+
 func matchVars(got map[string]string, want string) string {
 	for _, elem := range strings.Split(want, " ") {
 		p := strings.Split(elem, "=")
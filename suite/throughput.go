@@ -24,6 +24,10 @@
 // target server).
 // Checks can be turned off on per scenario basis.
 //
+// Instead of executing all Main tests in order a scenario may sample its
+// Main tests randomly according to Weights to produce a realistic traffic
+// mix (e.g. 70% browse, 20% search and 10% checkout requests).
+//
 // If all tests (and thus request) in a suite/scenario have been
 // executed, the suite is repeated. To reach the desired request througput
 // rate each scenario is run in multiple parallel threads. New threads are
@@ -71,12 +75,20 @@ type Scenario struct {
 	// is made but no checks are performed on the response.
 	OmitChecks bool
 
+	// Weights maps the File of Main tests of the suite to their weight.
+	// If set, each repetition of the scenario executes just one Main
+	// test drawn randomly with a probability proportional to its weight
+	// instead of executing all Main tests in order. Main tests sharing
+	// the same File form a group which shares the weight. Main tests
+	// not listed are not executed.
+	Weights map[string]int
+
 	globals map[string]string
 	jar     *cookiejar.Jar
 }
 
 // setup runs the Setup tests of sc.
-func (sc *Scenario) setup(logger *log.Logger) *Suite {
+func (sc *Scenario) setup(logger ht.Logger) *Suite {
 	suite := NewFromRaw(sc.RawSuite, sc.globals, sc.jar, logger)
 	// Cap tests to setup-tests.
 	suite.tests = suite.tests[:len(sc.RawSuite.Setup)]
@@ -104,8 +116,52 @@ func (sc *Scenario) setup(logger *log.Logger) *Suite {
 	return suite
 }
 
+// mainWeights returns the cumulated weights of the Main tests of sc or
+// nil if sc uses no weights.
+func (sc *Scenario) mainWeights() ([]int, error) {
+	if len(sc.Weights) == 0 {
+		return nil, nil
+	}
+
+	groupSize := make(map[string]int)
+	for _, elem := range sc.RawSuite.Main {
+		groupSize[elem.File]++
+	}
+	for file, w := range sc.Weights {
+		if groupSize[file] == 0 {
+			return nil, fmt.Errorf("weight for unknown Main test %q", file)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("negative weight %d for %q", w, file)
+		}
+	}
+
+	// Group members share the weight: Scale all weights by the product
+	// of the group sizes to keep them integral.
+	scale := 1
+	for _, n := range groupSize {
+		scale *= n
+	}
+	cumulated := make([]int, len(sc.RawSuite.Main))
+	sum := 0
+	for i, elem := range sc.RawSuite.Main {
+		sum += sc.Weights[elem.File] * scale / groupSize[elem.File]
+		cumulated[i] = sum
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("sum of weights is zero")
+	}
+	return cumulated, nil
+}
+
+// sampleMain draws the index of a Main test from the cumulated weights.
+func sampleMain(cumulated []int) int {
+	r := ht.RandomIntn(cumulated[len(cumulated)-1])
+	return sort.Search(len(cumulated), func(i int) bool { return cumulated[i] > r })
+}
+
 // teardown runs the Teardown tests of sc.
-func (sc *Scenario) teardown(logger *log.Logger) *Suite {
+func (sc *Scenario) teardown(logger ht.Logger) *Suite {
 	suite := NewFromRaw(sc.RawSuite, sc.globals, sc.jar, logger)
 	// Cap tests to setup-tests.
 	suite.tests = suite.tests[len(suite.tests)-len(sc.RawSuite.Teardown):]
@@ -130,15 +186,71 @@ func (sc *Scenario) teardown(logger *log.Logger) *Suite {
 	return suite
 }
 
+// Pacing describes the think time a thread (a virtual user) pauses
+// between two repetitions of its scenario.
+type Pacing struct {
+	// Distribution of the pauses:
+	//   "constant"   : always pause Mean
+	//   "uniform"    : uniformly distributed in [0, 2*Mean]
+	//   "exponential": exponentially distributed with mean Mean
+	// An empty Distribution or a zero Mean disables pausing.
+	Distribution string
+
+	// Mean duration of the pauses.
+	Mean time.Duration
+}
+
+// ParsePacing parses pacings of the form "<distribution>:<mean>",
+// e.g. "uniform:2s". The empty string results in no pacing.
+func ParsePacing(s string) (Pacing, error) {
+	if s == "" {
+		return Pacing{}, nil
+	}
+	i := strings.Index(s, ":")
+	if i == -1 {
+		return Pacing{}, fmt.Errorf("pacing %q not of the form <distribution>:<mean>", s)
+	}
+	p := Pacing{Distribution: s[:i]}
+	switch p.Distribution {
+	case "constant", "uniform", "exponential":
+	default:
+		return Pacing{}, fmt.Errorf("unknown pacing distribution %q", p.Distribution)
+	}
+	mean, err := time.ParseDuration(s[i+1:])
+	if err != nil {
+		return Pacing{}, err
+	}
+	if mean < 0 {
+		return Pacing{}, fmt.Errorf("negative pacing %s", mean)
+	}
+	p.Mean = mean
+	return p, nil
+}
+
+// Pause returns a random pause drawn from p's distribution.
+func (p Pacing) Pause() time.Duration {
+	switch p.Distribution {
+	case "constant":
+		return p.Mean
+	case "uniform":
+		return time.Duration(2 * rand.Float64() * float64(p.Mean))
+	case "exponential":
+		return time.Duration(rand.ExpFloat64() * float64(p.Mean))
+	}
+	return 0
+}
+
 // A pool is kinda thread pool for the given scenario.
 type pool struct {
 	Scenario
-	No      int // Sequence number of the scenario
-	Chan    chan bender.Test
-	wg      *sync.WaitGroup
-	mu      *sync.Mutex
-	Threads int
-	Misses  int
+	Pacing    Pacing
+	Cumulated []int // cumulated weights of the Main tests, nil if unweighted
+	No        int   // Sequence number of the scenario
+	Chan      chan bender.Test
+	wg        *sync.WaitGroup
+	mu        *sync.Mutex
+	Threads   int
+	Misses    int
 }
 
 // IDSep is the separator string used in constructing IDs for the individual
@@ -150,7 +262,7 @@ var IDSep = "\u2237"
 
 // newThread starts a new thread/goroutine which iterates tests in the pool's
 // scenario.
-func (p *pool) newThread(stop chan bool, logger *log.Logger) {
+func (p *pool) newThread(stop chan bool, logger ht.Logger) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.MaxThreads > 0 && p.Threads >= p.MaxThreads {
@@ -220,6 +332,11 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 
 			nSetup, nMain := len(p.Scenario.RawSuite.Setup), len(p.Scenario.RawSuite.Main)
 			suite.tests = suite.tests[nSetup : nSetup+nMain]
+			if p.Cumulated != nil {
+				// Execute just one randomly drawn test.
+				t = sampleMain(p.Cumulated)
+				suite.tests = suite.tests[t : t+1]
+			}
 			suite.Iterate(executor)
 			if p.Scenario.Verbosity >= 2 {
 				logger.Printf("Scenario %d %q: Finished repetition %d of thread %d: %s\n",
@@ -227,6 +344,14 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 			}
 
 			repetition += 1
+
+			if pause := p.Pacing.Pause(); pause > 0 {
+				select {
+				case <-stop:
+					done = true
+				case <-time.After(pause):
+				}
+			}
 		}
 		if p.Scenario.Verbosity >= 1 {
 			logger.Printf("Scenario %d %q: Done with thread %d",
@@ -241,7 +366,7 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 // The request are drawn randoemly from the given scenarios (while each suite
 // the scenario consists of executes linearely on each thread).
 // The thread pool of the scenarios is returned for cleanup purpose.
-func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test, stop chan bool, logger *log.Logger) ([]*pool, error) {
+func makeRequest(scenarios []Scenario, rate float64, pacing Pacing, requests chan bender.Test, stop chan bool, logger ht.Logger) ([]*pool, error) {
 	// Choosing a scenario to contribute to the total set of request is done
 	// by looking up a (thread) pool with the desired probability: Pool indices
 	// are distributed in 100 selectors.
@@ -251,12 +376,18 @@ func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test,
 	pools := make([]*pool, len(scenarios))
 	cummulatedPercentage := 0
 	for i, s := range scenarios {
+		weights, err := s.mainWeights()
+		if err != nil {
+			return nil, fmt.Errorf("suite: scenario %d %q: %s", i+1, s.Name, err)
+		}
 		pool := pool{
-			Scenario: s,
-			No:       i,
-			Chan:     make(chan bender.Test, 2),
-			wg:       &sync.WaitGroup{},
-			mu:       &sync.Mutex{},
+			Scenario:  s,
+			Pacing:    pacing,
+			Cumulated: weights,
+			No:        i,
+			Chan:      make(chan bender.Test, 2),
+			wg:        &sync.WaitGroup{},
+			mu:        &sync.Mutex{},
 		}
 		pools[i] = &pool
 		pools[i].newThread(stop, logger)
@@ -284,7 +415,7 @@ func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test,
 			var test bender.Test
 			select {
 			case <-stop:
-				logger.Println("Request generation stopped.")
+				logger.Event("INFO", "request generation stopped")
 				return
 			case test = <-pool.Chan:
 				counter++
@@ -301,7 +432,7 @@ func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test,
 		pools[i].newThread(stop, logger)
 	}
 
-	logger.Println("Request generation started.")
+	logger.Event("INFO", "request generation started")
 	return pools, nil
 }
 
@@ -326,6 +457,10 @@ type ThroughputOptions struct {
 	// CollectFrom limit collection of tests to those test with a
 	// status equal or bader.
 	CollectFrom ht.Status
+
+	// Pacing is the think time each thread pauses after a repetition
+	// of its scenario to mimic real users.
+	Pacing Pacing
 }
 
 // Throughput runs a throughput load test with request taken from the given
@@ -360,7 +495,7 @@ type ThroughputOptions struct {
 // distribution between scenarios.
 func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer) ([]TestData, *Suite, error) {
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 1)
-	logger := log.New(bufferedStdout, "", 256)
+	logger := ht.NewLogger(log.New(bufferedStdout, "", 256))
 
 	// Make sure all request come from some scenario.
 	sum := 0
@@ -370,6 +505,11 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 	if sum != 100 {
 		return nil, nil, fmt.Errorf("Sum of Percentage = %d%% (must be 100)", sum)
 	}
+	for i := range scenarios {
+		if _, err := scenarios[i].mainWeights(); err != nil {
+			return nil, nil, fmt.Errorf("Scenario %d %q: %s", i+1, scenarios[i].Name, err)
+		}
+	}
 
 	// Execute Teardown code on any case.
 	defer func() {
@@ -420,7 +560,7 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 		intervals = bender.RampedExponentialIntervalGenerator(opts.Rate, opts.Ramp)
 	}
 
-	pools, err := makeRequest(scenarios, opts.Rate, request, stop, logger)
+	pools, err := makeRequest(scenarios, opts.Rate, opts.Pacing, request, stop, logger)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -448,7 +588,7 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 		time.Sleep(1 * time.Second)
 	}
 	close(stop)
-	logger.Println("Finished Throughput test.")
+	logger.Event("INFO", "finished throughput test")
 	for _, p := range pools {
 		p.mu.Lock()
 		logger.Printf("Scenario %d %q: Draining pool with %d threads\n",
@@ -523,6 +663,11 @@ func analyseOutcome(data []TestData, pools []*pool) error {
 		errors = append(errors, derr...)
 	}
 
+	derr = analyseMix(data, pools)
+	if derr != nil {
+		errors = append(errors, derr...)
+	}
+
 	if len(errors) > 0 {
 		return errors
 	}
@@ -614,6 +759,71 @@ func analyseDistribution(data []TestData, pools []*pool) ht.ErrorList {
 	return nil
 }
 
+// analyseMix reports the achieved mix of Main tests of the weighted
+// scenarios and compares it to the target mix given by the weights.
+func analyseMix(data []TestData, pools []*pool) ht.ErrorList {
+	errors := ht.ErrorList{}
+
+	// Count requests per scenario and test.
+	cnt := make(map[int]map[int]int)
+	for _, d := range data {
+		parts := strings.SplitN(d.ID, IDSep, 3)
+		nums := strings.Split(parts[0], "/")
+		if len(nums) < 4 {
+			continue
+		}
+		sn, _ := strconv.Atoi(nums[0])
+		tn, _ := strconv.Atoi(nums[3])
+		if cnt[sn-1] == nil {
+			cnt[sn-1] = make(map[int]int)
+		}
+		cnt[sn-1][tn-1]++
+	}
+
+	for i, p := range pools {
+		if p.Cumulated == nil {
+			continue
+		}
+		total := 0
+		for _, n := range cnt[i] {
+			total += n
+		}
+		if total == 0 {
+			continue
+		}
+
+		// Aggregate tests and weights per group.
+		files := []string{}
+		actual := make(map[string]int)
+		for t, elem := range p.Scenario.RawSuite.Main {
+			if _, seen := actual[elem.File]; !seen {
+				files = append(files, elem.File)
+			}
+			actual[elem.File] += cnt[i][t]
+		}
+		wsum := 0
+		for _, file := range files {
+			wsum += p.Scenario.Weights[file]
+		}
+		for _, file := range files {
+			got := 100 * float64(actual[file]) / float64(total)
+			want := 100 * float64(p.Scenario.Weights[file]) / float64(wsum)
+			fmt.Printf("Scenario %d %q: %s: %d requests = %.1f%% (target %.1f%%)\n",
+				i+1, p.Scenario.Name, file, actual[file], got, want)
+			if got < want-5 || got > want+5 {
+				errors = append(errors,
+					fmt.Errorf("test %s of scenario %d %q contributed %.1f%% (want %.1f%%)",
+						file, i+1, p.Scenario.Name, got, want))
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
 func analyseOverage(data []TestData) error {
 	N := len(data)
 	s := time.Duration(0)
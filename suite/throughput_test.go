@@ -363,7 +363,10 @@ func TestThroughput2(t *testing.T) {
 		"HOST": ts.URL,
 	}
 
-	scenarios := raw.ToScenario(global)
+	scenarios, err := raw.ToScenario(global)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
 	for i, scen := range scenarios {
 		fmt.Printf("%d. %d%% %q (max %d threads)\n",
 			i+1, scen.Percentage, scen.Name, scen.MaxThreads)
@@ -450,3 +453,90 @@ func TestStatusRing(t *testing.T) {
 	}
 
 }
+
+// ----------------------------------------------------------------------------
+// Pacing
+
+func TestParsePacing(t *testing.T) {
+	for i, tc := range []struct {
+		s    string
+		want Pacing
+		err  bool
+	}{
+		{"", Pacing{}, false},
+		{"constant:2s", Pacing{"constant", 2 * time.Second}, false},
+		{"uniform:500ms", Pacing{"uniform", 500 * time.Millisecond}, false},
+		{"exponential:1m", Pacing{"exponential", time.Minute}, false},
+		{"gauss:1s", Pacing{}, true},
+		{"uniform", Pacing{}, true},
+		{"uniform:-1s", Pacing{}, true},
+	} {
+		got, err := ParsePacing(tc.s)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("%d. %q: got %v, %v", i, tc.s, got, err)
+		}
+	}
+}
+
+func TestPacingPause(t *testing.T) {
+	if p := (Pacing{}).Pause(); p != 0 {
+		t.Errorf("no pacing: got %s", p)
+	}
+	if p := (Pacing{"constant", time.Second}).Pause(); p != time.Second {
+		t.Errorf("constant: got %s", p)
+	}
+	for _, dist := range []string{"uniform", "exponential"} {
+		pacing := Pacing{dist, time.Second}
+		sum := time.Duration(0)
+		for i := 0; i < 2000; i++ {
+			p := pacing.Pause()
+			if p < 0 || (dist == "uniform" && p > 2*time.Second) {
+				t.Fatalf("%s: got pause %s", dist, p)
+			}
+			sum += p
+		}
+		if mean := sum / 2000; mean < 900*time.Millisecond || mean > 1100*time.Millisecond {
+			t.Errorf("%s: got mean %s", dist, mean)
+		}
+	}
+}
+
+func TestMainWeights(t *testing.T) {
+	rs := &RawSuite{
+		Main: []RawElement{
+			{File: "browse.ht"}, {File: "search.ht"},
+			{File: "browse.ht"}, {File: "checkout.ht"},
+		},
+	}
+	sc := Scenario{RawSuite: rs,
+		Weights: map[string]int{"browse.ht": 70, "search.ht": 20, "checkout.ht": 10},
+	}
+	cumulated, err := sc.mainWeights()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	// Scale is 2 as browse.ht is used twice.
+	if want := []int{70, 110, 180, 200}; fmt.Sprint(cumulated) != fmt.Sprint(want) {
+		t.Errorf("Got %v, want %v", cumulated, want)
+	}
+
+	cnt := make([]int, len(rs.Main))
+	for i := 0; i < 10000; i++ {
+		cnt[sampleMain(cumulated)]++
+	}
+	if browse := cnt[0] + cnt[2]; browse < 6700 || browse > 7300 {
+		t.Errorf("Got %d browse samples, want about 7000", browse)
+	}
+	if cnt[3] < 800 || cnt[3] > 1200 {
+		t.Errorf("Got %d checkout samples, want about 1000", cnt[3])
+	}
+
+	sc.Weights = map[string]int{"login.ht": 10}
+	if _, err := sc.mainWeights(); err == nil {
+		t.Errorf("Missing error for unknown test")
+	}
+	sc.Weights = map[string]int{"browse.ht": 0}
+	if _, err := sc.mainWeights(); err == nil {
+		t.Errorf("Missing error for zero weights")
+	}
+}